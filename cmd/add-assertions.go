@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/seanhuebl/sqlc-qol/v2/internal/addassertions"
+	"github.com/spf13/cobra"
+)
+
+var (
+	assertModelsPath string
+	assertInterface  string
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "add-assertions",
+		Short: "Add compile-time interface assertions for moved model types",
+		Long: `Type-checks your models package and appends var _ Iface = (*Model)(nil) assertions to
+the models file for every struct in it that implements the given interface. Models that
+don't implement it are skipped and listed; existing assertions are never duplicated.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return addassertions.Run(assertModelsPath, assertInterface)
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&assertModelsPath,
+			"models",
+			"m",
+			"",
+			"path to the Go source file defining your models (e.g. internal/models/models.go)")
+	_ = cmd.MarkFlagRequired("models")
+
+	cmd.Flags().
+		StringVar(&assertInterface,
+			"interface",
+			"",
+			"interface to assert: a name declared in the models package (Validator) or import path and name (fmt.Stringer)")
+	_ = cmd.MarkFlagRequired("interface")
+
+	rootCmd.AddCommand(cmd)
+}
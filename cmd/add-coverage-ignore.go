@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"github.com/seanhuebl/sqlc-qol/v2/internal/addcoverageignore"
+	"github.com/spf13/cobra"
+)
+
+var (
+	coverageDir       string
+	coverageDirective string
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "add-coverage-ignore",
+		Short: "Mark SQLC generated files as ignored by coverage tooling",
+		Long: `Walks every .go file under a directory and inserts a coverage-ignore directive comment
+(e.g. // coverage:ignore file) right below the "// Code generated ... DO NOT EDIT." header
+of each generated file. Hand-written files and files that already carry the directive are
+left untouched.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return addcoverageignore.Run(coverageDir, coverageDirective)
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&coverageDir,
+			"dir",
+			"d",
+			"",
+			"root directory where your database files live (e.g. internal/database)")
+	_ = cmd.MarkFlagRequired("dir")
+
+	cmd.Flags().
+		StringVar(&coverageDirective,
+			"directive",
+			"// coverage:ignore file",
+			"directive comment your coverage tool honors")
+
+	rootCmd.AddCommand(cmd)
+}
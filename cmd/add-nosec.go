@@ -1,13 +1,50 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+
 	"github.com/seanhuebl/sqlc-qol/v2/internal/addnosec"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pending"
 	"github.com/spf13/cobra"
 )
 
 var (
 	addTargets string
 	addCSV     string
+	addCSVDir  string
+	addHeader  bool
+	addColumn  string
+	addBase    string
+	addType    string
+	addKinds   []string
+	addFold    bool
+	addShow    bool
+	addPrint   bool
+	addNorm    bool
+	addRespect []string
+	addTrail   string
+	addGroup   string
+	addDirect  bool
+	addNewline bool
+	addMaxLen  int
+	addTabs    int
+	addGosec   string
+	addGenOnly bool
+	addRules   []string
+	addReasons string
+	addNeedWhy bool
+	addDryRun  bool
+	addEmitPos bool
+	addChmod   bool
+	addExec    string
+	addStrict  bool
+	addSize    bool
+	addReform  bool
+
+	addIncremental      bool
+	addStateFile        string
+	addResetIncremental bool
 )
 
 func init() {
@@ -16,11 +53,67 @@ func init() {
 		Use:   "add-nosec",
 		Short: "Add gosec // #nosec comments to SQLC generated code for targeted consts",
 		Long: `Scans Go source files matching a glob pattern for targeted consts that are flagged by gosec as hardcoded credentials.
-It adds a // #nosec comment to the const declaration to ignore the gosec warning.`,
-		Args: cobra.ExactArgs(1), // Expecting a single argument: the glob pattern
+It adds a // #nosec comment to the const declaration to ignore the gosec warning.
+The glob pattern and targets can also be set in the --config file (add_nosec.glob,
+add_nosec.targets, add_nosec.csv); the argument and flags override it.`,
+		Args: cobra.MaximumNArgs(1), // Expecting at most the glob pattern
 		RunE: func(cmd *cobra.Command, args []string) error {
-			globPattern := args[0]
-			return addnosec.Run(globPattern, addTargets, addCSV, cfg)
+			globPattern := cfg.AddNosec.Glob
+			if len(args) > 0 {
+				globPattern = args[0]
+			}
+			if globPattern == "" {
+				return fmt.Errorf("requires the glob pattern argument (or add_nosec.glob in %s)", configPath)
+			}
+			targets, csvPath := addTargets, addCSV
+			if addTargetsFromConfig(cmd) {
+				targets, csvPath = cfg.AddNosec.Targets, cfg.AddNosec.CSV
+			}
+			res, err := addnosec.RunWithResult(globPattern, targets, csvPath, cfg, addnosec.Options{
+				CSVDir:                 addCSVDir,
+				CSVHasHeader:           addHeader,
+				CSVColumn:              addColumn,
+				GlobBase:               addBase,
+				ConstType:              addType,
+				DeclKinds:              addKinds,
+				CaseInsensitive:        addFold,
+				ShowChanges:            addShow,
+				PrintTargets:           addPrint,
+				NormalizeNosec:         addNorm,
+				Rules:                  addRules,
+				Reasons:                addReasons,
+				RequireReason:          addNeedWhy,
+				RespectDirectives:      addRespect,
+				TrailingComment:        addTrail,
+				MaxLineLength:          addMaxLen,
+				TabWidth:               addTabs,
+				GroupMode:              addGroup,
+				TargetedEdit:           addDirect,
+				NormalizeFinalNewline:  addNewline,
+				FailOnUnsuppressed:     addGosec,
+				RequireGeneratedHeader: addGenOnly,
+				Check:                  addDryRun || addEmitPos,
+				Diff:                   addDryRun,
+				EmitPositions:          addEmitPos,
+				Chmod:                  addChmod,
+				Exec:                   addExec,
+				Strict:                 addStrict,
+				SizeReport:             addSize,
+				WarnOnReformat:         addReform,
+				AuditLog:               auditLogPath,
+				AuditTruncate:          auditTruncate,
+				ZipOut:                 zipOut,
+				ChangedOnly:            changedOnly,
+				Incremental:            addIncremental,
+				StateFile:              addStateFile,
+				ResetIncremental:       addResetIncremental,
+			})
+			// --emit-positions output is meant for tools, keep it to positions
+			var pendingErr *pending.Error
+			if !addEmitPos && (err == nil || errors.As(err, &pendingErr)) {
+				fmt.Print(res.Summary())
+			}
+			return err
 		},
 	}
 
@@ -28,17 +121,226 @@ It adds a // #nosec comment to the const declaration to ignore the gosec warning
 		StringVarP(&addTargets,
 			"targets", "t",
 			"",
-			"comma-separated list of target consts to add gosec ignore comments for")
+			"comma-separated list of target consts to add gosec ignore comments for, or - to read them from stdin")
 
 	cmd.Flags().
 		StringVarP(&addCSV,
 			"csv",
 			"c",
 			"",
-			"path to CSV file containing target consts (no headers)")
+			"path to CSV file containing target consts (no headers), or - to read it from stdin")
+
+	cmd.Flags().
+		StringVar(&addCSVDir,
+			"csv-dir",
+			"",
+			"path to a directory of CSV files (no headers) whose targets are merged")
+
+	cmd.Flags().
+		BoolVar(&addHeader,
+			"csv-has-header",
+			false,
+			"skip the first record of the targets CSV files as a header")
+
+	cmd.Flags().
+		StringVar(&addColumn,
+			"csv-column",
+			"",
+			"read target names from this CSV column only, by header name (with --csv-has-header) or 1-based index")
+
+	cmd.Flags().
+		StringVar(&addBase,
+			"glob-base",
+			"",
+			"directory the glob pattern is resolved against; reported paths are relative to it")
+
+	cmd.Flags().
+		StringVar(&addType,
+			"const-type",
+			"",
+			"only tag targets explicitly declared with this type (e.g. APIKey)")
+
+	cmd.Flags().
+		StringSliceVar(&addKinds,
+			"kinds",
+			addnosec.DefaultDeclKinds,
+			"comma-separated declaration kinds targets are matched against: const, var and field (composite literal keys)")
+
+	cmd.Flags().
+		BoolVar(&addFold,
+			"case-insensitive",
+			false,
+			"match target names regardless of case (may over-match)")
+
+	cmd.Flags().
+		BoolVar(&addShow,
+			"show-changes",
+			false,
+			"print a file:line listing of every spec tagged and the total count")
+
+	cmd.Flags().
+		BoolVar(&addPrint,
+			"print-targets",
+			false,
+			"print the resolved target names, sorted, before processing")
+
+	cmd.Flags().
+		StringSliceVar(&addRespect,
+			"respect-directives",
+			[]string{"nolint:gosec"},
+			"linter directives (name[:linter]) that already suppress gosec; targets carrying one are skipped")
+
+	cmd.Flags().
+		BoolVar(&addNorm,
+			"normalize-nosec-spacing",
+			false,
+			"rewrite existing #nosec comments on targets to the canonical '// #nosec' spacing")
+
+	cmd.Flags().
+		StringSliceVar(&addRules,
+			"rule",
+			nil,
+			"comma-separated gosec rule IDs the comments suppress (// #nosec G101 G404); bare #nosec comments on targets are upgraded")
+
+	cmd.Flags().
+		StringVar(&addReasons,
+			"reasons",
+			"",
+			"path to a YAML file mapping target names to the reason appended to their comment (// #nosec -- reason)")
+	_ = cmd.MarkFlagFilename("reasons", "yaml", "yml", "json")
+
+	cmd.Flags().
+		BoolVar(&addNeedWhy,
+			"require-reason",
+			false,
+			"fail before processing if any target's comment would carry no reason")
+
+	cmd.Flags().
+		StringVar(&addTrail,
+			"trailing-comment",
+			addnosec.TrailingAbove,
+			"where to put // #nosec on a target that already has a trailing comment: above or append")
+
+	cmd.Flags().
+		StringVar(&addGroup,
+			"group-mode",
+			addnosec.GroupPerSpec,
+			"how matched specs of a const ( ... ) block are tagged: per-spec, shared or auto")
+
+	cmd.Flags().
+		IntVar(&addMaxLen,
+			"max-line-length",
+			0,
+			"column limit; a // #nosec that would push a line past it goes on the line above instead (0 disables)")
+
+	cmd.Flags().
+		IntVar(&addTabs,
+			"tab-width",
+			addnosec.DefaultTabWidth,
+			"number of columns a tab counts for in --max-line-length")
+
+	cmd.Flags().
+		BoolVar(&addDirect,
+			"targeted-edit",
+			false,
+			"insert comments into the source bytes without reformatting unrelated code")
+
+	cmd.Flags().
+		BoolVar(&addNewline,
+			"normalize-final-newline",
+			false,
+			"make files written by --targeted-edit end with exactly one newline")
+
+	cmd.Flags().
+		StringVar(&addGosec,
+			"fail-on-unsuppressed",
+			"",
+			"path to a gosec JSON report; fail if any finding in the matched files still lacks a #nosec comment")
+
+	cmd.Flags().
+		BoolVar(&addGenOnly,
+			"require-generated-header",
+			false,
+			"refuse to tag targets in files without a '// Code generated ... DO NOT EDIT.' header and list them")
+
+	cmd.Flags().
+		BoolVar(&addDryRun,
+			"dry-run",
+			false,
+			"print a unified diff of the files that would change without writing them; exits 2 if any would")
+
+	cmd.Flags().
+		BoolVar(&addEmitPos,
+			"emit-positions",
+			false,
+			"dry run printing a file:line:col: add-nosec <name> line for every spec that would be tagged")
+
+	cmd.Flags().
+		BoolVar(&addChmod,
+			"chmod",
+			false,
+			"make read-only matched files writable for their owner instead of failing before any edit")
+
+	cmd.Flags().
+		StringVar(&addExec,
+			"exec",
+			"",
+			"external command each matched file is piped through (stdin to stdout) after the built-in transform")
+
+	cmd.Flags().
+		BoolVar(&addStrict,
+			"strict",
+			false,
+			"fail instead of warning when a matched file changed on disk between being read and written, or a target matched nothing")
+
+	cmd.Flags().
+		BoolVar(&addSize,
+			"size-report",
+			false,
+			"print the lines and bytes added and removed across the modified matched files at the end of the run")
+
+	cmd.Flags().
+		BoolVar(&addReform,
+			"warn-on-reformat",
+			false,
+			"warn about matched files that would change only by gofmt normalization, not by the transform")
+
+	cmd.Flags().
+		BoolVar(&addIncremental,
+			"incremental",
+			false,
+			"only process files that changed since the last incremental run")
+
+	cmd.Flags().
+		StringVar(&addStateFile,
+			"state-file",
+			".sqlc-qol/add-nosec.state.json",
+			"path of the state file used by --incremental")
+
+	cmd.Flags().
+		BoolVar(&addResetIncremental,
+			"reset-incremental",
+			false,
+			"discard the incremental state and process every file")
 
-	cmd.MarkFlagsMutuallyExclusive("targets", "csv")
 	_ = cmd.MarkFlagFilename("csv", "csv")
+	_ = cmd.MarkFlagDirname("csv-dir")
+	_ = cmd.MarkFlagDirname("glob-base")
+	_ = cmd.MarkFlagFilename("fail-on-unsuppressed", "json")
+
+	addAuditFlags(cmd)
+	addZipFlags(cmd)
 
 	rootCmd.AddCommand(cmd)
 }
+
+// addTargetsFromConfig reports whether add-nosec takes its targets from the
+// config file: none of --targets, --csv and --csv-dir is passed.
+func addTargetsFromConfig(cmd *cobra.Command) bool {
+	for _, name := range []string{"targets", "csv", "csv-dir"} {
+		if cmd.Flags().Changed(name) {
+			return false
+		}
+	}
+	return true
+}
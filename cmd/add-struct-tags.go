@@ -0,0 +1,43 @@
+package cmd
+
+import (
+	"github.com/seanhuebl/sqlc-qol/v2/internal/addstructtags"
+	"github.com/spf13/cobra"
+)
+
+var (
+	structTagsModelsPath string
+	structTagsRulesPath  string
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "add-struct-tags",
+		Short: "Add db, gorm or other struct tags to SQLC model fields",
+		Long: `Parses your SQLC models file and adds struct tags derived from each exported field's
+name, following a rules file mapping tag names to naming conventions (e.g. db:"user_id" and gorm:"column:user_id").
+Existing tags are never clobbered, so the command is safe to re-run after every sqlc generate.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return addstructtags.Run(structTagsModelsPath, structTagsRulesPath)
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&structTagsModelsPath,
+			"models",
+			"m",
+			"",
+			"path to the Go source file defining your models (e.g. internal/models/models.go)")
+	_ = cmd.MarkFlagRequired("models")
+
+	cmd.Flags().
+		StringVarP(&structTagsRulesPath,
+			"rules",
+			"r",
+			"",
+			"path to a JSON rules file mapping tag names to naming conventions")
+	_ = cmd.MarkFlagRequired("rules")
+	_ = cmd.MarkFlagFilename("rules", "json")
+
+	rootCmd.AddCommand(cmd)
+}
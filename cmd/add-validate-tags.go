@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"github.com/seanhuebl/sqlc-qol/v2/internal/addvalidatetags"
+	"github.com/spf13/cobra"
+)
+
+var (
+	validateModelsPath string
+	validateRulesPath  string
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "add-validate-tags",
+		Short: "Add go-playground/validator tags to SQLC model fields",
+		Long: `Parses your SQLC models file and adds validate:"..." struct tags to the fields
+of every struct, following a rules file (by default non-pointer, non-Null fields get "required").
+Existing validate tags are never clobbered, so the command is safe to re-run after every sqlc generate.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return addvalidatetags.Run(validateModelsPath, validateRulesPath)
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&validateModelsPath,
+			"models",
+			"m",
+			"",
+			"path to the Go source file defining your models (e.g. internal/models/models.go)")
+	_ = cmd.MarkFlagRequired("models")
+
+	cmd.Flags().
+		StringVarP(&validateRulesPath,
+			"rules",
+			"r",
+			"",
+			"path to a JSON rules file mapping field conditions to validate tags")
+	_ = cmd.MarkFlagFilename("rules", "json")
+
+	rootCmd.AddCommand(cmd)
+}
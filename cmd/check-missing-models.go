@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/qualifymodels"
+	"github.com/spf13/cobra"
+)
+
+var (
+	missingModels  string
+	missingDirs    []string
+	missingOptions qualifymodels.Options
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "check-missing-models",
+		Short: "Report bare type references in query files that match no current model",
+		Long: `Collects the model names from your models file like qualify-models, then lists every bare,
+exported identifier used as a type in the query files that is neither a model nor declared
+in the query package, most likely a model that was removed or renamed and that
+qualify-models would leave bare. Exits non-zero if any is found. Nothing is modified.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			missing, err := qualifymodels.CheckMissing(missingModels, missingDirs, missingOptions)
+			if err != nil {
+				return err
+			}
+			for _, m := range missing {
+				fmt.Println(m)
+			}
+			if len(missing) > 0 {
+				return fmt.Errorf("found %d references to unknown models", len(missing))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&missingModels,
+			"models",
+			"m",
+			"",
+			"path to the Go source file defining your models (e.g. internal/models/models.go)")
+	_ = cmd.MarkFlagRequired("models")
+
+	cmd.Flags().
+		StringSliceVarP(&missingDirs,
+			"dir",
+			"d",
+			nil,
+			"root directory where your database files live (e.g. internal/database); repeatable")
+	_ = cmd.MarkFlagRequired("dir")
+
+	cmd.Flags().
+		StringVar(&missingOptions.NameTemplate,
+			"name-template",
+			"",
+			"rule mapping generated type names onto model names, as for qualify-models")
+
+	cmd.Flags().
+		BoolVar(&missingOptions.CaseInsensitive,
+			"case-insensitive",
+			false,
+			"match identifiers against model names regardless of case")
+
+	cmd.Flags().
+		BoolVar(&missingOptions.StructsOnly,
+			"structs-only",
+			false,
+			"only treat the structs and type aliases of the models file as models, as for qualify-models")
+
+	cmd.Flags().
+		StringSliceVar(&missingOptions.BuildTags,
+			"build-tags",
+			nil,
+			"comma-separated build tags applied when discovering models and query files")
+
+	cmd.Flags().
+		StringVar(&missingOptions.Pattern,
+			"pattern",
+			"",
+			"only check files whose base name matches this glob (e.g. *.sql.go)")
+
+	rootCmd.AddCommand(cmd)
+}
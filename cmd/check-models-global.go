@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/modcheck"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/qualifymodels"
+	"github.com/spf13/cobra"
+)
+
+var (
+	globalModels   string
+	globalRoot     string
+	globalSkipDirs []string
+	globalOptions  qualifymodels.Options
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "check-models-global",
+		Short: "Report bare model references anywhere in the module",
+		Long: `Collects the model names from your models file like qualify-models, then walks every .go file
+from the module root (not just the query dirs) and lists each bare reference to a model used as a
+type outside the models package, such as hand-written code added after the models moved.
+Hidden, vendor and testdata directories are skipped. Exits non-zero if any is found. Nothing is modified.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := globalRoot
+			if root == "" {
+				mod, err := modcheck.Find(filepath.Dir(globalModels))
+				if err != nil {
+					return fmt.Errorf("failed to find the module root (pass --root): %w", err)
+				}
+				root = mod.Dir
+			}
+			bare, err := qualifymodels.CheckGlobal(globalModels, root, globalSkipDirs, globalOptions)
+			if err != nil {
+				return err
+			}
+			for _, b := range bare {
+				fmt.Println(b)
+			}
+			if len(bare) > 0 {
+				return fmt.Errorf("found %d bare references to models", len(bare))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&globalModels,
+			"models",
+			"m",
+			"",
+			"path to the Go source file defining your models (e.g. internal/models/models.go)")
+	_ = cmd.MarkFlagRequired("models")
+
+	cmd.Flags().
+		StringVar(&globalRoot,
+			"root",
+			"",
+			"directory to walk (default: the root of the module containing --models)")
+
+	cmd.Flags().
+		StringSliceVar(&globalSkipDirs,
+			"skip-dirs",
+			qualifymodels.DefaultSkipDirs,
+			"comma-separated directory names never descended into, on top of hidden ones")
+
+	cmd.Flags().
+		StringSliceVar(&globalOptions.BuildTags,
+			"build-tags",
+			nil,
+			"comma-separated build tags applied when discovering models and files")
+
+	rootCmd.AddCommand(cmd)
+}
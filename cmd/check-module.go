@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"path/filepath"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/modcheck"
+	"github.com/spf13/cobra"
+)
+
+var (
+	moduleModels        string
+	moduleDirs          []string
+	moduleImport        string
+	moduleAllowExternal bool
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "check-module",
+		Short: "Confirm the models import lies within the module of the query code",
+		Long: `Finds the go.mod nearest to the query directories and confirms that the models file belongs
+to the same module and that --import is the path that module imports it by. An import
+outside the module leaves code qualified by qualify-models uncompilable. Exits non-zero
+otherwise, unless --allow-external is set. qualify-models runs the same check before
+processing.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mod, err := modcheck.Check(moduleDirs, moduleModels, moduleImport)
+			if err != nil {
+				if !moduleAllowExternal {
+					return err
+				}
+				fmt.Printf("warning: %v\n", err)
+				return nil
+			}
+			fmt.Printf("%s is in module %s (%s)\n", moduleImport, mod.Path, filepath.Join(mod.Dir, "go.mod"))
+			return nil
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&moduleModels,
+			"models",
+			"m",
+			"",
+			"path to the Go source file defining your models (e.g. internal/models/models.go)")
+	_ = cmd.MarkFlagRequired("models")
+
+	cmd.Flags().
+		StringSliceVarP(&moduleDirs,
+			"dir",
+			"d",
+			nil,
+			"root directory where your database files live (e.g. internal/database); repeatable")
+	_ = cmd.MarkFlagRequired("dir")
+
+	cmd.Flags().
+		StringVarP(&moduleImport,
+			"import",
+			"i",
+			"",
+			"import path for your models package (e.g. github.com/me/app/internal/models)")
+	_ = cmd.MarkFlagRequired("import")
+
+	cmd.Flags().
+		BoolVar(&moduleAllowExternal,
+			"allow-external",
+			false,
+			"report a failed check as a warning and exit 0, for intentional cross-module setups")
+
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,80 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/addnosec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	placementTargets string
+	placementCSV     string
+	placementFix     bool
+	placementRespect []string
+	placementGroup   string
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "check-nosec-placement",
+		Short: "Report // #nosec comments that drifted off their target consts",
+		Long: `Scans Go source files matching a glob pattern and verifies that every // #nosec comment
+add-nosec would write is attached to a targeted const, and that every targeted const carries
+one. Hand-written suppressions, such as those in function bodies, are left alone.
+Drifted suppressions are listed and the command exits non-zero; with --fix orphaned
+comments are removed and the targets are re-tagged instead.`,
+		Args: cobra.ExactArgs(1), // Expecting a single argument: the glob pattern
+		RunE: func(cmd *cobra.Command, args []string) error {
+			drift, err := addnosec.CheckPlacement(args[0], placementTargets, placementCSV, cfg, addnosec.Options{
+				RespectDirectives: placementRespect,
+				GroupMode:         placementGroup,
+			}, placementFix)
+			if err != nil {
+				return err
+			}
+			for _, d := range drift {
+				fmt.Println(d)
+			}
+			if len(drift) > 0 && !placementFix {
+				return fmt.Errorf("found %d drifted #nosec suppressions", len(drift))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&placementTargets,
+			"targets", "t",
+			"",
+			"comma-separated list of target consts that should carry a // #nosec comment")
+
+	cmd.Flags().
+		StringVarP(&placementCSV,
+			"csv",
+			"c",
+			"",
+			"path to CSV file containing target consts (no headers)")
+
+	cmd.Flags().
+		BoolVar(&placementFix,
+			"fix",
+			false,
+			"remove orphaned // #nosec comments and re-tag the targets")
+
+	cmd.Flags().
+		StringSliceVar(&placementRespect,
+			"respect-directives",
+			[]string{"nolint:gosec"},
+			"linter directives (name[:linter]) that already suppress gosec; targets carrying one need no // #nosec")
+
+	cmd.Flags().
+		StringVar(&placementGroup,
+			"group-mode",
+			addnosec.GroupPerSpec,
+			"the --group-mode passed to add-nosec; with shared or auto the comment above a const ( ... ) block covers its specs")
+
+	_ = cmd.MarkFlagFilename("csv", "csv")
+
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/addnosec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	rulesFix  bool
+	rulesRule string
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "check-nosec-rules",
+		Short: "Report // #nosec comments that don't name a gosec rule",
+		Long: `Scans Go source files matching a glob pattern for blanket // #nosec comments, i.e. ones
+without a rule code such as G101. They are listed, marked as tool-managed (written by
+add-nosec) or hand-written, and the command exits non-zero; with --fix --rule G101 they
+are rewritten to suppress that rule instead.`,
+		Args: cobra.ExactArgs(1), // Expecting a single argument: the glob pattern
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fixRule := ""
+			if rulesFix {
+				fixRule = rulesRule
+			}
+			bare, err := addnosec.CheckRules(args[0], addnosec.Options{}, fixRule)
+			if err != nil {
+				return err
+			}
+			for _, b := range bare {
+				fmt.Println(b)
+			}
+			if len(bare) > 0 && !rulesFix {
+				return fmt.Errorf("found %d #nosec comments without a rule code", len(bare))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().
+		BoolVar(&rulesFix,
+			"fix",
+			false,
+			"rewrite blanket // #nosec comments to suppress --rule")
+
+	cmd.Flags().
+		StringVar(&rulesRule,
+			"rule",
+			"",
+			"gosec rule ID used by --fix (e.g. G101)")
+
+	cmd.MarkFlagsRequiredTogether("fix", "rule")
+
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,70 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/checkquerier"
+	"github.com/spf13/cobra"
+)
+
+var (
+	querierInterface string
+	querierDir       string
+	querierOpts      checkquerier.Options
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "check-querier",
+		Short: "Report drift between *Queries methods and a hand-written Querier interface",
+		Long: `Collects the query methods SQLC generates on *Queries and compares them with the methods
+of a hand-written Querier interface. Methods missing on either side, and methods whose
+signatures differ (compared by type, with qualified types resolved), are listed and the
+command exits non-zero.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			mismatches, err := checkquerier.Check(querierInterface, querierDir, querierOpts)
+			if err != nil {
+				return err
+			}
+			for _, m := range mismatches {
+				fmt.Println(m)
+			}
+			if len(mismatches) > 0 {
+				return fmt.Errorf("found %d querier mismatches", len(mismatches))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().
+		StringVar(&querierInterface,
+			"interface",
+			"",
+			"path to the Go file declaring the Querier interface (e.g. internal/database/querier.go)")
+	_ = cmd.MarkFlagRequired("interface")
+
+	cmd.Flags().
+		StringVarP(&querierDir,
+			"dir",
+			"d",
+			"",
+			"directory holding the SQLC generated query code (e.g. internal/database)")
+	_ = cmd.MarkFlagRequired("dir")
+
+	cmd.Flags().
+		StringVar(&querierOpts.Name,
+			"name",
+			"Querier",
+			"name of the interface type")
+
+	cmd.Flags().
+		StringVar(&querierOpts.Receiver,
+			"receiver",
+			"Queries",
+			"name of the generated type whose methods are checked")
+
+	_ = cmd.MarkFlagFilename("interface", "go")
+	_ = cmd.MarkFlagDirname("dir")
+
+	rootCmd.AddCommand(cmd)
+}
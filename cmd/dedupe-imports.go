@@ -0,0 +1,32 @@
+package cmd
+
+import (
+	"github.com/seanhuebl/sqlc-qol/v2/internal/dedupeimports"
+	"github.com/spf13/cobra"
+)
+
+var dedupeDir string
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "dedupe-imports",
+		Short: "Collapse duplicate imports in SQLC generated code",
+		Long: `Walks every .go file under a directory and merges import specs of the same path, as left
+behind by several tool passes: exact duplicates and redundant blank imports are removed,
+and a path imported under several names keeps a single one, preferring an alias.
+The set of imported packages never changes.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return dedupeimports.Run(dedupeDir)
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&dedupeDir,
+			"dir",
+			"d",
+			"",
+			"root directory where your database files live (e.g. internal/database)")
+	_ = cmd.MarkFlagRequired("dir")
+
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,73 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/genconstructors"
+	"github.com/spf13/cobra"
+)
+
+var (
+	ctorModelsPath string
+	ctorOut        string
+	ctorDefaults   []string
+	ctorOpts       genconstructors.Options
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "gen-constructors",
+		Short: "Generate New<Model>() constructors returning models with default field values",
+		Long: `Writes a New<Model>() constructor for every struct in your models file, returning the model
+with its fields initialized to defaults derived from their types. By default slices and maps
+start out empty instead of nil; --default adds or overrides rules per field type.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			ctorOpts.Defaults = make(map[string]string, len(ctorDefaults))
+			for _, rule := range ctorDefaults {
+				typ, expr, ok := strings.Cut(rule, "=")
+				if !ok {
+					return fmt.Errorf("invalid default %q: expected type=expression", rule)
+				}
+				ctorOpts.Defaults[strings.TrimSpace(typ)] = strings.TrimSpace(expr)
+			}
+			return genconstructors.Run(ctorModelsPath, ctorOut, ctorOpts)
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&ctorModelsPath,
+			"models",
+			"m",
+			"",
+			"path to your models file (e.g. internal/models/models.go)")
+	_ = cmd.MarkFlagRequired("models")
+
+	cmd.Flags().
+		StringVarP(&ctorOut,
+			"out",
+			"o",
+			"",
+			"path of the generated constructors file (e.g. internal/fixtures/constructors.go)")
+	_ = cmd.MarkFlagRequired("out")
+
+	cmd.Flags().
+		StringVar(&ctorOpts.Package,
+			"package",
+			"",
+			"package name of the generated file (default: the models package)")
+
+	cmd.Flags().
+		StringVar(&ctorOpts.ModelsImport,
+			"models-import",
+			"",
+			"import path of the models package, when the constructors live in another package")
+
+	cmd.Flags().
+		StringArrayVar(&ctorDefaults,
+			"default",
+			nil,
+			"field default as type=expression (e.g. time.Time=time.Now()); repeat for several types")
+
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"github.com/seanhuebl/sqlc-qol/v2/internal/genmock"
+	"github.com/spf13/cobra"
+)
+
+var (
+	mockDir         string
+	mockQueryImport string
+	mockOut         string
+	mockOpts        genmock.Options
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "gen-mock",
+		Short: "Generate a mock of the SQLC Querier interface",
+		Long: `Collects the query methods SQLC generates on *Queries and writes a mock implementation
+of the Querier interface, either a testify mock (--style testify) or a stub with one function
+field per method (--style stub). Signatures mirror the generated ones, including qualified model types.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return genmock.Run(mockDir, mockQueryImport, mockOut, mockOpts)
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&mockDir,
+			"dir",
+			"d",
+			"",
+			"directory holding the SQLC generated query code (e.g. internal/database)")
+	_ = cmd.MarkFlagRequired("dir")
+
+	cmd.Flags().
+		StringVar(&mockQueryImport,
+			"query-import",
+			"",
+			"import path of the generated query package (e.g. github.com/me/app/internal/database)")
+	_ = cmd.MarkFlagRequired("query-import")
+
+	cmd.Flags().
+		StringVarP(&mockOut,
+			"out",
+			"o",
+			"",
+			"path of the generated mock file (e.g. internal/mocks/querier.go)")
+	_ = cmd.MarkFlagRequired("out")
+
+	cmd.Flags().
+		StringVar(&mockOpts.Package,
+			"package",
+			"mocks",
+			"package name of the generated mock file")
+
+	cmd.Flags().
+		StringVar(&mockOpts.Name,
+			"name",
+			"Querier",
+			"name of the generated mock type")
+
+	cmd.Flags().
+		StringVar(&mockOpts.Style,
+			"style",
+			"testify",
+			"mock flavor: testify or stub")
+
+	rootCmd.AddCommand(cmd)
+}
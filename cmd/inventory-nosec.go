@@ -0,0 +1,46 @@
+package cmd
+
+import (
+	"os"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/addnosec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	inventoryDir    string
+	inventoryFormat string
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "inventory-nosec",
+		Short: "List every // #nosec suppression under a directory",
+		Long: `Scans every .go file under a directory, vendor and hidden directories excluded, and
+prints a table of each #nosec comment with its file, line, the name of the declaration
+it is attached to, and its rule codes and reason when present. Nothing is modified.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			inventory, err := addnosec.Inventory(inventoryDir)
+			if err != nil {
+				return err
+			}
+			return addnosec.WriteInventory(os.Stdout, inventory, inventoryFormat)
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&inventoryDir,
+			"dir",
+			"d",
+			"",
+			"root directory to scan (e.g. internal/database or .)")
+	_ = cmd.MarkFlagRequired("dir")
+
+	cmd.Flags().
+		StringVar(&inventoryFormat,
+			"format",
+			addnosec.FormatMarkdown,
+			"output format: markdown, csv or json")
+
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,31 @@
+package cmd
+
+import (
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pruneimports"
+	"github.com/spf13/cobra"
+)
+
+var pruneDir string
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "prune-imports",
+		Short: "Remove imports no longer referenced by SQLC generated code",
+		Long: `Walks every .go file under a directory and removes imports that no selector in the
+file refers to, e.g. an old models import path left behind by a migration.
+Blank and dot imports are never removed and import grouping is preserved.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return pruneimports.Run(pruneDir)
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&pruneDir,
+			"dir",
+			"d",
+			"",
+			"root directory where your database files live (e.g. internal/database)")
+	_ = cmd.MarkFlagRequired("dir")
+
+	rootCmd.AddCommand(cmd)
+}
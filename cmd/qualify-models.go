@@ -1,14 +1,43 @@
 package cmd
 
 import (
+	"fmt"
+
 	"github.com/seanhuebl/sqlc-qol/v2/internal/qualifymodels"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/sqlcconfig"
 	"github.com/spf13/cobra"
 )
 
 var (
-	modelFilePath string
-	rootDbDir     string
-	importPath    string
+	modelFilePath  string
+	rootDbDirs     []string
+	importPath     string
+	nameTemplate   string
+	qualifyFold    bool
+	qualifyStructs bool
+	buildTags      []string
+	replaceAlias   string
+	qualifyAlias   string
+	qualifyDryRun  bool
+	qualifyEmit    bool
+	qualifyChmod   bool
+	qualifyExec    string
+	qualifyStrict  bool
+	qualifySize    bool
+	qualifyReform  bool
+	qualifyResolve bool
+	qualifyFuncs   []string
+	qualifyGlob    string
+	qualifyExclude []string
+	qualifyExtern  bool
+	qualifyForce   bool
+	qualifyJobs    int
+
+	qualifySqlcConfig string
+
+	qualifyIncremental      bool
+	qualifyStateFile        string
+	qualifyResetIncremental bool
 )
 
 func init() {
@@ -21,7 +50,40 @@ re-writes the SQLC-generated .go files in your database to qualify those types
 this is to be used in tandem with a script that moves
 the SQLC models into an external global models package`,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			return qualifymodels.Run(modelFilePath, rootDbDir, importPath)
+			modelPath, dirs, modelImport, err := qualifyInputs(cmd)
+			if err != nil {
+				return err
+			}
+			return qualifymodels.Run(modelPath, dirs, modelImport, qualifymodels.Options{
+				NameTemplate:       nameTemplate,
+				CaseInsensitive:    qualifyFold,
+				StructsOnly:        qualifyStructs,
+				BuildTags:          buildTags,
+				ReplaceAlias:       replaceAlias,
+				Alias:              qualifyAlias,
+				Pattern:            qualifyGlob,
+				Exclude:            qualifyExclude,
+				CheckModule:        true,
+				AllowExternal:      qualifyExtern,
+				Force:              qualifyForce,
+				Jobs:               qualifyJobs,
+				Check:              qualifyDryRun || qualifyEmit,
+				EmitPositions:      qualifyEmit,
+				Chmod:              qualifyChmod,
+				Exec:               qualifyExec,
+				Strict:             qualifyStrict,
+				SizeReport:         qualifySize,
+				WarnOnReformat:     qualifyReform,
+				ResolveAliasTarget: qualifyResolve,
+				Funcs:              qualifyFuncs,
+				AuditLog:           auditLogPath,
+				AuditTruncate:      auditTruncate,
+				ZipOut:             zipOut,
+				ChangedOnly:        changedOnly,
+				Incremental:        qualifyIncremental,
+				StateFile:          qualifyStateFile,
+				ResetIncremental:   qualifyResetIncremental,
+			})
 		},
 	}
 
@@ -30,24 +92,210 @@ the SQLC models into an external global models package`,
 			"models",
 			"m",
 			"",
-			"path to the Go source file defining your models (e.g. internal/models/models.go)")
-	_ = cmd.MarkFlagRequired("models")
+			"path to the Go source file defining your models (e.g. internal/models/models.go), or a comma-separated list of files and globs in the models package")
 
 	cmd.Flags().
-		StringVarP(&rootDbDir,
+		StringSliceVarP(&rootDbDirs,
 			"dir",
 			"d",
-			"",
-			"root directory where your database files live (e.g. internal/database)")
-	_ = cmd.MarkFlagRequired("queries")
+			nil,
+			"root directory where your database files live (e.g. internal/database); repeatable")
 
 	cmd.Flags().
 		StringVarP(&importPath,
 			"import",
 			"i",
 			"",
-			"import path for your models package (e.g. internal/models)")
-	_ = cmd.MarkFlagRequired("import")
+			"import path for your models package (e.g. github.com/me/app/internal/models)")
+
+	cmd.Flags().
+		StringVar(&qualifySqlcConfig,
+			"sqlc-config",
+			"",
+			"read --dir and --import from this sqlc.yaml/sqlc.json, or the one in this directory (e.g. .); the flags override it")
+	_ = cmd.MarkFlagFilename("sqlc-config", "yaml", "yml", "json")
+
+	cmd.Flags().
+		StringVar(&nameTemplate,
+			"name-template",
+			"",
+			"rule mapping generated names onto model names: strip-prefix:<p>, strip-suffix:<s> or regex:<pattern>=><replace>")
+
+	cmd.Flags().
+		BoolVar(&qualifyFold,
+			"case-insensitive",
+			false,
+			"match identifiers against model names regardless of case (may over-match)")
+
+	cmd.Flags().
+		BoolVar(&qualifyStructs,
+			"structs-only",
+			false,
+			"only treat the structs and type aliases of the models file as models, leaving enums and other named types bare")
+
+	cmd.Flags().
+		StringVar(&qualifyGlob,
+			"pattern",
+			"",
+			"only process files whose base name matches this glob (e.g. *.sql.go)")
+
+	cmd.Flags().
+		StringSliceVar(&qualifyExclude,
+			"exclude",
+			nil,
+			"comma-separated directory names never descended into, on top of vendor and hidden ones")
+
+	cmd.Flags().
+		StringSliceVar(&buildTags,
+			"build-tags",
+			nil,
+			"comma-separated build tags applied when discovering models and query files (e.g. pg,integration)")
+
+	cmd.Flags().
+		StringVar(&replaceAlias,
+			"replace-alias",
+			"",
+			"old=new: switch references qualified with a previous alias (db.Transaction) to the models alias")
+
+	cmd.Flags().
+		StringVar(&qualifyAlias,
+			"alias",
+			"",
+			"name to qualify models with (alias.Transaction) and import them under (default: the last element of --import)")
+
+	cmd.Flags().
+		BoolVar(&qualifyExtern,
+			"allow-external",
+			false,
+			"only warn when --import lies outside the module of the query dirs or doesn't match the models file")
+
+	cmd.Flags().
+		BoolVar(&qualifyForce,
+			"force",
+			false,
+			"process the query files even when they already import the models package and use no bare model types")
+
+	cmd.Flags().
+		IntVar(&qualifyJobs,
+			"jobs",
+			0,
+			"number of query files processed in parallel (default: GOMAXPROCS)")
+
+	cmd.Flags().
+		BoolVar(&qualifyDryRun,
+			"dry-run",
+			false,
+			"list the files that would change without writing them; exits 2 if any would")
+
+	cmd.Flags().
+		BoolVar(&qualifyEmit,
+			"emit-positions",
+			false,
+			"dry run printing a file:line:col: qualify-models <name> line for every identifier that would be qualified")
+
+	cmd.Flags().
+		BoolVar(&qualifyChmod,
+			"chmod",
+			false,
+			"make read-only query files writable for their owner instead of failing before any edit")
+
+	cmd.Flags().
+		StringVar(&qualifyExec,
+			"exec",
+			"",
+			"external command each query file is piped through (stdin to stdout) after the built-in transform")
+
+	cmd.Flags().
+		BoolVar(&qualifyStrict,
+			"strict",
+			false,
+			"fail instead of skipping with a warning when a query file changed on disk between being read and written")
+
+	cmd.Flags().
+		BoolVar(&qualifySize,
+			"size-report",
+			false,
+			"print the lines and bytes added and removed across the modified query files at the end of the run")
+
+	cmd.Flags().
+		BoolVar(&qualifyReform,
+			"warn-on-reformat",
+			false,
+			"warn about query files that would change only by gofmt normalization, not by the transform")
+
+	cmd.Flags().
+		BoolVar(&qualifyResolve,
+			"resolve-alias-target",
+			false,
+			"qualify models declared as aliases of another package's type (type Account = accounting.Account) with that package instead")
+
+	cmd.Flags().
+		StringSliceVar(&qualifyFuncs,
+			"funcs",
+			nil,
+			"only qualify references inside the functions or methods of these names (comma-separated or repeatable)")
+
+	cmd.Flags().
+		BoolVar(&qualifyIncremental,
+			"incremental",
+			false,
+			"only process files that changed since the last incremental run")
+
+	cmd.Flags().
+		StringVar(&qualifyStateFile,
+			"state-file",
+			".sqlc-qol/qualify-models.state.json",
+			"path of the state file used by --incremental")
+
+	cmd.Flags().
+		BoolVar(&qualifyResetIncremental,
+			"reset-incremental",
+			false,
+			"discard the incremental state and process every file")
+
+	addAuditFlags(cmd)
+	addZipFlags(cmd)
 
 	rootCmd.AddCommand(cmd)
 }
+
+// qualifyInputs returns the models path, query dirs and models import path
+// to run with: --models, --dir and --import, or, for the ones not given, the
+// gen.go.out directories and models_package_import_path of the sqlc config
+// named by --sqlc-config, then the qualify_models section of the --config
+// file.
+func qualifyInputs(cmd *cobra.Command) (string, []string, string, error) {
+	modelPath, dirs, modelImport := modelFilePath, rootDbDirs, importPath
+	if !cmd.Flags().Changed("models") {
+		modelPath = cfg.QualifyModels.Models
+	}
+	if modelPath == "" {
+		return "", nil, "", fmt.Errorf(`required flag "models" not set (or set qualify_models.models in %s)`, configPath)
+	}
+	source := "pass --sqlc-config to read it from your sqlc config"
+	if cmd.Flags().Changed("sqlc-config") && (len(dirs) == 0 || modelImport == "") {
+		conf, err := sqlcconfig.Load(qualifySqlcConfig)
+		if err != nil {
+			return "", nil, "", err
+		}
+		if len(dirs) == 0 {
+			dirs = conf.OutDirs()
+		}
+		if modelImport == "" {
+			if modelImport, err = conf.ModelsImport(); err != nil && cfg.QualifyModels.Import == "" {
+				return "", nil, "", err
+			}
+		}
+		source = fmt.Sprintf("%s sets no models_package_import_path", conf.Path)
+	}
+	if modelImport == "" {
+		modelImport = cfg.QualifyModels.Import
+	}
+	if len(dirs) == 0 {
+		return "", nil, "", fmt.Errorf(`required flag "dir" not set (%s)`, source)
+	}
+	if modelImport == "" {
+		return "", nil, "", fmt.Errorf(`required flag "import" not set (%s, or set qualify_models.import in %s)`, source, configPath)
+	}
+	return modelPath, dirs, modelImport, nil
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQualifyModelsSqlcConfig(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Config            string
+		Args              []string
+		ExpectedErrSubStr string
+	}{
+		{
+			Name: "dir and import from the config",
+			Config: `version: "2"
+sql:
+  - gen:
+      go:
+        package: database
+        out: internal/database
+        models_package_import_path: example.com/app/internal/models
+`,
+		},
+		{
+			Name: "flags override the config",
+			Config: `version: "2"
+sql:
+  - gen:
+      go:
+        package: database
+        out: internal/database
+        models_package_import_path: example.com/app/models
+`,
+			Args: []string{"--import", "example.com/app/internal/models"},
+		},
+		{
+			Name: "config without a models import",
+			Config: `version: "2"
+sql:
+  - gen: {go: {package: database, out: internal/database}}
+`,
+			ExpectedErrSubStr: "sqlc.yaml sets no models_package_import_path",
+		},
+		{
+			Name:              "no config",
+			ExpectedErrSubStr: "no sqlc config found",
+		},
+	}
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		rootDbDirs, importPath, qualifySqlcConfig = nil, "", ""
+	}()
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			rootDbDirs, importPath, qualifySqlcConfig = nil, "", ""
+			dir := t.TempDir()
+			files := map[string]string{
+				"go.mod":                         "module example.com/app\n",
+				"internal/models/models.go":      "package models\n\ntype User struct{}\n",
+				"internal/database/query.sql.go": "package database\n\nvar U User\n",
+			}
+			if tc.Config != "" {
+				files["sqlc.yaml"] = tc.Config
+			}
+			for name, content := range files {
+				path := filepath.Join(dir, name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+				require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+			}
+
+			rootCmd.SetArgs(append([]string{"qualify-models", "--models", filepath.Join(dir, "internal/models/models.go"), "--sqlc-config", dir}, tc.Args...))
+			err := rootCmd.Execute()
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			got, err := os.ReadFile(filepath.Join(dir, "internal/database/query.sql.go"))
+			require.NoError(t, err)
+			require.Contains(t, string(got), "var U models.User")
+		})
+	}
+}
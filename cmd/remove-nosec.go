@@ -0,0 +1,109 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/addnosec"
+	"github.com/spf13/cobra"
+)
+
+var (
+	removeTargets string
+	removeCSV     string
+	removeCSVDir  string
+	removeBase    string
+	removeKinds   []string
+	removeRules   []string
+	removeReasons string
+	removeGroup   string
+	removeDryRun  bool
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "remove-nosec",
+		Short: "Remove the // #nosec comments add-nosec added to targeted consts",
+		Long: `Undoes add-nosec: scans Go source files matching a glob pattern and deletes the // #nosec
+comment add-nosec adds from each targeted const. Only comments that are exactly the form
+add-nosec writes (with the same --rule and --reasons) are removed; hand-written #nosec
+comments and unrelated comments on the same spec are kept.`,
+		Args: cobra.ExactArgs(1), // Expecting a single argument: the glob pattern
+		RunE: func(cmd *cobra.Command, args []string) error {
+			removed, err := addnosec.Remove(args[0], removeTargets, removeCSV, cfg, addnosec.Options{
+				CSVDir:    removeCSVDir,
+				GlobBase:  removeBase,
+				DeclKinds: removeKinds,
+				Rules:     removeRules,
+				Reasons:   removeReasons,
+				GroupMode: removeGroup,
+				Check:     removeDryRun,
+			})
+			for _, r := range removed {
+				fmt.Println(r)
+			}
+			return err
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&removeTargets,
+			"targets", "t",
+			"",
+			"comma-separated list of target consts to remove gosec ignore comments from, or - to read them from stdin")
+
+	cmd.Flags().
+		StringVarP(&removeCSV,
+			"csv",
+			"c",
+			"",
+			"path to CSV file containing target consts (no headers), or - to read it from stdin")
+
+	cmd.Flags().
+		StringVar(&removeCSVDir,
+			"csv-dir",
+			"",
+			"path to a directory of CSV files (no headers) whose targets are merged")
+
+	cmd.Flags().
+		StringVar(&removeBase,
+			"glob-base",
+			"",
+			"directory the glob pattern is resolved against; reported paths are relative to it")
+
+	cmd.Flags().
+		StringSliceVar(&removeKinds,
+			"kinds",
+			addnosec.DefaultDeclKinds,
+			"comma-separated declaration kinds targets are matched against: const, var and field (composite literal keys)")
+
+	cmd.Flags().
+		StringSliceVar(&removeRules,
+			"rule",
+			nil,
+			"comma-separated gosec rule IDs the comments to remove name, as passed to add-nosec --rule")
+
+	cmd.Flags().
+		StringVar(&removeReasons,
+			"reasons",
+			"",
+			"path to the YAML reasons file passed to add-nosec --reasons")
+	_ = cmd.MarkFlagFilename("reasons", "yaml", "yml", "json")
+
+	cmd.Flags().
+		StringVar(&removeGroup,
+			"group-mode",
+			addnosec.GroupPerSpec,
+			"the --group-mode passed to add-nosec; with shared or auto the comment above a const ( ... ) block is removed too")
+
+	cmd.Flags().
+		BoolVar(&removeDryRun,
+			"dry-run",
+			false,
+			"list the comments that would be removed without writing anything; exits 2 if there are any")
+
+	_ = cmd.MarkFlagFilename("csv", "csv")
+	_ = cmd.MarkFlagDirname("csv-dir")
+	_ = cmd.MarkFlagDirname("glob-base")
+
+	rootCmd.AddCommand(cmd)
+}
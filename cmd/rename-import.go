@@ -0,0 +1,50 @@
+package cmd
+
+import (
+	"github.com/seanhuebl/sqlc-qol/v2/internal/renameimport"
+	"github.com/spf13/cobra"
+)
+
+var (
+	renameDir  string
+	renameFrom string
+	renameTo   string
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "rename-import",
+		Short: "Rewrite an import path across SQLC generated code",
+		Long: `Walks every .go file under a directory and rewrites imports of one path (and of the
+packages below it) to another, e.g. after moving internal/models to pkg/models.
+Aliases are preserved and selector expressions are left untouched; an unaliased import
+whose package name would change is pinned to its old name with an alias.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return renameimport.Run(renameDir, renameFrom, renameTo)
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&renameDir,
+			"dir",
+			"d",
+			"",
+			"root directory where your database files live (e.g. internal/database)")
+	_ = cmd.MarkFlagRequired("dir")
+
+	cmd.Flags().
+		StringVar(&renameFrom,
+			"from",
+			"",
+			"import path to migrate away from (e.g. github.com/me/app/internal/models)")
+	_ = cmd.MarkFlagRequired("from")
+
+	cmd.Flags().
+		StringVar(&renameTo,
+			"to",
+			"",
+			"new import path (e.g. github.com/me/app/pkg/models)")
+	_ = cmd.MarkFlagRequired("to")
+
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,53 @@
+package cmd
+
+import (
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/rewriteheader"
+	"github.com/spf13/cobra"
+)
+
+var (
+	headerDir         string
+	headerPattern     string
+	headerReplacement []string
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "rewrite-header",
+		Short: "Remove or replace the DO NOT EDIT header of SQLC generated code",
+		Long: `Walks every .go file under a directory and removes the "// Code generated ... DO NOT EDIT."
+header line, or replaces it with a custom header, so tooling that skips generated files
+picks them up (or the other way around). Build constraints and the rest of each file are
+left untouched.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rewriteheader.Run(headerDir, rewriteheader.Options{
+				Pattern:     headerPattern,
+				Replacement: strings.Join(headerReplacement, "\n"),
+			})
+		},
+	}
+
+	cmd.Flags().
+		StringVarP(&headerDir,
+			"dir",
+			"d",
+			"",
+			"root directory where your database files live (e.g. internal/database)")
+	_ = cmd.MarkFlagRequired("dir")
+
+	cmd.Flags().
+		StringVar(&headerPattern,
+			"pattern",
+			rewriteheader.DefaultPattern,
+			"regular expression matching the header comment line to rewrite, // included")
+
+	cmd.Flags().
+		StringArrayVar(&headerReplacement,
+			"replacement",
+			nil,
+			"header comment line written in place of the match; repeat for several lines, omit to remove the match")
+
+	rootCmd.AddCommand(cmd)
+}
@@ -1,42 +1,339 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/seanhuebl/sqlc-qol/v2/internal/config"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/gitrepo"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pending"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/sqlcconfig"
 	"github.com/spf13/cobra"
 )
 
 var (
-	cfg config.Config
+	cfg            = config.Default()
+	configPath     string
+	allowedBaseDir string
+
+	auditLogPath  string
+	auditTruncate bool
+
+	zipOut      string
+	changedOnly bool
+
+	requireGit bool
+
+	exitZero bool
+
+	debugASTFile string
 
 	rootCmd = &cobra.Command{
 		Use:   "sql-qol",
 		Short: "CLI tool to enhance SQLC generated code",
+		// Execute prints the error once; a failed run, or a dry run with
+		// pending changes, isn't a usage mistake.
+		SilenceErrors: true,
+		SilenceUsage:  true,
 		Long: `sqlc-qol is a CLI tool that provides post-processing commands for SQLC generated code.
 It includes features such as:
 Adding gosec ignore comments when a query gets incorrectly flagged as hardcoded credentials.
 Qualifying model references for when the models get moved to an external models directory,
 It will go through the structs and replace all references in the SQLC content with 'models.'.
 Use one of the subcommands for the desired operation.`,
-		Run: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			if configCommands[cmd.Name()] {
+				if err := loadConfig(cmd); err != nil {
+					return err
+				}
+				if err := validateConfig(cmd); err != nil {
+					return err
+				}
+			}
+			return checkGit(cmd, args)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if debugASTFile != "" {
+				return dumpAST(debugASTFile)
+			}
 			fmt.Println("sqlc-qol: use -h to see available subcommands.")
+			return nil
 		},
 	}
 )
 
+// Exit codes of the CLI. Scripts can tell a dry run that found work to do
+// apart from a run that failed.
+const (
+	exitError   = 1
+	exitPending = 2
+)
+
 func Execute() {
 	if err := rootCmd.Execute(); err != nil {
-		log.Fatal(err)
+		fmt.Fprintln(rootCmd.ErrOrStderr(), "Error:", err)
+		if code := exitCode(err); code != 0 {
+			os.Exit(code)
+		}
+	}
+}
+
+// exitCode maps an error returned by a command to the process exit code:
+// exitPending when it only reports pending dry-run changes (0 with
+// --exit-zero, the report having been printed all the same), exitError
+// otherwise.
+func exitCode(err error) int {
+	var pendingErr *pending.Error
+	if errors.As(err, &pendingErr) {
+		if exitZero {
+			return 0
+		}
+		return exitPending
 	}
+	return exitError
 }
 
 func init() {
-	cfg.AllowedBaseDir = "./data"
+	rootCmd.PersistentFlags().
+		StringVar(&configPath,
+			"config",
+			config.FileName,
+			"YAML file setting defaults for the allowed CSV base dir and command inputs; flags override it")
+
+	rootCmd.PersistentFlags().
+		StringVar(&allowedBaseDir,
+			"allowed-base-dir",
+			config.Default().AllowedBaseDir,
+			"directory CSV target files must be inside, the working directory by default; relative paths are resolved against the working directory")
+
+	rootCmd.PersistentFlags().
+		BoolVar(&requireGit,
+			"require-git",
+			false,
+			"refuse to rewrite files in place unless they are inside a git repository")
+
+	rootCmd.PersistentFlags().
+		BoolVar(&exitZero,
+			"exit-zero",
+			false,
+			"exit 0 even when a dry run finds pending changes, still reporting them")
+
+	// Maintainer diagnostic, kept out of the help output.
+	rootCmd.Flags().
+		StringVar(&debugASTFile,
+			"debug-ast",
+			"",
+			"parse the given Go file, print its AST and exit without transforming anything")
+	_ = rootCmd.Flags().MarkHidden("debug-ast")
 
 	cobra.OnInitialize(func() {
 
 	})
 
 }
+
+// addAuditFlags registers --audit-log and --audit-log-truncate on cmd, for
+// the commands that record the changes they make.
+func addAuditFlags(cmd *cobra.Command) {
+	cmd.Flags().
+		StringVar(&auditLogPath,
+			"audit-log",
+			"",
+			"append a JSON-lines record of every change made to this file")
+
+	cmd.Flags().
+		BoolVar(&auditTruncate,
+			"audit-log-truncate",
+			false,
+			"truncate the audit log at the start of the run instead of appending")
+}
+
+// addZipFlags registers --zip-out and --changed-only on cmd, for the
+// commands that can package the files they process.
+func addZipFlags(cmd *cobra.Command) {
+	cmd.Flags().
+		StringVar(&zipOut,
+			"zip-out",
+			"",
+			"also package the processed files into this zip archive, preserving relative paths")
+
+	cmd.Flags().
+		BoolVar(&changedOnly,
+			"changed-only",
+			false,
+			"only add the files the run changes to the --zip-out archive")
+	_ = cmd.MarkFlagFilename("zip-out", "zip")
+}
+
+// dumpAST prints the parsed AST of file, comments included, to stdout.
+func dumpAST(file string) error {
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse %s: %w", file, err)
+	}
+	return ast.Fprint(os.Stdout, fset, f, ast.NotNilFilter)
+}
+
+// configCommands are the commands reading cfg. The others skip loading and
+// validating the config file, so a stray or broken one can't fail them.
+var configCommands = map[string]bool{
+	"add-nosec":             true,
+	"remove-nosec":          true,
+	"check-nosec-placement": true,
+	"verify":                true,
+	"qualify-models":        true,
+}
+
+// loadConfig reads the --config file into cfg, over the built-in defaults.
+// The default file is optional; one named with --config must exist.
+// --allowed-base-dir overrides the file, and the allowed base dir is then
+// made absolute.
+func loadConfig(cmd *cobra.Command) error {
+	loaded, err := config.Load(configPath)
+	if errors.Is(err, fs.ErrNotExist) && !cmd.Flags().Changed("config") {
+		loaded, err = config.Default(), nil
+	}
+	if err != nil {
+		return err
+	}
+	cfg = loaded
+	if cmd.Flags().Changed("allowed-base-dir") {
+		cfg.AllowedBaseDir = allowedBaseDir
+	}
+	if cfg.AllowedBaseDir != "" {
+		if cfg.AllowedBaseDir, err = filepath.Abs(cfg.AllowedBaseDir); err != nil {
+			return fmt.Errorf("failed to resolve allowed base dir: %w", err)
+		}
+	}
+	return nil
+}
+
+// validateConfig applies the flags of cmd that Config.Validate checks to cfg
+// and validates it, once flags are parsed, so every problem with the flags
+// and config file is reported at once. Target flags override the config
+// file's add_nosec targets together: passing any of --targets, --csv and
+// --csv-dir replaces all three.
+func validateConfig(cmd *cobra.Command) error {
+	flagValue := func(name string) (string, bool) {
+		flag := cmd.Flags().Lookup(name)
+		if flag == nil || !flag.Changed {
+			return "", false
+		}
+		return flag.Value.String(), true
+	}
+	targets, targetsSet := flagValue("targets")
+	csvPath, csvSet := flagValue("csv")
+	csvDir, csvDirSet := flagValue("csv-dir")
+	if targetsSet || csvSet || csvDirSet {
+		cfg.AddNosec.Targets, cfg.AddNosec.CSV, cfg.AddNosec.CSVDir = targets, csvPath, csvDir
+	}
+	if alias, ok := flagValue("alias"); ok {
+		cfg.QualifyModels.Alias = alias
+	}
+	if replace, ok := flagValue("replace-alias"); ok {
+		cfg.QualifyModels.ReplaceAlias = replace
+	}
+	return cfg.Validate()
+}
+
+// rewritingCommands are the commands rewriting existing files in place,
+// guarded by --require-git.
+var rewritingCommands = map[string]bool{
+	"add-nosec":             true,
+	"remove-nosec":          true,
+	"check-nosec-placement": true,
+	"qualify-models":        true,
+	"prune-imports":         true,
+	"dedupe-imports":        true,
+	"rename-import":         true,
+	"add-coverage-ignore":   true,
+	"rewrite-header":        true,
+	"add-validate-tags":     true,
+	"add-struct-tags":       true,
+	"add-assertions":        true,
+}
+
+// checkGit enforces --require-git: before a rewriting command writes
+// anything, the directories it rewrites files in must be inside a git work
+// tree, so every change can be recovered. Dry runs, and checks run without
+// --fix, write nothing and pass.
+func checkGit(cmd *cobra.Command, args []string) error {
+	if !requireGit || !rewritingCommands[cmd.Name()] {
+		return nil
+	}
+	for _, name := range []string{"dry-run", "emit-positions"} {
+		if dry, err := cmd.Flags().GetBool(name); err == nil && dry {
+			return nil
+		}
+	}
+	if fix, err := cmd.Flags().GetBool("fix"); err == nil && !fix {
+		return nil
+	}
+	for _, dir := range targetDirs(cmd, args) {
+		if _, err := gitrepo.Root(dir); err != nil {
+			return fmt.Errorf("%w: --require-git refuses to rewrite files git can't restore; initialize a repository and commit them first, or drop --require-git", err)
+		}
+	}
+	return nil
+}
+
+// targetDirs returns the directories cmd rewrites files in, as given by its
+// --dir (or the sqlc config named by --sqlc-config), --glob-base or --models
+// flags or its glob argument (or the --config file standing in for them),
+// falling back to the working directory.
+func targetDirs(cmd *cobra.Command, args []string) []string {
+	var dirs []string
+	if flag := cmd.Flags().Lookup("dir"); flag != nil && flag.Changed {
+		if flag.Value.Type() == "stringSlice" {
+			values, _ := cmd.Flags().GetStringSlice("dir")
+			dirs = append(dirs, values...)
+		} else {
+			dirs = append(dirs, flag.Value.String())
+		}
+	} else if flag := cmd.Flags().Lookup("sqlc-config"); flag != nil && flag.Changed {
+		// an unreadable config fails the command itself
+		if conf, err := sqlcconfig.Load(flag.Value.String()); err == nil {
+			dirs = append(dirs, conf.OutDirs()...)
+		}
+	}
+	models := ""
+	if flag := cmd.Flags().Lookup("models"); flag != nil && flag.Changed {
+		models = flag.Value.String()
+	} else if cmd.Name() == "qualify-models" {
+		models = cfg.QualifyModels.Models
+	}
+	if models != "" {
+		// qualify-models takes a list of models files and globs
+		for _, models := range strings.Split(models, ",") {
+			dirs = append(dirs, globDir(strings.TrimSpace(models)))
+		}
+	}
+	if flag := cmd.Flags().Lookup("glob-base"); flag != nil && flag.Changed {
+		dirs = append(dirs, flag.Value.String())
+	} else if (cmd.Name() == "add-nosec" || cmd.Name() == "remove-nosec" || cmd.Name() == "check-nosec-placement") && len(args) > 0 {
+		dirs = append(dirs, globDir(args[0]))
+	} else if cmd.Name() == "add-nosec" && cfg.AddNosec.Glob != "" {
+		dirs = append(dirs, globDir(cfg.AddNosec.Glob))
+	}
+	if len(dirs) == 0 {
+		dirs = append(dirs, ".")
+	}
+	return dirs
+}
+
+// globDir returns the directory part of pattern preceding its first
+// wildcard.
+func globDir(pattern string) string {
+	if i := strings.IndexAny(pattern, "*?["); i >= 0 {
+		pattern = pattern[:i]
+	}
+	return filepath.Dir(pattern)
+}
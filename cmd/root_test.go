@@ -0,0 +1,515 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/config"
+	"github.com/spf13/pflag"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExitCode(t *testing.T) {
+	// qualifyArgs runs qualify-models on file against the models and go.mod
+	// of qualifyFiles, written next to it.
+	qualifyArgs := func(file string, extra ...string) []string {
+		dir := filepath.Dir(file)
+		return append([]string{"qualify-models", "--models", filepath.Join(dir, "models", "models.go"),
+			"--dir", dir, "--import", "example.com/app/models", "--dry-run"}, extra...)
+	}
+	qualifyFiles := map[string]string{
+		"go.mod":           "module example.com/app\n",
+		"models/models.go": "package models\n\ntype User struct{}\n",
+	}
+	tests := []struct {
+		Name    string
+		Content string
+		// Files are written next to the file, by path relative to it.
+		Files        map[string]string
+		Args         func(file string) []string
+		ExitZero     bool
+		ExpectedCode int
+	}{
+		{
+			Name:         "dry run without pending changes",
+			Content:      "package foo\n\nconst bar = \"x\" // #nosec\n",
+			Args:         func(file string) []string { return []string{"add-nosec", file, "-t", "bar", "--dry-run"} },
+			ExpectedCode: 0,
+		},
+		{
+			Name:         "dry run with pending changes",
+			Content:      "package foo\n\nconst bar = \"x\"\n",
+			Args:         func(file string) []string { return []string{"add-nosec", file, "-t", "bar", "--dry-run"} },
+			ExpectedCode: exitPending,
+		},
+		{
+			Name:         "dry run that errors",
+			Content:      "package foo\n\nconst bar =\n",
+			Args:         func(file string) []string { return []string{"add-nosec", file, "-t", "bar", "--dry-run"} },
+			ExpectedCode: exitError,
+		},
+		{
+			Name:         "verify with pending changes",
+			Content:      "package foo\n\nconst bar = \"x\"\n",
+			Args:         func(file string) []string { return []string{"verify", "--glob", file, "-t", "bar"} },
+			ExpectedCode: exitPending,
+		},
+		{
+			Name:         "exit zero with pending changes",
+			Content:      "package foo\n\nconst bar = \"x\"\n",
+			Args:         func(file string) []string { return []string{"verify", "--glob", file, "-t", "bar", "--exit-zero"} },
+			ExitZero:     true,
+			ExpectedCode: 0,
+		},
+		{
+			Name:    "exit zero that errors",
+			Content: "package foo\n\nconst bar =\n",
+			Args: func(file string) []string {
+				return []string{"add-nosec", file, "-t", "bar", "--dry-run", "--exit-zero"}
+			},
+			ExitZero:     true,
+			ExpectedCode: exitError,
+		},
+		{
+			Name:         "verify that errors",
+			Content:      "package foo\n\nconst bar =\n",
+			Args:         func(file string) []string { return []string{"verify", "--glob", file, "-t", "bar"} },
+			ExpectedCode: exitError,
+		},
+		{
+			Name:         "qualify-models with pending changes",
+			Content:      "package foo\n\nvar U User\n",
+			Files:        qualifyFiles,
+			Args:         func(file string) []string { return qualifyArgs(file) },
+			ExpectedCode: exitPending,
+		},
+		{
+			Name:         "qualify-models that errors",
+			Content:      "package foo\n\nvar U =\n",
+			Files:        qualifyFiles,
+			Args:         func(file string) []string { return qualifyArgs(file) },
+			ExpectedCode: exitError,
+		},
+		{
+			Name:         "qualify-models exit zero with pending changes",
+			Content:      "package foo\n\nvar U User\n",
+			Files:        qualifyFiles,
+			Args:         func(file string) []string { return qualifyArgs(file, "--exit-zero") },
+			ExitZero:     true,
+			ExpectedCode: 0,
+		},
+		{
+			Name:         "remove-nosec with pending changes",
+			Content:      "package foo\n\nconst bar = \"x\" // #nosec\n",
+			Args:         func(file string) []string { return []string{"remove-nosec", file, "-t", "bar", "--dry-run"} },
+			ExpectedCode: exitPending,
+		},
+		{
+			Name:         "remove-nosec that errors",
+			Content:      "package foo\n\nconst bar =\n",
+			Args:         func(file string) []string { return []string{"remove-nosec", file, "-t", "bar", "--dry-run"} },
+			ExpectedCode: exitError,
+		},
+		{
+			Name:    "remove-nosec exit zero with pending changes",
+			Content: "package foo\n\nconst bar = \"x\" // #nosec\n",
+			Args: func(file string) []string {
+				return []string{"remove-nosec", file, "-t", "bar", "--dry-run", "--exit-zero"}
+			},
+			ExitZero:     true,
+			ExpectedCode: 0,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "query.sql.go")
+			if err := os.WriteFile(file, []byte(tc.Content), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			for name, content := range tc.Files {
+				path := filepath.Join(filepath.Dir(file), name)
+				require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+				require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+			}
+			// the code is the one the process exits with
+			_, stderr, code := runCLI(t, tc.Args(file)...)
+			require.Equal(t, tc.ExpectedCode, code, stderr)
+			if tc.ExitZero {
+				require.Contains(t, stderr, "Error: ", "the pending changes must still be reported")
+			}
+			got, err := os.ReadFile(file)
+			require.NoError(t, err)
+			require.Equal(t, tc.Content, string(got), "a dry run must not write")
+		})
+	}
+}
+
+func TestRequireGit(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Git               bool
+		Args              func(dir string) []string
+		ExpectedErrSubStr string
+		ExpectedWrite     bool
+	}{
+		{
+			Name:              "outside a repository",
+			Args:              func(dir string) []string { return []string{"add-nosec", filepath.Join(dir, "*.sql.go"), "-t", "bar"} },
+			ExpectedErrSubStr: "is not inside a git repository: --require-git refuses to rewrite",
+		},
+		{
+			Name:              "directory flag outside a repository",
+			Args:              func(dir string) []string { return []string{"prune-imports", "--dir", dir} },
+			ExpectedErrSubStr: "is not inside a git repository",
+		},
+		{
+			Name:          "inside a repository",
+			Git:           true,
+			Args:          func(dir string) []string { return []string{"add-nosec", filepath.Join(dir, "*.sql.go"), "-t", "bar"} },
+			ExpectedWrite: true,
+		},
+		{
+			Name: "dry run outside a repository",
+			Args: func(dir string) []string {
+				return []string{"add-nosec", filepath.Join(dir, "*.sql.go"), "-t", "bar", "--dry-run"}
+			},
+			ExpectedErrSubStr: "files would change",
+		},
+		{
+			Name: "placement fix outside a repository",
+			Args: func(dir string) []string {
+				return []string{"check-nosec-placement", filepath.Join(dir, "*.sql.go"), "-t", "bar", "--fix"}
+			},
+			ExpectedErrSubStr: "is not inside a git repository",
+		},
+		{
+			Name: "placement check outside a repository",
+			Args: func(dir string) []string {
+				return []string{"check-nosec-placement", filepath.Join(dir, "*.sql.go"), "-t", "bar"}
+			},
+			ExpectedErrSubStr: "found 1 drifted #nosec suppressions",
+		},
+	}
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		requireGit, addDryRun, placementFix = false, false, false
+	}()
+	content := "package foo\n\nconst bar = \"x\"\n"
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			requireGit, addDryRun, placementFix = false, false, false
+			dir := t.TempDir()
+			file := filepath.Join(dir, "query.sql.go")
+			if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			if tc.Git {
+				if err := os.Mkdir(filepath.Join(dir, ".git"), 0755); err != nil {
+					t.Fatalf("failed to create .git: %v", err)
+				}
+			}
+			rootCmd.SetArgs(append(tc.Args(dir), "--require-git"))
+			err := rootCmd.Execute()
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+			} else {
+				require.NoError(t, err)
+			}
+			got, err := os.ReadFile(file)
+			require.NoError(t, err)
+			if tc.ExpectedWrite {
+				require.Equal(t, "package foo\n\nconst bar = \"x\" // #nosec\n", string(got))
+			} else {
+				require.Equal(t, content, string(got))
+			}
+		})
+	}
+}
+
+func TestConfigFile(t *testing.T) {
+	dir := t.TempDir()
+	queryFile := filepath.Join(dir, "internal", "database", "query.sql.go")
+	files := map[string]string{
+		"go.mod":                         "module example.com/app\n",
+		"internal/models/models.go":      "package models\n\ntype User struct{}\n",
+		"internal/database/query.sql.go": "package database\n\nconst bar = \"x\"\n\nvar U User\n",
+		".sqlc-qol.yaml": `add_nosec:
+  glob: ` + filepath.Join(dir, "internal/database/*.sql.go") + `
+  targets: bar
+qualify_models:
+  models: ` + filepath.Join(dir, "internal/models/models.go") + `
+  import: example.com/app/internal/models
+`,
+		"other/other.go": "package other\n\nconst bar, baz = \"x\", \"y\"\n",
+		"bad.yaml":       "add_nosec:\n  globs: x\n",
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	}
+
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	reset := func() {
+		resetFlags(t, "add-nosec", "qualify-models")
+		addTargets, addCSV, modelFilePath, rootDbDirs, importPath, qualifySqlcConfig = "", "", "", nil, "", ""
+	}
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		reset()
+	}()
+	configFile := filepath.Join(dir, ".sqlc-qol.yaml")
+
+	reset()
+	rootCmd.SetArgs([]string{"add-nosec", "--config", configFile})
+	require.NoError(t, rootCmd.Execute())
+	reset()
+	rootCmd.SetArgs([]string{"qualify-models", "--config", configFile, "--dir", filepath.Dir(queryFile)})
+	require.NoError(t, rootCmd.Execute())
+	got, err := os.ReadFile(queryFile)
+	require.NoError(t, err)
+	require.Equal(t, "package database\n\nimport \"example.com/app/internal/models\"\n\nconst bar = \"x\" // #nosec\n\nvar U models.User\n", string(got))
+
+	// the argument and flags override the config file
+	reset()
+	rootCmd.SetArgs([]string{"add-nosec", "--config", configFile, filepath.Join(dir, "other", "*.go"), "-t", "baz"})
+	require.NoError(t, rootCmd.Execute())
+	got, err = os.ReadFile(filepath.Join(dir, "other", "other.go"))
+	require.NoError(t, err)
+	require.Equal(t, "package other\n\nconst bar, baz = \"x\", \"y\" // #nosec\n", string(got))
+
+	reset()
+	rootCmd.SetArgs([]string{"add-nosec", "--config", filepath.Join(dir, "bad.yaml")})
+	require.ErrorContains(t, rootCmd.Execute(), "field globs not found")
+
+	reset()
+	rootCmd.SetArgs([]string{"add-nosec", "--config", filepath.Join(dir, "missing.yaml")})
+	require.ErrorContains(t, rootCmd.Execute(), "no such file or directory")
+
+	reset()
+	rootCmd.SetArgs([]string{"add-nosec", "-t", "bar"})
+	require.ErrorContains(t, rootCmd.Execute(), "requires the glob pattern argument")
+}
+
+func TestAllowedBaseDir(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	queryFile := filepath.Join(dir, "query.sql.go")
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "lists"), 0755))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "other"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "lists", "targets.csv"), []byte("bar\n"), 0644))
+
+	rootCmd.SetOut(io.Discard)
+	rootCmd.SetErr(io.Discard)
+	defer func() {
+		rootCmd.SetOut(nil)
+		rootCmd.SetErr(nil)
+		resetFlags(t, "add-nosec")
+		addCSV = ""
+	}()
+	tests := []struct {
+		Name              string
+		Args              []string
+		ExpectedErrSubStr string
+	}{
+		{Name: "default is the working directory"},
+		{Name: "relative flag", Args: []string{"--allowed-base-dir", "lists"}},
+		{Name: "working directory", Args: []string{"--allowed-base-dir", "."}},
+		{Name: "csv outside the dir", Args: []string{"--allowed-base-dir", "other"}, ExpectedErrSubStr: "is not within the allowed directory \"" + filepath.Join(dir, "other") + "\""},
+		{Name: "missing dir", Args: []string{"--allowed-base-dir", "missing"}, ExpectedErrSubStr: "no such file or directory"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			resetFlags(t, "add-nosec")
+			require.NoError(t, os.WriteFile(queryFile, []byte("package foo\n\nconst bar = \"x\"\n"), 0644))
+			rootCmd.SetArgs(append([]string{"add-nosec", queryFile, "--csv", filepath.Join("lists", "targets.csv")}, tc.Args...))
+			err := rootCmd.Execute()
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			got, err := os.ReadFile(queryFile)
+			require.NoError(t, err)
+			require.Equal(t, "package foo\n\nconst bar = \"x\" // #nosec\n", string(got))
+		})
+	}
+}
+
+// resetFlags marks the root flags and those of the named commands as not
+// given, since flags parsed by an earlier Execute call stay changed, and
+// restores the config they fill.
+func resetFlags(t *testing.T, commands ...string) {
+	t.Helper()
+	rootCmd.PersistentFlags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
+	for _, name := range commands {
+		sub, _, err := rootCmd.Find([]string{name})
+		require.NoError(t, err)
+		sub.Flags().VisitAll(func(f *pflag.Flag) { f.Changed = false })
+	}
+	cfg, configPath, allowedBaseDir = config.Default(), config.FileName, config.Default().AllowedBaseDir
+}
+
+// executeEnv names the environment variable holding the newline-separated
+// arguments runCLI passes to Execute in a re-run of the test binary.
+const executeEnv = "SQLC_QOL_EXECUTE_ARGS"
+
+// runCLI runs Execute with args in a child process, as the binary would be
+// run, and returns what it wrote to stdout and stderr and its exit code.
+func runCLI(t *testing.T, args ...string) (string, string, int) {
+	t.Helper()
+	child := exec.Command(os.Args[0], "-test.run=^TestExecuteChild$")
+	child.Env = append(os.Environ(), executeEnv+"="+strings.Join(args, "\n"))
+	var stdout, stderr bytes.Buffer
+	child.Stdout, child.Stderr = &stdout, &stderr
+	err := child.Run()
+	code := 0
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		code = exitErr.ExitCode()
+	} else {
+		require.NoError(t, err)
+	}
+	return stdout.String(), stderr.String(), code
+}
+
+// TestExecuteChild is the child process of runCLI.
+func TestExecuteChild(t *testing.T) {
+	args, ok := os.LookupEnv(executeEnv)
+	if !ok {
+		t.Skip("only run by runCLI")
+	}
+	rootCmd.SetArgs(strings.Split(args, "\n"))
+	Execute()
+	os.Exit(0)
+}
+
+func TestExecuteOutput(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "query.sql.go")
+	if err := os.WriteFile(file, []byte("package foo\n\nconst bar = \"x\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	_, stderr, code := runCLI(t, "add-nosec", file, "-t", "bar", "--dry-run")
+	require.Equal(t, exitPending, code)
+	require.Equal(t, "Error: add-nosec: 1 files would change:\n  "+file+"\n", stderr,
+		"a pending run should report its error once, without the usage text")
+
+	_, stderr, code = runCLI(t, "add-nosec", file, "--no-such-flag")
+	require.Equal(t, exitError, code)
+	require.Equal(t, "Error: unknown flag: --no-such-flag\n", stderr)
+}
+
+func TestDryRunDiffOutput(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "query.sql.go")
+	if err := os.WriteFile(file, []byte("package foo\n\nconst bar = \"x\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	stdout, stderr, code := runCLI(t, "add-nosec", file, "-t", "bar", "--dry-run")
+	require.Equal(t, exitPending, code)
+	require.Equal(t, "--- "+file+"\n+++ "+file+`
+@@ -1,3 +1,3 @@
+ package foo
+ 
+-const bar = "x"
++const bar = "x" // #nosec
+1 files processed, 1 targets tagged, 0 skipped
+`, stdout, "the diff should be followed by the summary only")
+	require.Equal(t, "Error: add-nosec: 1 files would change:\n  "+file+"\n", stderr)
+}
+
+func TestValidateConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "query.sql.go")
+	if err := os.WriteFile(file, []byte("package foo\n\nconst bar = \"x\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	missing := filepath.Join(dir, "missing")
+	tests := []struct {
+		Name             string
+		Args             []string
+		ExpectedErrLines []string
+	}{
+		{
+			Name:             "every problem reported at once",
+			Args:             []string{"add-nosec", file, "-t", "bar", "--csv", "targets.csv", "--allowed-base-dir", missing},
+			ExpectedErrLines: []string{"no such file or directory", "are mutually exclusive"},
+		},
+		{
+			Name:             "targets flags of another command",
+			Args:             []string{"remove-nosec", file, "-t", "bar", "--csv-dir", dir},
+			ExpectedErrLines: []string{"are mutually exclusive"},
+		},
+		{
+			Name:             "alias",
+			Args:             []string{"qualify-models", "--models", file, "--dir", dir, "--import", "x/models", "--alias", "db-models", "--replace-alias", "db"},
+			ExpectedErrLines: []string{`invalid alias "db-models"`, `invalid replace-alias "db"`},
+		},
+		{
+			Name: "base dir unchecked without csv",
+			Args: []string{"add-nosec", file, "-t", "bar", "--allowed-base-dir", missing, "--dry-run"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			_, stderr, code := runCLI(t, tc.Args...)
+			if len(tc.ExpectedErrLines) == 0 {
+				require.NotContains(t, stderr, "allowed base dir")
+				return
+			}
+			require.Equal(t, exitError, code)
+			for _, line := range tc.ExpectedErrLines {
+				require.Contains(t, stderr, line)
+			}
+		})
+	}
+}
+
+func TestOutputFlagsScope(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "query.sql.go")
+	if err := os.WriteFile(file, []byte("package foo\n\nconst bar = \"x\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	zipPath := filepath.Join(t.TempDir(), "out.zip")
+
+	_, stderr, code := runCLI(t, "remove-nosec", file, "-t", "bar", "--zip-out", zipPath)
+	require.Equal(t, exitError, code)
+	require.Equal(t, "Error: unknown flag: --zip-out\n", stderr, "commands that don't archive must reject --zip-out")
+
+	_, stderr, code = runCLI(t, "check-nosec-placement", file, "-t", "bar", "--audit-log", filepath.Join(t.TempDir(), "audit.jsonl"))
+	require.Equal(t, exitError, code)
+	require.Equal(t, "Error: unknown flag: --audit-log\n", stderr, "commands that record no changes must reject --audit-log")
+
+	_, _, code = runCLI(t, "add-nosec", file, "-t", "bar", "--dry-run", "--zip-out", zipPath)
+	require.Equal(t, exitPending, code)
+	require.FileExists(t, zipPath)
+}
+
+func TestConfigFileScope(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	require.NoError(t, os.WriteFile(config.FileName, []byte("add_nosec:\n  globs: x\n"), 0644))
+	require.NoError(t, os.WriteFile("query.sql.go", []byte("package foo\n\nconst bar = \"x\" // #nosec\n"), 0644))
+
+	_, stderr, code := runCLI(t, "version")
+	require.Equal(t, 0, code, stderr)
+	stdout, stderr, code := runCLI(t, "inventory-nosec", "--dir", dir)
+	require.Equal(t, 0, code, stderr)
+	require.Contains(t, stdout, "query.sql.go")
+
+	_, stderr, code = runCLI(t, "add-nosec", "query.sql.go", "-t", "bar", "--dry-run")
+	require.Equal(t, exitError, code)
+	require.Contains(t, stderr, "field globs not found", "the commands reading the config must still reject a broken one")
+}
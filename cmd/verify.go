@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/addnosec"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pending"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/qualifymodels"
+	"github.com/spf13/cobra"
+)
+
+var (
+	verifyGlob    string
+	verifyTargets string
+	verifyCSV     string
+	verifyCSVDir  string
+
+	verifyModels string
+	verifyDirs   []string
+	verifyImport string
+)
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "verify",
+		Short: "Confirm SQLC generated code is already fully post-processed",
+		Long: `Runs add-nosec and qualify-models in check mode, writing nothing, and exits 0 only if
+neither would change a file. add-nosec is checked when --glob is given and qualify-models
+when --models is given; the files each would change are listed otherwise and the command
+exits 2.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if verifyGlob == "" && verifyModels == "" {
+				return errors.New("nothing to verify: pass --glob for add-nosec and/or --models for qualify-models")
+			}
+			files := make(map[string]bool)
+			var pendingErrs []error
+			report := func(err error) error {
+				var pendingErr *pending.Error
+				if !errors.As(err, &pendingErr) {
+					return err
+				}
+				pendingErrs = append(pendingErrs, pendingErr)
+				for _, file := range pendingErr.Files {
+					files[file] = true
+				}
+				return nil
+			}
+			if verifyGlob != "" {
+				err := addnosec.Run(verifyGlob, verifyTargets, verifyCSV, cfg, addnosec.Options{CSVDir: verifyCSVDir, Check: true})
+				if err := report(err); err != nil {
+					return err
+				}
+			}
+			if verifyModels != "" {
+				err := qualifymodels.Run(verifyModels, verifyDirs, verifyImport, qualifymodels.Options{Check: true})
+				if err := report(err); err != nil {
+					return err
+				}
+			}
+			if len(files) > 0 {
+				// wrapping the pending errors keeps the pending-changes exit code
+				return fmt.Errorf("generated code is not fully post-processed: %d files would change\n%w", len(files), errors.Join(pendingErrs...))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().
+		StringVar(&verifyGlob,
+			"glob",
+			"",
+			"glob pattern of the files add-nosec processes (e.g. internal/database/*.sql.go)")
+
+	cmd.Flags().
+		StringVarP(&verifyTargets,
+			"targets", "t",
+			"",
+			"comma-separated list of add-nosec target consts")
+
+	cmd.Flags().
+		StringVarP(&verifyCSV,
+			"csv",
+			"c",
+			"",
+			"path to CSV file containing add-nosec target consts (no headers)")
+
+	cmd.Flags().
+		StringVar(&verifyCSVDir,
+			"csv-dir",
+			"",
+			"path to a directory of CSV files containing add-nosec target consts")
+
+	cmd.Flags().
+		StringVarP(&verifyModels,
+			"models",
+			"m",
+			"",
+			"path to the Go source file defining your models, for qualify-models")
+
+	cmd.Flags().
+		StringSliceVarP(&verifyDirs,
+			"dir",
+			"d",
+			nil,
+			"root directory qualify-models processes (e.g. internal/database); repeatable")
+
+	cmd.Flags().
+		StringVarP(&verifyImport,
+			"import",
+			"i",
+			"",
+			"import path for your models package, for qualify-models")
+
+	cmd.MarkFlagsRequiredTogether("models", "dir", "import")
+	_ = cmd.MarkFlagFilename("csv", "csv")
+	_ = cmd.MarkFlagDirname("csv-dir")
+
+	rootCmd.AddCommand(cmd)
+}
@@ -0,0 +1,39 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+// Build metadata, set when linking a release:
+//
+//	go build -ldflags "-X github.com/seanhuebl/sqlc-qol/v2/cmd.version=v2.1.0 \
+//	  -X github.com/seanhuebl/sqlc-qol/v2/cmd.commit=$(git rev-parse --short HEAD) \
+//	  -X github.com/seanhuebl/sqlc-qol/v2/cmd.date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+// versionString returns the line the version command and --version print.
+func versionString(version, commit, date string) string {
+	return fmt.Sprintf("sqlc-qol %s (commit %s, built %s)", version, commit, date)
+}
+
+func init() {
+	cmd := &cobra.Command{
+		Use:   "version",
+		Short: "Print the version, git commit and build date of this binary",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Fprintln(cmd.OutOrStdout(), versionString(version, commit, date))
+		},
+	}
+
+	rootCmd.Version = versionString(version, commit, date)
+	rootCmd.SetVersionTemplate("{{.Version}}\n")
+
+	rootCmd.AddCommand(cmd)
+}
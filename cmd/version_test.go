@@ -0,0 +1,25 @@
+package cmd
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestVersionString(t *testing.T) {
+	require.Equal(t, "sqlc-qol dev (commit none, built unknown)", versionString(version, commit, date))
+	require.Equal(t, "sqlc-qol v2.1.0 (commit 3d88345, built 2026-10-14T09:00:00Z)", versionString("v2.1.0", "3d88345", "2026-10-14T09:00:00Z"))
+}
+
+func TestVersion(t *testing.T) {
+	var out bytes.Buffer
+	rootCmd.SetOut(&out)
+	defer rootCmd.SetOut(nil)
+	for _, args := range [][]string{{"version"}, {"--version"}} {
+		out.Reset()
+		rootCmd.SetArgs(args)
+		require.NoError(t, rootCmd.Execute())
+		require.Equal(t, "sqlc-qol dev (commit none, built unknown)\n", out.String(), "%v", args)
+	}
+}
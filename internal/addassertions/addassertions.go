@@ -0,0 +1,215 @@
+package addassertions
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+var (
+	readFile   = os.ReadFile
+	readDir    = os.ReadDir
+	createFile = os.Create
+
+	stdout io.Writer = os.Stdout
+)
+
+// Run type-checks the package declaring the models file at modelPath and
+// appends a compile-time assertion `var _ Iface = (*Model)(nil)` to the
+// models file for every struct declared in it that implements iface (with a
+// value or pointer receiver). Models that don't implement it are skipped
+// and reported, and assertions already present aren't duplicated, so the
+// command is idempotent.
+//
+// iface is either the name of an interface declared in the models package
+// (e.g. Validator) or an import path followed by the interface name (e.g.
+// fmt.Stringer or database/sql/driver.Valuer); the import is added when
+// needed.
+//
+// Returns an error if the package doesn't type-check, iface can't be
+// resolved to an interface, or the models file can't be written.
+func Run(modelPath, iface string) error {
+	fset := token.NewFileSet()
+	dir := filepath.Dir(modelPath)
+	files, modelFile, err := parsePackage(fset, dir, modelPath)
+	if err != nil {
+		return err
+	}
+
+	imp := importer.ForCompiler(fset, "source", nil).(types.ImporterFrom)
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	conf := types.Config{Importer: imp}
+	pkg, err := conf.Check(modelFile.Name.Name, fset, files, nil)
+	if err != nil {
+		return fmt.Errorf("failed to type-check models package %s: %w", dir, err)
+	}
+
+	// Resolve the interface and how the models file refers to it.
+	ifacePath, ifaceName := "", iface
+	scope := pkg.Scope()
+	if i := strings.LastIndex(iface, "."); i >= 0 {
+		ifacePath, ifaceName = iface[:i], iface[i+1:]
+		ifacePkg, err := imp.ImportFrom(ifacePath, absDir, 0)
+		if err != nil {
+			return fmt.Errorf("failed to import %s: %w", ifacePath, err)
+		}
+		scope = ifacePkg.Scope()
+	}
+	obj, ok := scope.Lookup(ifaceName).(*types.TypeName)
+	if !ok {
+		return fmt.Errorf("interface %s not found", iface)
+	}
+	ifaceType, ok := obj.Type().Underlying().(*types.Interface)
+	if !ok {
+		return fmt.Errorf("%s is not an interface", iface)
+	}
+	ifaceExpr := ifaceName
+	if ifacePath != "" {
+		ifaceExpr = importName(modelFile, ifacePath, obj.Pkg().Name()) + "." + ifaceName
+	}
+
+	existing := existingAssertions(modelFile)
+	var assertions []string
+	for _, decl := range modelFile.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok || typeSpec.TypeParams != nil {
+				continue
+			}
+			if _, ok := typeSpec.Type.(*ast.StructType); !ok {
+				continue
+			}
+			model := pkg.Scope().Lookup(typeSpec.Name.Name)
+			if !types.Implements(types.NewPointer(model.Type()), ifaceType) {
+				fmt.Fprintf(stdout, "skipping %s: does not implement %s\n", typeSpec.Name.Name, iface)
+				continue
+			}
+			assertion := fmt.Sprintf("%s = (*%s)(nil)", ifaceExpr, typeSpec.Name.Name)
+			if !existing[assertion] {
+				assertions = append(assertions, assertion)
+			}
+		}
+	}
+	if len(assertions) == 0 {
+		return nil
+	}
+	return appendAssertions(modelPath, ifacePath, ifaceExpr, assertions)
+}
+
+// parsePackage parses the non-test .go files of dir that belong to the same
+// package as modelPath, returning them along with modelPath's file.
+func parsePackage(fset *token.FileSet, dir, modelPath string) ([]*ast.File, *ast.File, error) {
+	modelFile, err := parser.ParseFile(fset, modelPath, nil, parser.ParseComments)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to parse model file: %w", err)
+	}
+	files := []*ast.File{modelFile}
+	entries, err := readDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read model directory %s: %w", dir, err)
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		file := filepath.Join(dir, name)
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || filepath.Clean(file) == filepath.Clean(modelPath) {
+			continue
+		}
+		f, err := parser.ParseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		if f.Name.Name == modelFile.Name.Name {
+			files = append(files, f)
+		}
+	}
+	return files, modelFile, nil
+}
+
+// importName returns the name f uses for importPath, or pkgName if f doesn't
+// import it yet.
+func importName(f *ast.File, importPath, pkgName string) string {
+	for _, spec := range f.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p == importPath {
+			if spec.Name != nil {
+				return spec.Name.Name
+			}
+			return pkgName
+		}
+	}
+	return pkgName
+}
+
+// existingAssertions returns the `Iface = value` text of every blank
+// variable declaration in f.
+func existingAssertions(f *ast.File) map[string]bool {
+	existing := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		spec, ok := n.(*ast.ValueSpec)
+		if !ok || spec.Type == nil || len(spec.Names) != 1 || spec.Names[0].Name != "_" || len(spec.Values) != 1 {
+			return true
+		}
+		existing[types.ExprString(spec.Type)+" = "+types.ExprString(spec.Values[0])] = true
+		return false
+	})
+	return existing
+}
+
+// appendAssertions appends the assertions to the models file, adding the
+// interface's import when it comes from another package.
+func appendAssertions(modelPath, ifacePath, ifaceExpr string, assertions []string) error {
+	src, err := readFile(modelPath) // #nosec G304 -- the models file was just parsed from this path
+	if err != nil {
+		return fmt.Errorf("failed to read model file: %w", err)
+	}
+	var buf bytes.Buffer
+	buf.Write(src)
+	buf.WriteString("\n// Compile-time interface assertions.\nvar (\n")
+	for _, assertion := range assertions {
+		fmt.Fprintf(&buf, "\t_ %s\n", assertion)
+	}
+	buf.WriteString(")\n")
+
+	fset := token.NewFileSet()
+	f, err := parser.ParseFile(fset, modelPath, buf.Bytes(), parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse updated model file: %w", err)
+	}
+	if ifacePath != "" {
+		name, _, _ := strings.Cut(ifaceExpr, ".")
+		if name == path.Base(ifacePath) {
+			astutil.AddImport(fset, f, ifacePath)
+		} else {
+			astutil.AddNamedImport(fset, f, name, ifacePath)
+		}
+	}
+
+	outFile, err := createFile(modelPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s for writing: %w", modelPath, err)
+	}
+	defer outFile.Close()
+	if err := format.Node(outFile, fset, f); err != nil {
+		return fmt.Errorf("failed to write updated file %s: %w", modelPath, err)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+package addassertions
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		InitContent    string
+		Iface          string
+		ExpectedOutput string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "local interface, value and pointer receivers",
+				ExpectedContent: `package models
+
+type Validator interface {
+	Validate() error
+}
+
+type User struct{}
+
+func (u *User) Validate() error { return nil }
+
+type Post struct{}
+
+func (p Post) Validate() error { return nil }
+
+type Tag struct{}
+
+// Compile-time interface assertions.
+var (
+	_ Validator = (*User)(nil)
+	_ Validator = (*Post)(nil)
+)
+`,
+			},
+			InitContent: `package models
+
+type Validator interface {
+	Validate() error
+}
+
+type User struct{}
+
+func (u *User) Validate() error { return nil }
+
+type Post struct{}
+
+func (p Post) Validate() error { return nil }
+
+type Tag struct{}
+`,
+			Iface:          "Validator",
+			ExpectedOutput: "skipping Tag: does not implement Validator\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "imported interface, existing assertion kept",
+				ExpectedContent: `package models
+
+import "fmt"
+
+type User struct{}
+
+func (u User) String() string { return "user" }
+
+type Post struct{}
+
+func (p Post) String() string { return "post" }
+
+var _ fmt.Stringer = (*User)(nil)
+
+// Compile-time interface assertions.
+var (
+	_ fmt.Stringer = (*Post)(nil)
+)
+`,
+			},
+			InitContent: `package models
+
+import "fmt"
+
+type User struct{}
+
+func (u User) String() string { return "user" }
+
+type Post struct{}
+
+func (p Post) String() string { return "post" }
+
+var _ fmt.Stringer = (*User)(nil)
+`,
+			Iface: "fmt.Stringer",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "import added for interface package",
+				ExpectedContent: `package models
+
+import "database/sql/driver"
+
+type Status struct{}
+
+func (s Status) Value() (driver.Value, error) { return nil, nil }
+
+// Compile-time interface assertions.
+var (
+	_ driver.Valuer = (*Status)(nil)
+)
+`,
+			},
+			InitContent: `package models
+
+import "database/sql/driver"
+
+type Status struct{}
+
+func (s Status) Value() (driver.Value, error) { return nil, nil }
+`,
+			Iface: "database/sql/driver.Valuer",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "missing import is added",
+				ExpectedContent: `package models
+
+import "fmt"
+
+type User struct{}
+
+func (u User) String() string { return "user" }
+
+// Compile-time interface assertions.
+var (
+	_ fmt.Stringer = (*User)(nil)
+)
+`,
+			},
+			InitContent: `package models
+
+type User struct{}
+
+func (u User) String() string { return "user" }
+`,
+			Iface: "fmt.Stringer",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "not an interface",
+				ExpectedErrSubStr: "User is not an interface",
+			},
+			InitContent: "package models\n\ntype User struct{}\n",
+			Iface:       "User",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "interface not found",
+				ExpectedErrSubStr: "interface Missing not found",
+			},
+			InitContent: "package models\n\ntype User struct{}\n",
+			Iface:       "Missing",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			var out bytes.Buffer
+			stdout = &out
+			defer func() { stdout = os.Stdout }()
+
+			modelPath := filepath.Join(t.TempDir(), "models.go")
+			if err := os.WriteFile(modelPath, []byte(tc.InitContent), 0644); err != nil {
+				t.Fatalf("failed to write model file: %v", err)
+			}
+			err := Run(modelPath, tc.Iface)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			got, err := os.ReadFile(modelPath)
+			require.NoError(t, err)
+			if diff := cmp.Diff(tc.ExpectedContent, string(got)); diff != "" {
+				t.Errorf("model file mismatch (-want +got):\n%s", diff)
+			}
+			require.Equal(t, tc.ExpectedOutput, out.String())
+
+			// a second run is a no-op
+			require.NoError(t, Run(modelPath, tc.Iface))
+			again, err := os.ReadFile(modelPath)
+			require.NoError(t, err)
+			require.Equal(t, string(got), string(again))
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package addcoverageignore
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/directives"
+)
+
+var (
+	parseFile  = parser.ParseFile
+	readFile   = os.ReadFile
+	createFile = os.Create
+	walkDir    = filepath.WalkDir
+
+	stdout io.Writer = os.Stdout
+)
+
+// generatedHeader matches the standard generated-code header line.
+var generatedHeader = regexp.MustCompile(`^// Code generated .* DO NOT EDIT\.$`)
+
+// Run inserts the directive comment (e.g. `// coverage:ignore file`) into
+// every generated .go file under rootDir, as its own comment right below the
+// `// Code generated ... DO NOT EDIT.` header. A directive without a leading
+// `//` is given one. The rest of the file, including the header and the
+// package clause, is left byte-for-byte untouched, and files that already
+// carry the directive above their package clause, aren't generated, or carry
+// the sqlc-qol:skip directive are skipped, so the command is idempotent.
+//
+// Returns an error if directive is empty, the walk fails, or any file can't
+// be parsed or written.
+func Run(rootDir, directive string) error {
+	directive = strings.TrimSpace(directive)
+	if directive == "" {
+		return fmt.Errorf("a directive is required")
+	}
+	if !strings.HasPrefix(directive, "//") {
+		directive = "// " + directive
+	}
+
+	var files []string
+	if err := walkDir(rootDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to walkDir %s: %w", rootDir, err)
+	}
+
+	for _, file := range files {
+		fset := token.NewFileSet()
+		f, err := parseFile(fset, file, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		if directives.SkipFile(f) {
+			fmt.Fprintf(stdout, "%s: skipped by %s directive\n", file, directives.Skip)
+			continue
+		}
+		header := headerGroup(f)
+		if header == nil || hasDirective(f, directive) {
+			continue
+		}
+		if err := insertDirective(file, fset.Position(header.End()).Offset, directive); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// headerGroup returns the comment group above f's package clause holding
+// the generated-code header, or nil if f isn't generated.
+func headerGroup(f *ast.File) *ast.CommentGroup {
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, cm := range cg.List {
+			if generatedHeader.MatchString(cm.Text) {
+				return cg
+			}
+		}
+	}
+	return nil
+}
+
+// hasDirective reports whether a comment above f's package clause is the
+// directive, ignoring surrounding whitespace.
+func hasDirective(f *ast.File, directive string) bool {
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		for _, cm := range cg.List {
+			if strings.TrimSpace(cm.Text) == directive {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// insertDirective rewrites file with the directive inserted as its own
+// paragraph at offset, the end of the header comment group.
+func insertDirective(file string, offset int, directive string) error {
+	src, err := readFile(file) // #nosec G304 -- the file was just parsed from this path
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", file, err)
+	}
+	rest := src[offset:]
+	sep := "\n"
+	if strings.HasPrefix(string(rest), "\n\n") {
+		sep = ""
+	}
+
+	outFile, err := createFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s for writing: %w", file, err)
+	}
+	defer outFile.Close()
+	if _, err := fmt.Fprintf(outFile, "%s\n\n%s%s%s", src[:offset], directive, sep, rest); err != nil {
+		return fmt.Errorf("failed to write updated file %s: %w", file, err)
+	}
+	return nil
+}
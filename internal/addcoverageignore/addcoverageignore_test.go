@@ -0,0 +1,137 @@
+package addcoverageignore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		InitContent string
+		Directive   string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "inserted below sqlc header",
+				ExpectedContent: `// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: users.sql
+
+// coverage:ignore file
+
+package database
+
+const getUser = "SELECT 1"
+`,
+			},
+			InitContent: `// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: users.sql
+
+package database
+
+const getUser = "SELECT 1"
+`,
+			Directive: "coverage:ignore file",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "header doubling as package doc",
+				ExpectedContent: `// Code generated by sqlc. DO NOT EDIT.
+
+//coverage:ignore
+
+package database
+`,
+			},
+			InitContent: `// Code generated by sqlc. DO NOT EDIT.
+package database
+`,
+			Directive: "//coverage:ignore",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "already annotated",
+				ExpectedContent: `// Code generated by sqlc. DO NOT EDIT.
+
+// coverage:ignore file
+package database
+`,
+			},
+			InitContent: `// Code generated by sqlc. DO NOT EDIT.
+
+// coverage:ignore file
+package database
+`,
+			Directive: "// coverage:ignore file",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:            "hand-written file untouched",
+				ExpectedContent: "// Package database wraps the queries.\npackage database\n",
+			},
+			InitContent: "// Package database wraps the queries.\npackage database\n",
+			Directive:   "coverage:ignore file",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "missing directive",
+				ExpectedErrSubStr: "a directive is required",
+			},
+			InitContent: "package database\n",
+			Directive:   "  ",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "query.sql.go")
+			if err := os.WriteFile(file, []byte(tc.InitContent), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			err := Run(dir, tc.Directive)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			// a second run changes nothing
+			require.NoError(t, Run(dir, tc.Directive))
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if diff := cmp.Diff(tc.ExpectedContent, string(got)); diff != "" {
+				t.Errorf("content mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestRunSkipDirective(t *testing.T) {
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "query.sql.go")
+	content := "// Code generated by sqlc. DO NOT EDIT.\n// sqlc-qol:skip\n\npackage database\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+	require.NoError(t, Run(dir, "coverage:ignore file"))
+	require.Equal(t, file+": skipped by sqlc-qol:skip directive\n", out.String())
+	got, err := os.ReadFile(file)
+	require.NoError(t, err)
+	require.Equal(t, content, string(got))
+}
@@ -1,137 +1,1354 @@
 package addnosec
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"encoding/csv"
 	"fmt"
 	"go/ast"
 	"go/format"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 
+	"github.com/seanhuebl/sqlc-qol/v2/internal/archive"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/audit"
 	"github.com/seanhuebl/sqlc-qol/v2/internal/config"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/directives"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/globstar"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/incremental"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pending"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pipeline"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/sizereport"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/unifieddiff"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/writable"
 	"golang.org/x/tools/go/ast/astutil"
 )
 
 var (
 	parseFile  = parser.ParseFile
-	glob       = filepath.Glob
+	glob       = globstar.Glob
 	createFile = os.Create
 	formatNode = format.Node
+	readFile   = os.ReadFile
+
+	stdin  io.Reader = os.Stdin
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
 
 	openFile  = os.Open
+	readDir   = os.ReadDir
+	stat      = os.Stat
 	pathAbs   = filepath.Abs
 	baseAbs   = filepath.Abs
-	hasPrefix = strings.HasPrefix
+	withinDir = isWithinDir
+	getwd     = os.Getwd
+)
+
+// DefaultComment is the suppression comment added to targets that don't
+// configure their own.
+const DefaultComment = "// #nosec"
+
+// StdinPath, passed as the targets or the CSV path, reads them from stdin:
+// names separated by commas or newlines, or CSV records.
+const StdinPath = "-"
+
+// DefaultTabWidth is the tab width used when Options.TabWidth is unset.
+const DefaultTabWidth = 4
+
+// Modes for Options.GroupMode.
+const (
+	GroupPerSpec = "per-spec"
+	GroupShared  = "shared"
+	GroupAuto    = "auto"
 )
 
+// Placements for Options.TrailingComment.
+const (
+	TrailingAbove  = "above"
+	TrailingAppend = "append"
+)
+
+// Declaration kinds for Options.DeclKinds.
+const (
+	KindConst = "const"
+	KindVar   = "var"
+	KindField = "field"
+)
+
+// DefaultDeclKinds are the declaration kinds tagged when Options.DeclKinds
+// is empty.
+var DefaultDeclKinds = []string{KindConst, KindVar}
+
+// Hook is a custom AST transform run on every processed file after the
+// built-in transform and before the file is formatted and written; see
+// pipeline.Hook.
+type Hook = pipeline.Hook
+
+// Options holds the optional settings for Run. The zero value keeps the
+// default behavior.
+type Options struct {
+	// CSVDir is a directory whose *.csv files are all read and merged into
+	// the target set (mutually exclusive with targets and csvPath).
+	CSVDir string
+	// CSVHasHeader skips the first record of every targets CSV as a header.
+	CSVHasHeader bool
+	// CSVColumn, when set, reads target names from one column of the
+	// targets CSVs only: the header cell with this name (requires
+	// CSVHasHeader), or else the column at this 1-based index. Other cells
+	// are ignored unless they hold a `//` comment.
+	CSVColumn string
+	// GlobBase, when set, is a directory the query glob is resolved against.
+	// Reported file paths are then shown relative to it.
+	GlobBase string
+	// ConstType, when set, only tags specs explicitly declared with this type
+	// (e.g. APIKey or auth.APIKey). Specs without an explicit type are skipped.
+	ConstType string
+	// DeclKinds lists the kinds of declaration a target name is matched
+	// against: KindConst and KindVar specs, wherever they are declared,
+	// and KindField keys of composite literals (`Config{Password: "..."}`),
+	// whose line gets a trailing comment. Empty means DefaultDeclKinds.
+	DeclKinds []string
+	// CaseInsensitive matches target names regardless of case. This can
+	// over-match when generated identifiers differ only by case.
+	CaseInsensitive bool
+	// ShowChanges prints a `file:line: const Name -> // #nosec` line for
+	// every spec tagged, followed by the total count.
+	ShowChanges bool
+	// RespectDirectives lists linter directives that already suppress gosec,
+	// of the form name[:linter] (e.g. nolint:gosec). A target spec carrying
+	// one in its comments, or in its declaration's doc comment, is left
+	// untagged and counted as already suppressed. A directive with no linter
+	// list (a bare //nolint) suppresses every linter and matches as well.
+	RespectDirectives []string
+	// PrintTargets prints the resolved target set, sorted by name, before
+	// processing: one name per line, followed by its comment when a CSV row
+	// configures one other than DefaultComment.
+	PrintTargets bool `json:"-"`
+	// NormalizeNosec rewrites existing #nosec comments on matched specs to
+	// the canonical `// #nosec [rules] [-- reason]` spacing and drops
+	// duplicates within the same comment group.
+	NormalizeNosec bool
+	// Rules are gosec rule IDs (e.g. G101) the added comments suppress,
+	// rendered as `// #nosec G101 G404`. Comments a CSV row configures with
+	// rules of their own keep them. A bare #nosec already on a target is
+	// upgraded to the rule-scoped form instead of being left alone.
+	Rules []string
+	// Reasons is the path of a YAML file mapping target names to the
+	// justification appended to their comment (`// #nosec G101 -- reason`),
+	// however the targets themselves are supplied. A comment already
+	// carrying a reason keeps it.
+	Reasons string
+	// RequireReason makes Run fail, before processing, listing every target
+	// whose comment would carry no reason.
+	RequireReason bool
+	// TrailingComment decides where `// #nosec` goes on a spec that already
+	// has a trailing comment: TrailingAbove (the default) puts it on its own
+	// line above the spec, TrailingAppend appends it to the existing
+	// comment (`// note // #nosec`). Either way a warning is printed.
+	TrailingComment string
+	// GroupMode decides how matched specs of a grouped declaration
+	// (`const ( ... )`) are tagged: GroupPerSpec (the default) gives each its
+	// own comment, GroupShared puts a single comment above the declaration,
+	// suppressing every spec in it, and GroupAuto shares only when every
+	// spec of the group matches.
+	GroupMode string
+	// MaxLineLength, when set, is the column limit a spec's line may not
+	// exceed once ` // #nosec` is appended to it, measured on the formatted
+	// output (so after gofmt aligns a const block), in bytes with tabs
+	// expanded to TabWidth. A comment that would overflow goes on the line
+	// above the spec instead, when the line above an ungrouped declaration
+	// is free.
+	MaxLineLength int
+	// TabWidth is the number of columns a tab counts for in MaxLineLength;
+	// 0 means DefaultTabWidth.
+	TabWidth int
+	// FailOnUnsuppressed is the path of a gosec JSON report. After
+	// processing, Run fails listing every finding in the matched files that
+	// still has no #nosec comment.
+	FailOnUnsuppressed string `json:"-"`
+	// RequireGeneratedHeader refuses to tag targets in files without the
+	// standard `// Code generated ... DO NOT EDIT.` header. Such files are
+	// left untouched and Run fails listing every target matched in them.
+	RequireGeneratedHeader bool
+	// TargetedEdit writes each new trailing `// #nosec` straight into the
+	// source bytes at the spec's end offset instead of reformatting the
+	// whole file, keeping unrelated code byte-for-byte intact and skipping
+	// the format pass. Trailing comments inside a grouped declaration are
+	// then not realigned by gofmt. Files whose edits aren't plain trailing
+	// insertions (an existing trailing comment, NormalizeNosec, Hooks, check
+	// mode) or whose edited source doesn't reparse fall back to the AST path.
+	TargetedEdit bool
+	// NormalizeFinalNewline makes files written by TargetedEdit end with
+	// exactly one newline, as files printed by go/format always do. Files
+	// whose only change is their final newline are then written too.
+	NormalizeFinalNewline bool
+
+	// Check runs without writing anything: files that would change are
+	// returned in a *pending.Error instead. Incremental state and the audit
+	// log are neither read nor written.
+	Check bool `json:"-"`
+	// Diff, with Check, also prints a unified diff of every file that would
+	// change to stdout, headed with its path.
+	Diff bool `json:"-"`
+	// SizeReport prints the lines and bytes added and removed across the
+	// modified files (the files that would be modified with Check) at the
+	// end of the run.
+	SizeReport bool `json:"-"`
+	// WarnOnReformat prints a warning for every file that would change only
+	// because go/format normalizes it (e.g. spaces hand-edited in place of
+	// tabs), not because of the transform. The file is still rewritten.
+	WarnOnReformat bool `json:"-"`
+	// Strict makes Run fail on a file that changed on disk between being
+	// read and written, instead of skipping it with a warning, and on
+	// targets that matched nothing, instead of warning about them.
+	Strict bool `json:"-"`
+	// Chmod makes read-only files matching the glob writable for their
+	// owner instead of failing the up-front writability check.
+	Chmod bool `json:"-"`
+	// EmitPositions prints a `file:line:col: add-nosec Name` line to stdout
+	// for every spec tagged, for tooling that jumps to each change. Usually
+	// combined with Check.
+	EmitPositions bool `json:"-"`
+	// ZipOut, when set, is the path of a zip archive receiving the processed
+	// contents of every file, at its path relative to the working directory
+	// (or GlobBase). It is written in check mode too, so CI can collect the
+	// result without touching the working tree.
+	ZipOut string `json:"-"`
+	// ChangedOnly limits ZipOut to the files the run changes.
+	ChangedOnly bool `json:"-"`
+
+	// Incremental skips files whose content hasn't changed since the last
+	// run recorded in StateFile. Any change to the run's inputs invalidates
+	// the state and forces a full pass.
+	Incremental bool
+	// StateFile is the path of the incremental state file.
+	StateFile string
+	// ResetIncremental discards the recorded state, forcing a full pass.
+	ResetIncremental bool
+	// AuditLog, when set, is a JSON-lines file receiving one record per
+	// change (operation, file, identifier, position, run timestamp).
+	AuditLog string `json:"-"`
+	// AuditTruncate truncates AuditLog at the start of the run instead of
+	// appending to it.
+	AuditTruncate bool `json:"-"`
+
+	// Hooks run in order on every processed file. The first error aborts
+	// the run; files already written stay written.
+	Hooks []Hook `json:"-"`
+	// Exec is an external command each processed file is piped through
+	// after Hooks, see exectransform.Hook.
+	Exec string
+}
+
 // Run scans all Go source files matching queryGlob and appends a “// #nosec” comment
 // to any const declarations whose names you’ve specified via targets or csvPath.
-// You must supply exactly one of targets (a comma‑separated list) or csvPath
-// (pointing to a CSV file under config.AllowedBaseDir); otherwise Run returns an error.
+// You must supply exactly one of targets (a comma‑separated list), csvPath
+// (pointing to a CSV file under config.AllowedBaseDir) or opts.CSVDir (a
+// directory of such CSV files); otherwise Run returns an error.
 //
 // It works by:
 //  1. Building a map of target names (from CSV or comma list).
 //  2. Globbing for files via queryGlob.
 //  3. Parsing each file’s AST, finding ast.ValueSpec nodes whose names match targets,
 //     and injecting a `// #nosec` comment if one isn’t already present.
-//  4. Rewriting each file in place with go/format.
+//  4. Running opts.Hooks, in order, on each file's AST, then piping it
+//     through opts.Exec when set.
+//  5. Rewriting each file in place with go/format, unless its content
+//     changed on disk since step 3 read it (see Options.Strict).
+//
+// With opts.Incremental set, files whose content is unchanged since the last
+// run recorded in opts.StateFile are skipped. Files carrying a
+// `// sqlc-qol:skip` directive above the package clause are never modified.
+// With opts.ZipOut set, the processed files are also packaged into a zip
+// archive.
+//
+// Other options change what Run reports:
+//   - opts.Check writes nothing and reports the files that would change in a
+//     *pending.Error, along with their diff with opts.Diff.
+//   - opts.RequireGeneratedHeader leaves files without a generated-code
+//     header that contain a target untouched and reports them as an error.
+//   - opts.FailOnUnsuppressed reports the gosec findings still lacking a
+//     #nosec comment afterwards as an error.
 //
 // Parameters:
-//   - queryGlob: glob pattern for selecting .go files (e.g. "internal/database/*.sql.go")
-//   - targets: comma‑separated const names (mutually exclusive with csvPath)
-//   - csvPath: path to a no‑header CSV listing const names (mutually exclusive with targets)
+//   - queryGlob: glob pattern for selecting .go files (e.g. "internal/database/*.sql.go"),
+//     relative to opts.GlobBase when set; `**` matches any number of
+//     directories, see globstar.Glob
+//   - targets: comma‑separated const names (mutually exclusive with csvPath).
+//     A name written as name@glob only applies to files whose path
+//     (relative to opts.GlobBase or the working directory) matches glob;
+//     CSV entries may be scoped the same way. StdinPath reads the names
+//     from stdin, separated by commas or newlines.
+//   - csvPath: path to a no‑header CSV listing const names (mutually exclusive with targets),
+//     or StdinPath to read the CSV from stdin
 //   - config: holds AllowedBaseDir for sanitizing CSV paths
+//   - opts: optional settings, see Options
 //
 // Returns an error if:
-//   - both or neither of targets/csvPath are provided,
+//   - more than one or none of targets/csvPath/opts.CSVDir are provided,
 //   - the CSV cannot be read/parsed or lies outside AllowedBaseDir,
-//   - globbing fails,
+//   - opts.GlobBase isn't a directory or globbing fails,
+//   - any matched file isn't writable (checked before anything is written,
+//     unless opts.Chmod fixes it),
 //   - any file can’t be parsed, opened, or written.
-func Run(queryGlob, targets, csvPath string, config config.Config) error {
-	var targetMap map[string]bool
-	var err error
+func Run(queryGlob, targets, csvPath string, config config.Config, opts Options) error {
+	_, err := RunWithResult(queryGlob, targets, csvPath, config, opts)
+	return err
+}
 
-	if csvPath != "" && targets != "" {
-		return fmt.Errorf("cannot specify both targets and csvPath")
-	} else if targets == "" && csvPath == "" {
-		return fmt.Errorf("must specify either targets or csvPath")
+// RunWithResult is Run, also returning what it did to each file and the
+// targets that matched nothing. The result covers the files processed
+// before any error.
+func RunWithResult(queryGlob, targets, csvPath string, config config.Config, opts Options) (Result, error) {
+	var res Result
+	err := run(queryGlob, targets, csvPath, config, opts, &res)
+	return res, err
+}
+
+// run implements Run, recording what it did in res.
+func run(queryGlob, targets, csvPath string, config config.Config, opts Options, res *Result) error {
+	switch opts.TrailingComment {
+	case "", TrailingAbove, TrailingAppend:
+	default:
+		return fmt.Errorf("invalid trailing comment placement %q: expected %s or %s", opts.TrailingComment, TrailingAbove, TrailingAppend)
+	}
+	if err := checkGroupMode(opts.GroupMode); err != nil {
+		return err
+	}
+	for _, rule := range opts.Rules {
+		if !ruleCode.MatchString(rule) {
+			return fmt.Errorf("invalid rule %q: expected a gosec rule ID such as G101", rule)
+		}
+	}
+	for _, kind := range opts.DeclKinds {
+		switch kind {
+		case KindConst, KindVar, KindField:
+		default:
+			return fmt.Errorf("invalid decl kind %q: expected %s, %s or %s", kind, KindConst, KindVar, KindField)
+		}
+	}
+	hooks, err := pipeline.WithExec(opts.Hooks, opts.Exec)
+	if err != nil {
+		return err
+	}
+	targetMap, err := loadTargets(targets, csvPath, config, opts)
+	if err != nil {
+		return err
+	}
+	if opts.PrintTargets {
+		printTargets(targetMap)
+	}
+	files, err := globFiles(queryGlob, opts)
+	if err != nil {
+		return err
+	}
+	if !opts.Check {
+		fixed, err := writable.Ensure(files, opts.Chmod)
+		for _, file := range fixed {
+			fmt.Fprintf(stdout, "%s: made writable\n", displayPath(file, opts))
+		}
+		if err != nil {
+			return err
+		}
 	}
 
-	if csvPath != "" {
-		targetMap, err = parseTargetsCSV(csvPath, config.AllowedBaseDir)
+	var state *incremental.State
+	if opts.Incremental && !opts.Check {
+		fpOpts := opts
+		fpOpts.ResetIncremental = false
+		fingerprint, err := incremental.Fingerprint(queryGlob, targetMap, config, fpOpts)
 		if err != nil {
-			return fmt.Errorf("error parsing CSV file: %w", err)
+			return err
+		}
+		if state, err = incremental.Load(opts.StateFile, fingerprint, opts.ResetIncremental); err != nil {
+			return err
 		}
-	} else {
-		targetMap = parseTargets(targets)
 	}
-	files, err := glob(queryGlob)
-	if err != nil {
-		return fmt.Errorf("failed to glob files with pattern %q: %w", queryGlob, err)
+
+	var auditLog *audit.Log
+	if opts.AuditLog != "" && !opts.Check {
+		if auditLog, err = audit.Open(opts.AuditLog, "add-nosec", opts.AuditTruncate); err != nil {
+			return err
+		}
+		defer auditLog.Close()
 	}
 
+	var zipOut *archive.Zip
+	if opts.ZipOut != "" {
+		if zipOut, err = archive.Create(opts.ZipOut, opts.ChangedOnly); err != nil {
+			return err
+		}
+		defer zipOut.Close()
+	}
+	var report *sizereport.Report
+	if opts.SizeReport {
+		report = &sizereport.Report{}
+	}
+
+	tagged, suppressed := 0, 0
+	var handWritten, handWrittenMatches, pendingFiles []string
+	matchedKeys := make(map[string]bool)
 	for _, file := range files {
+		if state != nil {
+			changed, err := state.Changed(file)
+			if err != nil {
+				return err
+			}
+			if !changed {
+				res.Unchanged++
+				continue
+			}
+		}
 
+		src, err := readFile(file) // #nosec G304 -- file was matched by the user's glob
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		// the hash read here guards the write below against lost updates
+		sum := sha256.Sum256(src)
 		fset := token.NewFileSet()
-		f, err := parseFile(fset, file, nil, parser.ParseComments)
+		f, err := parseFile(fset, file, src, parser.ParseComments)
 		if err != nil {
 			return fmt.Errorf("failed to parse file %s: %w", file, err)
 		}
+		if directives.SkipFile(f) {
+			fmt.Fprintf(stdout, "%s: skipped by %s directive\n", displayPath(file, opts), directives.Skip)
+			continue
+		}
+		fileTargets := scopedTargets(targetMap, file, opts)
+		if opts.RequireGeneratedHeader && !ast.IsGenerated(f) {
+			if matched := matchedTargets(fset, f, fileTargets, opts); len(matched) > 0 {
+				handWritten = append(handWritten, file)
+				for _, m := range matched {
+					handWrittenMatches = append(handWrittenMatches, displayPath(file, opts)+":"+m)
+				}
+				continue
+			}
+		}
 		origComments := f.Comments
 		commentMap := ast.NewCommentMap(fset, f, origComments)
 		if commentMap == nil {
 			commentMap = make(ast.CommentMap)
 		}
+		var auditErr error
+		targeted := opts.TargetedEdit && !opts.Check && !opts.NormalizeNosec && len(hooks) == 0
+		var insertAt []insertion
+		result := FileResult{Path: displayPath(file, opts)}
+		found := make(map[string]bool)
+		skip := func(name string) {
+			result.Skipped = append(result.Skipped, name)
+			found[targetKey(name, opts)] = true
+		}
+		record := func(node ast.Node, name string, parent ast.Node, text string) {
+			tagged++
+			result.Tagged = append(result.Tagged, name)
+			found[targetKey(name, opts)] = true
+			pos := fset.Position(node.Pos())
+			if err := auditLog.Record(name, pos); err != nil && auditErr == nil {
+				auditErr = err
+			}
+			if opts.EmitPositions {
+				fmt.Fprintf(stdout, "%s:%d:%d: add-nosec %s\n", displayPath(file, opts), pos.Line, pos.Column, name)
+			}
+			if opts.ShowChanges {
+				decl := "const"
+				if gd, ok := parent.(*ast.GenDecl); ok {
+					decl = gd.Tok.String()
+				} else if _, ok := node.(*ast.KeyValueExpr); ok {
+					decl = KindField
+				}
+				fmt.Fprintf(stdout, "%s:%d: %s %s -> %s\n", displayPath(file, opts), pos.Line, decl, name, text)
+			}
+		}
+		shared := make(map[*ast.ValueSpec]bool)
+		var trailing []trailingNosec
 		astutil.Apply(f, func(c *astutil.Cursor) bool {
+			if gd, ok := c.Node().(*ast.GenDecl); ok {
+				cg, matched := shareNosec(fset, gd, fileTargets, origComments, opts)
+				for _, valSpec := range matched {
+					shared[valSpec] = true
+				}
+				if cg != nil {
+					commentMap[gd] = append(commentMap[gd], cg)
+					targeted = false
+				}
+				for _, valSpec := range matched {
+					for _, name := range valSpec.Names {
+						if !isTarget(fileTargets, name.Name, opts) {
+							continue
+						}
+						if cg != nil {
+							record(valSpec, name.Name, gd, cg.List[0].Text)
+						} else {
+							skip(name.Name)
+						}
+					}
+				}
+				return true
+			}
+			if kv, ok := c.Node().(*ast.KeyValueExpr); ok {
+				name, ok := fieldTarget(kv, c.Parent(), fileTargets, opts)
+				if !ok {
+					return true
+				}
+				text := targetComment(fileTargets, name, opts)
+				cg, reason := fieldNosec(fset, kv, origComments, text, opts)
+				switch reason {
+				case "":
+					commentMap[kv] = append(commentMap[kv], cg)
+					targeted = false
+					record(kv, name, c.Parent(), text)
+				case "suppressed":
+					suppressed++
+					skip(name)
+				case "tagged":
+					skip(name)
+				case "commented":
+					skip(name)
+					fmt.Fprintf(stderr, "warning: %s:%d: field %s already has a trailing comment; add %s by hand\n",
+						displayPath(file, opts), fset.Position(kv.Pos()).Line, name, text)
+				}
+				return true
+			}
 			valSpec, ok := c.Node().(*ast.ValueSpec)
-			if !ok {
+			if !ok || shared[valSpec] {
+				return true
+			}
+			if !eligible(valSpec, c.Parent(), opts) {
+				return true
+			}
+			if respected(valSpec, c.Parent(), opts.RespectDirectives) {
+				counted := false
+				for _, name := range valSpec.Names {
+					if isTarget(fileTargets, name.Name, opts) {
+						if !counted {
+							suppressed++
+							counted = true
+						}
+						skip(name.Name)
+					}
+				}
 				return true
 			}
 			for _, name := range valSpec.Names {
-				if targetMap[name.Name] {
-					if hasNoSec := func() bool {
-						if valSpec.Comment != nil {
-							for _, cm := range valSpec.Comment.List {
-								if strings.Contains(cm.Text, "#nosec") {
-									return true
-								}
-							}
+				if isTarget(fileTargets, name.Name, opts) {
+					gd, _ := c.Parent().(*ast.GenDecl)
+					text := targetComment(fileTargets, name.Name, opts)
+					if hasNosec(valSpec, gd, text) {
+						if opts.NormalizeNosec {
+							normalizeNosec(valSpec.Doc)
+							normalizeNosec(valSpec.Comment)
+						}
+						if cm := upgradeNosec(valSpec, gd, opts.Rules); cm != nil {
+							targeted = false
+							record(valSpec, name.Name, c.Parent(), cm.Text)
+						} else {
+							skip(name.Name)
 						}
-						return false
-					}(); hasNoSec {
 						continue
 					}
-					cg := &ast.CommentGroup{
-						List: []*ast.Comment{
-							{
-								Slash: valSpec.End(),
-								Text:  "// #nosec",
-							},
-						},
+					cg := placeNosec(fset, valSpec, gd, origComments, text, opts)
+					if cg == nil {
+						// appended to the existing trailing comment
+						cg = valSpec.Comment
+						targeted = false
+						fmt.Fprintf(stderr, "warning: %s:%d: %s already has a trailing comment; appended %s to it\n",
+							displayPath(file, opts), fset.Position(valSpec.Pos()).Line, name.Name, text)
+					} else {
+						if valSpec.Comment != nil {
+							fmt.Fprintf(stderr, "warning: %s:%d: %s already has a trailing comment; placed %s on the line above\n",
+								displayPath(file, opts), fset.Position(valSpec.Pos()).Line, name.Name, text)
+						}
+						if cg.Pos() == valSpec.End()-1 {
+							// a plain trailing insertion
+							insertAt = append(insertAt, insertion{offset: fset.Position(valSpec.End()).Offset, text: text})
+							trailing = append(trailing, trailingNosec{valSpec, gd, cg})
+						} else {
+							targeted = false
+						}
+						commentMap[valSpec] = append(commentMap[valSpec], cg)
 					}
-					commentMap[valSpec] = append(commentMap[valSpec], cg)
+					record(valSpec, name.Name, c.Parent(), cg.List[len(cg.List)-1].Text)
 				}
 			}
 
 			return true
 		}, nil)
+		if auditErr != nil {
+			return auditErr
+		}
+		if moved, err := fitLineLength(file, fset, f, commentMap, origComments, trailing, opts); err != nil {
+			return err
+		} else if moved {
+			targeted = false
+		}
+		markMatched(matchedKeys, targetMap, found, file, opts)
+		if targeted {
+			if out, ok := insertNosec(file, src, insertAt); ok {
+				if opts.NormalizeFinalNewline {
+					out = normalizeFinalNewline(out)
+				}
+				changed := !bytes.Equal(src, out)
+				if skip, err := pipeline.SkipChanged(file, displayPath(file, opts), sum, opts.Strict, stderr); err != nil {
+					return err
+				} else if skip {
+					continue
+				}
+				report.Add(src, out)
+				if err := zipOut.Add(displayPath(file, opts), out, changed); err != nil {
+					return err
+				}
+				if changed {
+					if err := writeBytes(file, out); err != nil {
+						return err
+					}
+				}
+				if state != nil {
+					if err := state.Record(file); err != nil {
+						return err
+					}
+				}
+				res.Files = append(res.Files, result)
+				continue
+			}
+		}
+		f.Comments = commentMap.Comments()
+		if _, err := pipeline.RunHooks(hooks, fset, f, file); err != nil {
+			return err
+		}
+		if opts.Check || zipOut != nil || report != nil || opts.WarnOnReformat {
+			out, changed, err := formatFile(file, fset, f)
+			if err != nil {
+				return err
+			}
+			if opts.WarnOnReformat && changed && pipeline.ReformatOnly(src, out) {
+				pipeline.WarnReformat(stderr, displayPath(file, opts), "add-nosec")
+			}
+			if !opts.Check {
+				if skip, err := pipeline.SkipChanged(file, displayPath(file, opts), sum, opts.Strict, stderr); err != nil {
+					return err
+				} else if skip {
+					continue
+				}
+			}
+			report.Add(src, out)
+			if err := zipOut.Add(displayPath(file, opts), out, changed); err != nil {
+				return err
+			}
+			if opts.Check {
+				if changed {
+					pendingFiles = append(pendingFiles, displayPath(file, opts))
+					if opts.Diff {
+						fmt.Fprint(stdout, unifieddiff.Diff(file, file, src, out))
+					}
+				}
+				res.Files = append(res.Files, result)
+				continue
+			}
+			if err := writeBytes(file, out); err != nil {
+				return err
+			}
+		} else {
+			if skip, err := pipeline.SkipChanged(file, displayPath(file, opts), sum, opts.Strict, stderr); err != nil {
+				return err
+			} else if skip {
+				continue
+			}
+			outFile, err := createFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to open file %s for writing: %w", file, err)
+			}
+			defer outFile.Close()
+			if err := formatNode(outFile, fset, f); err != nil {
+				return fmt.Errorf("failed to write formatted file %s: %w", file, err)
+			}
+		}
+		if state != nil {
+			if err := state.Record(file); err != nil {
+				return err
+			}
+		}
+		res.Files = append(res.Files, result)
+	}
+	if res.Unchanged == 0 {
+		res.Unmatched = unmatchedTargets(targetMap, matchedKeys)
+	}
+	if !opts.Strict {
+		for _, target := range res.Unmatched {
+			fmt.Fprintf(stderr, "warning: target %s matched nothing in the files processed\n", target)
+		}
+	}
+	if err := zipOut.Close(); err != nil {
+		return err
+	}
+	if suppressed > 0 {
+		fmt.Fprintf(stdout, "%d specs skipped: already suppressed by a linter directive\n", suppressed)
+	}
+	if opts.ShowChanges {
+		fmt.Fprintf(stdout, "%d specs tagged\n", tagged)
+	}
+	report.Fprint(stdout)
+	if state != nil {
+		if err := state.Save(opts.StateFile); err != nil {
+			return err
+		}
+	}
+	if len(handWritten) > 0 {
+		return fmt.Errorf("targets matched in %d files without a generated-code header, left untouched:\n  %s",
+			len(handWritten), strings.Join(handWrittenMatches, "\n  "))
+	}
+	if opts.Strict && len(res.Unmatched) > 0 {
+		return fmt.Errorf("%d targets matched nothing in the files processed (check for typos):\n  %s",
+			len(res.Unmatched), strings.Join(res.Unmatched, "\n  "))
+	}
+	if len(pendingFiles) > 0 {
+		return &pending.Error{Operation: "add-nosec", Files: pendingFiles}
+	}
+	if opts.FailOnUnsuppressed != "" {
+		remaining, err := checkUnsuppressed(opts.FailOnUnsuppressed, files)
+		if err != nil {
+			return err
+		}
+		if len(remaining) > 0 {
+			lines := make([]string, len(remaining))
+			for i, finding := range remaining {
+				lines[i] = "  " + finding.String()
+			}
+			return fmt.Errorf("%d gosec findings remain unsuppressed:\n%s", len(remaining), strings.Join(lines, "\n"))
+		}
+	}
+	return nil
+}
+
+// loadTargets checks that exactly one target source is set and builds the
+// target set from it, with opts.Rules and the reasons of opts.Reasons
+// applied.
+func loadTargets(targets, csvPath string, config config.Config, opts Options) (map[string]string, error) {
+	var targetMap map[string]string
+	var err error
+
+	if csvPath != "" && targets != "" {
+		return nil, fmt.Errorf("cannot specify both targets and csvPath")
+	} else if opts.CSVDir != "" && (targets != "" || csvPath != "") {
+		return nil, fmt.Errorf("cannot specify csvDir together with targets or csvPath")
+	} else if targets == "" && csvPath == "" && opts.CSVDir == "" {
+		return nil, fmt.Errorf("must specify either targets or csvPath (or csvDir)")
+	}
+
+	if csvPath == StdinPath {
+		targetMap, err = parseCSVRecords(stdin, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing CSV from stdin: %w", err)
+		}
+	} else if csvPath != "" {
+		targetMap, err = parseTargetsCSV(csvPath, config.AllowedBaseDir, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing CSV file: %w", err)
+		}
+	} else if opts.CSVDir != "" {
+		targetMap, err = parseTargetsCSVDir(opts.CSVDir, config.AllowedBaseDir, opts)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing CSV directory: %w", err)
+		}
+	} else if targets == StdinPath {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read targets from stdin: %w", err)
+		}
+		targetMap = parseTargets(strings.ReplaceAll(string(data), "\n", ","))
+	} else {
+		targetMap = parseTargets(targets)
+	}
+	if err := validateScopes(targetMap); err != nil {
+		return nil, err
+	}
+	if opts.CaseInsensitive {
+		targetMap = foldTargets(targetMap)
+	}
+	if len(opts.Rules) > 0 {
+		for key, comment := range targetMap {
+			if rules, reason, ok := parseNosec(comment); ok && len(rules) == 0 {
+				targetMap[key] = formatNosec(opts.Rules, reason)
+			}
+		}
+	}
+	if opts.Reasons != "" || opts.RequireReason {
+		var reasons map[string]string
+		if opts.Reasons != "" {
+			if reasons, err = loadReasons(opts.Reasons, opts); err != nil {
+				return nil, err
+			}
+		}
+		if err := applyReasons(targetMap, reasons, opts); err != nil {
+			return nil, err
+		}
+	}
+	return targetMap, nil
+}
+
+// hasNosec reports whether valSpec already carries a #nosec comment, or
+// one containing text, as its trailing comment or doc comment (the
+// declaration's doc comment for an ungrouped declaration, gd being valSpec's
+// parent).
+func hasNosec(valSpec *ast.ValueSpec, gd *ast.GenDecl, text string) bool {
+	groups := []*ast.CommentGroup{valSpec.Comment, valSpec.Doc}
+	if gd != nil && !gd.Lparen.IsValid() {
+		groups = append(groups, gd.Doc)
+	}
+	for _, cg := range groups {
+		if cg == nil {
+			continue
+		}
+		for _, cm := range cg.List {
+			if isNosec(cm.Text) || strings.Contains(cm.Text, text) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// upgradeNosec rewrites the first bare #nosec comment found where hasNosec
+// looks to the rule-scoped form suppressing rules, keeping its
+// justification, and returns it. It returns nil when rules is empty or
+// there is no bare #nosec.
+func upgradeNosec(valSpec *ast.ValueSpec, gd *ast.GenDecl, rules []string) *ast.Comment {
+	if len(rules) == 0 {
+		return nil
+	}
+	groups := []*ast.CommentGroup{valSpec.Comment, valSpec.Doc}
+	if gd != nil && !gd.Lparen.IsValid() {
+		groups = append(groups, gd.Doc)
+	}
+	for _, cg := range groups {
+		if cg == nil {
+			continue
+		}
+		for _, cm := range cg.List {
+			if existing, reason, ok := parseNosec(cm.Text); ok && len(existing) == 0 {
+				cm.Text = formatNosec(rules, reason)
+				return cm
+			}
+		}
+	}
+	return nil
+}
+
+// respected reports whether valSpec, or parent when it is valSpec's
+// declaration, carries a comment matching one of directives.
+func respected(valSpec *ast.ValueSpec, parent ast.Node, directives []string) bool {
+	if len(directives) == 0 {
+		return false
+	}
+	groups := []*ast.CommentGroup{valSpec.Comment, valSpec.Doc}
+	if gd, ok := parent.(*ast.GenDecl); ok {
+		groups = append(groups, gd.Doc)
+	}
+	for _, cg := range groups {
+		if cg == nil {
+			continue
+		}
+		for _, cm := range cg.List {
+			for _, directive := range directives {
+				if matchesDirective(cm.Text, directive) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// matchesDirective reports whether the comment text is the linter directive
+// name[:linter], e.g. `//nolint:gosec` or `//nolint:errcheck,gosec // reason`
+// for nolint:gosec. A directive without a linter list matches any linter.
+func matchesDirective(text, directive string) bool {
+	name, linter, hasLinter := strings.Cut(strings.TrimSpace(directive), ":")
+	body, ok := strings.CutPrefix(text, "//")
+	if name == "" || !ok {
+		return false
+	}
+	fields := strings.Fields(body)
+	if len(fields) == 0 {
+		return false
+	}
+	commentName, list, hasList := strings.Cut(fields[0], ":")
+	if commentName != name {
+		return false
+	}
+	if !hasLinter || !hasList {
+		return true
+	}
+	for _, l := range strings.Split(list, ",") {
+		if l == linter {
+			return true
+		}
+	}
+	return false
+}
+
+// checkGroupMode returns an error unless mode is a valid Options.GroupMode.
+func checkGroupMode(mode string) error {
+	switch mode {
+	case "", GroupPerSpec, GroupShared, GroupAuto:
+		return nil
+	}
+	return fmt.Errorf("invalid group mode %q: expected %s, %s or %s", mode, GroupPerSpec, GroupShared, GroupAuto)
+}
+
+// coversSpecs reports whether gd's doc comment suppresses its specs: always
+// for an ungrouped declaration, and for a grouped one when opts.GroupMode
+// puts a shared comment above it.
+func coversSpecs(gd *ast.GenDecl, opts Options) bool {
+	if gd == nil {
+		return false
+	}
+	return !gd.Lparen.IsValid() || opts.GroupMode == GroupShared || opts.GroupMode == GroupAuto
+}
+
+// shareNosec decides, for opts.GroupMode, whether gd's matched specs share
+// one comment. It returns the specs sharing one, along with the new comment
+// group holding the first target's comment positioned above gd, or a nil
+// group if gd's doc comment already holds one. It returns no specs when gd
+// isn't a grouped const or var declaration, the mode doesn't share, or the
+// line above gd holds code the comment would attach to.
+func shareNosec(fset *token.FileSet, gd *ast.GenDecl, targetMap map[string]string, comments []*ast.CommentGroup, opts Options) (*ast.CommentGroup, []*ast.ValueSpec) {
+	if opts.GroupMode != GroupShared && opts.GroupMode != GroupAuto {
+		return nil, nil
+	}
+	if !gd.Lparen.IsValid() || (gd.Tok != token.CONST && gd.Tok != token.VAR) {
+		return nil, nil
+	}
+	var matched []*ast.ValueSpec
+	text := ""
+	for _, spec := range gd.Specs {
+		valSpec, ok := spec.(*ast.ValueSpec)
+		if !ok || !eligible(valSpec, gd, opts) || respected(valSpec, gd, opts.RespectDirectives) {
+			continue
+		}
+		for _, name := range valSpec.Names {
+			if isTarget(targetMap, name.Name, opts) {
+				if text == "" {
+					text = targetComment(targetMap, name.Name, opts)
+				}
+				matched = append(matched, valSpec)
+				break
+			}
+		}
+	}
+	if len(matched) == 0 || (opts.GroupMode == GroupAuto && len(matched) != len(gd.Specs)) {
+		return nil, nil
+	}
+	if gd.Doc != nil {
+		for _, cm := range gd.Doc.List {
+			if isNosec(cm.Text) || strings.Contains(cm.Text, text) {
+				return nil, matched
+			}
+		}
+	}
+	if !lineAboveFree(fset, gd.Pos(), comments) {
+		return nil, nil
+	}
+	return &ast.CommentGroup{List: []*ast.Comment{{Slash: gd.Pos() - 1, Text: text}}}, matched
+}
+
+// placeNosec returns a new comment group holding text, the suppression
+// comment, positioned for valSpec, gd being its parent declaration. The
+// comment trails the spec unless the spec already has a trailing comment.
+// Then, with the above placement, it is positioned on the line above the
+// spec; with the append placement, or when the line above an ungrouped
+// declaration holds code the comment would attach to, it is appended to the
+// existing comment instead and nil is returned. Trailing comments pushing a
+// line past opts.MaxLineLength are moved by fitLineLength.
+func placeNosec(fset *token.FileSet, valSpec *ast.ValueSpec, gd *ast.GenDecl, comments []*ast.CommentGroup, text string, opts Options) *ast.CommentGroup {
+	if valSpec.Comment == nil {
+		// Anchor the comment inside the spec's last token rather
+		// than at End(): when a `)` or another declaration starts
+		// right at End() (e.g. `const (a = "x"; b = "y")`), a
+		// comment positioned there is printed after that token
+		// and ends up attached to the wrong node.
+		return &ast.CommentGroup{List: []*ast.Comment{{Slash: valSpec.End() - 1, Text: text}}}
+	}
+	if opts.TrailingComment != TrailingAppend {
+		if cg := nosecAbove(fset, valSpec, gd, comments, text); cg != nil {
+			return cg
+		}
+	}
+	last := valSpec.Comment.List[len(valSpec.Comment.List)-1]
+	last.Text += " " + text
+	return nil
+}
+
+// nosecAbove returns a comment group holding text positioned so it is
+// printed on its own line above valSpec: just before the spec in a group, or
+// at the end of the line above an ungrouped declaration. It returns nil if
+// that line holds code the comment would attach to.
+func nosecAbove(fset *token.FileSet, valSpec *ast.ValueSpec, gd *ast.GenDecl, comments []*ast.CommentGroup, text string) *ast.CommentGroup {
+	if gd != nil && gd.Lparen.IsValid() {
+		return &ast.CommentGroup{List: []*ast.Comment{{Slash: valSpec.Pos() - 1, Text: text}}}
+	}
+	if gd != nil && lineAboveFree(fset, gd.Pos(), comments) {
+		return &ast.CommentGroup{List: []*ast.Comment{{Slash: gd.Pos() - 1, Text: text}}}
+	}
+	return nil
+}
+
+// lineAboveFree reports whether the line above pos is blank or ends in a
+// comment, so a comment positioned at its end isn't printed as the trailing
+// comment of code on that line.
+func lineAboveFree(fset *token.FileSet, pos token.Pos, comments []*ast.CommentGroup) bool {
+	tf := fset.File(pos)
+	line := tf.Line(pos)
+	if line <= 1 {
+		return false
+	}
+	if tf.LineStart(line)-tf.LineStart(line-1) == 1 {
+		return true
+	}
+	for _, cg := range comments {
+		if tf.Line(cg.End()) == line-1 {
+			return true
+		}
+	}
+	return false
+}
+
+// trailingNosec is a suppression comment placed trailing a spec, subject to
+// Options.MaxLineLength.
+type trailingNosec struct {
+	valSpec *ast.ValueSpec
+	gd      *ast.GenDecl
+	cg      *ast.CommentGroup
+}
+
+// fitLineLength moves the trailing comments that push their line past
+// opts.MaxLineLength, once f (parsed from file) is formatted with the
+// comments of commentMap, to
+// the line above their spec where nosecAbove allows it. Each comment is
+// measured where gofmt prints it, after aligning the block around it, so the
+// file is formatted again after every move: realigning a block can shorten
+// the lines of the comments left. It reports whether any comment moved.
+func fitLineLength(file string, fset *token.FileSet, f *ast.File, commentMap ast.CommentMap, comments []*ast.CommentGroup, trailing []trailingNosec, opts Options) (bool, error) {
+	if opts.MaxLineLength <= 0 || len(trailing) == 0 {
+		return false, nil
+	}
+	tabWidth := opts.TabWidth
+	if tabWidth <= 0 {
+		tabWidth = DefaultTabWidth
+	}
+	moved := false
+	for {
+		// Trailing comments have the same column whatever their text, so
+		// each is printed as a unique marker to find its line.
+		texts := make([]string, len(trailing))
+		for i, t := range trailing {
+			texts[i] = t.cg.List[0].Text
+			t.cg.List[0].Text = fmt.Sprintf("//sqlc-qol:width:%d", i)
+		}
 		f.Comments = commentMap.Comments()
-		outFile, err := createFile(file)
+		var buf bytes.Buffer
+		err := format.Node(&buf, fset, f)
+		for i, t := range trailing {
+			t.cg.List[0].Text = texts[i]
+		}
 		if err != nil {
-			return fmt.Errorf("failed to open file %s for writing: %w", file, err)
+			return false, fmt.Errorf("failed to format file %s: %w", file, err)
+		}
+
+		var kept []trailingNosec
+		for i, t := range trailing {
+			marker := []byte(fmt.Sprintf("//sqlc-qol:width:%d\n", i))
+			at := bytes.Index(buf.Bytes(), marker)
+			if at < 0 {
+				kept = append(kept, t)
+				continue
+			}
+			lineStart := bytes.LastIndexByte(buf.Bytes()[:at], '\n') + 1
+			width := lineWidth(buf.Bytes()[lineStart:at], tabWidth) + len(texts[i])
+			if width <= opts.MaxLineLength {
+				kept = append(kept, t)
+				continue
+			}
+			above := nosecAbove(fset, t.valSpec, t.gd, comments, texts[i])
+			if above == nil {
+				kept = append(kept, t)
+				continue
+			}
+			groups := commentMap[t.valSpec]
+			for j, cg := range groups {
+				if cg == t.cg {
+					commentMap[t.valSpec] = append(groups[:j:j], groups[j+1:]...)
+					break
+				}
+			}
+			commentMap[t.valSpec] = append(commentMap[t.valSpec], above)
+			moved = true
+			kept = append(kept, trailing[i+1:]...)
+			break
 		}
-		defer outFile.Close()
-		if err := formatNode(outFile, fset, f); err != nil {
-			return fmt.Errorf("failed to write formatted file %s: %w", file, err)
+		if len(kept) == len(trailing) {
+			return moved, nil
+		}
+		trailing = kept
+	}
+}
+
+// lineWidth returns the width in columns of line, tabs advancing to the next
+// multiple of tabWidth.
+func lineWidth(line []byte, tabWidth int) int {
+	width := 0
+	for _, b := range line {
+		if b == '\t' {
+			width += tabWidth - width%tabWidth
+		} else {
+			width++
 		}
 	}
+	return width
+}
+
+// insertion is a suppression comment to insert at a spec's end offset.
+type insertion struct {
+	offset int
+	text   string
+}
+
+// insertNosec returns src with each insertion's comment inserted at its
+// offset, the end of a spec. It reports false, leaving the edit to the AST
+// path, if anything but whitespace follows an offset on its line (the
+// comment would swallow it) or if the edited source doesn't parse.
+func insertNosec(file string, src []byte, insertions []insertion) ([]byte, bool) {
+	sort.Slice(insertions, func(i, j int) bool { return insertions[i].offset < insertions[j].offset })
+	out := make([]byte, 0, len(src)+len(insertions)*len(" "+DefaultComment))
+	prev := 0
+	for _, ins := range insertions {
+		rest := src[ins.offset:]
+		if i := bytes.IndexByte(rest, '\n'); i >= 0 {
+			rest = rest[:i]
+		}
+		if len(bytes.TrimSpace(rest)) > 0 {
+			return nil, false
+		}
+		out = append(out, src[prev:ins.offset]...)
+		out = append(out, " "+ins.text...)
+		prev = ins.offset
+	}
+	out = append(out, src[prev:]...)
+	if _, err := parser.ParseFile(token.NewFileSet(), file, out, parser.ParseComments); err != nil {
+		return nil, false
+	}
+	return out, true
+}
+
+// normalizeFinalNewline returns src ending with exactly one newline.
+func normalizeFinalNewline(src []byte) []byte {
+	return append(bytes.TrimRight(src, "\n"), '\n')
+}
+
+// writeBytes overwrites file with src.
+func writeBytes(file string, src []byte) error {
+	outFile, err := createFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s for writing: %w", file, err)
+	}
+	defer outFile.Close()
+	if _, err := outFile.Write(src); err != nil {
+		return fmt.Errorf("failed to write file %s: %w", file, err)
+	}
 	return nil
 }
 
-func parseTargetsCSV(csvPath, allowedBaseDir string) (map[string]bool, error) {
+// formatFile formats f and reports whether writing it would change the
+// contents of file.
+func formatFile(file string, fset *token.FileSet, f *ast.File) ([]byte, bool, error) {
+	var buf bytes.Buffer
+	if err := formatNode(&buf, fset, f); err != nil {
+		return nil, false, fmt.Errorf("failed to format file %s: %w", file, err)
+	}
+	orig, err := readFile(file) // #nosec G304 -- the file was just parsed from this path
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to read file %s: %w", file, err)
+	}
+	return buf.Bytes(), !bytes.Equal(orig, buf.Bytes()), nil
+}
+
+// globFiles returns the files matching queryGlob, resolved against
+// opts.GlobBase when set.
+func globFiles(queryGlob string, opts Options) ([]string, error) {
+	if opts.GlobBase != "" {
+		info, err := stat(opts.GlobBase)
+		if err != nil {
+			return nil, fmt.Errorf("invalid glob base: %w", err)
+		}
+		if !info.IsDir() {
+			return nil, fmt.Errorf("invalid glob base: %s is not a directory", opts.GlobBase)
+		}
+		queryGlob = filepath.Join(opts.GlobBase, queryGlob)
+	}
+	files, err := glob(queryGlob)
+	if err != nil {
+		return nil, fmt.Errorf("failed to glob files with pattern %q: %w", queryGlob, err)
+	}
+	return files, nil
+}
+
+// displayPath returns file as reported to the user: relative to
+// opts.GlobBase when set.
+func displayPath(file string, opts Options) string {
+	if opts.GlobBase == "" {
+		return file
+	}
+	if rel, err := filepath.Rel(opts.GlobBase, file); err == nil {
+		return rel
+	}
+	return file
+}
+
+// matchedTargets returns a `line: name` entry for every target spec (or
+// composite literal field) in f that Run would consider, whether or not it already carries a #nosec.
+func matchedTargets(fset *token.FileSet, f *ast.File, targetMap map[string]string, opts Options) []string {
+	var matched []string
+	astutil.Apply(f, func(c *astutil.Cursor) bool {
+		if kv, ok := c.Node().(*ast.KeyValueExpr); ok {
+			if name, ok := fieldTarget(kv, c.Parent(), targetMap, opts); ok {
+				matched = append(matched, fmt.Sprintf("%d: %s", fset.Position(kv.Pos()).Line, name))
+			}
+			return true
+		}
+		valSpec, ok := c.Node().(*ast.ValueSpec)
+		if !ok || !eligible(valSpec, c.Parent(), opts) {
+			return true
+		}
+		for _, name := range valSpec.Names {
+			if isTarget(targetMap, name.Name, opts) {
+				matched = append(matched, fmt.Sprintf("%d: %s", fset.Position(name.Pos()).Line, name.Name))
+			}
+		}
+		return true
+	}, nil)
+	return matched
+}
+
+// eligible reports whether valSpec may be tagged at all: it must be of one
+// of opts.DeclKinds, must not carry the `// sqlc-qol:ignore` escape hatch
+// and must match opts.ConstType if set.
+func eligible(valSpec *ast.ValueSpec, parent ast.Node, opts Options) bool {
+	if gd, ok := parent.(*ast.GenDecl); ok && !tagsKind(opts, gd.Tok.String()) {
+		return false
+	}
+	if directives.Ignored(valSpec, parent) {
+		return false
+	}
+	return opts.ConstType == "" || hasType(valSpec, opts.ConstType)
+}
+
+// tagsKind reports whether targets are matched against declarations of
+// kind, per opts.DeclKinds.
+func tagsKind(opts Options, kind string) bool {
+	if len(opts.DeclKinds) == 0 {
+		return slices.Contains(DefaultDeclKinds, kind)
+	}
+	return slices.Contains(opts.DeclKinds, kind)
+}
+
+// isTarget reports whether name is in targetMap.
+func isTarget(targetMap map[string]string, name string, opts Options) bool {
+	_, ok := targetMap[targetKey(name, opts)]
+	return ok
+}
+
+// targetComment returns the suppression comment configured for name.
+func targetComment(targetMap map[string]string, name string, opts Options) string {
+	return targetMap[targetKey(name, opts)]
+}
+
+func targetKey(name string, opts Options) string {
+	if opts.CaseInsensitive {
+		return strings.ToLower(name)
+	}
+	return name
+}
+
+// hasType reports whether valSpec is explicitly declared with typeName. A
+// qualified type (auth.APIKey) matches both "auth.APIKey" and "APIKey".
+func hasType(valSpec *ast.ValueSpec, typeName string) bool {
+	switch t := valSpec.Type.(type) {
+	case *ast.Ident:
+		return t.Name == typeName
+	case *ast.SelectorExpr:
+		return t.Sel.Name == typeName || types.ExprString(t) == typeName
+	}
+	return false
+}
+
+// canonicalNosec returns the canonical form of a #nosec comment, e.g.
+// `//#nosec  G101` becomes `// #nosec G101`. ok is false for comments that
+// aren't a #nosec directive.
+func canonicalNosec(text string) (canonical string, ok bool) {
+	rules, reason, ok := parseNosec(text)
+	if !ok {
+		return "", false
+	}
+	return formatNosec(rules, reason), true
+}
+
+// parseNosec splits a #nosec comment into its rule codes and justification.
+// ok is false for comments that aren't a #nosec directive.
+func parseNosec(text string) (rules []string, reason string, ok bool) {
+	body := strings.TrimSpace(strings.TrimPrefix(text, "//"))
+	rest, ok := strings.CutPrefix(body, "#nosec")
+	if !ok || (rest != "" && rest[0] != ' ' && rest[0] != '\t') {
+		return nil, "", false
+	}
+	ruleText, reason, _ := strings.Cut(rest, "--")
+	return strings.Fields(ruleText), strings.TrimSpace(reason), true
+}
+
+// formatNosec renders a canonical `// #nosec [rules] [-- reason]` comment.
+func formatNosec(rules []string, reason string) string {
+	text := "// #nosec"
+	if len(rules) > 0 {
+		text += " " + strings.Join(rules, " ")
+	}
+	if reason != "" {
+		text += " -- " + reason
+	}
+	return text
+}
+
+// normalizeNosec rewrites the #nosec comments in cg to their canonical form
+// and drops duplicates, leaving other comments untouched.
+func normalizeNosec(cg *ast.CommentGroup) {
+	if cg == nil {
+		return
+	}
+	seen := make(map[string]bool)
+	list := cg.List[:0]
+	for _, cm := range cg.List {
+		if canonical, ok := canonicalNosec(cm.Text); ok {
+			if seen[canonical] {
+				continue
+			}
+			seen[canonical] = true
+			cm.Text = canonical
+		}
+		list = append(list, cm)
+	}
+	cg.List = list
+}
+
+// parseTargetsCSV reads the targets listed in the CSV at csvPath, mapped to
+// their suppression comment: the row's cell starting with `//`, if any, or
+// DefaultComment.
+func parseTargetsCSV(csvPath, allowedBaseDir string, opts Options) (map[string]string, error) {
 	// while low risk in CLI, sanitizing to protect users as much as possible from security risk
 	safePath, err := sanitizePath(csvPath, allowedBaseDir)
 	if err != nil {
@@ -144,30 +1361,155 @@ func parseTargetsCSV(csvPath, allowedBaseDir string) (map[string]bool, error) {
 		return nil, fmt.Errorf("failed to open CSV file: %w", err)
 	}
 	defer f.Close()
-	reader := csv.NewReader(f)
+	targetMap, err := parseCSVRecords(f, opts)
+	if err != nil {
+		return nil, fmt.Errorf("CSV file %s: %w", csvPath, err)
+	}
+	return targetMap, nil
+}
+
+// parseCSVRecords builds the target set from the CSV records read from r,
+// as described for parseTargetsCSV.
+func parseCSVRecords(r io.Reader, opts Options) (map[string]string, error) {
+	reader := csv.NewReader(r)
+	// rows with and without a comment column can be mixed
+	reader.FieldsPerRecord = -1
 	targets, err := reader.ReadAll()
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse CSV file: %w", err)
 	}
-	targetMap := make(map[string]bool)
+	var header []string
+	if opts.CSVHasHeader && len(targets) > 0 {
+		header, targets = targets[0], targets[1:]
+	}
+	column, err := csvColumn(header, opts)
+	if err != nil {
+		return nil, err
+	}
+	targetMap := make(map[string]string)
 
 	for _, target := range targets {
-		for _, name := range target {
-			trimmed := strings.TrimSpace(name)
-			if trimmed != "" {
-				targetMap[name] = true
+		// a cell starting with // is the comment for the row's names
+		comment := DefaultComment
+		var names []string
+		for i, cell := range target {
+			trimmed := strings.TrimSpace(cell)
+			if strings.HasPrefix(trimmed, "//") {
+				comment = trimmed
+			} else if trimmed != "" && (column < 0 || i == column) {
+				names = append(names, trimmed)
 			}
 		}
+		for _, name := range names {
+			targetMap[name] = comment
+		}
 	}
 	return targetMap, nil
 }
 
-func parseTargets(targets string) map[string]bool {
-	targetMap := make(map[string]bool)
+// csvColumn returns the 0-based index of the column opts.CSVColumn selects
+// in a CSV with the given header, or -1 when every column holds names.
+func csvColumn(header []string, opts Options) (int, error) {
+	if opts.CSVColumn == "" {
+		return -1, nil
+	}
+	for i, cell := range header {
+		if strings.TrimSpace(cell) == opts.CSVColumn {
+			return i, nil
+		}
+	}
+	if n, err := strconv.Atoi(opts.CSVColumn); err == nil {
+		if n < 1 {
+			return 0, fmt.Errorf("invalid column %d: columns are numbered from 1", n)
+		}
+		return n - 1, nil
+	}
+	if !opts.CSVHasHeader {
+		return 0, fmt.Errorf("column %q is selected by name, but the CSV has no header (pass --csv-has-header)", opts.CSVColumn)
+	}
+	return 0, fmt.Errorf("no column named %q in header %q", opts.CSVColumn, strings.Join(header, ","))
+}
+
+// parseTargetsCSVDir merges the targets of every *.csv file directly inside
+// csvDir. Each file is sanitized against allowedBaseDir like a single --csv.
+func parseTargetsCSVDir(csvDir, allowedBaseDir string, opts Options) (map[string]string, error) {
+	safeDir, err := sanitizePath(csvDir, allowedBaseDir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := readDir(safeDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV directory: %w", err)
+	}
+	targetMap := make(map[string]string)
+	found := 0
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".csv" {
+			continue
+		}
+		found++
+		csvPath := filepath.Join(safeDir, entry.Name())
+		fileTargets, err := parseTargetsCSV(csvPath, allowedBaseDir, opts)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		for name, comment := range fileTargets {
+			targetMap[name] = comment
+		}
+		fmt.Fprintf(stdout, "%s: %d targets\n", csvPath, len(fileTargets))
+	}
+	if found == 0 {
+		fmt.Fprintf(stderr, "warning: no CSV files found in %s\n", safeDir)
+	}
+	return targetMap, nil
+}
+
+// foldTargets lower-cases every target name for case-insensitive lookups,
+// leaving path scopes alone. When names differing only by case configure
+// different comments, the comment of the name sorting first wins.
+func foldTargets(targetMap map[string]string) map[string]string {
+	folded := make(map[string]string, len(targetMap))
+	for _, key := range sortedTargets(targetMap) {
+		name, scope, scoped := strings.Cut(key, scopeSep)
+		foldedKey := strings.ToLower(name)
+		if scoped {
+			foldedKey += scopeSep + scope
+		}
+		if _, ok := folded[foldedKey]; !ok {
+			folded[foldedKey] = targetMap[key]
+		}
+	}
+	return folded
+}
+
+// sortedTargets returns the names in targetMap in sorted order, so anything
+// reported per target is reproducible regardless of map iteration order.
+func sortedTargets(targetMap map[string]string) []string {
+	names := make([]string, 0, len(targetMap))
+	for name := range targetMap {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// printTargets writes the target set to stdout, sorted by name.
+func printTargets(targetMap map[string]string) {
+	for _, name := range sortedTargets(targetMap) {
+		if comment := targetMap[name]; comment != DefaultComment {
+			fmt.Fprintf(stdout, "%s\t%s\n", name, comment)
+		} else {
+			fmt.Fprintln(stdout, name)
+		}
+	}
+}
+
+func parseTargets(targets string) map[string]string {
+	targetMap := make(map[string]string)
 	for _, target := range strings.Split(targets, ",") {
 		trimmed := strings.TrimSpace(target)
 		if trimmed != "" {
-			targetMap[trimmed] = true
+			targetMap[trimmed] = DefaultComment
 		}
 	}
 	return targetMap
@@ -182,8 +1524,18 @@ func sanitizePath(csvPath, baseDir string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to get absolute base directory: %w", err)
 	}
-	if !hasPrefix(absPath, baseAbs) {
+	if !withinDir(absPath, baseAbs) {
 		return "", fmt.Errorf("invalid path: %q is not within the allowed directory %q", absPath, baseAbs)
 	}
 	return absPath, nil
 }
+
+// isWithinDir reports whether path is dir or lies below it, comparing whole
+// path elements: /data-evil/x.csv is not within /data.
+func isWithinDir(path, dir string) bool {
+	rel, err := filepath.Rel(dir, path)
+	if err != nil {
+		return false
+	}
+	return rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) && !filepath.IsAbs(rel)
+}
@@ -1,8 +1,14 @@
 package addnosec
 
 import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
 	"go/format"
 	"go/parser"
+	"go/token"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,7 +16,9 @@ import (
 
 	"github.com/google/go-cmp/cmp"
 	"github.com/seanhuebl/sqlc-qol/v2/internal/config"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/globstar"
 	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pending"
 	"github.com/stretchr/testify/require"
 )
 
@@ -82,6 +90,113 @@ const c = "false flagged hardcoded credentials"
 			HasCsv:     true,
 			CsvTargets: "bar,foobar,c",
 		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "per-target comments, csv success",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec G101 -- rotated weekly
+const foobar = "false flagged hardcoded credentials" // #nosec
+const c = "false flagged hardcoded credentials" //lint:ignore SA1019 legacy
+const d = "false flagged hardcoded credentials" //lint:ignore SA1019 legacy
+`,
+			},
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials"
+const foobar = "false flagged hardcoded credentials"
+const c = "false flagged hardcoded credentials"
+const d = "false flagged hardcoded credentials"
+`,
+			HasCsv:     true,
+			CsvTargets: "bar,,// #nosec G101 -- rotated weekly\nfoobar\nc,d,//lint:ignore SA1019 legacy\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "ignore directive skips spec",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // sqlc-qol:ignore
+const foobar = "false flagged hardcoded credentials" // #nosec
+
+// sqlc-qol:ignore
+const c = "false flagged hardcoded credentials"
+`,
+			},
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // sqlc-qol:ignore
+const foobar = "false flagged hardcoded credentials"
+
+// sqlc-qol:ignore
+const c = "false flagged hardcoded credentials"
+`,
+			Targets: "bar,foobar,c",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "ignore directive inside const block",
+				ExpectedContent: `package foo
+
+const (
+	bar    = "false flagged hardcoded credentials" // #nosec
+	foobar = "false flagged hardcoded credentials" // sqlc-qol:ignore
+)
+`,
+			},
+			InitContent: `package foo
+
+const (
+	bar    = "false flagged hardcoded credentials"
+	foobar = "false flagged hardcoded credentials" // sqlc-qol:ignore
+)
+`,
+			Targets: "bar,foobar",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "same-line declarations",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials"
+const foobar = "false flagged hardcoded credentials" // #nosec
+
+const (
+	a = "false flagged hardcoded credentials"
+	c = "false flagged hardcoded credentials" // #nosec
+)
+`,
+			},
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials"; const foobar = "false flagged hardcoded credentials"
+
+const (a = "false flagged hardcoded credentials"; c = "false flagged hardcoded credentials")
+`,
+			Targets: "foobar,c",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "same-line declarations, first of each tagged",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+const foobar = "false flagged hardcoded credentials"
+
+const (
+	a = "false flagged hardcoded credentials" // #nosec
+	c = "false flagged hardcoded credentials"
+)
+`,
+			},
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials"; const foobar = "false flagged hardcoded credentials"
+
+const (a = "false flagged hardcoded credentials"; c = "false flagged hardcoded credentials")
+`,
+			Targets: "bar,a",
+		},
 		{
 			BaseTestCase: helpers.BaseTestCase{
 				Name:              "simulate parse file error",
@@ -171,7 +286,7 @@ const bar = "false flagged hardcoded credentials"
 		},
 		{
 			BaseTestCase: helpers.BaseTestCase{
-				Name:              "simulate hasPrefix error",
+				Name:              "simulate withinDir error",
 				ExpectedContent:   "",
 				ExpectedErrSubStr: "is not within the allowed directory",
 			},
@@ -231,9 +346,9 @@ const bar = "false flagged hardcoded credentials"
 				openFile = os.Open
 				pathAbs = filepath.Abs
 				baseAbs = filepath.Abs
-				hasPrefix = strings.HasPrefix
+				withinDir = isWithinDir
 
-				openFile, pathAbs, baseAbs, hasPrefix = helpers.ExecuteAddnosecErrors(tc, openFile, pathAbs, baseAbs, hasPrefix)
+				openFile, pathAbs, baseAbs, withinDir = helpers.ExecuteAddnosecErrors(tc, openFile, pathAbs, baseAbs, withinDir)
 
 				tmpDataDir := filepath.Join(tmpDir, "data")
 				os.Mkdir(tmpDataDir, 0755)
@@ -247,10 +362,10 @@ const bar = "false flagged hardcoded credentials"
 				if _, err := tempCSV.Write([]byte(tc.CsvTargets)); err != nil {
 					t.Fatalf("failed to write to temp csv: %v", err)
 				}
-				err = Run(contentFile, tc.Targets, tempCSV.Name(), config.Config{AllowedBaseDir: tmpDataDir})
+				err = Run(contentFile, tc.Targets, tempCSV.Name(), config.Config{AllowedBaseDir: tmpDataDir}, Options{})
 
 			} else {
-				err = Run(contentFile, tc.Targets, "", config.Config{})
+				err = Run(contentFile, tc.Targets, "", config.Config{}, Options{})
 			}
 
 			if tc.ExpectedErrSubStr != "" {
@@ -274,3 +389,2421 @@ const bar = "false flagged hardcoded credentials"
 		})
 	}
 }
+
+// requireContent compares the content of file against the gofmt'd form of
+// expected.
+func requireContent(t *testing.T, file, expected string) {
+	t.Helper()
+	got, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatalf("failed to read content file: %v", err)
+	}
+	formattedExpected, err := format.Source([]byte(expected))
+	if err != nil {
+		t.Fatalf("failed to format expected content with gofmt standards: %v", err)
+	}
+	if diff := cmp.Diff(string(formattedExpected), string(got)); diff != "" {
+		t.Errorf("content file mismatch (-want +got)\n%s", diff)
+	}
+}
+
+func TestSanitizePath(t *testing.T) {
+	pathAbs = filepath.Abs
+	baseAbs = filepath.Abs
+	withinDir = isWithinDir
+
+	base := filepath.Join(t.TempDir(), "data")
+	tests := []struct {
+		Name              string
+		Path              string
+		ExpectedErrSubStr string
+	}{
+		{Name: "inside", Path: filepath.Join(base, "targets.csv")},
+		{Name: "nested", Path: filepath.Join(base, "team", "targets.csv")},
+		{Name: "dot dot back inside", Path: filepath.Join(base, "team", "..", "targets.csv")},
+		{
+			Name:              "sibling sharing the prefix",
+			Path:              filepath.Join(base+"-evil", "targets.csv"),
+			ExpectedErrSubStr: "is not within the allowed directory",
+		},
+		{
+			Name:              "parent",
+			Path:              filepath.Join(base, "..", "targets.csv"),
+			ExpectedErrSubStr: "is not within the allowed directory",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			got, err := sanitizePath(tc.Path, base)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, filepath.Clean(tc.Path), got)
+		})
+	}
+}
+
+func TestRunCSVDir(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		CSVFiles map[string]string
+		Targets  string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "targets merged across files",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+const foobar = "false flagged hardcoded credentials" // #nosec
+const c = "false flagged hardcoded credentials"
+`,
+			},
+			CSVFiles: map[string]string{
+				"team-a.csv": "bar",
+				"team-b.csv": "foobar",
+				"notes.txt":  "c",
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "empty directory tags nothing",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials"
+const foobar = "false flagged hardcoded credentials"
+const c = "false flagged hardcoded credentials"
+`,
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "csv dir and targets both set",
+				ExpectedErrSubStr: "cannot specify csvDir together with targets or csvPath",
+			},
+			Targets: "bar",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			openFile = os.Open
+			readDir = os.ReadDir
+			pathAbs = filepath.Abs
+			baseAbs = filepath.Abs
+			withinDir = isWithinDir
+
+			tmpDir := t.TempDir()
+			contentFile := filepath.Join(tmpDir, "content.sql.go")
+			initContent := `package foo
+
+const bar = "false flagged hardcoded credentials"
+const foobar = "false flagged hardcoded credentials"
+const c = "false flagged hardcoded credentials"
+`
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			csvDir := filepath.Join(tmpDir, "data", "suppressions")
+			if err := os.MkdirAll(csvDir, 0755); err != nil {
+				t.Fatalf("failed to create csv dir: %v", err)
+			}
+			for name, content := range tc.CSVFiles {
+				if err := os.WriteFile(filepath.Join(csvDir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("failed to write csv file: %v", err)
+				}
+			}
+
+			err := Run(contentFile, tc.Targets, "", config.Config{AllowedBaseDir: filepath.Join(tmpDir, "data")}, Options{CSVDir: csvDir})
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			} else if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			requireContent(t, contentFile, tc.ExpectedContent)
+		})
+	}
+}
+
+func TestRunCSVColumns(t *testing.T) {
+	untagged := `package foo
+
+const bar = "false flagged hardcoded credentials"
+const foobar = "false flagged hardcoded credentials"
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		CSV     string
+		Options Options
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "header skipped",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+const foobar = "false flagged hardcoded credentials"
+`,
+			},
+			CSV:     "const_name\nbar\n",
+			Options: Options{CSVHasHeader: true},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "column by name",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+const foobar = "false flagged hardcoded credentials" // #nosec G101 -- sqlc query text
+`,
+			},
+			CSV:     "rule,const_name,file,comment\nG101,bar,internal/db/a.sql.go,\nG101,foobar,internal/db/b.sql.go,// #nosec G101 -- sqlc query text\n",
+			Options: Options{CSVHasHeader: true, CSVColumn: "const_name"},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "column by index",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials"
+const foobar = "false flagged hardcoded credentials" // #nosec
+`,
+			},
+			CSV:     "bar,foobar\n",
+			Options: Options{CSVColumn: "2"},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "names padded with spaces",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+const foobar = "false flagged hardcoded credentials" // #nosec
+`,
+			},
+			CSV: "bar, foobar\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "unknown column name",
+				ExpectedErrSubStr: `no column named "name" in header "rule,const_name"`,
+			},
+			CSV:     "rule,const_name\nG101,bar\n",
+			Options: Options{CSVHasHeader: true, CSVColumn: "name"},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "column name without header",
+				ExpectedErrSubStr: `column "const_name" is selected by name, but the CSV has no header`,
+			},
+			CSV:     "bar\n",
+			Options: Options{CSVColumn: "const_name"},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "column index out of range",
+				ExpectedErrSubStr: "invalid column 0: columns are numbered from 1",
+			},
+			CSV:     "bar\n",
+			Options: Options{CSVColumn: "0"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			openFile = os.Open
+			pathAbs = filepath.Abs
+			baseAbs = filepath.Abs
+			withinDir = isWithinDir
+
+			tmpDir := t.TempDir()
+			contentFile := filepath.Join(tmpDir, "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(untagged), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			csvPath := filepath.Join(tmpDir, "targets.csv")
+			if err := os.WriteFile(csvPath, []byte(tc.CSV), 0644); err != nil {
+				t.Fatalf("failed to write csv file: %v", err)
+			}
+
+			err := Run(contentFile, "", csvPath, config.Config{AllowedBaseDir: tmpDir}, tc.Options)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				requireContent(t, contentFile, untagged)
+				return
+			}
+			require.NoError(t, err)
+			requireContent(t, contentFile, tc.ExpectedContent)
+		})
+	}
+}
+
+func TestRunStdinTargets(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		Targets string
+		CSVPath string
+		Stdin   string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "names separated by newlines and commas",
+				ExpectedContent: `package foo
+
+const bar = "x" // #nosec
+const foobar = "x" // #nosec
+const c = "x" // #nosec
+`,
+			},
+			Targets: StdinPath,
+			Stdin:   "bar\r\nfoobar, c\n\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "csv records",
+				ExpectedContent: `package foo
+
+const bar = "x" // #nosec G101
+const foobar = "x"
+const c = "x" // #nosec
+`,
+			},
+			CSVPath: StdinPath,
+			Stdin:   "bar,// #nosec G101\nc\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "stdin for both sources",
+				ExpectedErrSubStr: "cannot specify both targets and csvPath",
+			},
+			Targets: StdinPath,
+			CSVPath: StdinPath,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			stdin = strings.NewReader(tc.Stdin)
+			defer func() { stdin = os.Stdin }()
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			initContent := "package foo\n\nconst bar = \"x\"\nconst foobar = \"x\"\nconst c = \"x\"\n"
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+
+			err := Run(contentFile, tc.Targets, tc.CSVPath, config.Config{}, Options{})
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			requireContent(t, contentFile, tc.ExpectedContent)
+		})
+	}
+}
+
+func TestRunReasons(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		CSV           string
+		Targets       string
+		Reasons       string
+		RequireReason bool
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "csv targets with reasons from the mapping",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec G101 -- fixture credentials
+const foobar = "false flagged hardcoded credentials" // #nosec G101 -- reviewed by security
+const c = "false flagged hardcoded credentials" // #nosec
+`,
+			},
+			CSV:     "bar,// #nosec G101\nfoobar,// #nosec G101 -- reviewed by security\nc\n",
+			Reasons: "bar: fixture credentials\nfoobar: overridden by the csv\nunused: not a target\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "scoped comma-list targets",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec -- fixture credentials
+const foobar = "false flagged hardcoded credentials"
+const c = "false flagged hardcoded credentials"
+`,
+			},
+			Targets: "bar@*.sql.go",
+			Reasons: "bar: fixture credentials\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "require reason",
+				ExpectedErrSubStr: "1 targets have no suppression reason (add them to the reasons file or drop --require-reason):\n  c",
+			},
+			CSV:           "bar\nc\n",
+			Reasons:       "bar: fixture credentials\n",
+			RequireReason: true,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "multi-line reason",
+				ExpectedErrSubStr: "reason for bar spans several lines",
+			},
+			Targets: "bar",
+			Reasons: "bar: |\n  first\n  second\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "malformed reasons file",
+				ExpectedErrSubStr: "failed to parse reasons file",
+			},
+			Targets: "bar",
+			Reasons: "- bar\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			readFile = os.ReadFile
+			openFile = os.Open
+			pathAbs = filepath.Abs
+			baseAbs = filepath.Abs
+			withinDir = isWithinDir
+			getwd = os.Getwd
+
+			// scopes match paths relative to the working directory
+			tmpDir := t.TempDir()
+			t.Chdir(tmpDir)
+			contentFile := "content.sql.go"
+			initContent := `package foo
+
+const bar = "false flagged hardcoded credentials"
+const foobar = "false flagged hardcoded credentials"
+const c = "false flagged hardcoded credentials"
+`
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			csvPath := ""
+			if tc.CSV != "" {
+				csvPath = filepath.Join(tmpDir, "targets.csv")
+				if err := os.WriteFile(csvPath, []byte(tc.CSV), 0644); err != nil {
+					t.Fatalf("failed to write csv file: %v", err)
+				}
+			}
+			reasonsPath := filepath.Join(tmpDir, "reasons.yaml")
+			if err := os.WriteFile(reasonsPath, []byte(tc.Reasons), 0644); err != nil {
+				t.Fatalf("failed to write reasons file: %v", err)
+			}
+
+			opts := Options{Reasons: reasonsPath, RequireReason: tc.RequireReason}
+			err := Run(contentFile, tc.Targets, csvPath, config.Config{AllowedBaseDir: tmpDir}, opts)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				got, readErr := os.ReadFile(contentFile)
+				require.NoError(t, readErr)
+				require.Equal(t, initContent, string(got), "nothing is written")
+				return
+			} else if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			requireContent(t, contentFile, tc.ExpectedContent)
+		})
+	}
+}
+
+func TestRunPrintTargets(t *testing.T) {
+	tests := []struct {
+		name            string
+		csv             string
+		caseInsensitive bool
+		expected        string
+	}{
+		{
+			name:     "sorted",
+			csv:      "foobar,// #nosec G101\nbar\nbaz,a\n",
+			expected: "a\nbar\nbaz\nfoobar\t// #nosec G101\n",
+		},
+		{
+			name:     "sorted regardless of csv order",
+			csv:      "baz,a\nfoobar,// #nosec G101\nbar\n",
+			expected: "a\nbar\nbaz\nfoobar\t// #nosec G101\n",
+		},
+		{
+			name:            "case collisions resolve to the first name",
+			csv:             "foo,// #nosec G204\nFoo,// #nosec G101\n",
+			caseInsensitive: true,
+			expected:        "foo\t// #nosec G101\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			openFile = os.Open
+			pathAbs = filepath.Abs
+			baseAbs = filepath.Abs
+			withinDir = isWithinDir
+			var out bytes.Buffer
+			stdout = &out
+			defer func() { stdout = os.Stdout }()
+
+			tmpDir := t.TempDir()
+			csvPath := filepath.Join(tmpDir, "targets.csv")
+			if err := os.WriteFile(csvPath, []byte(tc.csv), 0644); err != nil {
+				t.Fatalf("failed to write csv file: %v", err)
+			}
+			opts := Options{PrintTargets: true, CaseInsensitive: tc.caseInsensitive}
+			require.NoError(t, Run(filepath.Join(tmpDir, "*.sql.go"), "", csvPath, config.Config{AllowedBaseDir: tmpDir}, opts))
+			require.Equal(t, tc.expected, out.String())
+		})
+	}
+}
+
+func TestRunScopedTargets(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		Targets         string
+		ExpectedOther   string
+		CaseInsensitive bool
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "scoped entry only applies to matching files",
+				ExpectedContent: `package database
+
+const mySecret = "x" // #nosec
+const shared = "x" // #nosec
+`,
+			},
+			Targets: "mySecret@internal/database/*,shared",
+			ExpectedOther: `package other
+
+const mySecret = "x"
+const shared = "x" // #nosec
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "scope kept when folding case",
+				ExpectedContent: `package database
+
+const mySecret = "x" // #nosec
+const shared = "x"
+`,
+			},
+			Targets:         "MYSECRET@internal/database/*",
+			CaseInsensitive: true,
+			ExpectedOther: `package other
+
+const mySecret = "x"
+const shared = "x"
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "invalid scope",
+				ExpectedErrSubStr: `invalid scope in target "mySecret@internal/[database"`,
+			},
+			Targets: "mySecret@internal/[database",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "empty scope",
+				ExpectedErrSubStr: `invalid target "mySecret@": expected name@glob`,
+			},
+			Targets: "mySecret@",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			stat = os.Stat
+
+			base := t.TempDir()
+			dbFile := filepath.Join(base, "internal", "database", "secrets.sql.go")
+			otherFile := filepath.Join(base, "internal", "other", "secrets.go")
+			for file, pkg := range map[string]string{dbFile: "database", otherFile: "other"} {
+				if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+					t.Fatalf("failed to create dir: %v", err)
+				}
+				content := "package " + pkg + "\n\nconst mySecret = \"x\"\nconst shared = \"x\"\n"
+				if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+					t.Fatalf("failed to write content file: %v", err)
+				}
+			}
+
+			opts := Options{GlobBase: base, CaseInsensitive: tc.CaseInsensitive}
+			err := Run("internal/*/*.go", tc.Targets, "", config.Config{}, opts)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			requireContent(t, dbFile, tc.ExpectedContent)
+			requireContent(t, otherFile, tc.ExpectedOther)
+
+			drift, err := CheckPlacement("internal/*/*.go", tc.Targets, "", config.Config{}, opts, false)
+			require.NoError(t, err)
+			require.Empty(t, drift)
+		})
+	}
+}
+
+func TestScopedTargets(t *testing.T) {
+	getwd = func() (string, error) { return "/repo", nil }
+	defer func() { getwd = os.Getwd }()
+
+	targetMap := map[string]string{
+		"a":               DefaultComment,
+		"a@internal/db/*": "// #nosec G101",
+		"b@internal/db/*": DefaultComment,
+	}
+	require.Equal(t, map[string]string{"a": "// #nosec G101", "b": DefaultComment}, scopedTargets(targetMap, "/repo/internal/db/x.go", Options{}))
+	require.Equal(t, map[string]string{"a": "// #nosec G101", "b": DefaultComment}, scopedTargets(targetMap, "internal/db/x.go", Options{}))
+	require.Equal(t, map[string]string{"a": DefaultComment}, scopedTargets(targetMap, "/repo/internal/api/x.go", Options{}))
+	require.Equal(t, map[string]string{"a": DefaultComment}, scopedTargets(targetMap, "internal/db/nested/x.go", Options{}))
+}
+
+func TestRunIncremental(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	formatNode = format.Node
+
+	var written []string
+	createFile = func(name string) (*os.File, error) {
+		written = append(written, filepath.Base(name))
+		return os.Create(name)
+	}
+	defer func() { createFile = os.Create }()
+
+	tmpDir := t.TempDir()
+	initContent := `package foo
+
+const bar = "false flagged hardcoded credentials"
+`
+	for _, name := range []string{"a.sql.go", "b.sql.go"} {
+		if err := os.WriteFile(filepath.Join(tmpDir, name), []byte(initContent), 0644); err != nil {
+			t.Fatalf("failed to write content file: %v", err)
+		}
+	}
+	pattern := filepath.Join(tmpDir, "*.sql.go")
+	opts := Options{Incremental: true, StateFile: filepath.Join(tmpDir, "state.json")}
+
+	require.NoError(t, Run(pattern, "bar", "", config.Config{}, opts))
+	require.Equal(t, []string{"a.sql.go", "b.sql.go"}, written)
+
+	// only the regenerated file is processed on the next run
+	written = nil
+	if err := os.WriteFile(filepath.Join(tmpDir, "b.sql.go"), []byte(initContent), 0644); err != nil {
+		t.Fatalf("failed to rewrite content file: %v", err)
+	}
+	require.NoError(t, Run(pattern, "bar", "", config.Config{}, opts))
+	require.Equal(t, []string{"b.sql.go"}, written)
+
+	// changing the options invalidates the state
+	written = nil
+	require.NoError(t, Run(pattern, "bar,baz", "", config.Config{}, opts))
+	require.Equal(t, []string{"a.sql.go", "b.sql.go"}, written)
+
+	// nothing changed, nothing processed
+	written = nil
+	require.NoError(t, Run(pattern, "bar,baz", "", config.Config{}, opts))
+	require.Empty(t, written)
+
+	// reset forces a full pass
+	opts.ResetIncremental = true
+	require.NoError(t, Run(pattern, "bar,baz", "", config.Config{}, opts))
+	require.Equal(t, []string{"a.sql.go", "b.sql.go"}, written)
+}
+
+func TestRunConstType(t *testing.T) {
+	initContent := `package foo
+
+import "example.com/auth"
+
+type APIKey string
+
+const apiKey APIKey = "false flagged hardcoded credentials"
+const token auth.APIKey = "false flagged hardcoded credentials"
+const secret string = "false flagged hardcoded credentials"
+const untyped = "false flagged hardcoded credentials"
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		ConstType string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "bare type name matches local and qualified types",
+				ExpectedContent: `package foo
+
+import "example.com/auth"
+
+type APIKey string
+
+const apiKey APIKey = "false flagged hardcoded credentials" // #nosec
+const token auth.APIKey = "false flagged hardcoded credentials" // #nosec
+const secret string = "false flagged hardcoded credentials"
+const untyped = "false flagged hardcoded credentials"
+`,
+			},
+			ConstType: "APIKey",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "qualified type name",
+				ExpectedContent: `package foo
+
+import "example.com/auth"
+
+type APIKey string
+
+const apiKey APIKey = "false flagged hardcoded credentials"
+const token auth.APIKey = "false flagged hardcoded credentials" // #nosec
+const secret string = "false flagged hardcoded credentials"
+const untyped = "false flagged hardcoded credentials"
+`,
+			},
+			ConstType: "auth.APIKey",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			err := Run(contentFile, "apiKey,token,secret,untyped", "", config.Config{}, Options{ConstType: tc.ConstType})
+			require.NoError(t, err)
+
+			requireContent(t, contentFile, tc.ExpectedContent)
+		})
+	}
+}
+
+func TestRunDeclKinds(t *testing.T) {
+	constContent := `package foo
+
+const secret = "x"
+`
+	varContent := `package foo
+
+var secret = "x"
+
+func config() Config {
+	_ = map[string]string{secret: "y"}
+	return Config{
+		secret: "x",
+		other:  secret,
+	}
+}
+
+var c = Config{secret: "x"} // keep
+`
+	tests := []struct {
+		Name            string
+		Kinds           []string
+		ExpectedConst   string
+		ExpectedVar     string
+		ExpectedWarning string
+		ExpectedErr     string
+	}{
+		{
+			Name:          "default tags consts and vars",
+			ExpectedConst: "package foo\n\nconst secret = \"x\" // #nosec\n",
+			ExpectedVar:   strings.Replace(varContent, `var secret = "x"`, `var secret = "x" // #nosec`, 1),
+		},
+		{
+			Name:          "consts only",
+			Kinds:         []string{KindConst},
+			ExpectedConst: "package foo\n\nconst secret = \"x\" // #nosec\n",
+			ExpectedVar:   varContent,
+		},
+		{
+			Name:          "every kind",
+			Kinds:         []string{KindConst, KindVar, KindField},
+			ExpectedConst: "package foo\n\nconst secret = \"x\" // #nosec\n",
+			ExpectedVar: strings.NewReplacer(
+				`var secret = "x"`, `var secret = "x" // #nosec`,
+				`secret: "x",`, `secret: "x", // #nosec`,
+			).Replace(varContent),
+			ExpectedWarning: "field secret already has a trailing comment",
+		},
+		{
+			Name:        "unknown kind",
+			Kinds:       []string{"type"},
+			ExpectedErr: `invalid decl kind "type": expected const, var or field`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			readFile = os.ReadFile
+			var warnings bytes.Buffer
+			stderr = &warnings
+			defer func() { stderr = os.Stderr }()
+
+			dir := t.TempDir()
+			constFile := filepath.Join(dir, "const.sql.go")
+			varFile := filepath.Join(dir, "var.sql.go")
+			require.NoError(t, os.WriteFile(constFile, []byte(constContent), 0644))
+			require.NoError(t, os.WriteFile(varFile, []byte(varContent), 0644))
+
+			err := Run(filepath.Join(dir, "*.sql.go"), "secret", "", config.Config{}, Options{DeclKinds: tc.Kinds})
+			if tc.ExpectedErr != "" {
+				require.EqualError(t, err, tc.ExpectedErr)
+				return
+			}
+			require.NoError(t, err)
+			requireContent(t, constFile, tc.ExpectedConst)
+			requireContent(t, varFile, tc.ExpectedVar)
+			if tc.ExpectedWarning != "" {
+				require.Contains(t, warnings.String(), tc.ExpectedWarning)
+			}
+		})
+	}
+}
+
+func TestRunRules(t *testing.T) {
+	initContent := `package foo
+
+const bar = "x"
+const foobar = "x" // #nosec -- reviewed
+const c = "x" // #nosec G404
+
+// #nosec
+const d = "x"
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		Rules []string
+		CSV   string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "single rule, bare comments upgraded",
+				ExpectedContent: `package foo
+
+const bar = "x"    // #nosec G101
+const foobar = "x" // #nosec G101 -- reviewed
+const c = "x"      // #nosec G404
+
+// #nosec G101
+const d = "x"
+`,
+			},
+			Rules: []string{"G101"},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "several rules",
+				ExpectedContent: `package foo
+
+const bar = "x"    // #nosec G101 G404
+const foobar = "x" // #nosec G101 G404 -- reviewed
+const c = "x"      // #nosec G404
+
+// #nosec G101 G404
+const d = "x"
+`,
+			},
+			Rules: []string{"G101", "G404"},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "csv comment with its own rule kept",
+				ExpectedContent: `package foo
+
+const bar = "x"    // #nosec G204
+const foobar = "x" // #nosec -- reviewed
+const c = "x"      // #nosec G404
+
+// #nosec
+const d = "x"
+`,
+			},
+			Rules: []string{"G101"},
+			CSV:   "bar,// #nosec G204\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "invalid rule",
+				ExpectedErrSubStr: `invalid rule "101": expected a gosec rule ID such as G101`,
+			},
+			Rules: []string{"101"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			readFile = os.ReadFile
+			openFile = os.Open
+			pathAbs = filepath.Abs
+			baseAbs = filepath.Abs
+			withinDir = isWithinDir
+
+			tmpDir := t.TempDir()
+			contentFile := filepath.Join(tmpDir, "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			targets, csvPath := "bar,foobar,c,d", ""
+			if tc.CSV != "" {
+				targets, csvPath = "", filepath.Join(tmpDir, "targets.csv")
+				if err := os.WriteFile(csvPath, []byte(tc.CSV), 0644); err != nil {
+					t.Fatalf("failed to write csv file: %v", err)
+				}
+			}
+
+			err := Run(contentFile, targets, csvPath, config.Config{AllowedBaseDir: tmpDir}, Options{Rules: tc.Rules})
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			} else if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+
+			requireContent(t, contentFile, tc.ExpectedContent)
+		})
+	}
+}
+
+func TestRunCaseInsensitive(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+
+	contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+	initContent := `package foo
+
+const createAPIKey = "false flagged hardcoded credentials"
+const RevokeToken = "false flagged hardcoded credentials"
+const other = "false flagged hardcoded credentials"
+`
+	if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	require.NoError(t, Run(contentFile, "CreateApiKey,revoketoken", "", config.Config{}, Options{CaseInsensitive: true}))
+	requireContent(t, contentFile, `package foo
+
+const createAPIKey = "false flagged hardcoded credentials" // #nosec
+const RevokeToken = "false flagged hardcoded credentials" // #nosec
+const other = "false flagged hardcoded credentials"
+`)
+}
+
+func TestRunSkipDirective(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	formatNode = format.Node
+	var created []string
+	createFile = func(name string) (*os.File, error) {
+		created = append(created, name)
+		return os.Create(name)
+	}
+	defer func() { createFile = os.Create }()
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	dir := t.TempDir()
+	skipped := filepath.Join(dir, "skipped.sql.go")
+	tagged := filepath.Join(dir, "tagged.sql.go")
+	skippedContent := `// sqlc-qol:skip
+package foo
+
+const bar = "false flagged hardcoded credentials"
+`
+	if err := os.WriteFile(skipped, []byte(skippedContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	if err := os.WriteFile(tagged, []byte("package foo\n\nconst baz = \"x\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	require.NoError(t, Run(filepath.Join(dir, "*.sql.go"), "bar,baz", "", config.Config{}, Options{}))
+	require.Equal(t, []string{tagged}, created)
+	require.Equal(t, skipped+": skipped by sqlc-qol:skip directive\n", out.String())
+	requireContent(t, skipped, skippedContent)
+}
+
+func TestRunRequireGeneratedHeader(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+
+	dir := t.TempDir()
+	generated := filepath.Join(dir, "generated.go")
+	handWritten := filepath.Join(dir, "handwritten.go")
+	generatedContent := `// Code generated by sqlc. DO NOT EDIT.
+
+package foo
+
+const bar = "false flagged hardcoded credentials"
+`
+	handWrittenContent := `package foo
+
+const (
+	bar = "real credentials"
+	baz = "real credentials"
+)
+`
+	if err := os.WriteFile(generated, []byte(generatedContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	if err := os.WriteFile(handWritten, []byte(handWrittenContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+
+	err := Run(filepath.Join(dir, "*.go"), "bar,baz", "", config.Config{}, Options{RequireGeneratedHeader: true})
+	require.EqualError(t, err, "targets matched in 1 files without a generated-code header, left untouched:\n  "+
+		handWritten+":4: bar\n  "+handWritten+":5: baz")
+	requireContent(t, handWritten, handWrittenContent)
+	requireContent(t, generated, `// Code generated by sqlc. DO NOT EDIT.
+
+package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+`)
+}
+
+func TestRunGlobBase(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+	stat = os.Stat
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	base := t.TempDir()
+	contentFile := filepath.Join(base, "database", "content.sql.go")
+	if err := os.MkdirAll(filepath.Dir(contentFile), 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	if err := os.WriteFile(contentFile, []byte("package foo\n\nconst bar = \"x\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+
+	require.NoError(t, Run("database/*.sql.go", "bar", "", config.Config{}, Options{GlobBase: base, ShowChanges: true}))
+	require.Equal(t, filepath.Join("database", "content.sql.go")+":3: const bar -> // #nosec\n1 specs tagged\n", out.String())
+	requireContent(t, contentFile, "package foo\n\nconst bar = \"x\" // #nosec\n")
+
+	err := Run("*.sql.go", "bar", "", config.Config{}, Options{GlobBase: filepath.Join(base, "missing")})
+	require.ErrorContains(t, err, "invalid glob base")
+	err = Run("*.sql.go", "bar", "", config.Config{}, Options{GlobBase: contentFile})
+	require.EqualError(t, err, "invalid glob base: "+contentFile+" is not a directory")
+}
+
+func TestRunCheck(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	formatNode = format.Node
+	readFile = os.ReadFile
+	var created []string
+	createFile = func(name string) (*os.File, error) {
+		created = append(created, name)
+		return os.Create(name)
+	}
+	defer func() { createFile = os.Create }()
+
+	dir := t.TempDir()
+	pendingFile := filepath.Join(dir, "pending.sql.go")
+	doneFile := filepath.Join(dir, "done.sql.go")
+	pendingContent := "package foo\n\nconst bar = \"x\"\n"
+	if err := os.WriteFile(pendingFile, []byte(pendingContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	if err := os.WriteFile(doneFile, []byte("package foo\n\nconst baz = \"x\" // #nosec\n"), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+
+	err := Run(filepath.Join(dir, "*.sql.go"), "bar,baz", "", config.Config{}, Options{Check: true})
+	var pendingErr *pending.Error
+	require.ErrorAs(t, err, &pendingErr)
+	require.Equal(t, &pending.Error{Operation: "add-nosec", Files: []string{pendingFile}}, pendingErr)
+	require.Empty(t, created)
+	requireContent(t, pendingFile, pendingContent)
+
+	require.NoError(t, Run(filepath.Join(dir, "done.sql.go"), "bar,baz", "", config.Config{}, Options{Check: true}))
+}
+
+func TestRunCheckDiff(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	formatNode = format.Node
+	readFile = os.ReadFile
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	dir := t.TempDir()
+	pendingFile := filepath.Join(dir, "pending.sql.go")
+	pendingContent := "package foo\n\nconst bar = \"x\"\n"
+	if err := os.WriteFile(pendingFile, []byte(pendingContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "done.sql.go"), []byte("package foo\n\nconst baz = \"x\" // #nosec\n"), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+
+	err := Run(filepath.Join(dir, "*.sql.go"), "bar,baz", "", config.Config{}, Options{Check: true, Diff: true})
+	var pendingErr *pending.Error
+	require.ErrorAs(t, err, &pendingErr)
+	expected := "--- " + pendingFile + "\n" +
+		"+++ " + pendingFile + "\n" +
+		"@@ -1,3 +1,3 @@\n" +
+		" package foo\n" +
+		" \n" +
+		"-const bar = \"x\"\n" +
+		"+const bar = \"x\" // #nosec\n"
+	require.Equal(t, expected, out.String())
+	requireContent(t, pendingFile, pendingContent)
+}
+
+func TestRunWithResult(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	formatNode = format.Node
+	readFile = os.ReadFile
+	createFile = os.Create
+
+	t.Chdir(t.TempDir())
+	if err := os.WriteFile("a.sql.go", []byte("package foo\n\nconst bar = \"x\"\n\nconst baz = \"x\" // #nosec\n"), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	if err := os.WriteFile("b.sql.go", []byte("package foo\n\nvar qux = \"x\"\n"), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+
+	res, err := RunWithResult("*.sql.go", "bar,baz,qux,missing,bar@other/*", "", config.Config{}, Options{})
+	require.NoError(t, err)
+	expected := Result{
+		Files: []FileResult{
+			{Path: "a.sql.go", Tagged: []string{"bar"}, Skipped: []string{"baz"}},
+			{Path: "b.sql.go", Tagged: []string{"qux"}},
+		},
+		Unmatched: []string{"bar@other/*", "missing"},
+	}
+	if diff := cmp.Diff(expected, res); diff != "" {
+		t.Errorf("result mismatch (-want +got):\n%s", diff)
+	}
+	// unmatched targets are warned about on stderr, not repeated here
+	require.Equal(t, "2 files processed, 2 targets tagged, 1 skipped\n", res.Summary())
+
+	// a second run skips everything, already tagged
+	res, err = RunWithResult("*.sql.go", "bar,baz,qux", "", config.Config{}, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "2 files processed, 0 targets tagged, 3 skipped\n", res.Summary())
+}
+
+func TestRunUnmatchedTargets(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	formatNode = format.Node
+	readFile = os.ReadFile
+	createFile = os.Create
+	var warnings bytes.Buffer
+	stderr = &warnings
+	defer func() { stderr = os.Stderr }()
+
+	t.Chdir(t.TempDir())
+	content := "package foo\n\nconst bar = \"x\"\n"
+	if err := os.WriteFile("a.sql.go", []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+
+	// strict mode fails before anything is reported as pending
+	err := Run("*.sql.go", "bar,bax,qux", "", config.Config{}, Options{Strict: true, Check: true})
+	require.EqualError(t, err, "2 targets matched nothing in the files processed (check for typos):\n  bax\n  qux")
+	require.Empty(t, warnings.String())
+
+	err = Run("*.sql.go", "bar,bax,qux", "", config.Config{}, Options{})
+	require.NoError(t, err)
+	require.Equal(t, "warning: target bax matched nothing in the files processed\n"+
+		"warning: target qux matched nothing in the files processed\n", warnings.String())
+	requireContent(t, "a.sql.go", "package foo\n\nconst bar = \"x\" // #nosec\n")
+}
+
+func TestRunRecursiveGlob(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = globstar.Glob
+	defer func() { glob = filepath.Glob }()
+	formatNode = format.Node
+	readFile = os.ReadFile
+	createFile = os.Create
+
+	t.Chdir(t.TempDir())
+	content := "package foo\n\nconst bar = \"x\"\n"
+	for _, name := range []string{"internal/a.sql.go", "internal/db/users/b.sql.go"} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+	}
+
+	err := Run("internal/**/*.sql.go", "bar", "", config.Config{}, Options{})
+	require.NoError(t, err)
+	expected := "package foo\n\nconst bar = \"x\" // #nosec\n"
+	requireContent(t, "internal/a.sql.go", expected)
+	requireContent(t, "internal/db/users/b.sql.go", expected)
+}
+
+func TestRunZipOut(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	formatNode = format.Node
+	readFile = os.ReadFile
+	createFile = os.Create
+
+	t.Chdir(t.TempDir())
+	pendingContent := "package foo\n\nconst bar = \"x\"\n"
+	doneContent := "package foo\n\nconst baz = \"x\" // #nosec\n"
+	if err := os.WriteFile("pending.sql.go", []byte(pendingContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	if err := os.WriteFile("done.sql.go", []byte(doneContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	tagged := "package foo\n\nconst bar = \"x\" // #nosec\n"
+
+	// a dry run leaves the tree alone and archives only the changed file
+	err := Run("*.sql.go", "bar,baz", "", config.Config{}, Options{Check: true, ZipOut: "changed.zip", ChangedOnly: true})
+	var pendingErr *pending.Error
+	require.ErrorAs(t, err, &pendingErr)
+	requireContent(t, "pending.sql.go", pendingContent)
+	got, err := helpers.ReadZip("changed.zip")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"pending.sql.go": tagged}, got)
+
+	// a regular run writes in place and archives every processed file
+	require.NoError(t, Run("*.sql.go", "bar,baz", "", config.Config{}, Options{ZipOut: "all.zip"}))
+	requireContent(t, "pending.sql.go", tagged)
+	got, err = helpers.ReadZip("all.zip")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"pending.sql.go": tagged, "done.sql.go": doneContent}, got)
+
+	// with nothing left to change the archive is empty
+	require.NoError(t, Run("*.sql.go", "bar,baz", "", config.Config{}, Options{ZipOut: "none.zip", ChangedOnly: true}))
+	got, err = helpers.ReadZip("none.zip")
+	require.NoError(t, err)
+	require.Empty(t, got)
+}
+
+func TestRunEmitPositions(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	formatNode = format.Node
+	readFile = os.ReadFile
+	var created []string
+	createFile = func(name string) (*os.File, error) {
+		created = append(created, name)
+		return os.Create(name)
+	}
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { createFile, stdout = os.Create, os.Stdout }()
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "content.sql.go")
+	content := "package foo\n\nconst bar = \"x\"\n\nconst (\n\tbaz = \"x\" // #nosec\n\tfoo = \"x\"\n)\n"
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+
+	err := Run("*.sql.go", "bar,baz,foo", "", config.Config{}, Options{Check: true, EmitPositions: true, GlobBase: dir})
+	var pendingErr *pending.Error
+	require.ErrorAs(t, err, &pendingErr)
+	require.Equal(t, "content.sql.go:3:7: add-nosec bar\ncontent.sql.go:7:2: add-nosec foo\n", out.String())
+	require.Empty(t, created)
+	requireContent(t, file, content)
+}
+
+func TestRunReadOnly(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	formatNode = format.Node
+	readFile = os.ReadFile
+	var created []string
+	createFile = func(name string) (*os.File, error) {
+		created = append(created, name)
+		return os.Create(name)
+	}
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { createFile, stdout = os.Create, os.Stdout }()
+
+	dir := t.TempDir()
+	content := "package foo\n\nconst bar = \"x\"\n"
+	for _, name := range []string{"a.sql.go", "b.sql.go", "c.sql.go"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	for _, name := range []string{"a.sql.go", "c.sql.go"} {
+		if err := os.Chmod(filepath.Join(dir, name), 0444); err != nil {
+			t.Fatalf("failed to chmod %s: %v", name, err)
+		}
+	}
+
+	// every read-only file is reported before any file is written
+	err := Run("*.sql.go", "bar", "", config.Config{}, Options{GlobBase: dir})
+	require.EqualError(t, err, "2 files are not writable (make them writable or pass --chmod):\n"+
+		"  "+filepath.Join(dir, "a.sql.go")+": read-only (mode -r--r--r--)\n"+
+		"  "+filepath.Join(dir, "c.sql.go")+": read-only (mode -r--r--r--)")
+	require.Empty(t, created)
+	requireContent(t, filepath.Join(dir, "b.sql.go"), content)
+
+	// dry runs write nothing, so read-only files don't matter
+	err = Run("*.sql.go", "bar", "", config.Config{}, Options{GlobBase: dir, Check: true})
+	var pendingErr *pending.Error
+	require.ErrorAs(t, err, &pendingErr)
+
+	out.Reset()
+	err = Run("*.sql.go", "bar", "", config.Config{}, Options{GlobBase: dir, Chmod: true})
+	require.NoError(t, err)
+	require.Contains(t, out.String(), "a.sql.go: made writable\nc.sql.go: made writable\n")
+	expected := "package foo\n\nconst bar = \"x\" // #nosec\n"
+	for _, name := range []string{"a.sql.go", "b.sql.go", "c.sql.go"} {
+		requireContent(t, filepath.Join(dir, name), expected)
+	}
+}
+
+func TestRunConcurrentModification(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Options           Options
+		ExpectedErrSubStr string
+	}{
+		{
+			Name:    "changed file skipped",
+			Options: Options{},
+		},
+		{
+			Name:    "changed file skipped by targeted edit",
+			Options: Options{TargetedEdit: true},
+		},
+		{
+			Name:    "changed file skipped with zip output",
+			Options: Options{ZipOut: "out.zip"},
+		},
+		{
+			Name:              "strict fails",
+			Options:           Options{Strict: true},
+			ExpectedErrSubStr: "changed on disk since it was read; refusing to overwrite it",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			var warnings bytes.Buffer
+			stderr = &warnings
+			t.Chdir(t.TempDir())
+
+			contentFile := "content.sql.go"
+			if err := os.WriteFile(contentFile, []byte("package foo\n\nconst bar = \"x\"\n"), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			// another process rewrites the file right after it is read
+			newer := "package foo\n\nconst bar = \"y\"\n"
+			reads := 0
+			readFile = func(name string) ([]byte, error) {
+				data, err := os.ReadFile(name)
+				if reads++; reads == 1 {
+					return data, os.WriteFile(name, []byte(newer), 0644)
+				}
+				return data, err
+			}
+			defer func() { readFile, stderr = os.ReadFile, os.Stderr }()
+
+			err := Run(contentFile, "bar", "", config.Config{}, tc.Options)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+			} else {
+				require.NoError(t, err)
+				require.Contains(t, warnings.String(), "warning: content.sql.go changed on disk since it was read; skipped it")
+			}
+			requireContent(t, contentFile, newer)
+			if tc.Options.ZipOut != "" {
+				entries, err := helpers.ReadZip(tc.Options.ZipOut)
+				require.NoError(t, err)
+				require.Empty(t, entries)
+			}
+		})
+	}
+}
+
+func TestRunSizeReport(t *testing.T) {
+	tests := []struct {
+		Name    string
+		Options Options
+	}{
+		{Name: "in-place rewrite"},
+		{Name: "targeted edit", Options: Options{TargetedEdit: true}},
+		{Name: "dry run", Options: Options{Check: true}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			readFile = os.ReadFile
+			var out bytes.Buffer
+			stdout = &out
+			defer func() { stdout = os.Stdout }()
+
+			dir := t.TempDir()
+			for name, content := range map[string]string{
+				"a.sql.go": "package foo\n\nconst bar = \"x\"\n",
+				"b.sql.go": "package foo\n\nconst baz = \"x\"\n",
+			} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("failed to write %s: %v", name, err)
+				}
+			}
+
+			tc.Options.SizeReport = true
+			err := Run(filepath.Join(dir, "*.sql.go"), "bar", "", config.Config{}, tc.Options)
+			if tc.Options.Check {
+				var pendingErr *pending.Error
+				require.ErrorAs(t, err, &pendingErr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, "size report: 1 files changed, +1/-1 lines, +26/-16 bytes (net +10 bytes)\n", out.String())
+		})
+	}
+}
+
+func TestRunWarnOnReformat(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+	readFile = os.ReadFile
+	var warnings bytes.Buffer
+	stderr = &warnings
+	defer func() { stderr = os.Stderr }()
+
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		// hand-edited with spaces, but also gaining a comment
+		"a.sql.go": "package foo\n\nconst  bar = \"x\"\n",
+		// hand-edited with spaces, nothing to tag
+		"b.sql.go": "package foo\n\nconst  baz = \"x\"\n",
+		"c.sql.go": "package foo\n\nconst qux = \"x\"\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	require.NoError(t, Run(filepath.Join(dir, "*.sql.go"), "bar", "", config.Config{}, Options{WarnOnReformat: true}))
+	require.Equal(t, "warning: "+filepath.Join(dir, "b.sql.go")+" only changes by gofmt normalization, not by add-nosec; check the reformat is wanted\n", warnings.String())
+	got, err := os.ReadFile(filepath.Join(dir, "b.sql.go"))
+	require.NoError(t, err)
+	require.Equal(t, "package foo\n\nconst baz = \"x\"\n", string(got), "the reformat is still written")
+}
+
+func TestRunNormalizeFinalNewline(t *testing.T) {
+	tagged := "package foo\n\nconst bar = \"x\" // #nosec\n"
+	tests := []struct {
+		Name            string
+		InitContent     string
+		Options         Options
+		ExpectedContent string
+	}{
+		{
+			Name:            "missing newline",
+			InitContent:     "package foo\n\nconst bar = \"x\"",
+			Options:         Options{TargetedEdit: true, NormalizeFinalNewline: true},
+			ExpectedContent: tagged,
+		},
+		{
+			Name:            "multiple newlines",
+			InitContent:     "package foo\n\nconst bar = \"x\"\n\n\n",
+			Options:         Options{TargetedEdit: true, NormalizeFinalNewline: true},
+			ExpectedContent: tagged,
+		},
+		{
+			Name:            "only the final newline changes",
+			InitContent:     "package foo\n\nconst bar = \"x\" // #nosec\n\n",
+			Options:         Options{TargetedEdit: true, NormalizeFinalNewline: true},
+			ExpectedContent: tagged,
+		},
+		{
+			Name:            "targeted edit keeps endings without the option",
+			InitContent:     "package foo\n\nconst bar = \"x\"\n\n\n",
+			Options:         Options{TargetedEdit: true},
+			ExpectedContent: "package foo\n\nconst bar = \"x\" // #nosec\n\n\n",
+		},
+		{
+			Name:            "formatted output already ends with one newline",
+			InitContent:     "package foo\n\nconst bar = \"x\"",
+			Options:         Options{NormalizeFinalNewline: true},
+			ExpectedContent: tagged,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			readFile = os.ReadFile
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(tc.InitContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			require.NoError(t, Run(contentFile, "bar", "", config.Config{}, tc.Options))
+			got, err := os.ReadFile(contentFile)
+			require.NoError(t, err)
+			require.Equal(t, tc.ExpectedContent, string(got))
+		})
+	}
+}
+
+func TestRunShowChanges(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+	initContent := `package foo
+
+const bar = "false flagged hardcoded credentials"
+
+var foobar = "false flagged hardcoded credentials"
+
+const c = "false flagged hardcoded credentials" // #nosec
+`
+	if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	require.NoError(t, Run(contentFile, "bar,foobar,c", "", config.Config{}, Options{ShowChanges: true}))
+	expected := contentFile + ":3: const bar -> // #nosec\n" +
+		contentFile + ":5: var foobar -> // #nosec\n" +
+		"2 specs tagged\n"
+	require.Equal(t, expected, out.String())
+}
+
+func TestRunHooks(t *testing.T) {
+	initContent := `package foo
+
+const bar = "false flagged hardcoded credentials"
+`
+	var order []string
+	rename := func(fset *token.FileSet, f *ast.File) (bool, error) {
+		order = append(order, "rename")
+		f.Name.Name = "bar"
+		return true, nil
+	}
+	record := func(fset *token.FileSet, f *ast.File) (bool, error) {
+		order = append(order, "record")
+		return false, nil
+	}
+	fail := func(fset *token.FileSet, f *ast.File) (bool, error) {
+		return false, errors.New("boom")
+	}
+	tests := []struct {
+		helpers.BaseTestCase
+		Hooks         []Hook
+		ExpectedOrder []string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "hooks run in order after tagging",
+				ExpectedContent: `package bar
+
+const bar = "false flagged hardcoded credentials" // #nosec
+`,
+			},
+			Hooks:         []Hook{rename, record},
+			ExpectedOrder: []string{"rename", "record"},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "hook error aborts the run",
+				ExpectedContent:   initContent,
+				ExpectedErrSubStr: "hook 1 failed",
+			},
+			Hooks:         []Hook{record, fail, rename},
+			ExpectedOrder: []string{"record"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			order = nil
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			err := Run(contentFile, "bar", "", config.Config{}, Options{Hooks: tc.Hooks})
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+			} else {
+				require.NoError(t, err)
+			}
+			require.Equal(t, tc.ExpectedOrder, order)
+			requireContent(t, contentFile, tc.ExpectedContent)
+		})
+	}
+}
+
+func TestRunExec(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+	initContent := "package foo\n\nconst bar = \"x\"\n"
+	contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+	if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	ran := false
+	record := func(fset *token.FileSet, f *ast.File) (bool, error) {
+		ran = true
+		return false, nil
+	}
+
+	err := Run(contentFile, "bar", "", config.Config{}, Options{Exec: " "})
+	require.EqualError(t, err, "exec command is empty")
+
+	// the external command runs after Hooks; its failure leaves the file untouched
+	err = Run(contentFile, "bar", "", config.Config{}, Options{Hooks: []Hook{record}, Exec: "sqlc-qol-no-such-transform --flag"})
+	require.ErrorContains(t, err, "hook 1 failed on "+contentFile+": sqlc-qol-no-such-transform failed")
+	require.True(t, ran)
+	requireContent(t, contentFile, initContent)
+}
+
+func TestRunNormalizeNosec(t *testing.T) {
+	initContent := `package foo
+
+const bar = "false flagged hardcoded credentials" //#nosec
+const foobar = "false flagged hardcoded credentials" //   #nosec   G101
+const c = "false flagged hardcoded credentials" //#nosec G101 G404 --   rotated weekly
+const other = "false flagged hardcoded credentials" //#nosec
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		Normalize bool
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:            "left alone by default",
+				ExpectedContent: initContent,
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "normalized on matched specs only",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+const foobar = "false flagged hardcoded credentials" // #nosec G101
+const c = "false flagged hardcoded credentials" // #nosec G101 G404 -- rotated weekly
+const other = "false flagged hardcoded credentials" //#nosec
+`,
+			},
+			Normalize: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			require.NoError(t, Run(contentFile, "bar,foobar,c", "", config.Config{}, Options{NormalizeNosec: tc.Normalize}))
+			requireContent(t, contentFile, tc.ExpectedContent)
+		})
+	}
+}
+
+func TestRunTrailingComment(t *testing.T) {
+	initContent := `package foo
+
+// header comment
+const bar = "false flagged hardcoded credentials" // rotated weekly
+var x = 1
+const baz = "false flagged hardcoded credentials" // rotated weekly
+
+const (
+	foo    = "false flagged hardcoded credentials" // rotated weekly
+	foobar = "false flagged hardcoded credentials"
+)
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		Placement      string
+		ExpectedStderr string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "above by default",
+				ExpectedContent: `package foo
+
+// header comment
+// #nosec
+const bar = "false flagged hardcoded credentials" // rotated weekly
+var x = 1
+const baz = "false flagged hardcoded credentials" // rotated weekly // #nosec
+
+const (
+	// #nosec
+	foo    = "false flagged hardcoded credentials" // rotated weekly
+	foobar = "false flagged hardcoded credentials" // #nosec
+)
+`,
+			},
+			ExpectedStderr: `warning: %[1]s:4: bar already has a trailing comment; placed // #nosec on the line above
+warning: %[1]s:6: baz already has a trailing comment; appended // #nosec to it
+warning: %[1]s:9: foo already has a trailing comment; placed // #nosec on the line above
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "append",
+				ExpectedContent: `package foo
+
+// header comment
+const bar = "false flagged hardcoded credentials" // rotated weekly // #nosec
+var x = 1
+const baz = "false flagged hardcoded credentials" // rotated weekly // #nosec
+
+const (
+	foo    = "false flagged hardcoded credentials" // rotated weekly // #nosec
+	foobar = "false flagged hardcoded credentials" // #nosec
+)
+`,
+			},
+			Placement: TrailingAppend,
+			ExpectedStderr: `warning: %[1]s:4: bar already has a trailing comment; appended // #nosec to it
+warning: %[1]s:6: baz already has a trailing comment; appended // #nosec to it
+warning: %[1]s:9: foo already has a trailing comment; appended // #nosec to it
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "invalid placement",
+				ExpectedErrSubStr: `invalid trailing comment placement "below"`,
+			},
+			Placement: "below",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			var errOut bytes.Buffer
+			stderr = &errOut
+			defer func() { stderr = os.Stderr }()
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			opts := Options{TrailingComment: tc.Placement}
+			err := Run(contentFile, "bar,baz,foo,foobar", "", config.Config{}, opts)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			requireContent(t, contentFile, tc.ExpectedContent)
+			require.Equal(t, fmt.Sprintf(tc.ExpectedStderr, contentFile), errOut.String())
+
+			// a second run finds the comments and changes nothing
+			errOut.Reset()
+			require.NoError(t, Run(contentFile, "bar,baz,foo,foobar", "", config.Config{}, opts))
+			requireContent(t, contentFile, tc.ExpectedContent)
+			require.Empty(t, errOut.String())
+		})
+	}
+}
+
+func TestRunIdempotent(t *testing.T) {
+	initContent := `package foo
+
+// header comment
+const bar = "false flagged hardcoded credentials" // rotated weekly
+var x = 1
+const baz = "false flagged hardcoded credentials"
+
+const (
+	foo    = "false flagged hardcoded credentials"
+	foobar = "false flagged hardcoded credentials" // note
+	other  = "not a target"
+)
+
+var (
+	secret = "false flagged hardcoded credentials"
+)
+
+var cfg = Config{
+	Password: "false flagged hardcoded credentials",
+}
+
+func f() {
+	const local = "false flagged hardcoded credentials"
+	_ = local
+}
+`
+	tests := []struct {
+		Name    string
+		Options Options
+		Reasons string
+	}{
+		{Name: "defaults"},
+		{Name: "rule", Options: Options{Rules: []string{"G101"}}},
+		{Name: "rules", Options: Options{Rules: []string{"G101", "G404"}}},
+		{Name: "append", Options: Options{TrailingComment: TrailingAppend, Rules: []string{"G101"}}},
+		{Name: "shared group", Options: Options{GroupMode: GroupShared}},
+		{Name: "auto group", Options: Options{GroupMode: GroupAuto, Rules: []string{"G101"}}},
+		{Name: "max line length", Options: Options{MaxLineLength: 60}},
+		{Name: "targeted edit", Options: Options{TargetedEdit: true, Rules: []string{"G101"}}},
+		{Name: "fields", Options: Options{DeclKinds: []string{KindConst, KindVar, KindField}, Rules: []string{"G101"}}},
+		{Name: "normalize", Options: Options{NormalizeNosec: true, Rules: []string{"G101"}}},
+		{Name: "reasons", Options: Options{Rules: []string{"G101"}}, Reasons: "bar: rotated weekly\nfoo: sqlc query text\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			readFile = os.ReadFile
+			stderr = io.Discard
+			defer func() { stderr = os.Stderr }()
+
+			tmpDir := t.TempDir()
+			contentFile := filepath.Join(tmpDir, "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			if tc.Reasons != "" {
+				tc.Options.Reasons = filepath.Join(tmpDir, "reasons.yaml")
+				if err := os.WriteFile(tc.Options.Reasons, []byte(tc.Reasons), 0644); err != nil {
+					t.Fatalf("failed to write reasons file: %v", err)
+				}
+			}
+			targets := "bar,baz,foo,foobar,secret,Password,local"
+			require.NoError(t, Run(contentFile, targets, "", config.Config{}, tc.Options))
+			first, err := os.ReadFile(contentFile)
+			require.NoError(t, err)
+			require.NotEqual(t, initContent, string(first))
+
+			res, err := RunWithResult(contentFile, targets, "", config.Config{}, tc.Options)
+			require.NoError(t, err)
+			second, err := os.ReadFile(contentFile)
+			require.NoError(t, err)
+			if diff := cmp.Diff(string(first), string(second)); diff != "" {
+				t.Errorf("second run changed the file (-first +second):\n%s", diff)
+			}
+			require.Zero(t, res.Tagged(), "second run should tag nothing")
+		})
+	}
+}
+
+func TestRunMaxLineLength(t *testing.T) {
+	// `const bar = "false flagged hardcoded credentials" // #nosec` is 59
+	// columns wide, `	foo = "false flagged hardcoded credentials" // #nosec`
+	// 57 with its tab expanded to 4 columns.
+	initContent := `package foo
+
+const bar = "false flagged hardcoded credentials"
+var x = 1
+const baz = "false flagged hardcoded credentials"
+
+const (
+	foo = "false flagged hardcoded credentials"
+)
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		MaxLineLength int
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "unset keeps inline placement",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+var x = 1
+const baz = "false flagged hardcoded credentials" // #nosec
+
+const (
+	foo = "false flagged hardcoded credentials" // #nosec
+)
+`,
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "exactly at the limit stays inline",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+var x = 1
+const baz = "false flagged hardcoded credentials" // #nosec
+
+const (
+	foo = "false flagged hardcoded credentials" // #nosec
+)
+`,
+			},
+			MaxLineLength: 59,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "one past the limit goes above",
+				ExpectedContent: `package foo
+
+// #nosec
+const bar = "false flagged hardcoded credentials"
+var x = 1
+const baz = "false flagged hardcoded credentials" // #nosec
+
+const (
+	foo = "false flagged hardcoded credentials" // #nosec
+)
+`,
+			},
+			MaxLineLength: 58,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "grouped spec past the limit goes above",
+				ExpectedContent: `package foo
+
+// #nosec
+const bar = "false flagged hardcoded credentials"
+var x = 1
+const baz = "false flagged hardcoded credentials" // #nosec
+
+const (
+	// #nosec
+	foo = "false flagged hardcoded credentials"
+)
+`,
+			},
+			MaxLineLength: 53,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			opts := Options{MaxLineLength: tc.MaxLineLength}
+			require.NoError(t, Run(contentFile, "bar,baz,foo", "", config.Config{}, opts))
+			requireContent(t, contentFile, tc.ExpectedContent)
+
+			// a second run finds the comments and changes nothing
+			require.NoError(t, Run(contentFile, "bar,baz,foo", "", config.Config{}, opts))
+			requireContent(t, contentFile, tc.ExpectedContent)
+		})
+	}
+}
+
+func TestRunMaxLineLengthAligned(t *testing.T) {
+	// gofmt aligns pw with its neighbour, making
+	// `	pw                             = "y" // #nosec` 50 columns wide with
+	// its tab expanded to 4 columns, and 54 with 8.
+	initContent := `package foo
+
+const (
+	aVeryLongNeighbourConstantName = "x"
+	pw = "y"
+)
+`
+	inline := `package foo
+
+const (
+	aVeryLongNeighbourConstantName = "x"
+	pw                             = "y" // #nosec
+)
+`
+	above := `package foo
+
+const (
+	aVeryLongNeighbourConstantName = "x"
+	// #nosec
+	pw = "y"
+)
+`
+	tests := []struct {
+		Name            string
+		Options         Options
+		ExpectedContent string
+	}{
+		{Name: "aligned line at the limit stays inline", Options: Options{MaxLineLength: 50}, ExpectedContent: inline},
+		{Name: "aligned line past the limit goes above", Options: Options{MaxLineLength: 49}, ExpectedContent: above},
+		{Name: "wider tabs count", Options: Options{MaxLineLength: 53, TabWidth: 8}, ExpectedContent: above},
+		{Name: "targeted edit measures the aligned line", Options: Options{MaxLineLength: 49, TargetedEdit: true}, ExpectedContent: above},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			readFile = os.ReadFile
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			require.NoError(t, Run(contentFile, "pw", "", config.Config{}, tc.Options))
+			requireContent(t, contentFile, tc.ExpectedContent)
+		})
+	}
+}
+
+func TestRunGroupMode(t *testing.T) {
+	initContent := `package foo
+
+const (
+	bar   = "false flagged hardcoded credentials"
+	other = 1
+	baz   = "false flagged hardcoded credentials"
+)
+
+const (
+	foo    = "false flagged hardcoded credentials"
+	foobar = "false flagged hardcoded credentials"
+)
+`
+	perSpec := `package foo
+
+const (
+	bar   = "false flagged hardcoded credentials" // #nosec
+	other = 1
+	baz   = "false flagged hardcoded credentials" // #nosec
+)
+
+const (
+	foo    = "false flagged hardcoded credentials" // #nosec
+	foobar = "false flagged hardcoded credentials" // #nosec
+)
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		GroupMode string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:            "per-spec by default",
+				ExpectedContent: perSpec,
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:            "per-spec",
+				ExpectedContent: perSpec,
+			},
+			GroupMode: GroupPerSpec,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "shared",
+				ExpectedContent: `package foo
+
+// #nosec
+const (
+	bar   = "false flagged hardcoded credentials"
+	other = 1
+	baz   = "false flagged hardcoded credentials"
+)
+
+// #nosec
+const (
+	foo    = "false flagged hardcoded credentials"
+	foobar = "false flagged hardcoded credentials"
+)
+`,
+			},
+			GroupMode: GroupShared,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "auto shares fully matched groups only",
+				ExpectedContent: `package foo
+
+const (
+	bar   = "false flagged hardcoded credentials" // #nosec
+	other = 1
+	baz   = "false flagged hardcoded credentials" // #nosec
+)
+
+// #nosec
+const (
+	foo    = "false flagged hardcoded credentials"
+	foobar = "false flagged hardcoded credentials"
+)
+`,
+			},
+			GroupMode: GroupAuto,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "invalid mode",
+				ExpectedErrSubStr: `invalid group mode "block"`,
+			},
+			GroupMode: "block",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			var out bytes.Buffer
+			stdout = &out
+			defer func() { stdout = os.Stdout }()
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			opts := Options{GroupMode: tc.GroupMode, ShowChanges: true}
+			err := Run(contentFile, "bar,baz,foo,foobar", "", config.Config{}, opts)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			requireContent(t, contentFile, tc.ExpectedContent)
+			require.Contains(t, out.String(), "4 specs tagged\n")
+
+			// a second run finds the comments and changes nothing
+			out.Reset()
+			require.NoError(t, Run(contentFile, "bar,baz,foo,foobar", "", config.Config{}, opts))
+			requireContent(t, contentFile, tc.ExpectedContent)
+			require.Equal(t, "0 specs tagged\n", out.String())
+		})
+	}
+}
+
+func TestRunRespectDirectives(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+	var out bytes.Buffer
+	stdout = &out
+	stderr = io.Discard
+	defer func() { stdout, stderr = os.Stdout, os.Stderr }()
+
+	contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+	initContent := `package foo
+
+const bar = "x" //nolint:gosec
+
+//nolint:errcheck,gosec // generated
+const baz = "x"
+
+const qux = "x" //nolint:errcheck
+
+const quux = "x" //nolint
+
+//nolint:gosec
+const (
+	a = "x"
+	b = "x"
+)
+`
+	if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	targets := "bar,baz,qux,quux,a,b"
+	opts := Options{RespectDirectives: []string{"nolint:gosec"}}
+
+	require.NoError(t, Run(contentFile, targets, "", config.Config{}, opts))
+	requireContent(t, contentFile, `package foo
+
+const bar = "x" //nolint:gosec
+
+//nolint:errcheck,gosec // generated
+const baz = "x"
+
+// #nosec
+const qux = "x" //nolint:errcheck
+
+const quux = "x" //nolint
+
+//nolint:gosec
+const (
+	a = "x"
+	b = "x"
+)
+`)
+	require.Equal(t, "5 specs skipped: already suppressed by a linter directive\n", out.String())
+
+	drift, err := CheckPlacement(contentFile, targets, "", config.Config{}, opts, false)
+	require.NoError(t, err)
+	require.Empty(t, drift)
+}
+
+func TestMatchesDirective(t *testing.T) {
+	tests := []struct {
+		text      string
+		directive string
+		want      bool
+	}{
+		{"//nolint:gosec", "nolint:gosec", true},
+		{"//nolint:errcheck,gosec // reason", "nolint:gosec", true},
+		{"//nolint", "nolint:gosec", true},
+		{"//nolint:errcheck", "nolint:gosec", false},
+		{"//nolintlint", "nolint:gosec", false},
+		{"// nolint:gosec", "nolint:gosec", true},
+		{"// see nolint:gosec", "nolint:gosec", false},
+		{"/* nolint:gosec */", "nolint:gosec", false},
+		{"//lint:ignore G101 reason", "lint:ignore", true},
+		{"//nolint:gosec", "", false},
+	}
+	for _, tc := range tests {
+		require.Equal(t, tc.want, matchesDirective(tc.text, tc.directive), "%q matching %q", tc.text, tc.directive)
+	}
+}
+
+func TestRunTargetedEdit(t *testing.T) {
+	tests := []struct {
+		Name             string
+		InitContent      string
+		ExpectedTargeted string
+	}{
+		{
+			Name: "ungrouped specs match the AST path",
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials"
+
+const baz = ` + "`" + `
+SELECT 1
+` + "`" + `
+`,
+			ExpectedTargeted: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+
+const baz = ` + "`" + `
+SELECT 1
+` + "`" + ` // #nosec
+`,
+		},
+		{
+			Name: "unrelated code left unformatted",
+			InitContent: `package foo
+
+const (
+	bar = "false flagged hardcoded credentials"
+	foo = 1
+)
+
+var m = map[string]int{"a":1}
+`,
+			ExpectedTargeted: `package foo
+
+const (
+	bar = "false flagged hardcoded credentials" // #nosec
+	foo = 1
+)
+
+var m = map[string]int{"a":1}
+`,
+		},
+		{
+			Name: "code after the spec on its line falls back",
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials"; const baz = "false flagged hardcoded credentials"
+`,
+		},
+		{
+			Name: "existing trailing comment falls back",
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // rotated weekly
+const baz = "false flagged hardcoded credentials"
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			stderr = io.Discard
+			defer func() { stderr = os.Stderr }()
+
+			run := func(targeted bool) string {
+				file := filepath.Join(t.TempDir(), "query.sql.go")
+				if err := os.WriteFile(file, []byte(tc.InitContent), 0644); err != nil {
+					t.Fatalf("failed to write content file: %v", err)
+				}
+				require.NoError(t, Run(file, "bar,baz", "", config.Config{}, Options{TargetedEdit: targeted}))
+				got, err := os.ReadFile(file)
+				require.NoError(t, err)
+				return string(got)
+			}
+			viaAST, targeted := run(false), run(true)
+			if tc.ExpectedTargeted != "" {
+				if diff := cmp.Diff(tc.ExpectedTargeted, targeted); diff != "" {
+					t.Errorf("targeted edit mismatch (-want +got)\n%s", diff)
+				}
+			}
+			formatted, err := format.Source([]byte(targeted))
+			require.NoError(t, err)
+			if diff := cmp.Diff(viaAST, string(formatted)); diff != "" {
+				t.Errorf("targeted edit differs from the AST path once formatted (-ast +targeted)\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestNormalizeNosecDedupes(t *testing.T) {
+	cg := &ast.CommentGroup{List: []*ast.Comment{
+		{Text: "// #nosec"},
+		{Text: "//#nosec"},
+		{Text: "// keep me"},
+		{Text: "//  #nosec G101"},
+		{Text: "// #nosecurity is not a directive"},
+	}}
+	normalizeNosec(cg)
+	var got []string
+	for _, cm := range cg.List {
+		got = append(got, cm.Text)
+	}
+	require.Equal(t, []string{"// #nosec", "// keep me", "// #nosec G101", "// #nosecurity is not a directive"}, got)
+}
+
+func TestRunFailOnUnsuppressed(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+	openFile = os.Open
+
+	dir := t.TempDir()
+	contentFile := filepath.Join(dir, "content.sql.go")
+	initContent := `package foo
+
+const bar = "false flagged hardcoded credentials"
+
+const (
+	baz = "false flagged hardcoded credentials"
+	qux = "false flagged hardcoded credentials"
+)
+`
+	if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	report := filepath.Join(dir, "gosec.json")
+	reportContent := `{"Issues": [
+	{"rule_id": "G101", "details": "Potential hardcoded credentials", "file": "` + contentFile + `", "line": "3"},
+	{"rule_id": "G101", "details": "Potential hardcoded credentials", "file": "` + contentFile + `", "line": "6"},
+	{"rule_id": "G101", "details": "Potential hardcoded credentials", "file": "` + contentFile + `", "line": "7"},
+	{"rule_id": "G101", "details": "Potential hardcoded credentials", "file": "` + filepath.Join(dir, "other.go") + `", "line": "1"}
+]}`
+	if err := os.WriteFile(report, []byte(reportContent), 0644); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+
+	err := Run(contentFile, "bar,baz", "", config.Config{}, Options{FailOnUnsuppressed: report})
+	require.EqualError(t, err, "1 gosec findings remain unsuppressed:\n  "+
+		contentFile+":7: G101 Potential hardcoded credentials")
+
+	require.NoError(t, Run(contentFile, "bar,baz,qux", "", config.Config{}, Options{FailOnUnsuppressed: report}))
+
+	scopedFile := filepath.Join(dir, "scoped.sql.go")
+	scopedContent := `package foo
+
+const (
+	pw    = "y" // #nosec G304
+	token = "y" // note // #nosec G304 G101
+)
+`
+	if err := os.WriteFile(scopedFile, []byte(scopedContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	scopedReport := filepath.Join(dir, "scoped.json")
+	scopedReportContent := `{"Issues": [
+	{"rule_id": "G101", "details": "Potential hardcoded credentials", "file": "` + scopedFile + `", "line": "4"},
+	{"rule_id": "G101", "details": "Potential hardcoded credentials", "file": "` + scopedFile + `", "line": "5"}
+]}`
+	if err := os.WriteFile(scopedReport, []byte(scopedReportContent), 0644); err != nil {
+		t.Fatalf("failed to write report: %v", err)
+	}
+	err = Run(scopedFile, "pw,token", "", config.Config{}, Options{Rules: []string{"G304"}, FailOnUnsuppressed: scopedReport})
+	require.EqualError(t, err, "1 gosec findings remain unsuppressed:\n  "+
+		scopedFile+":4: G101 Potential hardcoded credentials", "a #nosec naming other rules doesn't suppress the finding")
+
+	err = Run(contentFile, "bar", "", config.Config{}, Options{FailOnUnsuppressed: filepath.Join(dir, "missing.json")})
+	require.ErrorContains(t, err, "failed to open gosec report")
+}
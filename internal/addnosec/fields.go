@@ -0,0 +1,64 @@
+package addnosec
+
+import (
+	"go/ast"
+	"go/token"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/directives"
+)
+
+// fieldTarget returns the name of the composite literal field kv sets, when
+// opts.DeclKinds includes KindField and the name is a target. Keys of map
+// literals, and every field when opts.ConstType is set (a field declares no
+// type), don't count.
+func fieldTarget(kv *ast.KeyValueExpr, parent ast.Node, targetMap map[string]string, opts Options) (string, bool) {
+	if !tagsKind(opts, KindField) || opts.ConstType != "" {
+		return "", false
+	}
+	key, ok := kv.Key.(*ast.Ident)
+	if !ok {
+		return "", false
+	}
+	if lit, ok := parent.(*ast.CompositeLit); !ok {
+		return "", false
+	} else if _, isMap := lit.Type.(*ast.MapType); isMap {
+		return "", false
+	}
+	return key.Name, isTarget(targetMap, key.Name, opts)
+}
+
+// fieldNosec returns a comment group holding text trailing the line kv ends
+// on, or nil along with the reason it can't: the line already carries a
+// #nosec or the ignore directive ("tagged"), one of opts.RespectDirectives
+// ("suppressed"), or another comment ("commented").
+func fieldNosec(fset *token.FileSet, kv *ast.KeyValueExpr, comments []*ast.CommentGroup, text string, opts Options) (*ast.CommentGroup, string) {
+	tokFile := fset.File(kv.End())
+	line := tokFile.Line(kv.End())
+	commented := false
+	for _, cg := range comments {
+		if tokFile.Line(cg.Pos()) != line || cg.Pos() < kv.End() {
+			continue
+		}
+		for _, cm := range cg.List {
+			if isNosec(cm.Text) || strings.Contains(cm.Text, text) || strings.Contains(cm.Text, directives.Ignore) {
+				return nil, "tagged"
+			}
+			for _, directive := range opts.RespectDirectives {
+				if matchesDirective(cm.Text, directive) {
+					return nil, "suppressed"
+				}
+			}
+		}
+		commented = true
+	}
+	if commented {
+		return nil, "commented"
+	}
+	// positioned at the line's end, past any comma following the value
+	end := tokFile.Pos(tokFile.Size())
+	if line < tokFile.LineCount() {
+		end = tokFile.LineStart(line+1) - 1
+	}
+	return &ast.CommentGroup{List: []*ast.Comment{{Slash: end, Text: text}}}, ""
+}
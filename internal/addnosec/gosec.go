@@ -0,0 +1,141 @@
+package addnosec
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// Finding is a single issue from a gosec JSON report (`gosec -fmt=json`).
+type Finding struct {
+	File    string `json:"file"`
+	Line    string `json:"line"`
+	RuleID  string `json:"rule_id"`
+	Details string `json:"details"`
+}
+
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%s: %s %s", f.File, f.Line, f.RuleID, f.Details)
+}
+
+// startLine returns the first line of the finding; gosec reports multi-line
+// findings as a range (e.g. "12-14").
+func (f Finding) startLine() (int, error) {
+	first, _, _ := strings.Cut(f.Line, "-")
+	return strconv.Atoi(first)
+}
+
+// parseGosecReport reads the findings from a gosec JSON report.
+func parseGosecReport(path string) ([]Finding, error) {
+	// the report is a trusted input chosen by the user running the tool
+	f, err := openFile(path) // #nosec G304 -- user-supplied report path
+	if err != nil {
+		return nil, fmt.Errorf("failed to open gosec report: %w", err)
+	}
+	defer f.Close()
+	var report struct {
+		Issues []Finding `json:"Issues"`
+	}
+	if err := json.NewDecoder(f).Decode(&report); err != nil {
+		return nil, fmt.Errorf("failed to parse gosec report %s: %w", path, err)
+	}
+	return report.Issues, nil
+}
+
+// checkUnsuppressed returns the findings of the gosec report at reportPath
+// that lie in one of files and are not covered by a #nosec comment. A
+// finding is covered when a node spanning its line carries a #nosec comment
+// naming no rules or the finding's rule, mirroring how gosec applies
+// suppressions to a node and its children.
+// Findings in files outside files are out of scope and ignored.
+func checkUnsuppressed(reportPath string, files []string) ([]Finding, error) {
+	findings, err := parseGosecReport(reportPath)
+	if err != nil {
+		return nil, err
+	}
+	processed := make(map[string]string, len(files))
+	for _, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return nil, err
+		}
+		processed[abs] = file
+	}
+
+	suppressed := make(map[string]func(line int, rule string) bool)
+	var remaining []Finding
+	for _, finding := range findings {
+		abs, err := filepath.Abs(finding.File)
+		if err != nil {
+			return nil, err
+		}
+		file, ok := processed[abs]
+		if !ok {
+			continue
+		}
+		line, err := finding.startLine()
+		if err != nil {
+			return nil, fmt.Errorf("invalid line %q in gosec report for %s", finding.Line, finding.File)
+		}
+		covered, ok := suppressed[file]
+		if !ok {
+			if covered, err = nosecCoverage(file); err != nil {
+				return nil, err
+			}
+			suppressed[file] = covered
+		}
+		if !covered(line, finding.RuleID) {
+			remaining = append(remaining, finding)
+		}
+	}
+	return remaining, nil
+}
+
+// nosecCoverage parses file and reports, for a given line and rule, whether
+// a node spanning that line has a #nosec comment associated with it that
+// suppresses the rule: one naming no rules, or naming that one.
+func nosecCoverage(file string) (func(line int, rule string) bool, error) {
+	fset := token.NewFileSet()
+	f, err := parseFile(fset, file, nil, parser.ParseComments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse file %s: %w", file, err)
+	}
+	type span struct {
+		start, end int
+		// rules is nil when the comment suppresses every rule
+		rules []string
+	}
+	var spans []span
+	for node, groups := range ast.NewCommentMap(fset, f, f.Comments) {
+		if _, ok := node.(*ast.File); ok {
+			continue
+		}
+		for _, cg := range groups {
+			for _, cm := range cg.List {
+				// gosec honours a #nosec anywhere in the comment, as in
+				// `// note // #nosec G101`
+				i := strings.Index(cm.Text, "#nosec")
+				if i < 0 {
+					continue
+				}
+				if rules, _, ok := parseNosec("//" + cm.Text[i:]); ok {
+					spans = append(spans, span{fset.Position(node.Pos()).Line, fset.Position(node.End()).Line, rules})
+				}
+			}
+		}
+	}
+	return func(line int, rule string) bool {
+		for _, s := range spans {
+			if s.start <= line && line <= s.end && (len(s.rules) == 0 || slices.Contains(s.rules, rule)) {
+				return true
+			}
+		}
+		return false
+	}, nil
+}
@@ -0,0 +1,159 @@
+package addnosec
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var walkDir = filepath.WalkDir
+
+// Inventory formats supported by WriteInventory.
+const (
+	FormatMarkdown = "markdown"
+	FormatCSV      = "csv"
+	FormatJSON     = "json"
+)
+
+// Suppression is a #nosec comment found by Inventory.
+type Suppression struct {
+	File string `json:"file"`
+	Line int    `json:"line"`
+	// Name is the name of the declaration the comment is attached to (the
+	// names of a multi-name spec joined with ", "), empty if none.
+	Name   string   `json:"name"`
+	Rules  []string `json:"rules"`
+	Reason string   `json:"reason"`
+}
+
+// Inventory lists every #nosec comment in the .go files under rootDir,
+// vendor and hidden directories excluded, sorted by file and line.
+//
+// Returns an error if the walk fails or any file can't be parsed.
+func Inventory(rootDir string) ([]Suppression, error) {
+	var files []string
+	if err := walkDir(rootDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != rootDir && (d.Name() == "vendor" || strings.HasPrefix(d.Name(), ".")) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.HasSuffix(p, ".go") {
+			files = append(files, p)
+		}
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walkDir %s: %w", rootDir, err)
+	}
+
+	var inventory []Suppression
+	for _, file := range files {
+		fset := token.NewFileSet()
+		f, err := parseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		names := make(map[*ast.CommentGroup]string)
+		for node, groups := range ast.NewCommentMap(fset, f, f.Comments) {
+			for _, cg := range groups {
+				names[cg] = declName(node)
+			}
+		}
+		for _, cg := range f.Comments {
+			for _, cm := range cg.List {
+				i := strings.Index(cm.Text, "#nosec")
+				if i < 0 {
+					continue
+				}
+				// also finds a #nosec appended to another comment
+				rules, reason, ok := parseNosec("// " + cm.Text[i:])
+				if !ok {
+					continue
+				}
+				if rules == nil {
+					rules = []string{}
+				}
+				inventory = append(inventory, Suppression{
+					File:   file,
+					Line:   fset.Position(cm.Pos()).Line,
+					Name:   names[cg],
+					Rules:  rules,
+					Reason: reason,
+				})
+			}
+		}
+	}
+	sort.SliceStable(inventory, func(i, j int) bool {
+		if inventory[i].File != inventory[j].File {
+			return inventory[i].File < inventory[j].File
+		}
+		return inventory[i].Line < inventory[j].Line
+	})
+	return inventory, nil
+}
+
+// declName returns the declared name of node, or "" if it declares nothing.
+func declName(node ast.Node) string {
+	switch n := node.(type) {
+	case *ast.GenDecl:
+		if len(n.Specs) == 1 {
+			return declName(n.Specs[0])
+		}
+	case *ast.ValueSpec:
+		names := make([]string, len(n.Names))
+		for i, name := range n.Names {
+			names[i] = name.Name
+		}
+		return strings.Join(names, ", ")
+	case *ast.TypeSpec:
+		return n.Name.Name
+	case *ast.FuncDecl:
+		return n.Name.Name
+	}
+	return ""
+}
+
+// WriteInventory writes inventory to w as a markdown table, CSV with a
+// header row, or JSON array, according to format.
+func WriteInventory(w io.Writer, inventory []Suppression, format string) error {
+	switch format {
+	case FormatMarkdown:
+		escape := strings.NewReplacer("|", `\|`).Replace
+		fmt.Fprintln(w, "| File | Line | Name | Rules | Reason |")
+		fmt.Fprintln(w, "| ---- | ---- | ---- | ----- | ------ |")
+		for _, s := range inventory {
+			fmt.Fprintf(w, "| %s | %d | %s | %s | %s |\n",
+				escape(s.File), s.Line, escape(s.Name), strings.Join(s.Rules, " "), escape(s.Reason))
+		}
+		return nil
+	case FormatCSV:
+		cw := csv.NewWriter(w)
+		_ = cw.Write([]string{"file", "line", "name", "rules", "reason"})
+		for _, s := range inventory {
+			_ = cw.Write([]string{s.File, strconv.Itoa(s.Line), s.Name, strings.Join(s.Rules, " "), s.Reason})
+		}
+		cw.Flush()
+		return cw.Error()
+	case FormatJSON:
+		if inventory == nil {
+			inventory = []Suppression{}
+		}
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(inventory)
+	}
+	return fmt.Errorf("invalid inventory format %q: expected %s, %s or %s", format, FormatMarkdown, FormatCSV, FormatJSON)
+}
@@ -0,0 +1,83 @@
+package addnosec
+
+import (
+	"bytes"
+	"go/parser"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestInventory(t *testing.T) {
+	parseFile = parser.ParseFile
+	dir := t.TempDir()
+	content := `package foo
+
+const bar = "x" // #nosec
+
+const (
+	baz = "x" // #nosec G101 -- rotated | weekly
+	qux = "x" // see ticket // #nosec G101
+)
+
+// #nosec G404 -- not used for security
+var a, b = 1, 2
+
+func f() {
+	_ = 1 // #nosec
+}
+
+// a #nosecret comment is not a suppression
+`
+	if err := os.MkdirAll(filepath.Join(dir, "vendor"), 0755); err != nil {
+		t.Fatalf("failed to create vendor dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "vendor", "v.go"), []byte("package v\n\nconst v = 1 // #nosec\n"), 0644); err != nil {
+		t.Fatalf("failed to write vendored file: %v", err)
+	}
+	file := filepath.Join(dir, "query.sql.go")
+	if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+
+	inventory, err := Inventory(dir)
+	require.NoError(t, err)
+	require.Equal(t, []Suppression{
+		{File: file, Line: 3, Name: "bar", Rules: []string{}},
+		{File: file, Line: 6, Name: "baz", Rules: []string{"G101"}, Reason: "rotated | weekly"},
+		{File: file, Line: 7, Name: "qux", Rules: []string{"G101"}},
+		{File: file, Line: 10, Name: "a, b", Rules: []string{"G404"}, Reason: "not used for security"},
+		{File: file, Line: 14, Rules: []string{}},
+	}, inventory)
+
+	formats := map[string]string{
+		FormatMarkdown: `| File | Line | Name | Rules | Reason |
+| ---- | ---- | ---- | ----- | ------ |
+| q.go | 6 | baz | G101 | rotated \| weekly |
+`,
+		FormatCSV: `file,line,name,rules,reason
+q.go,6,baz,G101,rotated | weekly
+`,
+		FormatJSON: `[
+  {
+    "file": "q.go",
+    "line": 6,
+    "name": "baz",
+    "rules": [
+      "G101"
+    ],
+    "reason": "rotated | weekly"
+  }
+]
+`,
+	}
+	entry := []Suppression{{File: "q.go", Line: 6, Name: "baz", Rules: []string{"G101"}, Reason: "rotated | weekly"}}
+	for format, expected := range formats {
+		var buf bytes.Buffer
+		require.NoError(t, WriteInventory(&buf, entry, format))
+		require.Equal(t, expected, buf.String(), format)
+	}
+	require.ErrorContains(t, WriteInventory(&bytes.Buffer{}, entry, "html"), `invalid inventory format "html"`)
+}
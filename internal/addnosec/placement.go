@@ -0,0 +1,250 @@
+package addnosec
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/config"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Drift kinds reported by CheckPlacement.
+const (
+	// DriftOrphaned is a #nosec comment Run would write that isn't attached
+	// to any target spec.
+	DriftOrphaned = "orphaned"
+	// DriftMissing is a target spec without a #nosec comment.
+	DriftMissing = "missing"
+)
+
+// Drift is a #nosec comment that no longer sits on its target spec, or a
+// target spec that lost its comment.
+type Drift struct {
+	File string
+	Line int
+	Kind string
+	// Name is the target name for DriftMissing.
+	Name string
+}
+
+func (d Drift) String() string {
+	if d.Kind == DriftMissing {
+		return fmt.Sprintf("%s:%d: %s is missing its #nosec comment", d.File, d.Line, d.Name)
+	}
+	return fmt.Sprintf("%s:%d: #nosec comment is not attached to a target spec", d.File, d.Line)
+}
+
+// CheckPlacement verifies that, in every file matching queryGlob, each
+// #nosec comment Run would write is attached (as a trailing or doc comment)
+// to a spec or composite literal field whose name is one of the targets, and
+// that every target spec carries one (or the custom comment its CSV row
+// configures) unless a directive in opts.RespectDirectives already
+// suppresses it. Targets are supplied exactly as for Run. With
+// opts.GroupMode GroupShared or GroupAuto, the comment above a grouped
+// declaration covers the specs in it.
+//
+// Only comments outside function bodies whose text is exactly a comment Run
+// writes for a target, or one Run appended to an existing trailing comment,
+// are reported as orphaned; hand-written suppressions (`// #nosec G304 --
+// path is validated`) are never touched.
+//
+// With fix set, orphaned comments are removed and the target specs are
+// re-tagged by running Run. The drift found before fixing is returned either
+// way.
+func CheckPlacement(queryGlob, targets, csvPath string, config config.Config, opts Options, fix bool) ([]Drift, error) {
+	if err := checkGroupMode(opts.GroupMode); err != nil {
+		return nil, err
+	}
+	targetMap, err := loadTargets(targets, csvPath, config, opts)
+	if err != nil {
+		return nil, err
+	}
+	files, err := globFiles(queryGlob, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []Drift
+	for _, file := range files {
+		fset := token.NewFileSet()
+		f, err := parseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+
+		fileTargets := scopedTargets(targetMap, file, opts)
+		attached := make(map[*ast.Comment]bool)
+		var bodies []ast.Node
+		astutil.Apply(f, func(c *astutil.Cursor) bool {
+			switch n := c.Node().(type) {
+			case *ast.BlockStmt:
+				if _, ok := c.Parent().(*ast.FuncDecl); ok {
+					bodies = append(bodies, n)
+				} else if _, ok := c.Parent().(*ast.FuncLit); ok {
+					bodies = append(bodies, n)
+				}
+			case *ast.KeyValueExpr:
+				if _, ok := fieldTarget(n, c.Parent(), fileTargets, opts); ok {
+					tokFile := fset.File(n.End())
+					for _, cg := range f.Comments {
+						if tokFile.Line(cg.Pos()) != tokFile.Line(n.End()) || cg.Pos() < n.End() {
+							continue
+						}
+						for _, cm := range cg.List {
+							if isNosec(cm.Text) {
+								attached[cm] = true
+							}
+						}
+					}
+				}
+				return true
+			}
+			valSpec, ok := c.Node().(*ast.ValueSpec)
+			if !ok || !eligible(valSpec, c.Parent(), opts) {
+				return true
+			}
+			var name string
+			for _, ident := range valSpec.Names {
+				if isTarget(fileTargets, ident.Name, opts) {
+					name = ident.Name
+					break
+				}
+			}
+			if name == "" || respected(valSpec, c.Parent(), opts.RespectDirectives) {
+				return true
+			}
+			groups := []*ast.CommentGroup{valSpec.Doc, valSpec.Comment}
+			if gd, ok := c.Parent().(*ast.GenDecl); ok && coversSpecs(gd, opts) {
+				groups = append(groups, gd.Doc)
+			}
+			found := false
+			for _, cg := range groups {
+				if cg == nil {
+					continue
+				}
+				for _, cm := range cg.List {
+					if isNosec(cm.Text) || strings.Contains(cm.Text, targetComment(fileTargets, name, opts)) {
+						attached[cm] = true
+						found = true
+					}
+				}
+			}
+			if !found {
+				drift = append(drift, Drift{File: displayPath(file, opts), Line: fset.Position(valSpec.Pos()).Line, Kind: DriftMissing, Name: name})
+			}
+			return true
+		}, nil)
+
+		orphaned := make(map[*ast.Comment]bool)
+		for _, cg := range f.Comments {
+			for _, cm := range cg.List {
+				if attached[cm] || !managedNosec(cm.Text, fileTargets) || within(bodies, cm) {
+					continue
+				}
+				orphaned[cm] = true
+				drift = append(drift, Drift{File: displayPath(file, opts), Line: fset.Position(cm.Pos()).Line, Kind: DriftOrphaned})
+			}
+		}
+
+		if fix && len(orphaned) > 0 {
+			f.Comments = dropComments(f, orphaned)
+			if err := writeFile(file, fset, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if fix && len(drift) > 0 {
+		opts.Incremental = false
+		if err := Run(queryGlob, targets, csvPath, config, opts); err != nil {
+			return nil, err
+		}
+	}
+	return drift, nil
+}
+
+// managedNosec reports whether a comment's text is a suppression Run writes
+// for one of the targets: exactly that comment, or that comment appended to
+// an existing trailing comment.
+func managedNosec(text string, targetMap map[string]string) bool {
+	if !isNosec(text) {
+		return false
+	}
+	for _, comment := range targetMap {
+		if text == comment || strings.HasSuffix(text, " "+comment) {
+			return true
+		}
+	}
+	return false
+}
+
+// within reports whether cm lies inside one of nodes.
+func within(nodes []ast.Node, cm *ast.Comment) bool {
+	for _, n := range nodes {
+		if n.Pos() <= cm.Pos() && cm.End() <= n.End() {
+			return true
+		}
+	}
+	return false
+}
+
+// isNosec reports whether a comment's text is a #nosec directive.
+func isNosec(text string) bool {
+	return strings.Contains(text, "#nosec")
+}
+
+// dropComments removes the given comments from f and returns its remaining
+// comment groups. Groups left empty are dropped and detached from the nodes
+// referencing them, since go/printer also prints a spec's line comment.
+func dropComments(f *ast.File, drop map[*ast.Comment]bool) []*ast.CommentGroup {
+	var kept []*ast.CommentGroup
+	for _, cg := range f.Comments {
+		var list []*ast.Comment
+		for _, cm := range cg.List {
+			if !drop[cm] {
+				list = append(list, cm)
+			}
+		}
+		cg.List = list
+		if len(list) > 0 {
+			kept = append(kept, cg)
+		}
+	}
+	detach := func(cg **ast.CommentGroup) {
+		if *cg != nil && len((*cg).List) == 0 {
+			*cg = nil
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.GenDecl:
+			detach(&n.Doc)
+		case *ast.ValueSpec:
+			detach(&n.Doc)
+			detach(&n.Comment)
+		case *ast.TypeSpec:
+			detach(&n.Doc)
+			detach(&n.Comment)
+		case *ast.Field:
+			detach(&n.Doc)
+			detach(&n.Comment)
+		}
+		return true
+	})
+	return kept
+}
+
+func writeFile(file string, fset *token.FileSet, f *ast.File) error {
+	outFile, err := createFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s for writing: %w", file, err)
+	}
+	defer outFile.Close()
+	if err := formatNode(outFile, fset, f); err != nil {
+		return fmt.Errorf("failed to write formatted file %s: %w", file, err)
+	}
+	return nil
+}
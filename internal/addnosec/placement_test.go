@@ -0,0 +1,176 @@
+package addnosec
+
+import (
+	"go/format"
+	"go/parser"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/config"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPlacement(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		InitContent   string
+		ExpectedDrift []Drift
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "all suppressions in place",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+const other = "not a target"
+`,
+			},
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+const other = "not a target"
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "suppression drifted onto the wrong spec",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+const other = "not a target"
+`,
+			},
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials"
+const other = "not a target" // #nosec
+`,
+			ExpectedDrift: []Drift{
+				{Line: 3, Kind: DriftMissing, Name: "bar"},
+				{Line: 4, Kind: DriftOrphaned},
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "suppression left on its own line",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+
+// keep me
+
+const other = "not a target"
+`,
+			},
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials"
+
+// keep me
+// #nosec
+const other = "not a target"
+`,
+			ExpectedDrift: []Drift{
+				{Line: 3, Kind: DriftMissing, Name: "bar"},
+				{Line: 6, Kind: DriftOrphaned},
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "hand-written suppressions kept",
+				ExpectedContent: `package foo
+
+import "os"
+
+const bar = "false flagged hardcoded credentials" // #nosec
+
+const other = "x" // #nosec G101 -- reviewed by security
+
+func open(p string) {
+	f, _ := os.Open(p) // #nosec G304 -- path is validated
+	_ = f
+	// #nosec
+	_ = p
+}
+`,
+			},
+			InitContent: `package foo
+
+import "os"
+
+const bar = "false flagged hardcoded credentials" // #nosec
+
+const other = "x" // #nosec G101 -- reviewed by security
+
+func open(p string) {
+	f, _ := os.Open(p) // #nosec G304 -- path is validated
+	_ = f
+	// #nosec
+	_ = p
+}
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(tc.InitContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			for i := range tc.ExpectedDrift {
+				tc.ExpectedDrift[i].File = contentFile
+			}
+
+			drift, err := CheckPlacement(contentFile, "bar", "", config.Config{}, Options{}, false)
+			require.NoError(t, err)
+			require.Equal(t, tc.ExpectedDrift, drift)
+
+			drift, err = CheckPlacement(contentFile, "bar", "", config.Config{}, Options{}, true)
+			require.NoError(t, err)
+			require.Equal(t, tc.ExpectedDrift, drift)
+			requireContent(t, contentFile, tc.ExpectedContent)
+
+			drift, err = CheckPlacement(contentFile, "bar", "", config.Config{}, Options{}, false)
+			require.NoError(t, err)
+			require.Empty(t, drift, "no drift should remain after --fix")
+		})
+	}
+}
+
+func TestCheckPlacementGroupShared(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+	readFile = os.ReadFile
+
+	contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+	initContent := `package foo
+
+const (
+	bar    = "x"
+	foobar = "x"
+	other  = "x"
+)
+`
+	if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	opts := Options{GroupMode: GroupShared}
+	require.NoError(t, Run(contentFile, "bar,foobar", "", config.Config{}, opts))
+	tagged, err := os.ReadFile(contentFile)
+	require.NoError(t, err)
+	require.Contains(t, string(tagged), "// #nosec\nconst (")
+
+	drift, err := CheckPlacement(contentFile, "bar,foobar", "", config.Config{}, opts, true)
+	require.NoError(t, err)
+	require.Empty(t, drift, "the shared comment should cover the block's targets")
+	requireContent(t, contentFile, string(tagged))
+}
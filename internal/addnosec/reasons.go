@@ -0,0 +1,58 @@
+package addnosec
+
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// loadReasons reads the name→reason mapping at path, a YAML (or JSON)
+// object such as `dbPassword: fixture credentials for local development`.
+// With opts.CaseInsensitive its names are lower-cased like target names.
+func loadReasons(path string, opts Options) (map[string]string, error) {
+	data, err := readFile(path) // #nosec G304 -- reasons file is chosen by the user running the tool
+	if err != nil {
+		return nil, fmt.Errorf("failed to read reasons file: %w", err)
+	}
+	var raw map[string]string
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("failed to parse reasons file %s: %w", path, err)
+	}
+	reasons := make(map[string]string, len(raw))
+	for name, reason := range raw {
+		reason = strings.TrimSpace(reason)
+		if strings.ContainsAny(reason, "\r\n") {
+			return nil, fmt.Errorf("reasons file %s: reason for %s spans several lines", path, name)
+		}
+		reasons[targetKey(strings.TrimSpace(name), opts)] = reason
+	}
+	return reasons, nil
+}
+
+// applyReasons appends to the suppression comment of every target the
+// reason reasons gives its name (`// #nosec G101 -- reason`), whatever the
+// target's scope. Comments already carrying a reason, and comments that
+// aren't a #nosec directive, are left as they are.
+//
+// With opts.RequireReason, returns an error listing every target left
+// without a reason.
+func applyReasons(targetMap, reasons map[string]string, opts Options) error {
+	var missing []string
+	for _, key := range sortedTargets(targetMap) {
+		name, _, _ := strings.Cut(key, scopeSep)
+		rules, reason, ok := parseNosec(targetMap[key])
+		if ok && reason == "" && reasons[name] != "" {
+			reason = reasons[name]
+			targetMap[key] = formatNosec(rules, reason)
+		}
+		if ok && reason == "" {
+			missing = append(missing, key)
+		}
+	}
+	if opts.RequireReason && len(missing) > 0 {
+		return fmt.Errorf("%d targets have no suppression reason (add them to the reasons file or drop --require-reason):\n  %s",
+			len(missing), strings.Join(missing, "\n  "))
+	}
+	return nil
+}
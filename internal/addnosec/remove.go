@@ -0,0 +1,196 @@
+package addnosec
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"sort"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/config"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/directives"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pending"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+// Removal is a suppression comment Remove took off a target.
+type Removal struct {
+	File string
+	Line int
+	Name string
+}
+
+func (r Removal) String() string {
+	return fmt.Sprintf("%s:%d: removed #nosec from %s", r.File, r.Line, r.Name)
+}
+
+// Remove undoes Run: in every file matching queryGlob, it deletes the
+// suppression comment Run adds to each target (DefaultComment, or the comment
+// configured by the target's CSV row, opts.Rules and opts.Reasons) from the
+// target spec's doc and trailing comments, and from the doc comment of an
+// ungrouped declaration. Targets are supplied exactly as for Run, and
+// opts.DeclKinds and opts.ConstType select the specs as for Run; with
+// KindField, the comment trailing a targeted composite literal field is
+// removed too.
+//
+// Only comments that are exactly that text are removed, so hand-written
+// #nosec comments and unrelated comments stay. A comment Run appended to an
+// existing trailing comment (`// note // #nosec`) is cut back to `// note`.
+// Other comments in the same group are kept, and a group left empty is
+// dropped. The comment --group-mode shared puts above a `const ( ... )`
+// block is only removed when opts.GroupMode is GroupShared or GroupAuto, as
+// it may otherwise be a hand-placed comment covering other specs. Comments
+// are cut from the source, which is then formatted, so a doc comment losing
+// its last line stays attached to its spec.
+//
+// With opts.Check nothing is written and the files that would change are
+// returned as a *pending.Error. The removals are returned either way.
+func Remove(queryGlob, targets, csvPath string, config config.Config, opts Options) ([]Removal, error) {
+	if err := checkGroupMode(opts.GroupMode); err != nil {
+		return nil, err
+	}
+	for _, rule := range opts.Rules {
+		if !ruleCode.MatchString(rule) {
+			return nil, fmt.Errorf("invalid rule %q: expected a gosec rule ID such as G101", rule)
+		}
+	}
+	targetMap, err := loadTargets(targets, csvPath, config, opts)
+	if err != nil {
+		return nil, err
+	}
+	files, err := globFiles(queryGlob, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []Removal
+	var pendingFiles []string
+	for _, file := range files {
+		src, err := readFile(file) // #nosec G304 -- file was matched by the user's glob
+		if err != nil {
+			return nil, fmt.Errorf("failed to read file %s: %w", file, err)
+		}
+		fset := token.NewFileSet()
+		f, err := parseFile(fset, file, src, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		if directives.SkipFile(f) {
+			continue
+		}
+
+		fileTargets := scopedTargets(targetMap, file, opts)
+		cuts := make(map[*ast.Comment]cut)
+		strip := func(cg *ast.CommentGroup, name string) {
+			if cg == nil {
+				return
+			}
+			text := targetComment(fileTargets, name, opts)
+			for _, cm := range cg.List {
+				if _, ok := cuts[cm]; ok {
+					continue
+				}
+				start, end := fset.Position(cm.Pos()).Offset, fset.Position(cm.End()).Offset
+				if cm.Text == text {
+					cuts[cm] = commentCut(src, start, end)
+				} else if note, ok := strings.CutSuffix(cm.Text, " "+text); ok && strings.TrimSpace(note) != "//" {
+					cuts[cm] = cut{start + len(strings.TrimRight(note, " \t")), end}
+				} else {
+					continue
+				}
+				removed = append(removed, Removal{File: displayPath(file, opts), Line: fset.Position(cm.Pos()).Line, Name: name})
+			}
+		}
+		astutil.Apply(f, func(c *astutil.Cursor) bool {
+			if kv, ok := c.Node().(*ast.KeyValueExpr); ok {
+				name, ok := fieldTarget(kv, c.Parent(), fileTargets, opts)
+				if !ok {
+					return true
+				}
+				tokFile := fset.File(kv.End())
+				for _, cg := range f.Comments {
+					if tokFile.Line(cg.Pos()) == tokFile.Line(kv.End()) && cg.Pos() >= kv.End() {
+						strip(cg, name)
+					}
+				}
+				return true
+			}
+			valSpec, ok := c.Node().(*ast.ValueSpec)
+			if !ok || !eligible(valSpec, c.Parent(), opts) {
+				return true
+			}
+			for _, ident := range valSpec.Names {
+				if !isTarget(fileTargets, ident.Name, opts) {
+					continue
+				}
+				strip(valSpec.Doc, ident.Name)
+				strip(valSpec.Comment, ident.Name)
+				if gd, ok := c.Parent().(*ast.GenDecl); ok && coversSpecs(gd, opts) {
+					strip(gd.Doc, ident.Name)
+				}
+			}
+			return true
+		}, nil)
+
+		if len(cuts) == 0 {
+			continue
+		}
+		if opts.Check {
+			pendingFiles = append(pendingFiles, displayPath(file, opts))
+			continue
+		}
+		out, err := format.Source(applyCuts(src, cuts))
+		if err != nil {
+			return nil, fmt.Errorf("failed to format file %s: %w", file, err)
+		}
+		if err := writeBytes(file, out); err != nil {
+			return nil, err
+		}
+	}
+	if len(pendingFiles) > 0 {
+		return removed, &pending.Error{Operation: "remove-nosec", Files: pendingFiles}
+	}
+	return removed, nil
+}
+
+// cut is a byte range [start, end) of a source file to delete.
+type cut struct {
+	start, end int
+}
+
+// commentCut returns the range to delete to remove the comment at
+// [start, end) of src: its whole line, newline included, when nothing else
+// is on it, or else the comment along with the blanks before it.
+func commentCut(src []byte, start, end int) cut {
+	lineStart := bytes.LastIndexByte(src[:start], '\n') + 1
+	lineEnd := len(src)
+	if i := bytes.IndexByte(src[end:], '\n'); i >= 0 {
+		lineEnd = end + i + 1
+	}
+	if len(bytes.TrimSpace(src[lineStart:start])) == 0 && len(bytes.TrimSpace(src[end:lineEnd])) == 0 {
+		return cut{lineStart, lineEnd}
+	}
+	for start > lineStart && (src[start-1] == ' ' || src[start-1] == '\t') {
+		start--
+	}
+	return cut{start, end}
+}
+
+// applyCuts returns src without the ranges of cuts, which don't overlap.
+func applyCuts(src []byte, cuts map[*ast.Comment]cut) []byte {
+	sorted := make([]cut, 0, len(cuts))
+	for _, c := range cuts {
+		sorted = append(sorted, c)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].start < sorted[j].start })
+	out := make([]byte, 0, len(src))
+	prev := 0
+	for _, c := range sorted {
+		out = append(out, src[prev:c.start]...)
+		prev = c.end
+	}
+	return append(out, src[prev:]...)
+}
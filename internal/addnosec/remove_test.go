@@ -0,0 +1,191 @@
+package addnosec
+
+import (
+	"go/format"
+	"go/parser"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/config"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pending"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRemove(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		InitContent     string
+		Targets         string
+		Options         Options
+		ExpectedRemoved []Removal
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "injected comments removed",
+				ExpectedContent: `package foo
+
+const bar = "false flagged hardcoded credentials"
+
+const (
+	foobar = "x"
+	c      = "x" // #nosec
+)
+`,
+			},
+			InitContent: `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+
+const (
+	foobar = "x" // #nosec
+	c      = "x" // #nosec
+)
+`,
+			Targets:         "bar,foobar",
+			ExpectedRemoved: []Removal{{Line: 3, Name: "bar"}, {Line: 6, Name: "foobar"}},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "hand-written comments kept",
+				ExpectedContent: `package foo
+
+const bar = "x" // #nosec G404 -- reviewed by security
+
+// keep me
+const foobar = "x"
+
+const c = "x" // note
+`,
+			},
+			InitContent: `package foo
+
+const bar = "x" // #nosec G404 -- reviewed by security
+
+// keep me
+// #nosec
+const foobar = "x"
+
+const c = "x" // note // #nosec
+`,
+			Targets:         "bar,foobar,c",
+			ExpectedRemoved: []Removal{{Line: 6, Name: "foobar"}, {Line: 9, Name: "c"}},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "rule-scoped comments and fields",
+				ExpectedContent: `package foo
+
+const bar = "x"
+
+var cfg = Config{
+	Password: "x",
+	Other:    "x", // #nosec G101
+}
+`,
+			},
+			InitContent: `package foo
+
+const bar = "x" // #nosec G101
+
+var cfg = Config{
+	Password: "x", // #nosec G101
+	Other:    "x", // #nosec G101
+}
+`,
+			Targets:         "bar,Password",
+			Options:         Options{Rules: []string{"G101"}, DeclKinds: []string{KindConst, KindField}},
+			ExpectedRemoved: []Removal{{Line: 3, Name: "bar"}, {Line: 6, Name: "Password"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			parseFile = parser.ParseFile
+			glob = filepath.Glob
+			createFile = os.Create
+			formatNode = format.Node
+			readFile = os.ReadFile
+
+			contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+			if err := os.WriteFile(contentFile, []byte(tc.InitContent), 0644); err != nil {
+				t.Fatalf("failed to write content file: %v", err)
+			}
+			for i := range tc.ExpectedRemoved {
+				tc.ExpectedRemoved[i].File = contentFile
+			}
+
+			checkOpts := tc.Options
+			checkOpts.Check = true
+			removed, err := Remove(contentFile, tc.Targets, "", config.Config{}, checkOpts)
+			var pendingErr *pending.Error
+			require.ErrorAs(t, err, &pendingErr)
+			require.Equal(t, tc.ExpectedRemoved, removed)
+			requireContent(t, contentFile, tc.InitContent)
+
+			removed, err = Remove(contentFile, tc.Targets, "", config.Config{}, tc.Options)
+			require.NoError(t, err)
+			require.Equal(t, tc.ExpectedRemoved, removed)
+			requireContent(t, contentFile, tc.ExpectedContent)
+
+			removed, err = Remove(contentFile, tc.Targets, "", config.Config{}, tc.Options)
+			require.NoError(t, err)
+			require.Empty(t, removed, "nothing should be left to remove")
+		})
+	}
+}
+
+func TestRemoveUndoesRun(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+	readFile = os.ReadFile
+
+	contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+	initContent := `package foo
+
+// createUser inserts a user.
+const createUser = "INSERT INTO users"
+
+const (
+	getUser = "SELECT 1" // query
+	other   = "x"
+)
+`
+	if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	opts := Options{TrailingComment: TrailingAppend, Rules: []string{"G101"}}
+	require.NoError(t, Run(contentFile, "createUser,getUser", "", config.Config{}, opts))
+	_, err := Remove(contentFile, "createUser,getUser", "", config.Config{}, opts)
+	require.NoError(t, err)
+	requireContent(t, contentFile, initContent)
+}
+
+func TestRemoveUndoesRunGroupShared(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+	readFile = os.ReadFile
+
+	contentFile := filepath.Join(t.TempDir(), "content.sql.go")
+	initContent := `package foo
+
+// queries
+const (
+	bar    = "x"
+	foobar = "x"
+)
+`
+	if err := os.WriteFile(contentFile, []byte(initContent), 0644); err != nil {
+		t.Fatalf("failed to write content file: %v", err)
+	}
+	opts := Options{GroupMode: GroupShared}
+	require.NoError(t, Run(contentFile, "bar,foobar", "", config.Config{}, opts))
+	removed, err := Remove(contentFile, "bar,foobar", "", config.Config{}, opts)
+	require.NoError(t, err)
+	require.Equal(t, []Removal{{File: contentFile, Line: 4, Name: "bar"}}, removed)
+	requireContent(t, contentFile, initContent)
+}
@@ -0,0 +1,98 @@
+package addnosec
+
+import (
+	"fmt"
+	"path"
+	"strings"
+)
+
+// FileResult is what Run did to one file.
+type FileResult struct {
+	// Path is the file's path, relative to opts.GlobBase when set.
+	Path string
+	// Tagged lists the targets given a #nosec comment (or, in check mode,
+	// that would be), in file order.
+	Tagged []string
+	// Skipped lists the targets found but left as they were: already
+	// tagged, suppressed by a linter directive, or a field whose line
+	// already carries another comment.
+	Skipped []string
+}
+
+// Result is what Run did, file by file.
+type Result struct {
+	// Files lists the files processed, in glob order. Files skipped by a
+	// directive, left unchanged by opts.Incremental or not written because
+	// they changed on disk aren't listed.
+	Files []FileResult
+	// Unmatched lists the targets that didn't match anything in any file
+	// processed, sorted. Left empty when opts.Incremental skipped files, as
+	// their targets weren't looked for.
+	Unmatched []string
+	// Unchanged counts the files opts.Incremental skipped.
+	Unchanged int
+}
+
+// Tagged returns the number of targets tagged across all files.
+func (r Result) Tagged() int {
+	n := 0
+	for _, f := range r.Files {
+		n += len(f.Tagged)
+	}
+	return n
+}
+
+// Skipped returns the number of targets found but left as they were.
+func (r Result) Skipped() int {
+	n := 0
+	for _, f := range r.Files {
+		n += len(f.Skipped)
+	}
+	return n
+}
+
+// Summary renders r as `3 files processed, 5 targets tagged, 2 skipped`.
+// The skipped count covers every reason a found target is left as it was,
+// see FileResult.Skipped. Unmatched targets are left out: Run already warns
+// about each one on stderr, or fails with opts.Strict.
+func (r Result) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d files processed, %d targets tagged, %d skipped", len(r.Files), r.Tagged(), r.Skipped())
+	if r.Unchanged > 0 {
+		fmt.Fprintf(&b, ", %d files unchanged since the last run", r.Unchanged)
+	}
+	b.WriteString("\n")
+	return b.String()
+}
+
+// markMatched marks in matched the keys of targetMap applying to file whose
+// name is in names: unscoped keys, and scoped ones whose glob matches file.
+func markMatched(matched map[string]bool, targetMap map[string]string, names map[string]bool, file string, opts Options) {
+	filePath := ""
+	for key := range targetMap {
+		name, scope, scoped := strings.Cut(key, scopeSep)
+		if !names[name] {
+			continue
+		}
+		if scoped {
+			if filePath == "" {
+				filePath = scopePath(file, opts)
+			}
+			if ok, _ := path.Match(scope, filePath); !ok {
+				continue
+			}
+		}
+		matched[key] = true
+	}
+}
+
+// unmatchedTargets returns the sorted keys of targetMap missing from matched.
+func unmatchedTargets(targetMap map[string]string, matched map[string]bool) []string {
+	var unmatched []string
+	for _, key := range sortedTargets(targetMap) {
+		if !matched[key] {
+			unmatched = append(unmatched, key)
+		}
+	}
+	return unmatched
+}
@@ -0,0 +1,93 @@
+package addnosec
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"regexp"
+)
+
+// ruleCode matches a gosec rule ID such as G101.
+var ruleCode = regexp.MustCompile(`^G\d+$`)
+
+// BareNosec is a #nosec comment that doesn't name the rule it suppresses.
+type BareNosec struct {
+	File string
+	Line int
+	Text string
+	// Managed reports whether the comment looks like one add-nosec wrote:
+	// exactly `// #nosec`, trailing a const or var spec.
+	Managed bool
+}
+
+func (b BareNosec) String() string {
+	origin := "hand-written"
+	if b.Managed {
+		origin = "tool-managed"
+	}
+	return fmt.Sprintf("%s:%d: %s has no rule code (%s)", b.File, b.Line, b.Text, origin)
+}
+
+// CheckRules reports every #nosec comment without a rule code in the files
+// matching queryGlob (see Options.GlobBase). With fixRule set, those
+// comments are rewritten to suppress only fixRule, keeping any
+// justification (`// #nosec -- reason` becomes `// #nosec G101 -- reason`).
+// The comments found before fixing are returned either way.
+//
+// Returns an error if fixRule isn't a gosec rule ID, globbing fails, or any
+// file can't be parsed or written.
+func CheckRules(queryGlob string, opts Options, fixRule string) ([]BareNosec, error) {
+	if fixRule != "" && !ruleCode.MatchString(fixRule) {
+		return nil, fmt.Errorf("invalid rule %q: expected a gosec rule ID such as G101", fixRule)
+	}
+	files, err := globFiles(queryGlob, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var bare []BareNosec
+	for _, file := range files {
+		fset := token.NewFileSet()
+		f, err := parseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+
+		managed := make(map[*ast.Comment]bool)
+		ast.Inspect(f, func(n ast.Node) bool {
+			if valSpec, ok := n.(*ast.ValueSpec); ok && valSpec.Comment != nil {
+				for _, cm := range valSpec.Comment.List {
+					managed[cm] = cm.Text == "// #nosec"
+				}
+			}
+			return true
+		})
+
+		changed := false
+		for _, cg := range f.Comments {
+			for _, cm := range cg.List {
+				rules, reason, ok := parseNosec(cm.Text)
+				if !ok || len(rules) > 0 {
+					continue
+				}
+				bare = append(bare, BareNosec{
+					File:    displayPath(file, opts),
+					Line:    fset.Position(cm.Pos()).Line,
+					Text:    cm.Text,
+					Managed: managed[cm],
+				})
+				if fixRule != "" {
+					cm.Text = formatNosec([]string{fixRule}, reason)
+					changed = true
+				}
+			}
+		}
+		if changed {
+			if err := writeFile(file, fset, f); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return bare, nil
+}
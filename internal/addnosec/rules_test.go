@@ -0,0 +1,82 @@
+package addnosec
+
+import (
+	"go/format"
+	"go/parser"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckRules(t *testing.T) {
+	parseFile = parser.ParseFile
+	glob = filepath.Glob
+	createFile = os.Create
+	formatNode = format.Node
+
+	initContent := `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec
+
+const scoped = "false flagged hardcoded credentials" // #nosec G101
+
+func read(path string) {
+	_, _ = os.ReadFile(path) //#nosec -- path is validated by the caller
+}
+`
+	fixed := `package foo
+
+const bar = "false flagged hardcoded credentials" // #nosec G101
+
+const scoped = "false flagged hardcoded credentials" // #nosec G101
+
+func read(path string) {
+	_, _ = os.ReadFile(path) // #nosec G101 -- path is validated by the caller
+}
+`
+	setup := func(t *testing.T) string {
+		file := filepath.Join(t.TempDir(), "content.sql.go")
+		if err := os.WriteFile(file, []byte(initContent), 0644); err != nil {
+			t.Fatalf("failed to write content file: %v", err)
+		}
+		return file
+	}
+	expected := func(file string) []BareNosec {
+		return []BareNosec{
+			{File: file, Line: 3, Text: "// #nosec", Managed: true},
+			{File: file, Line: 8, Text: "//#nosec -- path is validated by the caller"},
+		}
+	}
+
+	t.Run("report", func(t *testing.T) {
+		file := setup(t)
+		got, err := CheckRules(file, Options{}, "")
+		require.NoError(t, err)
+		if diff := cmp.Diff(expected(file), got); diff != "" {
+			t.Errorf("bare comments differ (-want +got):\n%s", diff)
+		}
+		requireContent(t, file, initContent)
+	})
+
+	t.Run("fix", func(t *testing.T) {
+		file := setup(t)
+		got, err := CheckRules(file, Options{}, "G101")
+		require.NoError(t, err)
+		if diff := cmp.Diff(expected(file), got); diff != "" {
+			t.Errorf("bare comments differ (-want +got):\n%s", diff)
+		}
+		requireContent(t, file, fixed)
+
+		got, err = CheckRules(file, Options{}, "G101")
+		require.NoError(t, err)
+		require.Empty(t, got)
+	})
+
+	t.Run("invalid rule", func(t *testing.T) {
+		_, err := CheckRules(setup(t), Options{}, "blanket")
+		require.EqualError(t, err, `invalid rule "blanket": expected a gosec rule ID such as G101`)
+	})
+}
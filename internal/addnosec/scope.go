@@ -0,0 +1,78 @@
+package addnosec
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// scopeSep separates a target name from the path glob scoping it, as in
+// `mySecret@internal/database/*`.
+const scopeSep = "@"
+
+// validateScopes checks the `name@glob` entries of targetMap.
+func validateScopes(targetMap map[string]string) error {
+	for _, key := range sortedTargets(targetMap) {
+		name, scope, ok := strings.Cut(key, scopeSep)
+		if !ok {
+			continue
+		}
+		if name == "" || scope == "" {
+			return fmt.Errorf("invalid target %q: expected name%sglob", key, scopeSep)
+		}
+		if _, err := path.Match(scope, ""); err != nil {
+			return fmt.Errorf("invalid scope in target %q: %w", key, err)
+		}
+	}
+	return nil
+}
+
+// scopedTargets returns the targets applying to file, keyed by name:
+// unscoped entries, plus scoped entries whose glob matches file's path
+// relative to the working directory (or opts.GlobBase). A matching scoped
+// entry's comment takes precedence over the name's unscoped one.
+func scopedTargets(targetMap map[string]string, file string, opts Options) map[string]string {
+	scoped := false
+	for key := range targetMap {
+		if strings.Contains(key, scopeSep) {
+			scoped = true
+			break
+		}
+	}
+	if !scoped {
+		return targetMap
+	}
+
+	filePath := scopePath(file, opts)
+	fileTargets := make(map[string]string, len(targetMap))
+	var matched []string
+	for _, key := range sortedTargets(targetMap) {
+		name, scope, ok := strings.Cut(key, scopeSep)
+		if !ok {
+			fileTargets[name] = targetMap[key]
+		} else if match, _ := path.Match(scope, filePath); match {
+			matched = append(matched, key)
+		}
+	}
+	for _, key := range matched {
+		name, _, _ := strings.Cut(key, scopeSep)
+		fileTargets[name] = targetMap[key]
+	}
+	return fileTargets
+}
+
+// scopePath returns the slash-separated path target scopes are matched
+// against: file relative to opts.GlobBase when set, otherwise relative to
+// the working directory when file is absolute and lies below it.
+func scopePath(file string, opts Options) string {
+	p := displayPath(file, opts)
+	if filepath.IsAbs(p) {
+		if wd, err := getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, p); err == nil && !strings.HasPrefix(rel, "..") {
+				p = rel
+			}
+		}
+	}
+	return filepath.ToSlash(filepath.Clean(p))
+}
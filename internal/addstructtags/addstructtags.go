@@ -0,0 +1,204 @@
+package addstructtags
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"strings"
+	"unicode"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/structtags"
+)
+
+var (
+	parseFile  = parser.ParseFile
+	createFile = os.Create
+	formatNode = format.Node
+	readFile   = os.ReadFile
+)
+
+// Naming conventions a tag value can be derived from a field name with, e.g.
+// for the field UserID.
+const (
+	Snake          = "snake"           // user_id
+	Camel          = "camel"           // userId
+	Pascal         = "pascal"          // UserId
+	Kebab          = "kebab"           // user-id
+	Lower          = "lower"           // userid
+	ScreamingSnake = "screaming-snake" // USER_ID
+	AsIs           = "as-is"           // UserID
+)
+
+// NamePlaceholder is replaced by the converted field name in a Tag's Format.
+const NamePlaceholder = "{name}"
+
+// Tag describes one struct tag key to add to every field.
+type Tag struct {
+	// Name is the tag key, e.g. db or gorm.
+	Name string `json:"name"`
+	// Convention converts the field name into the tag value, see Snake.
+	Convention string `json:"convention"`
+	// Format, when set, is the tag value with NamePlaceholder standing for
+	// the converted name, e.g. "column:{name}" for GORM or
+	// "{name},omitempty".
+	Format string `json:"format,omitempty"`
+}
+
+// Rules is the content of a rules file. Tags are added to each field in
+// order.
+type Rules struct {
+	Tags []Tag `json:"tags"`
+}
+
+// Run adds the struct tags described by the rules file at rulesPath to the
+// exported fields of every struct declared in the models file at modelPath,
+// so one invocation can add e.g. both db and gorm tags. Tags a field already
+// carries are never clobbered, so running it twice is a no-op. Embedded and
+// unexported fields, and fields declaring several names (which share one
+// tag), are skipped. When any tag was added, the file is rewritten in place
+// using go/format, which keeps the tags aligned; otherwise it is left
+// untouched.
+//
+// Returns an error if the rules file can't be read or is invalid (no tags,
+// an empty or repeated name, an unknown convention), or if the models file
+// can't be parsed or written.
+func Run(modelPath, rulesPath string) error {
+	rules, err := loadRules(rulesPath)
+	if err != nil {
+		return err
+	}
+
+	fset := token.NewFileSet()
+	f, err := parseFile(fset, modelPath, nil, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse model file: %w", err)
+	}
+
+	changed := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		structType, ok := n.(*ast.StructType)
+		if !ok {
+			return true
+		}
+		for _, field := range structType.Fields.List {
+			if len(field.Names) != 1 || !field.Names[0].IsExported() {
+				continue
+			}
+			for _, tag := range rules.Tags {
+				if structtags.Add(field, tag.Name, tag.value(field.Names[0].Name)) {
+					changed = true
+				}
+			}
+		}
+		return true
+	})
+	if !changed {
+		return nil
+	}
+
+	outFile, err := createFile(modelPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s for writing: %w", modelPath, err)
+	}
+	defer outFile.Close()
+	if err := formatNode(outFile, fset, f); err != nil {
+		return fmt.Errorf("failed to write formatted file %s: %w", modelPath, err)
+	}
+	return nil
+}
+
+func loadRules(rulesPath string) (Rules, error) {
+	data, err := readFile(rulesPath) // #nosec G304 -- rules file is chosen by the user running the tool
+	if err != nil {
+		return Rules{}, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return Rules{}, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	if len(rules.Tags) == 0 {
+		return Rules{}, fmt.Errorf("rules file %s declares no tags", rulesPath)
+	}
+	seen := make(map[string]bool, len(rules.Tags))
+	for _, tag := range rules.Tags {
+		if tag.Name == "" || strings.ContainsAny(tag.Name, " :\"`") {
+			return Rules{}, fmt.Errorf("invalid tag name %q", tag.Name)
+		}
+		if seen[tag.Name] {
+			return Rules{}, fmt.Errorf("tag %q is declared more than once", tag.Name)
+		}
+		seen[tag.Name] = true
+		if convert(tag.Convention, "ID") == "" {
+			return Rules{}, fmt.Errorf("invalid convention %q for tag %s: expected %s, %s, %s, %s, %s, %s or %s",
+				tag.Convention, tag.Name, Snake, Camel, Pascal, Kebab, Lower, ScreamingSnake, AsIs)
+		}
+	}
+	return rules, nil
+}
+
+// value returns the tag value for the field called name.
+func (t Tag) value(name string) string {
+	converted := convert(t.Convention, name)
+	if t.Format == "" {
+		return converted
+	}
+	return strings.ReplaceAll(t.Format, NamePlaceholder, converted)
+}
+
+// convert returns name following convention, or "" for an unknown
+// convention.
+func convert(convention, name string) string {
+	words := splitWords(name)
+	switch convention {
+	case Snake:
+		return strings.ToLower(strings.Join(words, "_"))
+	case ScreamingSnake:
+		return strings.ToUpper(strings.Join(words, "_"))
+	case Kebab:
+		return strings.ToLower(strings.Join(words, "-"))
+	case Lower:
+		return strings.ToLower(name)
+	case Camel, Pascal:
+		var b strings.Builder
+		for i, word := range words {
+			word = strings.ToLower(word)
+			if i > 0 || convention == Pascal {
+				word = strings.ToUpper(word[:1]) + word[1:]
+			}
+			b.WriteString(word)
+		}
+		return b.String()
+	case AsIs:
+		return name
+	}
+	return ""
+}
+
+// splitWords splits a Go identifier into its words, keeping initialisms
+// together: UserID becomes User, ID and HTTPServer2 becomes HTTP, Server2.
+func splitWords(name string) []string {
+	runes := []rune(name)
+	var words []string
+	start := 0
+	for i := 1; i < len(runes); i++ {
+		prev, cur := runes[i-1], runes[i]
+		boundary := cur == '_' ||
+			(unicode.IsUpper(cur) && (unicode.IsLower(prev) || unicode.IsDigit(prev))) ||
+			(unicode.IsUpper(cur) && unicode.IsUpper(prev) && i+1 < len(runes) && unicode.IsLower(runes[i+1]))
+		if !boundary {
+			continue
+		}
+		if word := strings.Trim(string(runes[start:i]), "_"); word != "" {
+			words = append(words, word)
+		}
+		start = i
+	}
+	if word := strings.Trim(string(runes[start:]), "_"); word != "" {
+		words = append(words, word)
+	}
+	return words
+}
@@ -0,0 +1,174 @@
+package addstructtags
+
+import (
+	"go/format"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	modelContent := `package models
+
+type User struct {
+	ID        int64
+	UserID    int64
+	HTTPURL   string
+	CreatedAt string ` + "`json:\"created_at\"`" + `
+	Nickname  string ` + "`db:\"nick\"`" + `
+	internal  string
+	A, B      int
+	Base
+}
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		Rules string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "db and gorm tags, existing tags kept",
+				ExpectedContent: `package models
+
+type User struct {
+	ID        int64  ` + "`db:\"id\" gorm:\"column:id\"`" + `
+	UserID    int64  ` + "`db:\"user_id\" gorm:\"column:user_id\"`" + `
+	HTTPURL   string ` + "`db:\"httpurl\" gorm:\"column:httpurl\"`" + `
+	CreatedAt string ` + "`json:\"created_at\" db:\"created_at\" gorm:\"column:created_at\"`" + `
+	Nickname  string ` + "`db:\"nick\" gorm:\"column:nickname\"`" + `
+	internal  string
+	A, B      int
+	Base
+}
+`,
+			},
+			Rules: `{"tags": [
+				{"name": "db", "convention": "snake"},
+				{"name": "gorm", "convention": "snake", "format": "column:{name}"}
+			]}`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "camel json tags",
+				ExpectedContent: `package models
+
+type User struct {
+	ID        int64  ` + "`json:\"id,omitempty\"`" + `
+	UserID    int64  ` + "`json:\"userId,omitempty\"`" + `
+	HTTPURL   string ` + "`json:\"httpurl,omitempty\"`" + `
+	CreatedAt string ` + "`json:\"created_at\"`" + `
+	Nickname  string ` + "`db:\"nick\" json:\"nickname,omitempty\"`" + `
+	internal  string
+	A, B      int
+	Base
+}
+`,
+			},
+			Rules: `{"tags": [{"name": "json", "convention": "camel", "format": "{name},omitempty"}]}`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "unknown convention",
+				ExpectedErrSubStr: `invalid convention "title" for tag db`,
+			},
+			Rules: `{"tags": [{"name": "db", "convention": "title"}]}`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "repeated tag",
+				ExpectedErrSubStr: `tag "db" is declared more than once`,
+			},
+			Rules: `{"tags": [{"name": "db", "convention": "snake"}, {"name": "db", "convention": "camel"}]}`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "invalid tag name",
+				ExpectedErrSubStr: `invalid tag name "my tag"`,
+			},
+			Rules: `{"tags": [{"name": "my tag", "convention": "snake"}]}`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "no tags",
+				ExpectedErrSubStr: "declares no tags",
+			},
+			Rules: `{"tags": []}`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "malformed rules file",
+				ExpectedErrSubStr: "failed to parse rules file",
+			},
+			Rules: `{"tags": [`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			modelFile := filepath.Join(tmpDir, "models.go")
+			if err := os.WriteFile(modelFile, []byte(modelContent), 0644); err != nil {
+				t.Fatalf("failed to write model file: %v", err)
+			}
+			rulesFile := filepath.Join(tmpDir, "rules.json")
+			if err := os.WriteFile(rulesFile, []byte(tc.Rules), 0644); err != nil {
+				t.Fatalf("failed to write rules file: %v", err)
+			}
+
+			err := Run(modelFile, rulesFile)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			} else if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			got, err := os.ReadFile(modelFile)
+			if err != nil {
+				t.Fatalf("failed to read model file: %v", err)
+			}
+			formattedExpected, err := format.Source([]byte(tc.ExpectedContent))
+			if err != nil {
+				t.Fatalf("failed to format expected content with gofmt standards: %v", err)
+			}
+			if diff := cmp.Diff(string(formattedExpected), string(got)); diff != "" {
+				t.Errorf("model file mismatch (-want +got)\n%s", diff)
+			}
+
+			// a second run must not change anything
+			require.NoError(t, Run(modelFile, rulesFile))
+			again, err := os.ReadFile(modelFile)
+			if err != nil {
+				t.Fatalf("failed to read model file: %v", err)
+			}
+			require.Equal(t, string(got), string(again))
+		})
+	}
+}
+
+func TestConvert(t *testing.T) {
+	tests := []struct {
+		convention string
+		name       string
+		expected   string
+	}{
+		{Snake, "UserID", "user_id"},
+		{Snake, "HTTPServer2", "http_server2"},
+		{Snake, "ID", "id"},
+		{Camel, "UserID", "userId"},
+		{Camel, "HTTPServer", "httpServer"},
+		{Pascal, "UserID", "UserId"},
+		{Kebab, "CreatedAt", "created-at"},
+		{Lower, "UserID", "userid"},
+		{ScreamingSnake, "UserID", "USER_ID"},
+		{AsIs, "UserID", "UserID"},
+		{"title", "UserID", ""},
+	}
+	for _, tc := range tests {
+		t.Run(tc.convention+" "+tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, convert(tc.convention, tc.name))
+		})
+	}
+}
@@ -0,0 +1,167 @@
+package addvalidatetags
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/structtags"
+)
+
+var (
+	parseFile  = parser.ParseFile
+	createFile = os.Create
+	formatNode = format.Node
+	readFile   = os.ReadFile
+)
+
+// Rule maps a field condition onto the value of its validate tag.
+//
+// Supported conditions are:
+//   - any:          every field
+//   - nullable:     pointer fields and Null* types (e.g. sql.NullString)
+//   - non-nullable: every other field
+//   - type:<type>:  fields of exactly this type (e.g. type:uuid.UUID)
+//   - field:<name>: fields with this name (e.g. field:Email)
+type Rule struct {
+	When string `json:"when"`
+	Tag  string `json:"tag"`
+}
+
+// Rules is the content of a rules file. The first rule matching a field wins;
+// a rule with an empty Tag leaves matching fields untagged.
+type Rules struct {
+	Rules []Rule `json:"rules"`
+}
+
+// DefaultRules tags every non-nullable field as required.
+var DefaultRules = Rules{Rules: []Rule{{When: "non-nullable", Tag: "required"}}}
+
+// Run adds `validate` struct tags to the fields of every struct declared in
+// the models file at modelPath, following the rules file at rulesPath (or
+// DefaultRules when rulesPath is empty). Fields that already carry a validate
+// tag are left untouched, so running it twice is a no-op. The file is
+// rewritten in place using go/format.
+//
+// Returns an error if the rules file can't be read or holds an unknown
+// condition, or if the models file can't be parsed or written.
+func Run(modelPath, rulesPath string) error {
+	rules := DefaultRules
+	if rulesPath != "" {
+		var err error
+		if rules, err = loadRules(rulesPath); err != nil {
+			return err
+		}
+	}
+
+	fset := token.NewFileSet()
+	f, err := parseFile(fset, modelPath, nil, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse model file: %w", err)
+	}
+
+	ast.Inspect(f, func(n ast.Node) bool {
+		structType, ok := n.(*ast.StructType)
+		if !ok {
+			return true
+		}
+		for _, field := range structType.Fields.List {
+			if len(field.Names) == 0 {
+				continue
+			}
+			if tag := rules.tagFor(field); tag != "" {
+				structtags.Add(field, "validate", tag)
+			}
+		}
+		return true
+	})
+
+	outFile, err := createFile(modelPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s for writing: %w", modelPath, err)
+	}
+	defer outFile.Close()
+	if err := formatNode(outFile, fset, f); err != nil {
+		return fmt.Errorf("failed to write formatted file %s: %w", modelPath, err)
+	}
+	return nil
+}
+
+func loadRules(rulesPath string) (Rules, error) {
+	data, err := readFile(rulesPath) // #nosec G304 -- rules file is chosen by the user running the tool
+	if err != nil {
+		return Rules{}, fmt.Errorf("failed to read rules file: %w", err)
+	}
+	var rules Rules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return Rules{}, fmt.Errorf("failed to parse rules file: %w", err)
+	}
+	for _, rule := range rules.Rules {
+		if !validCondition(rule.When) {
+			return Rules{}, fmt.Errorf("invalid rule condition %q", rule.When)
+		}
+	}
+	return rules, nil
+}
+
+func validCondition(when string) bool {
+	switch when {
+	case "any", "nullable", "non-nullable":
+		return true
+	}
+	kind, arg, ok := strings.Cut(when, ":")
+	return ok && arg != "" && (kind == "type" || kind == "field")
+}
+
+// tagFor returns the validate tag of the first rule matching field.
+func (r Rules) tagFor(field *ast.Field) string {
+	for _, rule := range r.Rules {
+		if matches(rule.When, field) {
+			return rule.Tag
+		}
+	}
+	return ""
+}
+
+func matches(when string, field *ast.Field) bool {
+	switch when {
+	case "any":
+		return true
+	case "nullable":
+		return nullable(field.Type)
+	case "non-nullable":
+		return !nullable(field.Type)
+	}
+	kind, arg, _ := strings.Cut(when, ":")
+	switch kind {
+	case "type":
+		return types.ExprString(field.Type) == arg
+	case "field":
+		for _, name := range field.Names {
+			if name.Name == arg {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// nullable reports whether expr is a pointer or a Null* type such as
+// sql.NullString or a generated NullStatus enum wrapper.
+func nullable(expr ast.Expr) bool {
+	switch t := expr.(type) {
+	case *ast.StarExpr:
+		return true
+	case *ast.Ident:
+		return strings.HasPrefix(t.Name, "Null")
+	case *ast.SelectorExpr:
+		return strings.HasPrefix(t.Sel.Name, "Null")
+	}
+	return false
+}
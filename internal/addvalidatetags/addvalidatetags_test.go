@@ -0,0 +1,135 @@
+package addvalidatetags
+
+import (
+	"go/format"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	modelContent := `package models
+
+import "database/sql"
+
+type User struct {
+	ID       int64
+	Email    string
+	Nickname sql.NullString
+	Manager  *User
+	Status   NullUserStatus
+	Name     string ` + "`json:\"name\"`" + `
+	Role     string ` + "`validate:\"oneof=admin user\"`" + `
+}
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		Rules string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "default rules tag non-nullable fields",
+				ExpectedContent: `package models
+
+import "database/sql"
+
+type User struct {
+	ID       int64          ` + "`validate:\"required\"`" + `
+	Email    string         ` + "`validate:\"required\"`" + `
+	Nickname sql.NullString
+	Manager  *User
+	Status   NullUserStatus
+	Name     string ` + "`json:\"name\" validate:\"required\"`" + `
+	Role     string ` + "`validate:\"oneof=admin user\"`" + `
+}
+`,
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "rules file, first match wins",
+				ExpectedContent: `package models
+
+import "database/sql"
+
+type User struct {
+	ID       int64
+	Email    string         ` + "`validate:\"required,email\"`" + `
+	Nickname sql.NullString ` + "`validate:\"omitempty\"`" + `
+	Manager  *User          ` + "`validate:\"omitempty\"`" + `
+	Status   NullUserStatus ` + "`validate:\"omitempty\"`" + `
+	Name     string ` + "`json:\"name\" validate:\"required\"`" + `
+	Role     string ` + "`validate:\"oneof=admin user\"`" + `
+}
+`,
+			},
+			Rules: `{"rules": [
+				{"when": "field:Email", "tag": "required,email"},
+				{"when": "type:int64", "tag": ""},
+				{"when": "nullable", "tag": "omitempty"},
+				{"when": "any", "tag": "required"}
+			]}`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "unknown rule condition",
+				ExpectedErrSubStr: "invalid rule condition",
+			},
+			Rules: `{"rules": [{"when": "exported", "tag": "required"}]}`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "malformed rules file",
+				ExpectedErrSubStr: "failed to parse rules file",
+			},
+			Rules: `{"rules": [`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			modelFile := filepath.Join(tmpDir, "models.go")
+			if err := os.WriteFile(modelFile, []byte(modelContent), 0644); err != nil {
+				t.Fatalf("failed to write model file: %v", err)
+			}
+			rulesFile := ""
+			if tc.Rules != "" {
+				rulesFile = filepath.Join(tmpDir, "rules.json")
+				if err := os.WriteFile(rulesFile, []byte(tc.Rules), 0644); err != nil {
+					t.Fatalf("failed to write rules file: %v", err)
+				}
+			}
+
+			err := Run(modelFile, rulesFile)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			} else if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			got, err := os.ReadFile(modelFile)
+			if err != nil {
+				t.Fatalf("failed to read model file: %v", err)
+			}
+			formattedExpected, err := format.Source([]byte(tc.ExpectedContent))
+			if err != nil {
+				t.Fatalf("failed to format expected content with gofmt standards: %v", err)
+			}
+			if diff := cmp.Diff(string(formattedExpected), string(got)); diff != "" {
+				t.Errorf("model file mismatch (-want +got)\n%s", diff)
+			}
+
+			// a second run must not change anything
+			require.NoError(t, Run(modelFile, rulesFile))
+			again, err := os.ReadFile(modelFile)
+			if err != nil {
+				t.Fatalf("failed to read model file: %v", err)
+			}
+			require.Equal(t, string(got), string(again))
+		})
+	}
+}
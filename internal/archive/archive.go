@@ -0,0 +1,96 @@
+package archive
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var (
+	createFile = os.Create
+	getwd      = os.Getwd
+)
+
+// Zip packages processed files into a zip archive, for uploading the result
+// of a run as a build artifact. A nil *Zip discards files, so callers don't
+// need to check whether archiving is enabled.
+type Zip struct {
+	file        io.WriteCloser
+	w           *zip.Writer
+	changedOnly bool
+	// names maps each entry added to the file stored under it
+	names map[string]string
+}
+
+// Create creates the zip archive at path. With changedOnly set, Add only
+// stores files the run changed.
+func Create(path string, changedOnly bool) (*Zip, error) {
+	f, err := createFile(path) // #nosec G304 -- archive path is chosen by the user running the tool
+	if err != nil {
+		return nil, fmt.Errorf("failed to create zip archive %s: %w", path, err)
+	}
+	return &Zip{file: f, w: zip.NewWriter(f), changedOnly: changedOnly, names: make(map[string]string)}, nil
+}
+
+// Add stores content, the processed contents of file, under file's path
+// relative to the working directory. changed reports whether processing
+// changed the file. Adding a file whose entry name another file already
+// took, as `../a/x.go` and `a/x.go` do, is an error.
+func (z *Zip) Add(file string, content []byte, changed bool) error {
+	if z == nil || z.changedOnly && !changed {
+		return nil
+	}
+	name := entryName(file)
+	if other, ok := z.names[name]; ok {
+		return fmt.Errorf("failed to add %s to zip archive: %s is already stored as %s", file, other, name)
+	}
+	z.names[name] = file
+	w, err := z.w.Create(name)
+	if err != nil {
+		return fmt.Errorf("failed to add %s to zip archive: %w", file, err)
+	}
+	if _, err := w.Write(content); err != nil {
+		return fmt.Errorf("failed to add %s to zip archive: %w", file, err)
+	}
+	return nil
+}
+
+// Close finishes the archive and closes the underlying file. Closing an
+// archive again is a no-op, so Close can be deferred for error paths as well.
+func (z *Zip) Close() error {
+	if z == nil || z.w == nil {
+		return nil
+	}
+	err := z.w.Close()
+	if cerr := z.file.Close(); err == nil {
+		err = cerr
+	}
+	z.w = nil
+	if err != nil {
+		return fmt.Errorf("failed to write zip archive: %w", err)
+	}
+	return nil
+}
+
+// entryName returns the slash-separated archive path of file. Absolute
+// paths are made relative to the working directory; paths outside it keep
+// their absolute form minus the leading separator, so no entry climbs out of
+// the extraction directory.
+func entryName(file string) string {
+	name := filepath.Clean(file)
+	if filepath.IsAbs(name) {
+		if wd, err := getwd(); err == nil {
+			if rel, err := filepath.Rel(wd, name); err == nil && !strings.HasPrefix(rel, "..") {
+				name = rel
+			}
+		}
+	}
+	name = filepath.ToSlash(name)
+	for strings.HasPrefix(name, "../") {
+		name = strings.TrimPrefix(name, "../")
+	}
+	return strings.TrimLeft(strings.TrimPrefix(name, filepath.VolumeName(name)), "/")
+}
@@ -0,0 +1,76 @@
+package archive
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZip(t *testing.T) {
+	tests := []struct {
+		name        string
+		changedOnly bool
+		expected    map[string]string
+	}{
+		{
+			name: "all files",
+			expected: map[string]string{
+				"internal/database/users.sql.go": "package database // changed\n",
+				"internal/database/db.go":        "package database\n",
+			},
+		},
+		{
+			name:        "changed only",
+			changedOnly: true,
+			expected: map[string]string{
+				"internal/database/users.sql.go": "package database // changed\n",
+			},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Chdir(t.TempDir())
+			wd, err := os.Getwd()
+			require.NoError(t, err)
+			z, err := Create("out.zip", tc.changedOnly)
+			require.NoError(t, err)
+			require.NoError(t, z.Add(filepath.Join(wd, "internal", "database", "users.sql.go"), []byte("package database // changed\n"), true))
+			require.NoError(t, z.Add(filepath.Join("internal", "database", "db.go"), []byte("package database\n"), false))
+			require.NoError(t, z.Close())
+			require.NoError(t, z.Close())
+
+			got, err := helpers.ReadZip("out.zip")
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+}
+
+func TestZipCollision(t *testing.T) {
+	t.Chdir(t.TempDir())
+	z, err := Create("out.zip", false)
+	require.NoError(t, err)
+	defer z.Close()
+	require.NoError(t, z.Add(filepath.Join("a", "x.go"), []byte("package a\n"), true))
+	err = z.Add(filepath.Join("..", "a", "x.go"), []byte("package a\n"), true)
+	require.EqualError(t, err, "failed to add "+filepath.Join("..", "a", "x.go")+" to zip archive: "+filepath.Join("a", "x.go")+" is already stored as a/x.go")
+}
+
+func TestZipNil(t *testing.T) {
+	var z *Zip
+	require.NoError(t, z.Add("file.go", nil, true))
+	require.NoError(t, z.Close())
+}
+
+func TestEntryName(t *testing.T) {
+	getwd = func() (string, error) { return "/work", nil }
+	defer func() { getwd = os.Getwd }()
+
+	require.Equal(t, "a/b.go", entryName("/work/a/b.go"))
+	require.Equal(t, "a/b.go", entryName("./a/../a/b.go"))
+	require.Equal(t, "b.go", entryName("../../b.go"))
+	require.Equal(t, "other/b.go", entryName("/other/b.go"))
+}
@@ -0,0 +1,75 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"go/token"
+	"os"
+	"time"
+)
+
+var (
+	openFile = os.OpenFile
+	now      = time.Now
+)
+
+// Record is a single change, written as one JSON line.
+type Record struct {
+	// Time is the start of the run that made the change.
+	Time       time.Time `json:"time"`
+	Operation  string    `json:"operation"`
+	File       string    `json:"file"`
+	Identifier string    `json:"identifier"`
+	Line       int       `json:"line"`
+	Column     int       `json:"column"`
+}
+
+// Log appends Records to an audit log file. A nil *Log discards records, so
+// callers don't need to check whether auditing is enabled.
+type Log struct {
+	file      *os.File
+	enc       *json.Encoder
+	operation string
+	runAt     time.Time
+}
+
+// Open opens the audit log at path for the given operation. Records are
+// appended to any existing content unless truncate is set.
+func Open(path, operation string, truncate bool) (*Log, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	if truncate {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	}
+	f, err := openFile(path, flags, 0600) // #nosec G304 -- audit log path is chosen by the user running the tool
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log %s: %w", path, err)
+	}
+	return &Log{file: f, enc: json.NewEncoder(f), operation: operation, runAt: now().UTC()}, nil
+}
+
+// Record writes a record for a change to identifier at pos.
+func (l *Log) Record(identifier string, pos token.Position) error {
+	if l == nil {
+		return nil
+	}
+	err := l.enc.Encode(Record{
+		Time:       l.runAt,
+		Operation:  l.operation,
+		File:       pos.Filename,
+		Identifier: identifier,
+		Line:       pos.Line,
+		Column:     pos.Column,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to write audit record: %w", err)
+	}
+	return nil
+}
+
+// Close closes the underlying file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	return l.file.Close()
+}
@@ -0,0 +1,56 @@
+package audit
+
+import (
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLog(t *testing.T) {
+	runAt := time.Date(2025, 3, 6, 12, 0, 0, 0, time.UTC)
+	now = func() time.Time { return runAt }
+	defer func() { now = time.Now }()
+
+	tests := []struct {
+		Name     string
+		Truncate bool
+		Expected string
+	}{
+		{
+			Name: "append keeps earlier runs",
+			Expected: `{"time":"2025-03-06T12:00:00Z","operation":"add-nosec","file":"a.sql.go","identifier":"first","line":1,"column":7}
+{"time":"2025-03-06T12:00:00Z","operation":"add-nosec","file":"a.sql.go","identifier":"second","line":2,"column":7}
+`,
+		},
+		{
+			Name:     "truncate starts over",
+			Truncate: true,
+			Expected: `{"time":"2025-03-06T12:00:00Z","operation":"add-nosec","file":"a.sql.go","identifier":"second","line":2,"column":7}
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "audit.jsonl")
+			for i, ident := range []string{"first", "second"} {
+				log, err := Open(path, "add-nosec", tc.Truncate)
+				require.NoError(t, err)
+				require.NoError(t, log.Record(ident, token.Position{Filename: "a.sql.go", Line: i + 1, Column: 7}))
+				require.NoError(t, log.Close())
+			}
+			got, err := os.ReadFile(path)
+			require.NoError(t, err)
+			require.Equal(t, tc.Expected, string(got))
+		})
+	}
+}
+
+func TestNilLog(t *testing.T) {
+	var log *Log
+	require.NoError(t, log.Record("x", token.Position{}))
+	require.NoError(t, log.Close())
+}
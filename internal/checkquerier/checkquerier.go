@@ -0,0 +1,199 @@
+package checkquerier
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/querier"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+var parseFile = parser.ParseFile
+
+// Mismatch kinds reported by Check.
+const (
+	// MissingFromInterface is a method on the receiver absent from the interface.
+	MissingFromInterface = "missing from interface"
+	// MissingFromQueries is an interface method the receiver doesn't declare.
+	MissingFromQueries = "missing from queries"
+	// SignatureDiffers is a method whose signatures differ between the two.
+	SignatureDiffers = "signature differs"
+)
+
+// Mismatch is a method that is out of sync between the generated receiver
+// and the hand-written interface.
+type Mismatch struct {
+	Method string
+	Kind   string
+	// Queries and Interface are the normalized signatures, set when the
+	// method exists on that side.
+	Queries   string
+	Interface string
+}
+
+func (m Mismatch) String() string {
+	switch m.Kind {
+	case MissingFromInterface:
+		return fmt.Sprintf("%s: %s %s", m.Method, m.Kind, m.Queries)
+	case MissingFromQueries:
+		return fmt.Sprintf("%s: %s %s", m.Method, m.Kind, m.Interface)
+	default:
+		return fmt.Sprintf("%s: %s: queries %s, interface %s", m.Method, m.Kind, m.Queries, m.Interface)
+	}
+}
+
+// Options holds the optional settings for Check. Empty fields fall back to
+// the defaults noted below.
+type Options struct {
+	// Name is the name of the interface type (default "Querier").
+	Name string
+	// Receiver is the generated type whose methods are checked (default "Queries").
+	Receiver string
+}
+
+// Check compares the exported methods generated on *Queries in dir against
+// the methods of the Querier interface declared in interfacePath, and
+// returns every method missing on either side or whose signatures differ,
+// sorted by method name.
+//
+// Signatures are compared by parameter and result types only, with package
+// qualifiers resolved to their import paths so that different import
+// aliases compare equal. The query package's own types match whether they
+// are written bare (interface in the query package) or qualified with the
+// query package's name.
+//
+// Returns an error if either side can't be parsed or the interface isn't
+// declared in interfacePath.
+func Check(interfacePath, dir string, opts Options) ([]Mismatch, error) {
+	if opts.Name == "" {
+		opts.Name = "Querier"
+	}
+	if opts.Receiver == "" {
+		opts.Receiver = "Queries"
+	}
+
+	methods, err := querier.Collect(dir, opts.Receiver)
+	if err != nil {
+		return nil, err
+	}
+	generated := make(map[string]string, len(methods))
+	queryPkg := ""
+	for _, m := range methods {
+		queryPkg = m.Package
+		generated[m.Name] = signature(m.Type, m.Imports, m.Package, m.Package)
+	}
+
+	declared, err := interfaceMethods(interfacePath, opts.Name, queryPkg)
+	if err != nil {
+		return nil, err
+	}
+
+	var mismatches []Mismatch
+	for name, sig := range generated {
+		ifaceSig, ok := declared[name]
+		switch {
+		case !ok:
+			mismatches = append(mismatches, Mismatch{Method: name, Kind: MissingFromInterface, Queries: sig})
+		case ifaceSig != sig:
+			mismatches = append(mismatches, Mismatch{Method: name, Kind: SignatureDiffers, Queries: sig, Interface: ifaceSig})
+		}
+	}
+	for name, sig := range declared {
+		if _, ok := generated[name]; !ok {
+			mismatches = append(mismatches, Mismatch{Method: name, Kind: MissingFromQueries, Interface: sig})
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool { return mismatches[i].Method < mismatches[j].Method })
+	return mismatches, nil
+}
+
+// interfaceMethods returns the normalized signatures of the methods of the
+// interface named name in file. Embedded interfaces are not followed.
+func interfaceMethods(file, name, queryPkg string) (map[string]string, error) {
+	f, err := parseFile(token.NewFileSet(), file, nil, parser.SkipObjectResolution)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse interface file %s: %w", file, err)
+	}
+	imports := querier.Imports(f)
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok || typeSpec.Name.Name != name {
+				continue
+			}
+			iface, ok := typeSpec.Type.(*ast.InterfaceType)
+			if !ok {
+				return nil, fmt.Errorf("%s in %s is not an interface", name, file)
+			}
+			methods := make(map[string]string)
+			for _, field := range iface.Methods.List {
+				fn, ok := field.Type.(*ast.FuncType)
+				if !ok {
+					continue
+				}
+				for _, ident := range field.Names {
+					methods[ident.Name] = signature(fn, imports, f.Name.Name, queryPkg)
+				}
+			}
+			return methods, nil
+		}
+	}
+	return nil, fmt.Errorf("interface %s not found in %s", name, file)
+}
+
+// signature renders fn's parameter and result types, without names, in a
+// normalized form: package qualifiers become import paths, and bare
+// identifiers declared by filePkg become filePkg-qualified. Qualifiers whose
+// import path ends in queryPkg are treated as the query package itself.
+func signature(fn *ast.FuncType, imports map[string]string, filePkg, queryPkg string) string {
+	normalize := func(expr ast.Expr) string {
+		return types.ExprString(astutil.Apply(expr, func(c *astutil.Cursor) bool {
+			switch n := c.Node().(type) {
+			case *ast.SelectorExpr:
+				pkg, ok := n.X.(*ast.Ident)
+				if !ok {
+					return true
+				}
+				importPath, ok := imports[pkg.Name]
+				if !ok {
+					return true
+				}
+				if path.Base(importPath) == queryPkg {
+					importPath = queryPkg
+				}
+				c.Replace(ast.NewIdent(importPath + "." + n.Sel.Name))
+				return false
+			case *ast.Ident:
+				if c.Name() == "Names" || c.Name() == "Sel" || !n.IsExported() || types.Universe.Lookup(n.Name) != nil {
+					return true
+				}
+				c.Replace(ast.NewIdent(filePkg + "." + n.Name))
+			}
+			return true
+		}, nil).(ast.Expr))
+	}
+	list := func(fields *ast.FieldList) string {
+		if fields == nil {
+			return ""
+		}
+		var parts []string
+		for _, field := range fields.List {
+			typ := normalize(field.Type)
+			for i := 0; i < max(len(field.Names), 1); i++ {
+				parts = append(parts, typ)
+			}
+		}
+		return strings.Join(parts, ", ")
+	}
+	return "func(" + list(fn.Params) + ") (" + list(fn.Results) + ")"
+}
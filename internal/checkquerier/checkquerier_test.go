@@ -0,0 +1,141 @@
+package checkquerier
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+const queryContent = `package database
+
+import (
+	"context"
+
+	"example.com/app/internal/models"
+)
+
+type Queries struct{}
+
+type CreateUserParams struct {
+	Name string
+}
+
+func (q *Queries) GetUser(ctx context.Context, id int64) (models.User, error) {
+	return models.User{}, nil
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (*models.User, error) {
+	return nil, nil
+}
+
+func (q *Queries) ListUsers(ctx context.Context, limit, offset int32) ([]models.User, error) {
+	return nil, nil
+}
+
+func (q *Queries) WithTx() *Queries {
+	return q
+}
+`
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name      string
+		iface     string
+		expected  []Mismatch
+		expectErr string
+	}{
+		{
+			name: "in sync, same package",
+			iface: `package database
+
+import (
+	"context"
+
+	m "example.com/app/internal/models"
+)
+
+type Querier interface {
+	CreateUser(ctx context.Context, arg CreateUserParams) (*m.User, error)
+	GetUser(context.Context, int64) (m.User, error)
+	ListUsers(ctx context.Context, limit int32, offset int32) ([]m.User, error)
+}
+`,
+		},
+		{
+			name: "in sync, other package",
+			iface: `package repo
+
+import (
+	"context"
+
+	"example.com/app/internal/database"
+	"example.com/app/internal/models"
+)
+
+type Querier interface {
+	CreateUser(ctx context.Context, arg database.CreateUserParams) (*models.User, error)
+	GetUser(ctx context.Context, id int64) (models.User, error)
+	ListUsers(ctx context.Context, limit, offset int32) ([]models.User, error)
+}
+`,
+		},
+		{
+			name: "drifted",
+			iface: `package database
+
+import (
+	"context"
+
+	"example.com/app/internal/models"
+)
+
+type Querier interface {
+	GetUser(ctx context.Context, id int32) (models.User, error)
+	ListUsers(ctx context.Context, limit, offset int32) ([]models.User, error)
+	DeleteUser(ctx context.Context, id int64) error
+}
+`,
+			expected: []Mismatch{
+				{Method: "CreateUser", Kind: MissingFromInterface, Queries: "func(context.Context, database.CreateUserParams) (*example.com/app/internal/models.User, error)"},
+				{Method: "DeleteUser", Kind: MissingFromQueries, Interface: "func(context.Context, int64) (error)"},
+				{
+					Method:    "GetUser",
+					Kind:      SignatureDiffers,
+					Queries:   "func(context.Context, int64) (example.com/app/internal/models.User, error)",
+					Interface: "func(context.Context, int32) (example.com/app/internal/models.User, error)",
+				},
+			},
+		},
+		{
+			name:      "interface not found",
+			iface:     "package database\n\ntype Other interface{}\n",
+			expectErr: "interface Querier not found in",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			if err := os.WriteFile(filepath.Join(dir, "query.sql.go"), []byte(queryContent), 0644); err != nil {
+				t.Fatalf("failed to write query file: %v", err)
+			}
+			ifacePath := filepath.Join(t.TempDir(), "querier.go")
+			if err := os.WriteFile(ifacePath, []byte(tc.iface), 0644); err != nil {
+				t.Fatalf("failed to write interface file: %v", err)
+			}
+
+			got, err := Check(ifacePath, dir, Options{})
+			if tc.expectErr != "" {
+				require.ErrorContains(t, err, tc.expectErr)
+				return
+			}
+			require.NoError(t, err)
+			if diff := cmp.Diff(tc.expected, got); diff != "" {
+				t.Errorf("mismatches differ (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
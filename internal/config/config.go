@@ -1,5 +1,113 @@
 package config
 
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/token"
+	"io"
+	"os"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the config file the CLI reads from the working directory
+// unless --config names another.
+const FileName = ".sqlc-qol.yaml"
+
+// Config holds the settings shared by the commands: the built-in defaults,
+// overridden by the config file, overridden in turn by the flags Validate
+// checks. Fields tagged `yaml:"-"` can only come from flags.
 type Config struct {
-	AllowedBaseDir string
+	AllowedBaseDir string `yaml:"allowed_base_dir"`
+
+	AddNosec      AddNosec      `yaml:"add_nosec"`
+	QualifyModels QualifyModels `yaml:"qualify_models"`
+}
+
+// AddNosec holds the add-nosec defaults the config file can set, used when
+// the corresponding argument or flags aren't given.
+type AddNosec struct {
+	// Glob is the glob pattern argument.
+	Glob string `yaml:"glob"`
+	// Targets and CSV stand in for --targets and --csv, used only when
+	// none of --targets, --csv and --csv-dir is passed. Once the flags are
+	// applied, Targets, CSV and CSVDir hold the target source of the
+	// command run, whichever command that is.
+	Targets string `yaml:"targets"`
+	CSV     string `yaml:"csv"`
+	CSVDir  string `yaml:"-"`
+}
+
+// QualifyModels holds the qualify-models defaults the config file can set,
+// used when the corresponding flags aren't given.
+type QualifyModels struct {
+	Models string `yaml:"models"`
+	Import string `yaml:"import"`
+	// Alias and ReplaceAlias are the --alias and --replace-alias flags.
+	Alias        string `yaml:"-"`
+	ReplaceAlias string `yaml:"-"`
+}
+
+// Default returns the built-in configuration.
+func Default() Config {
+	return Config{AllowedBaseDir: "."}
+}
+
+// Load reads the YAML config file at path over Default, so keys the file
+// leaves out keep their built-in value. Unknown keys are an error, to catch
+// typos; the values themselves are checked by Validate. The error wraps
+// fs.ErrNotExist when the file doesn't exist.
+func Load(path string) (Config, error) {
+	c := Default()
+	data, err := os.ReadFile(path) // #nosec G304 -- the config path is chosen by the user
+	if err != nil {
+		return c, fmt.Errorf("failed to read config %s: %w", path, err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&c); err != nil && !errors.Is(err, io.EOF) {
+		return c, fmt.Errorf("failed to parse config %s: %w", path, err)
+	}
+	return c, nil
+}
+
+// Validate checks the invariants of c and returns every violation joined
+// into a single error, or nil if c is valid:
+//   - AllowedBaseDir is set and, when AddNosec reads targets from a CSV file
+//     or directory (not stdin), names an existing directory.
+//   - AddNosec sets at most one of Targets, CSV and CSVDir.
+//   - QualifyModels.Alias, if set, is a Go identifier.
+//   - QualifyModels.ReplaceAlias, if set, is old=new, both Go identifiers.
+func (c Config) Validate() error {
+	var errs []error
+	readsCSV := (c.AddNosec.CSV != "" && c.AddNosec.CSV != "-") || c.AddNosec.CSVDir != ""
+	if c.AllowedBaseDir == "" {
+		errs = append(errs, errors.New("allowed base dir must be set"))
+	} else if readsCSV {
+		if info, err := os.Stat(c.AllowedBaseDir); err != nil {
+			errs = append(errs, fmt.Errorf("allowed base dir %q: %w", c.AllowedBaseDir, err))
+		} else if !info.IsDir() {
+			errs = append(errs, fmt.Errorf("allowed base dir %q is not a directory", c.AllowedBaseDir))
+		}
+	}
+	sources := 0
+	for _, source := range []string{c.AddNosec.Targets, c.AddNosec.CSV, c.AddNosec.CSVDir} {
+		if source != "" {
+			sources++
+		}
+	}
+	if sources > 1 {
+		errs = append(errs, errors.New("targets (--targets, add_nosec.targets), csv (--csv, add_nosec.csv) and --csv-dir are mutually exclusive"))
+	}
+	if alias := c.QualifyModels.Alias; alias != "" && !token.IsIdentifier(alias) {
+		errs = append(errs, fmt.Errorf("invalid alias %q: expected a Go identifier", alias))
+	}
+	if replace := c.QualifyModels.ReplaceAlias; replace != "" {
+		if from, to, ok := strings.Cut(replace, "="); !ok || !token.IsIdentifier(from) || !token.IsIdentifier(to) {
+			errs = append(errs, fmt.Errorf("invalid replace-alias %q: expected old=new, both Go identifiers", replace))
+		}
+	}
+	return errors.Join(errs...)
 }
@@ -0,0 +1,133 @@
+package config
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidate(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "targets.csv")
+	if err := os.WriteFile(file, nil, 0644); err != nil {
+		t.Fatalf("failed to write file: %v", err)
+	}
+
+	csv := AddNosec{CSV: "targets.csv"}
+	tests := []struct {
+		name   string
+		config Config
+		errMsg string
+	}{
+		{name: "valid", config: Config{AllowedBaseDir: dir, AddNosec: csv}},
+		{name: "empty base dir", config: Config{}, errMsg: "allowed base dir must be set"},
+		{name: "missing base dir", config: Config{AllowedBaseDir: filepath.Join(dir, "missing"), AddNosec: csv}, errMsg: "no such file or directory"},
+		{name: "base dir is a file", config: Config{AllowedBaseDir: file, AddNosec: AddNosec{CSVDir: "csv"}}, errMsg: "is not a directory"},
+		{name: "missing base dir without csv", config: Config{AllowedBaseDir: filepath.Join(dir, "missing"), AddNosec: AddNosec{Targets: "a"}}},
+		{name: "missing base dir with csv from stdin", config: Config{AllowedBaseDir: filepath.Join(dir, "missing"), AddNosec: AddNosec{CSV: "-"}}},
+		{
+			name:   "targets and csv",
+			config: Config{AllowedBaseDir: dir, AddNosec: AddNosec{Targets: "a", CSV: "b.csv"}},
+			errMsg: "are mutually exclusive",
+		},
+		{
+			name:   "csv and csv dir",
+			config: Config{AllowedBaseDir: dir, AddNosec: AddNosec{CSV: "b.csv", CSVDir: "csv"}},
+			errMsg: "are mutually exclusive",
+		},
+		{name: "alias", config: Config{AllowedBaseDir: dir, QualifyModels: QualifyModels{Alias: "dbm"}}},
+		{
+			name:   "invalid alias",
+			config: Config{AllowedBaseDir: dir, QualifyModels: QualifyModels{Alias: "db-models"}},
+			errMsg: `invalid alias "db-models": expected a Go identifier`,
+		},
+		{name: "replace alias", config: Config{AllowedBaseDir: dir, QualifyModels: QualifyModels{ReplaceAlias: "db=models"}}},
+		{
+			name:   "replace alias without new",
+			config: Config{AllowedBaseDir: dir, QualifyModels: QualifyModels{ReplaceAlias: "db"}},
+			errMsg: `invalid replace-alias "db": expected old=new, both Go identifiers`,
+		},
+		{
+			name:   "replace alias to a non-identifier",
+			config: Config{AllowedBaseDir: dir, QualifyModels: QualifyModels{ReplaceAlias: "db=my-models"}},
+			errMsg: `invalid replace-alias "db=my-models"`,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := tc.config.Validate()
+			if tc.errMsg == "" {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorContains(t, err, tc.errMsg)
+		})
+	}
+
+	err := Config{AddNosec: AddNosec{Targets: "a", CSV: "b.csv"}, QualifyModels: QualifyModels{Alias: "db-models"}}.Validate()
+	require.ErrorContains(t, err, "allowed base dir must be set")
+	require.ErrorContains(t, err, "mutually exclusive", "every violation should be reported")
+	require.ErrorContains(t, err, "invalid alias", "every violation should be reported")
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name     string
+		content  string
+		expected Config
+		errMsg   string
+	}{
+		{
+			name: "full",
+			content: `allowed_base_dir: ./csv
+add_nosec:
+  glob: "internal/database/*.sql.go"
+  csv: ./csv/targets.csv
+qualify_models:
+  models: internal/models/models.go
+  import: example.com/app/internal/models
+`,
+			expected: Config{
+				AllowedBaseDir: "./csv",
+				AddNosec:       AddNosec{Glob: "internal/database/*.sql.go", CSV: "./csv/targets.csv"},
+				QualifyModels:  QualifyModels{Models: "internal/models/models.go", Import: "example.com/app/internal/models"},
+			},
+		},
+		{
+			name:     "defaults kept",
+			content:  "add_nosec:\n  targets: createUser\n",
+			expected: Config{AllowedBaseDir: ".", AddNosec: AddNosec{Targets: "createUser"}},
+		},
+		{name: "empty", expected: Default()},
+		{name: "unknown key", content: "qualify_model:\n  models: x\n", errMsg: "field qualify_model not found"},
+		{
+			name:     "targets and csv",
+			content:  "add_nosec:\n  targets: a\n  csv: b.csv\n",
+			expected: Config{AllowedBaseDir: ".", AddNosec: AddNosec{Targets: "a", CSV: "b.csv"}},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(dir, tc.name+".yaml")
+			if err := os.WriteFile(path, []byte(tc.content), 0644); err != nil {
+				t.Fatalf("failed to write config: %v", err)
+			}
+			got, err := Load(path)
+			if tc.errMsg != "" {
+				require.ErrorContains(t, err, tc.errMsg)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, got)
+		})
+	}
+
+	_, err := Load(filepath.Join(dir, "missing.yaml"))
+	require.ErrorIs(t, err, fs.ErrNotExist)
+}
@@ -0,0 +1,271 @@
+package dedupeimports
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pruneimports"
+)
+
+var (
+	parseFile  = parser.ParseFile
+	createFile = os.Create
+	formatNode = format.Node
+	walkDir    = filepath.WalkDir
+
+	stderr io.Writer = os.Stderr
+)
+
+// Run collapses duplicate import specs in every .go file under rootDir, as
+// left behind by several tool passes over generated code. The set of
+// imported packages never changes:
+//
+//   - exact duplicates (same path, same name or both unnamed) are removed,
+//     keeping the first;
+//   - a path imported under several names is merged into a single spec,
+//     keeping the first aliased one if any (the unnamed one otherwise), and
+//     references through the dropped names are rewritten to the kept one;
+//   - a blank (`_`) import of a path imported otherwise is removed.
+//
+// Dot imports are only de-duplicated, never merged. As in prune-imports,
+// the package name of an unnamed import is assumed from its path. When a
+// file references a package name that none of its imports provides, or the
+// kept name is also declared in the file, merging could break it, so only
+// exact and blank duplicates are removed and a warning is printed instead.
+//
+// Files without duplicates are left byte-for-byte untouched, so the command
+// is idempotent.
+//
+// Returns an error if the walk fails or any file can't be parsed or written.
+func Run(rootDir string) error {
+	var files []string
+	if err := walkDir(rootDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to walkDir %s: %w", rootDir, err)
+	}
+
+	for _, file := range files {
+		fset := token.NewFileSet()
+		f, err := parseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		if !dedupe(fset, f, file) {
+			continue
+		}
+		if err := func() error {
+			outFile, err := createFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to open file %s for writing: %w", file, err)
+			}
+			defer outFile.Close()
+			return formatNode(outFile, fset, f)
+		}(); err != nil {
+			return fmt.Errorf("failed to write updated file %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// dedupe removes the duplicate imports of f and reports whether any were
+// removed.
+func dedupe(fset *token.FileSet, f *ast.File, file string) bool {
+	drop := make(map[*ast.ImportSpec]bool)
+	seen := make(map[string]bool)
+	var paths []string
+	byPath := make(map[string][]*ast.ImportSpec)
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		key := explicitName(spec) + " " + importPath
+		if seen[key] {
+			drop[spec] = true
+			continue
+		}
+		seen[key] = true
+		if _, ok := byPath[importPath]; !ok {
+			paths = append(paths, importPath)
+		}
+		byPath[importPath] = append(byPath[importPath], spec)
+	}
+
+	var merged []*ast.ImportSpec
+	renames := make(map[string]string)
+	for _, importPath := range paths {
+		var regular, blanks []*ast.ImportSpec
+		dotted := false
+		for _, spec := range byPath[importPath] {
+			switch explicitName(spec) {
+			case "_":
+				blanks = append(blanks, spec)
+			case ".":
+				dotted = true
+			default:
+				regular = append(regular, spec)
+			}
+		}
+		if len(regular) > 0 || dotted {
+			for _, spec := range blanks {
+				drop[spec] = true
+			}
+		}
+		if len(regular) < 2 {
+			continue
+		}
+		keep := regular[0]
+		for _, spec := range regular {
+			if spec.Name != nil {
+				keep = spec
+				break
+			}
+		}
+		for _, spec := range regular {
+			if spec == keep {
+				continue
+			}
+			merged = append(merged, spec)
+			if name := localName(spec); name != localName(keep) {
+				renames[name] = localName(keep)
+			}
+		}
+	}
+
+	if len(merged) > 0 {
+		if reason := unsafeRename(f, renames); reason != "" {
+			fmt.Fprintf(stderr, "warning: %s: %s; leaving imports of the same path under different names untouched\n", file, reason)
+		} else {
+			for _, spec := range merged {
+				drop[spec] = true
+			}
+			renameReferences(f, renames)
+		}
+	}
+	if len(drop) == 0 {
+		return false
+	}
+	deleteSpecs(fset, f, drop)
+	return true
+}
+
+// explicitName returns the name spec gives its import, or "" if unnamed.
+func explicitName(spec *ast.ImportSpec) string {
+	if spec.Name == nil {
+		return ""
+	}
+	return spec.Name.Name
+}
+
+// localName returns the name spec's package is referred to by in the file.
+func localName(spec *ast.ImportSpec) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	importPath, _ := strconv.Unquote(spec.Path.Value)
+	return pruneimports.AssumedName(importPath)
+}
+
+// unsafeRename returns why rewriting references per renames could change
+// what they refer to, or "" if it can't.
+func unsafeRename(f *ast.File, renames map[string]string) string {
+	provided := make(map[string]bool)
+	for _, spec := range f.Imports {
+		provided[localName(spec)] = true
+	}
+	kept := make(map[string]bool)
+	for _, name := range renames {
+		kept[name] = true
+	}
+	reason := ""
+	ast.Inspect(f, func(n ast.Node) bool {
+		if reason != "" {
+			return false
+		}
+		switch n := n.(type) {
+		case *ast.SelectorExpr:
+			if ident, ok := n.X.(*ast.Ident); ok && ident.Obj == nil && !provided[ident.Name] {
+				reason = fmt.Sprintf("%q is not provided by any import", ident.Name)
+			}
+		case *ast.Ident:
+			if n.Obj != nil && kept[n.Name] {
+				reason = fmt.Sprintf("%q is also declared in the file", n.Name)
+			}
+		}
+		return true
+	})
+	return reason
+}
+
+// renameReferences rewrites the package references of f per renames.
+func renameReferences(f *ast.File, renames map[string]string) {
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+			if name, ok := renames[ident.Name]; ok {
+				ident.Name = name
+			}
+		}
+		return true
+	})
+}
+
+// deleteSpecs removes the import specs in drop from f, along with their
+// comments and any import declaration left empty. Like
+// astutil.DeleteNamedImport, it closes the line a removed spec leaves behind
+// so import groups stay as they were.
+func deleteSpecs(fset *token.FileSet, f *ast.File, drop map[*ast.ImportSpec]bool) {
+	removed := make(map[*ast.CommentGroup]bool)
+	for i := 0; i < len(f.Decls); i++ {
+		gen, ok := f.Decls[i].(*ast.GenDecl)
+		if !ok || gen.Tok != token.IMPORT {
+			continue
+		}
+		for j := 0; j < len(gen.Specs); j++ {
+			spec := gen.Specs[j].(*ast.ImportSpec)
+			if !drop[spec] {
+				continue
+			}
+			gen.Specs = slices.Delete(gen.Specs, j, j+1)
+			removed[spec.Doc] = true
+			removed[spec.Comment] = true
+			if j > 0 && gen.Rparen.IsValid() {
+				prev := gen.Specs[j-1].(*ast.ImportSpec)
+				prevLine := fset.PositionFor(prev.Path.ValuePos, false).Line
+				line := fset.PositionFor(spec.Path.ValuePos, false).Line
+				if line-prevLine == 1 && line != fset.File(gen.Rparen).LineCount() {
+					fset.File(gen.Rparen).MergeLine(line)
+				}
+			}
+			j--
+		}
+		if len(gen.Specs) == 0 {
+			removed[gen.Doc] = true
+			f.Decls = slices.Delete(f.Decls, i, i+1)
+			i--
+		}
+	}
+	f.Imports = slices.DeleteFunc(f.Imports, func(spec *ast.ImportSpec) bool { return drop[spec] })
+	f.Comments = slices.DeleteFunc(f.Comments, func(cg *ast.CommentGroup) bool { return removed[cg] })
+}
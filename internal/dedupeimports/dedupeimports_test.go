@@ -0,0 +1,232 @@
+package dedupeimports
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		InitContent     string
+		ExpectedWarning string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "duplicate default imports removed, groups preserved",
+				ExpectedContent: `package database
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func Get(ctx context.Context, db *sql.DB) pgtype.Text {
+	return pgtype.Text{}
+}
+`,
+			},
+			InitContent: `package database
+
+import (
+	"context"
+	"database/sql"
+	"context"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func Get(ctx context.Context, db *sql.DB) pgtype.Text {
+	return pgtype.Text{}
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "duplicate import declarations",
+				ExpectedContent: `package database
+
+import "context"
+
+var _ context.Context
+`,
+			},
+			InitContent: `package database
+
+import "context"
+
+import "context"
+
+var _ context.Context
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "aliased and default imports merged into the alias",
+				ExpectedContent: `package database
+
+import (
+	"context"
+
+	models "github.com/me/app/internal/models"
+)
+
+func Get(ctx context.Context) (models.User, *models.Transaction, error) {
+	var u models.User
+	return u, &models.Transaction{}, nil
+}
+`,
+			},
+			InitContent: `package database
+
+import (
+	"context"
+	"github.com/me/app/internal/models"
+
+	models "github.com/me/app/internal/models"
+	m "github.com/me/app/internal/models"
+	m "github.com/me/app/internal/models" // again
+)
+
+func Get(ctx context.Context) (models.User, *m.Transaction, error) {
+	var u models.User
+	return u, &m.Transaction{}, nil
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "blank import of an imported path removed",
+				ExpectedContent: `package database
+
+import (
+	"github.com/lib/pq"
+)
+
+var _ = pq.Efatal
+`,
+			},
+			InitContent: `package database
+
+import (
+	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	_ "github.com/lib/pq"
+)
+
+var _ = pq.Efatal
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "kept name declared locally leaves names alone",
+				ExpectedContent: `package database
+
+import (
+	"github.com/me/app/internal/models"
+	m "github.com/me/app/internal/models"
+)
+
+func Get(m string) models.User { return models.User{} }
+`,
+			},
+			InitContent: `package database
+
+import (
+	"github.com/me/app/internal/models"
+	m "github.com/me/app/internal/models"
+	m "github.com/me/app/internal/models"
+)
+
+func Get(m string) models.User { return models.User{} }
+`,
+			ExpectedWarning: `"m" is also declared in the file`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "unknown package name leaves names alone",
+				ExpectedContent: `package database
+
+import (
+	"github.com/me/app/internal/models"
+	db "github.com/me/app/internal/models"
+)
+
+var _ = thing.Value
+`,
+			},
+			InitContent: `package database
+
+import (
+	"github.com/me/app/internal/models"
+	db "github.com/me/app/internal/models"
+)
+
+var _ = thing.Value
+`,
+			ExpectedWarning: `"thing" is not provided by any import`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "files without duplicates are not reformatted",
+				ExpectedContent: `package database
+import ( "context"; . "strings" )
+var   _ context.Context
+var _ = Repeat
+`,
+			},
+			InitContent: `package database
+import ( "context"; . "strings" )
+var   _ context.Context
+var _ = Repeat
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			var warnings bytes.Buffer
+			stderr = &warnings
+			defer func() { stderr = os.Stderr }()
+
+			tmpDir := t.TempDir()
+			file := filepath.Join(tmpDir, "nested", "query.sql.go")
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			if err := os.WriteFile(file, []byte(tc.InitContent), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			require.NoError(t, Run(tmpDir))
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if diff := cmp.Diff(tc.ExpectedContent, string(got)); diff != "" {
+				t.Errorf("file mismatch (-want +got)\n%s", diff)
+			}
+			if tc.ExpectedWarning != "" {
+				require.Contains(t, warnings.String(), tc.ExpectedWarning)
+			} else {
+				require.Empty(t, warnings.String())
+			}
+
+			// running again must be a no-op
+			require.NoError(t, Run(tmpDir))
+			again, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			require.Equal(t, string(got), string(again))
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package directives
+
+import (
+	"go/ast"
+	"strings"
+)
+
+// Ignore is the inline directive that tells sqlc-qol to leave a spec alone,
+// e.g. `const foo = "bar" // sqlc-qol:ignore`.
+const Ignore = "sqlc-qol:ignore"
+
+// Skip is the file-level directive that tells sqlc-qol to leave a whole file
+// alone. It must appear in a comment above the package clause.
+const Skip = "sqlc-qol:skip"
+
+// Has reports whether any comment in cg carries the given directive.
+func Has(cg *ast.CommentGroup, directive string) bool {
+	if cg == nil {
+		return false
+	}
+	for _, c := range cg.List {
+		text := strings.TrimSpace(strings.TrimPrefix(c.Text, "//"))
+		if text == directive || strings.HasPrefix(text, directive+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// Ignored reports whether node carries the Ignore directive in its doc or
+// line comment. parent is the node's parent; for a spec in an ungrouped
+// declaration (`const foo = ...`) the doc comment lives on the parent GenDecl.
+func Ignored(node, parent ast.Node) bool {
+	switch n := node.(type) {
+	case *ast.ValueSpec:
+		if Has(n.Doc, Ignore) || Has(n.Comment, Ignore) {
+			return true
+		}
+	case *ast.TypeSpec:
+		if Has(n.Doc, Ignore) || Has(n.Comment, Ignore) {
+			return true
+		}
+	case *ast.Field:
+		return Has(n.Doc, Ignore) || Has(n.Comment, Ignore)
+	default:
+		return false
+	}
+	if gd, ok := parent.(*ast.GenDecl); ok && !gd.Lparen.IsValid() {
+		return Has(gd.Doc, Ignore)
+	}
+	return false
+}
+
+// SkipFile reports whether f carries the Skip directive in a comment above
+// its package clause.
+func SkipFile(f *ast.File) bool {
+	for _, cg := range f.Comments {
+		if cg.Pos() > f.Package {
+			break
+		}
+		if Has(cg, Skip) {
+			return true
+		}
+	}
+	return false
+}
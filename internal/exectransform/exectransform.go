@@ -0,0 +1,69 @@
+package exectransform
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// FileEnv is the environment variable holding the path of the file piped to
+// the external command.
+const FileEnv = "SQLC_QOL_FILE"
+
+var command = exec.Command
+
+// Hook returns an AST hook piping each file through the external command:
+// the file, formatted, is written to its stdin, and its stdout is taken as
+// the new content of the file. The command line is split on whitespace and
+// run without a shell; the file's path is passed in FileEnv.
+//
+// The output must parse as Go source. It replaces f, positioned in a new
+// file of fset, so the caller formats and writes it like any other change.
+// Output identical to the input reports no change.
+//
+// Returns an error if commandLine is empty. The hook itself fails if the
+// command can't be started, exits non-zero (its stderr is included), or its
+// output doesn't parse.
+func Hook(commandLine string) (func(*token.FileSet, *ast.File) (bool, error), error) {
+	args := strings.Fields(commandLine)
+	if len(args) == 0 {
+		return nil, errors.New("exec command is empty")
+	}
+	return func(fset *token.FileSet, f *ast.File) (bool, error) {
+		name := fset.Position(f.Package).Filename
+		var in bytes.Buffer
+		if err := format.Node(&in, fset, f); err != nil {
+			return false, fmt.Errorf("failed to format %s for %s: %w", name, args[0], err)
+		}
+
+		var out, stderr bytes.Buffer
+		cmd := command(args[0], args[1:]...) // #nosec G204 -- the command is chosen by the user running the tool
+		cmd.Stdin = bytes.NewReader(in.Bytes())
+		cmd.Stdout = &out
+		cmd.Stderr = &stderr
+		cmd.Env = append(os.Environ(), FileEnv+"="+name)
+		if err := cmd.Run(); err != nil {
+			if msg := strings.TrimSpace(stderr.String()); msg != "" {
+				return false, fmt.Errorf("%s failed: %w: %s", args[0], err, msg)
+			}
+			return false, fmt.Errorf("%s failed: %w", args[0], err)
+		}
+		if bytes.Equal(in.Bytes(), out.Bytes()) {
+			return false, nil
+		}
+
+		parsed, err := parser.ParseFile(fset, name, out.Bytes(), parser.ParseComments)
+		if err != nil {
+			return false, fmt.Errorf("output of %s is not valid Go: %w", args[0], err)
+		}
+		*f = *parsed
+		return true, nil
+	}, nil
+}
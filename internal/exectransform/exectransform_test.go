@@ -0,0 +1,113 @@
+package exectransform
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// TestHelperProcess stands in for the external command, as selected by its
+// first argument.
+func TestHelperProcess(t *testing.T) {
+	if os.Getenv("SQLC_QOL_HELPER_PROCESS") != "1" {
+		return
+	}
+	args := os.Args
+	for len(args) > 0 && args[0] != "--" {
+		args = args[1:]
+	}
+	in, _ := io.ReadAll(os.Stdin)
+	switch args[1] {
+	case "cat":
+		os.Stdout.Write(in)
+	case "replace":
+		os.Stdout.WriteString(strings.ReplaceAll(string(in), args[2], args[3]))
+	case "file":
+		fmt.Fprintf(os.Stdout, "%s// piped from %s\n", in, os.Getenv(FileEnv))
+	case "garbage":
+		os.Stdout.WriteString("not go")
+	case "fail":
+		os.Stderr.WriteString("boom\n")
+		os.Exit(3)
+	}
+	os.Exit(0)
+}
+
+func TestHook(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Command           string
+		ExpectedChanged   bool
+		ExpectedContent   string
+		ExpectedErrSubStr string
+	}{
+		{
+			Name:            "transformed output",
+			Command:         "replace bar baz",
+			ExpectedChanged: true,
+			ExpectedContent: "package foo\n\nconst baz = \"x\" // #nosec\n",
+		},
+		{
+			Name:            "file path passed in env",
+			Command:         "file",
+			ExpectedChanged: true,
+			ExpectedContent: "package foo\n\nconst bar = \"x\" // #nosec\n// piped from content.sql.go\n",
+		},
+		{
+			Name:            "unchanged output",
+			Command:         "cat",
+			ExpectedContent: "package foo\n\nconst bar = \"x\" // #nosec\n",
+		},
+		{
+			Name:              "output not Go",
+			Command:           "garbage",
+			ExpectedErrSubStr: "output of garbage is not valid Go",
+		},
+		{
+			Name:              "command fails",
+			Command:           "fail",
+			ExpectedErrSubStr: "fail failed: exit status 3: boom",
+		},
+	}
+	t.Setenv("SQLC_QOL_HELPER_PROCESS", "1")
+	command = func(name string, args ...string) *exec.Cmd {
+		return exec.Command(os.Args[0], append([]string{"-test.run=TestHelperProcess", "--", name}, args...)...)
+	}
+	defer func() { command = exec.Command }()
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			src := "package foo\n\nconst bar = \"x\" // #nosec\n"
+			fset := token.NewFileSet()
+			f, err := parser.ParseFile(fset, "content.sql.go", src, parser.ParseComments)
+			require.NoError(t, err)
+
+			hook, err := Hook(tc.Command)
+			require.NoError(t, err)
+			changed, err := hook(fset, f)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.ExpectedChanged, changed)
+			var buf bytes.Buffer
+			require.NoError(t, format.Node(&buf, fset, f))
+			require.Equal(t, tc.ExpectedContent, buf.String())
+		})
+	}
+}
+
+func TestHookEmptyCommand(t *testing.T) {
+	_, err := Hook(" \t")
+	require.EqualError(t, err, "exec command is empty")
+}
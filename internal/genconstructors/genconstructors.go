@@ -0,0 +1,349 @@
+package genconstructors
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pruneimports"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+var (
+	parseFile  = parser.ParseFile
+	createFile = os.Create
+	mkdirAll   = os.MkdirAll
+)
+
+// Wildcard keys of Options.Defaults.
+const (
+	// AnySlice matches any slice type without a rule of its own.
+	AnySlice = "[]"
+	// AnyMap matches any map type without a rule of its own.
+	AnyMap = "map"
+)
+
+// EmptyLiteral is the default expression standing for an empty composite
+// literal of the field's type (e.g. []string{}).
+const EmptyLiteral = "{}"
+
+// DefaultRules returns the field defaults Options.Defaults is merged over:
+// slices and maps start out empty rather than nil, everything else keeps its
+// zero value.
+func DefaultRules() map[string]string {
+	return map[string]string{AnySlice: EmptyLiteral, AnyMap: EmptyLiteral}
+}
+
+// Options holds the optional settings for Run. Empty fields fall back to
+// the defaults noted below.
+type Options struct {
+	// Package is the package clause of the generated file (default: the
+	// models file's package).
+	Package string
+	// ModelsImport is the import path of the models package. When set, the
+	// constructors live in another package: model types are qualified with
+	// its package name and the import is added.
+	ModelsImport string
+	// Defaults maps a field type, as written in the models file (e.g.
+	// time.Time or []string), to the Go expression fields of that type are
+	// initialized to. AnySlice and AnyMap match any slice or map type
+	// without a rule of its own, EmptyLiteral is an empty composite literal
+	// of the field's type, and an empty expression leaves the field at its
+	// zero value. The rules are merged over DefaultRules.
+	Defaults map[string]string
+}
+
+// Run writes a `New<Model>() <Model>` constructor to outPath for every
+// struct declared in the models file at modelPath. Each constructor returns
+// the model with its fields set to the defaults their types map to (see
+// Options.Defaults); fields without a default are omitted and keep their
+// zero value.
+//
+// Identifiers the models file declares (e.g. an enum constant used as a
+// default) are qualified when the constructors live in another package, and
+// every package a default references is imported.
+//
+// Returns an error if the models file can't be parsed, a default isn't a
+// valid expression or references a package the models file doesn't import,
+// or the constructors can't be written.
+func Run(modelPath, outPath string, opts Options) error {
+	fset := token.NewFileSet()
+	f, err := parseFile(fset, modelPath, nil, parser.ParseComments)
+	if err != nil {
+		return fmt.Errorf("failed to parse model file: %w", err)
+	}
+	if opts.Package == "" {
+		opts.Package = f.Name.Name
+	}
+	rules := DefaultRules()
+	for typ, expr := range opts.Defaults {
+		rules[typ] = expr
+	}
+
+	src, err := generate(f, rules, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := mkdirAll(filepath.Dir(outPath), 0750); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	outFile, err := createFile(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s for writing: %w", outPath, err)
+	}
+	defer outFile.Close()
+	if _, err := outFile.Write(src); err != nil {
+		return fmt.Errorf("failed to write constructors file %s: %w", outPath, err)
+	}
+	return nil
+}
+
+// constructor is a model and the keyed fields its constructor sets.
+type constructor struct {
+	model  string
+	fields []string // "Name: expr"
+}
+
+func generate(f *ast.File, rules map[string]string, opts Options) ([]byte, error) {
+	fileImports := make(map[string]string)
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := pruneimports.AssumedName(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		fileImports[name] = importPath
+	}
+
+	alias := ""
+	if opts.ModelsImport != "" {
+		alias = pruneimports.AssumedName(opts.ModelsImport)
+	}
+	declared := declaredNames(f)
+	imports := make(map[string]string)
+	qualify := func(expr ast.Expr) (string, error) {
+		if alias != "" {
+			expr = qualifyLocal(expr, alias, declared)
+		}
+		if err := usedPackages(expr, fileImports, alias, declared, imports); err != nil {
+			return "", err
+		}
+		return types.ExprString(expr), nil
+	}
+
+	var ctors []constructor
+	for _, decl := range f.Decls {
+		genDecl, ok := decl.(*ast.GenDecl)
+		if !ok || genDecl.Tok != token.TYPE {
+			continue
+		}
+		for _, spec := range genDecl.Specs {
+			typeSpec, ok := spec.(*ast.TypeSpec)
+			if !ok || typeSpec.TypeParams != nil || !typeSpec.Name.IsExported() && alias != "" {
+				continue
+			}
+			st, ok := typeSpec.Type.(*ast.StructType)
+			if !ok {
+				continue
+			}
+			ctor := constructor{model: typeSpec.Name.Name}
+			for _, field := range st.Fields.List {
+				key := types.ExprString(field.Type)
+				expr, ok := rules[key]
+				if !ok {
+					switch t := field.Type.(type) {
+					case *ast.ArrayType:
+						if t.Len == nil {
+							expr = rules[AnySlice]
+						}
+					case *ast.MapType:
+						expr = rules[AnyMap]
+					}
+				}
+				if expr == "" {
+					continue
+				}
+				value, err := render(field.Type, expr, qualify)
+				if err != nil {
+					return nil, fmt.Errorf("invalid default for %s fields of %s: %w", key, typeSpec.Name.Name, err)
+				}
+				for _, name := range fieldNames(field) {
+					if !token.IsExported(name) && alias != "" {
+						continue
+					}
+					ctor.fields = append(ctor.fields, name+": "+value)
+				}
+			}
+			ctors = append(ctors, ctor)
+		}
+	}
+
+	if alias != "" && len(ctors) > 0 {
+		imports[alias] = opts.ModelsImport
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by sqlc-qol gen-constructors. DO NOT EDIT.\n\npackage %s\n\n", opts.Package)
+	paths := make([]string, 0, len(imports))
+	for name, importPath := range imports {
+		if pruneimports.AssumedName(importPath) != name {
+			paths = append(paths, name+" "+strconv.Quote(importPath))
+		} else {
+			paths = append(paths, strconv.Quote(importPath))
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return unaliased(paths[i]) < unaliased(paths[j]) })
+	if len(paths) > 0 {
+		fmt.Fprintf(&buf, "import (\n%s\n)\n\n", strings.Join(paths, "\n"))
+	}
+
+	for i, ctor := range ctors {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		model := ctor.model
+		if alias != "" {
+			model = alias + "." + model
+		}
+		fmt.Fprintf(&buf, "// New%s returns a new %s with its fields set to their defaults.\n", ctor.model, ctor.model)
+		fmt.Fprintf(&buf, "func New%s() %s {\n", ctor.model, model)
+		if len(ctor.fields) == 0 {
+			fmt.Fprintf(&buf, "\treturn %s{}\n}\n", model)
+			continue
+		}
+		fmt.Fprintf(&buf, "\treturn %s{\n", model)
+		for _, field := range ctor.fields {
+			fmt.Fprintf(&buf, "\t\t%s,\n", field)
+		}
+		buf.WriteString("\t}\n}\n")
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated constructors: %w", err)
+	}
+	return src, nil
+}
+
+// render returns the Go source of the default expr for a field of type typ.
+func render(typ ast.Expr, expr string, qualify func(ast.Expr) (string, error)) (string, error) {
+	if expr == EmptyLiteral {
+		t, err := qualify(typ)
+		if err != nil {
+			return "", err
+		}
+		return t + "{}", nil
+	}
+	parsed, err := parser.ParseExpr(expr)
+	if err != nil {
+		return "", fmt.Errorf("failed to parse %q: %w", expr, err)
+	}
+	return qualify(parsed)
+}
+
+// fieldNames returns the names of field, or the type name of an embedded
+// field.
+func fieldNames(field *ast.Field) []string {
+	if len(field.Names) > 0 {
+		names := make([]string, len(field.Names))
+		for i, name := range field.Names {
+			names[i] = name.Name
+		}
+		return names
+	}
+	typ := field.Type
+	if star, ok := typ.(*ast.StarExpr); ok {
+		typ = star.X
+	}
+	switch t := typ.(type) {
+	case *ast.Ident:
+		return []string{t.Name}
+	case *ast.SelectorExpr:
+		return []string{t.Sel.Name}
+	}
+	return nil
+}
+
+// declaredNames returns the names of the top-level declarations in f.
+func declaredNames(f *ast.File) map[string]bool {
+	declared := make(map[string]bool)
+	for _, decl := range f.Decls {
+		switch decl := decl.(type) {
+		case *ast.FuncDecl:
+			if decl.Recv == nil {
+				declared[decl.Name.Name] = true
+			}
+		case *ast.GenDecl:
+			for _, spec := range decl.Specs {
+				switch spec := spec.(type) {
+				case *ast.TypeSpec:
+					declared[spec.Name.Name] = true
+				case *ast.ValueSpec:
+					for _, name := range spec.Names {
+						declared[name.Name] = true
+					}
+				}
+			}
+		}
+	}
+	return declared
+}
+
+// qualifyLocal qualifies the bare identifiers in expr that the models file
+// declares with alias.
+func qualifyLocal(expr ast.Expr, alias string, declared map[string]bool) ast.Expr {
+	return astutil.Apply(expr, func(c *astutil.Cursor) bool {
+		ident, ok := c.Node().(*ast.Ident)
+		if !ok || c.Name() == "Sel" || c.Name() == "Key" || !declared[ident.Name] {
+			return true
+		}
+		c.Replace(&ast.SelectorExpr{X: ast.NewIdent(alias), Sel: ast.NewIdent(ident.Name)})
+		return false
+	}, nil).(ast.Expr)
+}
+
+// usedPackages records the import of every package referenced by expr.
+// Selectors on identifiers the models file declares (e.g. a field of a
+// package-level var) aren't package references.
+func usedPackages(expr ast.Expr, fileImports map[string]string, alias string, declared map[string]bool, imports map[string]string) error {
+	var err error
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok || err != nil {
+			return err == nil
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		switch importPath, ok := fileImports[pkg.Name]; {
+		case pkg.Name == alias, alias == "" && declared[pkg.Name]:
+		case ok:
+			imports[pkg.Name] = importPath
+		default:
+			err = fmt.Errorf("package %s is not imported by the models file", pkg.Name)
+		}
+		return false
+	})
+	return err
+}
+
+func unaliased(spec string) string {
+	if _, quoted, ok := strings.Cut(spec, " "); ok {
+		return quoted
+	}
+	return spec
+}
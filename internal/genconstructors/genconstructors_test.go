@@ -0,0 +1,154 @@
+package genconstructors
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+const modelContent = `package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type Status string
+
+const StatusActive Status = "active"
+
+type Transaction struct {
+	ID        int64
+	Tags      []string
+	Attrs     map[string]string
+	Statuses  []Status
+	Status    Status
+	Payload   json.RawMessage
+	CreatedAt time.Time
+}
+
+type Empty struct{}
+
+type Page[T any] struct {
+	Items []T
+}
+`
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		Opts Options
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "same package, default rules",
+				ExpectedContent: `// Code generated by sqlc-qol gen-constructors. DO NOT EDIT.
+
+package models
+
+// NewTransaction returns a new Transaction with its fields set to their defaults.
+func NewTransaction() Transaction {
+	return Transaction{
+		Tags:     []string{},
+		Attrs:    map[string]string{},
+		Statuses: []Status{},
+	}
+}
+
+// NewEmpty returns a new Empty with its fields set to their defaults.
+func NewEmpty() Empty {
+	return Empty{}
+}
+`,
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "other package, custom rules",
+				ExpectedContent: `// Code generated by sqlc-qol gen-constructors. DO NOT EDIT.
+
+package fixtures
+
+import (
+	"encoding/json"
+	"example.com/app/internal/models"
+	"time"
+)
+
+// NewTransaction returns a new Transaction with its fields set to their defaults.
+func NewTransaction() models.Transaction {
+	return models.Transaction{
+		Attrs:     map[string]string{},
+		Statuses:  []models.Status{},
+		Status:    models.StatusActive,
+		Payload:   json.RawMessage("{}"),
+		CreatedAt: time.Now(),
+	}
+}
+
+// NewEmpty returns a new Empty with its fields set to their defaults.
+func NewEmpty() models.Empty {
+	return models.Empty{}
+}
+`,
+			},
+			Opts: Options{
+				Package:      "fixtures",
+				ModelsImport: "example.com/app/internal/models",
+				Defaults: map[string]string{
+					AnySlice:          "",
+					"[]Status":        EmptyLiteral,
+					"Status":          "StatusActive",
+					"json.RawMessage": `json.RawMessage("{}")`,
+					"time.Time":       "time.Now()",
+				},
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "invalid expression",
+				ExpectedErrSubStr: "invalid default for time.Time fields of Transaction",
+			},
+			Opts: Options{Defaults: map[string]string{"time.Time": "time.Now("}},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "package not imported",
+				ExpectedErrSubStr: "package uuid is not imported by the models file",
+			},
+			Opts: Options{Defaults: map[string]string{"int64": "uuid.New().ID()"}},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			modelPath := filepath.Join(tmpDir, "models", "models.go")
+			if err := os.MkdirAll(filepath.Dir(modelPath), 0755); err != nil {
+				t.Fatalf("failed to create models dir: %v", err)
+			}
+			if err := os.WriteFile(modelPath, []byte(modelContent), 0644); err != nil {
+				t.Fatalf("failed to write model file: %v", err)
+			}
+			outPath := filepath.Join(tmpDir, "fixtures", "constructors.go")
+
+			err := Run(modelPath, outPath, tc.Opts)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			} else if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			got, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatalf("failed to read constructors file: %v", err)
+			}
+			if diff := cmp.Diff(tc.ExpectedContent, string(got)); diff != "" {
+				t.Errorf("constructors file mismatch (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,282 @@
+package genmock
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/types"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/querier"
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+var (
+	createFile = os.Create
+	mkdirAll   = os.MkdirAll
+)
+
+const testifyMockImport = "github.com/stretchr/testify/mock"
+
+// Options holds the optional settings for Run. Empty fields fall back to
+// the defaults noted below.
+type Options struct {
+	// Package is the package clause of the generated file (default "mocks").
+	Package string
+	// Name is the name of the generated mock type (default "Querier").
+	Name string
+	// Receiver is the generated type whose methods are mocked (default "Queries").
+	Receiver string
+	// Style is either "testify" (default) for a testify mock.Mock based mock
+	// or "stub" for a struct of per-method function fields.
+	Style string
+}
+
+// Run collects the query methods generated on *Queries in dir and writes a
+// mock implementation of the Querier interface to outPath.
+//
+// Method signatures mirror the generated ones. Types declared by the query
+// package itself (e.g. CreateUserParams) are qualified with queryImport's
+// package name, and every package referenced by a signature (including an
+// external models package) is imported.
+//
+// Parameters:
+//   - dir:         directory holding the SQLC-generated query code.
+//   - queryImport: import path of the generated query package.
+//   - outPath:     path of the generated mock file.
+//   - opts:        optional settings, see Options.
+//
+// Returns an error if the query code can't be parsed, the style is unknown,
+// or the mock can't be written.
+func Run(dir, queryImport, outPath string, opts Options) error {
+	if opts.Package == "" {
+		opts.Package = "mocks"
+	}
+	if opts.Name == "" {
+		opts.Name = "Querier"
+	}
+	if opts.Receiver == "" {
+		opts.Receiver = "Queries"
+	}
+	if opts.Style == "" {
+		opts.Style = "testify"
+	}
+	if opts.Style != "testify" && opts.Style != "stub" {
+		return fmt.Errorf("unknown mock style %q: expected testify or stub", opts.Style)
+	}
+
+	methods, err := querier.Collect(dir, opts.Receiver)
+	if err != nil {
+		return err
+	}
+	src, err := generate(methods, queryImport, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := mkdirAll(filepath.Dir(outPath), 0750); err != nil {
+		return fmt.Errorf("failed to create output directory: %w", err)
+	}
+	outFile, err := createFile(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s for writing: %w", outPath, err)
+	}
+	defer outFile.Close()
+	if _, err := outFile.Write(src); err != nil {
+		return fmt.Errorf("failed to write mock file %s: %w", outPath, err)
+	}
+	return nil
+}
+
+// signature is a method rendered for the mocks package.
+type signature struct {
+	name    string
+	params  []string // "name type"
+	args    []string // names to forward, with a trailing ... for variadics
+	results []string // types
+}
+
+func generate(methods []querier.Method, queryImport string, opts Options) ([]byte, error) {
+	localAlias := path.Base(queryImport)
+	imports := make(map[string]string)
+	if opts.Style == "testify" {
+		imports["mock"] = testifyMockImport
+	}
+
+	sigs := make([]signature, 0, len(methods))
+	for _, m := range methods {
+		sig := signature{name: m.Name}
+		render := func(expr ast.Expr) string {
+			expr = qualifyLocal(expr, localAlias)
+			usedPackages(expr, m.Imports, localAlias, queryImport, imports)
+			return types.ExprString(expr)
+		}
+		if m.Type.Params != nil {
+			for _, field := range m.Type.Params.List {
+				typ := render(field.Type)
+				names := field.Names
+				if len(names) == 0 {
+					names = []*ast.Ident{ast.NewIdent("_")}
+				}
+				for _, name := range names {
+					paramName := name.Name
+					if paramName == "_" {
+						paramName = "p" + strconv.Itoa(len(sig.params))
+					}
+					sig.params = append(sig.params, paramName+" "+typ)
+					if _, ok := field.Type.(*ast.Ellipsis); ok {
+						paramName += "..."
+					}
+					sig.args = append(sig.args, paramName)
+				}
+			}
+		}
+		if m.Type.Results != nil {
+			for _, field := range m.Type.Results.List {
+				typ := render(field.Type)
+				for range max(len(field.Names), 1) {
+					sig.results = append(sig.results, typ)
+				}
+			}
+		}
+		sigs = append(sigs, sig)
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by sqlc-qol gen-mock. DO NOT EDIT.\n\npackage %s\n\n", opts.Package)
+	paths := make([]string, 0, len(imports))
+	for name, importPath := range imports {
+		if path.Base(importPath) != name {
+			paths = append(paths, name+" "+strconv.Quote(importPath))
+		} else {
+			paths = append(paths, strconv.Quote(importPath))
+		}
+	}
+	sort.Slice(paths, func(i, j int) bool { return unaliased(paths[i]) < unaliased(paths[j]) })
+	if len(paths) > 0 {
+		fmt.Fprintf(&buf, "import (\n%s\n)\n\n", strings.Join(paths, "\n"))
+	}
+
+	if opts.Style == "testify" {
+		writeTestify(&buf, opts.Name, sigs)
+	} else {
+		writeStub(&buf, opts.Name, sigs)
+	}
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to format generated mock: %w", err)
+	}
+	return src, nil
+}
+
+func writeTestify(buf *bytes.Buffer, name string, sigs []signature) {
+	fmt.Fprintf(buf, "// %s is a testify mock of the Querier interface.\ntype %s struct {\n\tmock.Mock\n}\n", name, name)
+	for _, sig := range sigs {
+		fmt.Fprintf(buf, "\n// %s mocks the %s query.\n", sig.name, sig.name)
+		fmt.Fprintf(buf, "func (m *%s) %s(%s)%s {\n", name, sig.name, strings.Join(sig.params, ", "), resultList(sig.results))
+		called := fmt.Sprintf("m.Called(%s)", strings.Join(trimVariadic(sig.args), ", "))
+		if len(sig.results) == 0 {
+			fmt.Fprintf(buf, "\t%s\n}\n", called)
+			continue
+		}
+		fmt.Fprintf(buf, "\targs := %s\n", called)
+		returns := make([]string, len(sig.results))
+		for i, typ := range sig.results {
+			if typ == "error" {
+				returns[i] = fmt.Sprintf("args.Error(%d)", i)
+				continue
+			}
+			returns[i] = fmt.Sprintf("r%d", i)
+			fmt.Fprintf(buf, "\tvar r%d %s\n\tif v := args.Get(%d); v != nil {\n\t\tr%d = v.(%s)\n\t}\n", i, typ, i, i, typ)
+		}
+		fmt.Fprintf(buf, "\treturn %s\n}\n", strings.Join(returns, ", "))
+	}
+}
+
+func writeStub(buf *bytes.Buffer, name string, sigs []signature) {
+	fmt.Fprintf(buf, "// %s is a stub implementation of the Querier interface. Each method calls\n// the matching <Method>Func field, which must be set before use.\ntype %s struct {\n", name, name)
+	for _, sig := range sigs {
+		fmt.Fprintf(buf, "\t%sFunc func(%s)%s\n", sig.name, strings.Join(sig.params, ", "), resultList(sig.results))
+	}
+	buf.WriteString("}\n")
+	for _, sig := range sigs {
+		fmt.Fprintf(buf, "\n// %s calls %sFunc.\n", sig.name, sig.name)
+		fmt.Fprintf(buf, "func (m *%s) %s(%s)%s {\n", name, sig.name, strings.Join(sig.params, ", "), resultList(sig.results))
+		call := fmt.Sprintf("m.%sFunc(%s)", sig.name, strings.Join(sig.args, ", "))
+		if len(sig.results) == 0 {
+			fmt.Fprintf(buf, "\t%s\n}\n", call)
+		} else {
+			fmt.Fprintf(buf, "\treturn %s\n}\n", call)
+		}
+	}
+}
+
+func resultList(results []string) string {
+	switch len(results) {
+	case 0:
+		return ""
+	case 1:
+		return " " + results[0]
+	}
+	return " (" + strings.Join(results, ", ") + ")"
+}
+
+// trimVariadic drops the ... suffix so variadic arguments are recorded by
+// testify as a single slice.
+func trimVariadic(args []string) []string {
+	trimmed := make([]string, len(args))
+	for i, arg := range args {
+		trimmed[i] = strings.TrimSuffix(arg, "...")
+	}
+	return trimmed
+}
+
+func unaliased(spec string) string {
+	if _, quoted, ok := strings.Cut(spec, " "); ok {
+		return quoted
+	}
+	return spec
+}
+
+// qualifyLocal qualifies exported, non-builtin bare identifiers in expr (the
+// query package's own types) with alias.
+func qualifyLocal(expr ast.Expr, alias string) ast.Expr {
+	return astutil.Apply(expr, func(c *astutil.Cursor) bool {
+		ident, ok := c.Node().(*ast.Ident)
+		if !ok || c.Name() == "Sel" || c.Name() == "Names" {
+			return true
+		}
+		if !ident.IsExported() || types.Universe.Lookup(ident.Name) != nil {
+			return true
+		}
+		c.Replace(&ast.SelectorExpr{X: ast.NewIdent(alias), Sel: ast.NewIdent(ident.Name)})
+		return false
+	}, nil).(ast.Expr)
+}
+
+// usedPackages records the import of every package referenced by expr.
+func usedPackages(expr ast.Expr, fileImports map[string]string, localAlias, queryImport string, imports map[string]string) {
+	ast.Inspect(expr, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		pkg, ok := sel.X.(*ast.Ident)
+		if !ok {
+			return true
+		}
+		if pkg.Name == localAlias {
+			imports[pkg.Name] = queryImport
+		} else if importPath, ok := fileImports[pkg.Name]; ok {
+			imports[pkg.Name] = importPath
+		}
+		return false
+	})
+}
@@ -0,0 +1,193 @@
+package genmock
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+const dbContent = `package database
+
+import (
+	"context"
+	"database/sql"
+)
+
+type DBTX interface {
+	ExecContext(context.Context, string, ...interface{}) (sql.Result, error)
+}
+
+func New(db DBTX) *Queries {
+	return &Queries{db: db}
+}
+
+type Queries struct {
+	db DBTX
+}
+
+func (q *Queries) WithTx(tx *sql.Tx) *Queries {
+	return &Queries{db: tx}
+}
+`
+
+const queryContent = `package database
+
+import (
+	"context"
+
+	"example.com/app/internal/models"
+)
+
+type CreateUserParams struct {
+	Name string
+}
+
+func (q *Queries) GetUser(ctx context.Context, id int64) (models.User, error) {
+	return models.User{}, nil
+}
+
+func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (*models.User, error) {
+	return nil, nil
+}
+
+func (q *Queries) DeleteUser(ctx context.Context, _ int64) error {
+	return nil
+}
+
+func (q *Queries) helper() {}
+`
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		Style string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "testify style",
+				ExpectedContent: `// Code generated by sqlc-qol gen-mock. DO NOT EDIT.
+
+package mocks
+
+import (
+	"context"
+	"example.com/app/internal/database"
+	"example.com/app/internal/models"
+	"github.com/stretchr/testify/mock"
+)
+
+// Querier is a testify mock of the Querier interface.
+type Querier struct {
+	mock.Mock
+}
+
+// CreateUser mocks the CreateUser query.
+func (m *Querier) CreateUser(ctx context.Context, arg database.CreateUserParams) (*models.User, error) {
+	args := m.Called(ctx, arg)
+	var r0 *models.User
+	if v := args.Get(0); v != nil {
+		r0 = v.(*models.User)
+	}
+	return r0, args.Error(1)
+}
+
+// DeleteUser mocks the DeleteUser query.
+func (m *Querier) DeleteUser(ctx context.Context, p1 int64) error {
+	args := m.Called(ctx, p1)
+	return args.Error(0)
+}
+
+// GetUser mocks the GetUser query.
+func (m *Querier) GetUser(ctx context.Context, id int64) (models.User, error) {
+	args := m.Called(ctx, id)
+	var r0 models.User
+	if v := args.Get(0); v != nil {
+		r0 = v.(models.User)
+	}
+	return r0, args.Error(1)
+}
+`,
+			},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "stub style",
+				ExpectedContent: `// Code generated by sqlc-qol gen-mock. DO NOT EDIT.
+
+package mocks
+
+import (
+	"context"
+	"example.com/app/internal/database"
+	"example.com/app/internal/models"
+)
+
+// Querier is a stub implementation of the Querier interface. Each method calls
+// the matching <Method>Func field, which must be set before use.
+type Querier struct {
+	CreateUserFunc func(ctx context.Context, arg database.CreateUserParams) (*models.User, error)
+	DeleteUserFunc func(ctx context.Context, p1 int64) error
+	GetUserFunc    func(ctx context.Context, id int64) (models.User, error)
+}
+
+// CreateUser calls CreateUserFunc.
+func (m *Querier) CreateUser(ctx context.Context, arg database.CreateUserParams) (*models.User, error) {
+	return m.CreateUserFunc(ctx, arg)
+}
+
+// DeleteUser calls DeleteUserFunc.
+func (m *Querier) DeleteUser(ctx context.Context, p1 int64) error {
+	return m.DeleteUserFunc(ctx, p1)
+}
+
+// GetUser calls GetUserFunc.
+func (m *Querier) GetUser(ctx context.Context, id int64) (models.User, error) {
+	return m.GetUserFunc(ctx, id)
+}
+`,
+			},
+			Style: "stub",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "unknown style",
+				ExpectedErrSubStr: "unknown mock style",
+			},
+			Style: "gomock",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			dir := filepath.Join(tmpDir, "database")
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				t.Fatalf("failed to create query dir: %v", err)
+			}
+			for name, content := range map[string]string{"db.go": dbContent, "users.sql.go": queryContent} {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+					t.Fatalf("failed to write %s: %v", name, err)
+				}
+			}
+			outPath := filepath.Join(tmpDir, "mocks", "querier.go")
+
+			err := Run(dir, "example.com/app/internal/database", outPath, Options{Style: tc.Style})
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			} else if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			got, err := os.ReadFile(outPath)
+			if err != nil {
+				t.Fatalf("failed to read mock file: %v", err)
+			}
+			if diff := cmp.Diff(tc.ExpectedContent, string(got)); diff != "" {
+				t.Errorf("mock file mismatch (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,35 @@
+package gitrepo
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var stat = os.Stat
+
+// Root returns the root of the git work tree containing dir: the nearest of
+// dir and its parents holding a .git entry. Both a .git directory and the
+// .git file of a linked worktree or submodule count.
+//
+// Returns an error if dir isn't inside a git work tree.
+func Root(dir string) (string, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	for d := absDir; ; d = filepath.Dir(d) {
+		_, err := stat(filepath.Join(d, ".git"))
+		if err == nil {
+			return d, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to check %s for a git repository: %w", d, err)
+		}
+		if filepath.Dir(d) == d {
+			return "", fmt.Errorf("%s is not inside a git repository", absDir)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package gitrepo
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRoot(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Dir               string
+		ExpectedRoot      string
+		ExpectedErrSubStr string
+	}{
+		{
+			Name:         "repository root",
+			Dir:          "repo",
+			ExpectedRoot: "repo",
+		},
+		{
+			Name:         "nested directory",
+			Dir:          "repo/internal/database",
+			ExpectedRoot: "repo",
+		},
+		{
+			Name:         "worktree with a .git file",
+			Dir:          "worktree/internal",
+			ExpectedRoot: "worktree",
+		},
+		{
+			Name:              "unversioned directory",
+			Dir:               "loose/internal",
+			ExpectedErrSubStr: "is not inside a git repository",
+		},
+	}
+	root := t.TempDir()
+	for _, dir := range []string{"repo/.git", "repo/internal/database", "worktree/internal", "loose/internal"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(root, "worktree", ".git"), []byte("gitdir: ../repo/.git/worktrees/wt\n"), 0644); err != nil {
+		t.Fatalf("failed to write .git file: %v", err)
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			// ignore any repository above the test's root
+			stat = func(name string) (os.FileInfo, error) {
+				if rel, err := filepath.Rel(root, name); err != nil || strings.HasPrefix(rel, "..") {
+					return nil, os.ErrNotExist
+				}
+				return os.Stat(name)
+			}
+			defer func() { stat = os.Stat }()
+
+			got, err := Root(filepath.Join(root, tc.Dir))
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, filepath.Join(root, tc.ExpectedRoot), got)
+		})
+	}
+}
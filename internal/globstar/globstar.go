@@ -0,0 +1,97 @@
+package globstar
+
+import (
+	"io/fs"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// Glob returns the names of all files matching pattern, like filepath.Glob,
+// which it calls for patterns without `**`. A path element of exactly `**`
+// matches zero or more directories, so `internal/**/*.sql.go` matches
+// `internal/a.sql.go` as well as `internal/db/users/a.sql.go`. Matches are
+// returned in lexical order; unreadable directories are skipped, as
+// filepath.Glob ignores I/O errors. Hidden and vendor directories below the
+// wildcard-free leading part are skipped too, unless an element of pattern
+// names them literally, as in `**/vendor/**/*.go`.
+//
+// Returns filepath.ErrBadPattern if pattern is malformed.
+func Glob(pattern string) ([]string, error) {
+	if !strings.Contains(pattern, "**") {
+		return filepath.Glob(pattern)
+	}
+	elems := strings.Split(filepath.ToSlash(pattern), "/")
+	for _, elem := range elems {
+		if _, err := path.Match(elem, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	// walk from the longest leading part free of wildcards
+	i := 0
+	for i < len(elems)-1 && !hasMeta(elems[i]) {
+		i++
+	}
+	root := strings.Join(elems[:i], "/")
+	if root == "" && i > 0 {
+		root = "/"
+	} else if root == "" {
+		root = "."
+	}
+	rest := elems[i:]
+
+	var matches []string
+	err := filepath.WalkDir(filepath.FromSlash(root), func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if d != nil && d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(filepath.FromSlash(root), p)
+		if err != nil || rel == "." {
+			return nil
+		}
+		if d.IsDir() && skipped(d.Name()) && !slices.Contains(rest, d.Name()) {
+			return filepath.SkipDir
+		}
+		if match(rest, strings.Split(filepath.ToSlash(rel), "/")) {
+			matches = append(matches, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return matches, nil
+}
+
+// match reports whether the path elements name match the pattern elements
+// pattern, `**` matching any number of them.
+func match(pattern, name []string) bool {
+	if len(pattern) == 0 {
+		return len(name) == 0
+	}
+	if pattern[0] == "**" {
+		return match(pattern[1:], name) || (len(name) > 0 && match(pattern, name[1:]))
+	}
+	if len(name) == 0 {
+		return false
+	}
+	ok, _ := path.Match(pattern[0], name[0])
+	return ok && match(pattern[1:], name[1:])
+}
+
+// skipped reports whether the directory name is one a `**` walk leaves out:
+// a hidden or vendor directory.
+func skipped(name string) bool {
+	return name == "vendor" || strings.HasPrefix(name, ".")
+}
+
+// hasMeta reports whether elem contains any of the characters recognized by
+// path.Match.
+func hasMeta(elem string) bool {
+	return strings.ContainsAny(elem, `*?[\`)
+}
@@ -0,0 +1,89 @@
+package globstar
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGlob(t *testing.T) {
+	t.Chdir(t.TempDir())
+	for _, name := range []string{
+		"internal/a.sql.go",
+		"internal/db/b.sql.go",
+		"internal/db/users/c.sql.go",
+		"internal/db/users/models.go",
+		"other/d.sql.go",
+		"internal/.cache/e.sql.go",
+		"internal/vendor/f.sql.go",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, []byte("package foo\n"), 0644))
+	}
+
+	tests := []struct {
+		Name              string
+		Pattern           string
+		Expected          []string
+		ExpectedErrSubStr string
+	}{
+		{
+			Name:     "single level",
+			Pattern:  "internal/db/*.sql.go",
+			Expected: []string{"internal/db/b.sql.go"},
+		},
+		{
+			Name:     "recursive",
+			Pattern:  "internal/**/*.sql.go",
+			Expected: []string{"internal/a.sql.go", "internal/db/b.sql.go", "internal/db/users/c.sql.go"},
+		},
+		{
+			Name:     "leading",
+			Pattern:  "**/users/*.go",
+			Expected: []string{"internal/db/users/c.sql.go", "internal/db/users/models.go"},
+		},
+		{
+			Name:     "wildcard before",
+			Pattern:  "*/**/d.sql.go",
+			Expected: []string{"other/d.sql.go"},
+		},
+		{
+			Name:     "hidden and vendor directories named literally",
+			Pattern:  "internal/**/vendor/*.sql.go",
+			Expected: []string{"internal/vendor/f.sql.go"},
+		},
+		{
+			Name:     "hidden directory as the root",
+			Pattern:  "internal/.cache/**/*.go",
+			Expected: []string{"internal/.cache/e.sql.go"},
+		},
+		{
+			Name:    "missing root",
+			Pattern: "missing/**/*.go",
+		},
+		{
+			Name:              "bad pattern",
+			Pattern:           "internal/**/[.go",
+			ExpectedErrSubStr: "syntax error in pattern",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			matches, err := Glob(tc.Pattern)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			for i := range matches {
+				matches[i] = filepath.ToSlash(matches[i])
+			}
+			if diff := cmp.Diff(tc.Expected, matches); diff != "" {
+				t.Errorf("matches mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package helpers
 
 import (
+	"archive/zip"
 	"fmt"
 	"go/ast"
 	"go/parser"
@@ -174,7 +175,7 @@ func ExecuteBaseTCErrorsANS(
 
 // ExecuteAddnosecErrors returns a modified set of function dependencies that simulate error conditions
 // for testing purposes. It accepts a testCase struct with various error flags and the original
-// implementations of four functions: openFile, pathAbs, baseAbs, and hasPrefix. Depending on
+// implementations of four functions: openFile, pathAbs, baseAbs, and withinDir. Depending on
 // which error flag in testCase is set to true, it replaces the corresponding function with a stub
 // that returns a simulated error or failure behavior.
 //
@@ -185,14 +186,14 @@ func ExecuteBaseTCErrorsANS(
 //   - openErr: if true, the openFile function is replaced to simulate a file opening error.
 //   - pathErr: if true, the pathAbs function is replaced to simulate an absolute path resolution error.
 //   - baseDirErr: if true, the baseAbs function is replaced to simulate a base directory resolution error.
-//   - prefixErr: if true, the hasPrefix function is replaced to always return false.
+//   - prefixErr: if true, the withinDir function is replaced to always return false.
 //   - expectedErrSubStr: a substring expected to be present in the error message.
 //
 // The functions provided are as follows:
 //   - openFile: opens a file by name and returns an os.File pointer.
 //   - pathAbs: returns the absolute form of a given path.
 //   - baseAbs: returns the base directory of a given path.
-//   - hasPrefix: checks whether a string has a specific prefix.
+//   - withinDir: checks whether a path lies within a directory.
 //
 // Only one error is simulated based on the order of evaluation:
 //  1. openErr
@@ -202,24 +203,24 @@ func ExecuteBaseTCErrorsANS(
 //
 // If none of the error flags are set, the original functions are returned unmodified.
 //
-// Returns a tuple of four functions corresponding to openFile, pathAbs, baseAbs, and hasPrefix,
+// Returns a tuple of four functions corresponding to openFile, pathAbs, baseAbs, and withinDir,
 // where each function may be the original or a stub that returns a simulated error or failure behavior.
 func ExecuteAddnosecErrors(
 	testCase AddnosecTC,
 	openFile func(name string) (*os.File, error),
 	pathAbs func(path string) (string, error),
 	baseAbs func(path string) (string, error),
-	hasPrefix func(s string, prefix string) bool,
+	withinDir func(path, dir string) bool,
 ) (
 	func(name string) (*os.File, error),
 	func(path string) (string, error),
 	func(path string) (string, error),
-	func(s string, prefix string) bool,
+	func(path, dir string) bool,
 ) {
 	oF := openFile
 	pA := pathAbs
 	bA := baseAbs
-	hP := hasPrefix
+	hP := withinDir
 
 	if testCase.OpenErr {
 		oF = func(name string) (*os.File, error) {
@@ -240,10 +241,33 @@ func ExecuteAddnosecErrors(
 		return oF, pA, bA, hP
 	}
 	if testCase.PrefixErr {
-		hP = func(s, prefix string) bool {
+		hP = func(path, dir string) bool {
 			return false
 		}
 		return oF, pA, bA, hP
 	}
 	return oF, pA, bA, hP
 }
+
+// ReadZip returns the contents of the zip archive at path by entry name.
+func ReadZip(path string) (map[string]string, error) {
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	files := make(map[string]string)
+	for _, f := range r.File {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		content, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		files[f.Name] = string(content)
+	}
+	return files, nil
+}
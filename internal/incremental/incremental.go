@@ -0,0 +1,106 @@
+package incremental
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+var (
+	readFile  = os.ReadFile
+	writeFile = os.WriteFile
+)
+
+// State records the content hash of every file processed by the last run,
+// along with a fingerprint of the options that run used. A file whose hash
+// still matches is skipped on the next incremental run.
+type State struct {
+	Fingerprint string            `json:"fingerprint"`
+	Files       map[string]string `json:"files"`
+}
+
+// Fingerprint hashes the JSON encoding of parts so that any change to the
+// inputs of a run invalidates the recorded state.
+func Fingerprint(parts ...any) (string, error) {
+	data, err := json.Marshal(parts)
+	if err != nil {
+		return "", fmt.Errorf("failed to fingerprint options: %w", err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// Load reads the state file at path. A missing file, a fingerprint that no
+// longer matches, or reset all yield an empty state so that every file is
+// processed again.
+func Load(path, fingerprint string, reset bool) (*State, error) {
+	empty := &State{Fingerprint: fingerprint, Files: make(map[string]string)}
+	if reset {
+		return empty, nil
+	}
+	data, err := readFile(path) // #nosec -- state file path is chosen by the user running the tool
+	if errors.Is(err, fs.ErrNotExist) {
+		return empty, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+	var state State
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+	if state.Fingerprint != fingerprint || state.Files == nil {
+		return empty, nil
+	}
+	return &state, nil
+}
+
+// Changed reports whether file differs from the content recorded by the
+// last run (or was never recorded).
+func (s *State) Changed(file string) (bool, error) {
+	sum, err := hashFile(file)
+	if err != nil {
+		return false, err
+	}
+	return s.Files[filepath.Clean(file)] != sum, nil
+}
+
+// Record stores the current content hash of file.
+func (s *State) Record(file string) error {
+	sum, err := hashFile(file)
+	if err != nil {
+		return err
+	}
+	s.Files[filepath.Clean(file)] = sum
+	return nil
+}
+
+// Save writes the state to path, creating parent directories as needed.
+func (s *State) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0750); err != nil {
+			return fmt.Errorf("failed to create state directory: %w", err)
+		}
+	}
+	if err := writeFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write state file %s: %w", path, err)
+	}
+	return nil
+}
+
+func hashFile(file string) (string, error) {
+	data, err := readFile(file) // #nosec -- file comes from the run's own discovery
+	if err != nil {
+		return "", fmt.Errorf("failed to hash %s: %w", file, err)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,83 @@
+package incremental
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestState(t *testing.T) {
+	tests := []struct {
+		Name            string
+		LoadFingerprint string
+		Reset           bool
+		Modify          bool
+		ExpectedChanged bool
+	}{
+		{
+			Name:            "unchanged file is skipped",
+			LoadFingerprint: "a",
+			ExpectedChanged: false,
+		},
+		{
+			Name:            "modified file is processed",
+			LoadFingerprint: "a",
+			Modify:          true,
+			ExpectedChanged: true,
+		},
+		{
+			Name:            "fingerprint change invalidates state",
+			LoadFingerprint: "b",
+			ExpectedChanged: true,
+		},
+		{
+			Name:            "reset forces full pass",
+			LoadFingerprint: "a",
+			Reset:           true,
+			ExpectedChanged: true,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			file := filepath.Join(tmpDir, "query.sql.go")
+			statePath := filepath.Join(tmpDir, "state", "state.json")
+			if err := os.WriteFile(file, []byte("package foo\n"), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			state, err := Load(statePath, "a", false)
+			require.NoError(t, err)
+			changed, err := state.Changed(file)
+			require.NoError(t, err)
+			require.True(t, changed, "file should be unseen on a fresh state")
+			require.NoError(t, state.Record(file))
+			require.NoError(t, state.Save(statePath))
+
+			if tc.Modify {
+				if err := os.WriteFile(file, []byte("package bar\n"), 0644); err != nil {
+					t.Fatalf("failed to modify file: %v", err)
+				}
+			}
+
+			state, err = Load(statePath, tc.LoadFingerprint, tc.Reset)
+			require.NoError(t, err)
+			changed, err = state.Changed(file)
+			require.NoError(t, err)
+			require.Equal(t, tc.ExpectedChanged, changed)
+		})
+	}
+}
+
+func TestFingerprint(t *testing.T) {
+	a, err := Fingerprint("glob", map[string]bool{"x": true, "y": true})
+	require.NoError(t, err)
+	b, err := Fingerprint("glob", map[string]bool{"y": true, "x": true})
+	require.NoError(t, err)
+	c, err := Fingerprint("glob", map[string]bool{"x": true})
+	require.NoError(t, err)
+	require.Equal(t, a, b)
+	require.NotEqual(t, a, c)
+}
@@ -0,0 +1,126 @@
+package modcheck
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+var readFile = os.ReadFile
+
+// Module is a Go module found on disk.
+type Module struct {
+	// Path is the module path declared by its go.mod.
+	Path string
+	// Dir is the directory holding its go.mod.
+	Dir string
+}
+
+// Find returns the module owning dir: the one declared by the go.mod in dir
+// or the nearest parent directory.
+//
+// Returns an error if no go.mod is found or it declares no module path.
+func Find(dir string) (Module, error) {
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return Module{}, fmt.Errorf("failed to resolve %s: %w", dir, err)
+	}
+	for d := absDir; ; d = filepath.Dir(d) {
+		goMod := filepath.Join(d, "go.mod")
+		data, err := readFile(goMod) // #nosec G304 -- go.mod of a directory chosen by the user running the tool
+		if err == nil {
+			modPath := modulePath(data)
+			if modPath == "" {
+				return Module{}, fmt.Errorf("%s declares no module path", goMod)
+			}
+			return Module{Path: modPath, Dir: d}, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return Module{}, fmt.Errorf("failed to read %s: %w", goMod, err)
+		}
+		if filepath.Dir(d) == d {
+			return Module{}, fmt.Errorf("no go.mod found in %s or any parent directory", absDir)
+		}
+	}
+}
+
+// Check confirms that the query directories, the models file at modelPath
+// and modelImport all belong to one module: the dirs and the models file are
+// owned by the same go.mod, and modelImport is the import path that module
+// gives the models file's directory. An import outside the module would
+// leave the qualified code uncompilable.
+//
+// The module of the query dirs is returned even when the check fails, so
+// callers can report it.
+func Check(dirs []string, modelPath, modelImport string) (Module, error) {
+	if len(dirs) == 0 {
+		return Module{}, errors.New("no query directory to check")
+	}
+	mod, err := Find(dirs[0])
+	if err != nil {
+		return Module{}, err
+	}
+	for _, dir := range dirs[1:] {
+		other, err := Find(dir)
+		if err != nil {
+			return mod, err
+		}
+		if other.Dir != mod.Dir {
+			return mod, fmt.Errorf("query directories %s and %s belong to different modules (%s and %s)", dirs[0], dir, mod.Path, other.Path)
+		}
+	}
+
+	if modelImport != mod.Path && !strings.HasPrefix(modelImport, mod.Path+"/") {
+		return mod, fmt.Errorf("import %q is outside module %s (%s)", modelImport, mod.Path, filepath.Join(mod.Dir, "go.mod"))
+	}
+
+	modelDir, err := filepath.Abs(filepath.Dir(modelPath))
+	if err != nil {
+		return mod, fmt.Errorf("failed to resolve %s: %w", modelPath, err)
+	}
+	modelMod, err := Find(modelDir)
+	if err != nil {
+		return mod, err
+	}
+	if modelMod.Dir != mod.Dir {
+		return mod, fmt.Errorf("models file %s belongs to module %s, not to module %s of the queries", modelPath, modelMod.Path, mod.Path)
+	}
+	rel, err := filepath.Rel(mod.Dir, modelDir)
+	if err != nil {
+		return mod, fmt.Errorf("failed to resolve %s within module %s: %w", modelPath, mod.Path, err)
+	}
+	want := mod.Path
+	if rel != "." {
+		want += "/" + filepath.ToSlash(rel)
+	}
+	if modelImport != want {
+		return mod, fmt.Errorf("import %q does not match models file %s, which module %s imports as %q", modelImport, modelPath, mod.Path, want)
+	}
+	return mod, nil
+}
+
+// modulePath returns the module path declared by the go.mod contents data,
+// or "" if there is none.
+func modulePath(data []byte) string {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		rest, ok := strings.CutPrefix(line, "module")
+		if !ok || rest == "" || (rest[0] != ' ' && rest[0] != '\t' && rest[0] != '"') {
+			continue
+		}
+		rest, _, _ = strings.Cut(rest, "//")
+		rest = strings.TrimSpace(rest)
+		if unquoted, err := strconv.Unquote(rest); err == nil {
+			return unquoted
+		}
+		return rest
+	}
+	return ""
+}
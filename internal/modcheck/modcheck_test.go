@@ -0,0 +1,110 @@
+package modcheck
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Dirs              []string
+		ModelPath         string
+		Import            string
+		ExpectedErrSubStr string
+	}{
+		{
+			Name:      "same module",
+			Dirs:      []string{"app/internal/database", "app/internal/reports"},
+			ModelPath: "app/internal/models/models.go",
+			Import:    "example.com/app/internal/models",
+		},
+		{
+			Name:              "import outside the module",
+			Dirs:              []string{"app/internal/database"},
+			ModelPath:         "app/internal/models/models.go",
+			Import:            "example.com/other/models",
+			ExpectedErrSubStr: `import "example.com/other/models" is outside module example.com/app`,
+		},
+		{
+			Name:              "import not matching the models file",
+			Dirs:              []string{"app/internal/database"},
+			ModelPath:         "app/internal/models/models.go",
+			Import:            "example.com/app/models",
+			ExpectedErrSubStr: `which module example.com/app imports as "example.com/app/internal/models"`,
+		},
+		{
+			Name:              "models file in a nested module",
+			Dirs:              []string{"app/internal/database"},
+			ModelPath:         "app/shared/models/models.go",
+			Import:            "example.com/app/shared/models",
+			ExpectedErrSubStr: "belongs to module example.com/app/shared, not to module example.com/app",
+		},
+		{
+			Name:              "query dirs in different modules",
+			Dirs:              []string{"app/internal/database", "app/shared/database"},
+			ModelPath:         "app/internal/models/models.go",
+			Import:            "example.com/app/internal/models",
+			ExpectedErrSubStr: "belong to different modules (example.com/app and example.com/app/shared)",
+		},
+		{
+			Name:              "no go.mod",
+			Dirs:              []string{"loose"},
+			ModelPath:         "loose/models.go",
+			Import:            "example.com/app/internal/models",
+			ExpectedErrSubStr: "no go.mod found",
+		},
+	}
+	root := t.TempDir()
+	for name, content := range map[string]string{
+		"app/go.mod":                         "module example.com/app\n\ngo 1.24\n",
+		"app/internal/models/models.go":      "package models\n",
+		"app/internal/database/query.sql.go": "package database\n",
+		"app/internal/reports/report.sql.go": "package reports\n",
+		"app/shared/go.mod":                  "module \"example.com/app/shared\" // split out\n",
+		"app/shared/models/models.go":        "package models\n",
+		"app/shared/database/query.sql.go":   "package database\n",
+		"loose/models.go":                    "package models\n",
+	} {
+		file := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(file, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			// ignore any go.mod above the test's root
+			readFile = func(name string) ([]byte, error) {
+				if rel, err := filepath.Rel(root, name); err != nil || strings.HasPrefix(rel, "..") {
+					return nil, os.ErrNotExist
+				}
+				return os.ReadFile(name)
+			}
+			defer func() { readFile = os.ReadFile }()
+			dirs := make([]string, len(tc.Dirs))
+			for i, dir := range tc.Dirs {
+				dirs[i] = filepath.Join(root, dir)
+			}
+			mod, err := Check(dirs, filepath.Join(root, tc.ModelPath), tc.Import)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, Module{Path: "example.com/app", Dir: filepath.Join(root, "app")}, mod)
+		})
+	}
+}
+
+func TestModulePath(t *testing.T) {
+	require.Equal(t, "example.com/app", modulePath([]byte("// comment\nmodule example.com/app\n")))
+	require.Equal(t, "example.com/app", modulePath([]byte("module \"example.com/app\" // quoted\n")))
+	require.Equal(t, "", modulePath([]byte("go 1.24\nmodulex foo\n")))
+}
@@ -0,0 +1,28 @@
+package pending
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Error is returned by a check-mode run to report the files it would have
+// changed. No file has been written when it is returned.
+type Error struct {
+	// Operation is the command that would make the changes, e.g. add-nosec.
+	Operation string
+	Files     []string
+	// Notes optionally describes, per file, a change worth calling out,
+	// e.g. the import that would be added.
+	Notes map[string]string
+}
+
+func (e *Error) Error() string {
+	lines := make([]string, len(e.Files))
+	for i, file := range e.Files {
+		lines[i] = file
+		if note := e.Notes[file]; note != "" {
+			lines[i] += " (" + note + ")"
+		}
+	}
+	return fmt.Sprintf("%s: %d files would change:\n  %s", e.Operation, len(e.Files), strings.Join(lines, "\n  "))
+}
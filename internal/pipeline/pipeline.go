@@ -0,0 +1,88 @@
+// Package pipeline holds the per-file steps add-nosec and qualify-models
+// share: running custom AST hooks after the built-in transform, spotting
+// files that only change by reformatting, and guarding the write-back of a
+// file against concurrent changes.
+package pipeline
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/token"
+	"io"
+	"os"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/exectransform"
+)
+
+var readFile = os.ReadFile
+
+// Hook is a custom AST transform run on every processed file after the
+// built-in transform and before the file is formatted and written, so several
+// transforms share a single parse/format pass. It reports whether it changed
+// the file.
+//
+// A Hook may be called on several files at once, each with its own fset and
+// f (qualify-models does, see its Options.Jobs), so it must be safe for
+// concurrent use: any state it shares across calls needs its own locking.
+type Hook func(fset *token.FileSet, f *ast.File) (changed bool, err error)
+
+// WithExec returns hooks followed by the hook running command, if any.
+func WithExec(hooks []Hook, command string) ([]Hook, error) {
+	if command == "" {
+		return hooks, nil
+	}
+	hook, err := exectransform.Hook(command)
+	if err != nil {
+		return nil, err
+	}
+	return append(hooks[:len(hooks):len(hooks)], hook), nil
+}
+
+// RunHooks runs hooks in order on f, parsed from file, stopping at the first
+// error. It reports whether any hook changed the file.
+func RunHooks(hooks []Hook, fset *token.FileSet, f *ast.File, file string) (bool, error) {
+	changed := false
+	for i, hook := range hooks {
+		hookChanged, err := hook(fset, f)
+		if err != nil {
+			return changed, fmt.Errorf("hook %d failed on %s: %w", i, file, err)
+		}
+		changed = changed || hookChanged
+	}
+	return changed, nil
+}
+
+// SkipChanged reports whether file must be skipped because its content no
+// longer hashes to sum, taken when it was read: another process changed it
+// since, and overwriting it would lose that update. A warning naming the file
+// as display is written to warn. With strict set this is an error instead.
+func SkipChanged(file, display string, sum [sha256.Size]byte, strict bool, warn io.Writer) (bool, error) {
+	now, err := readFile(file) // #nosec G304 -- the file was just parsed from this path
+	if err != nil {
+		return false, fmt.Errorf("failed to re-read file %s: %w", file, err)
+	}
+	if sha256.Sum256(now) == sum {
+		return false, nil
+	}
+	if strict {
+		return false, fmt.Errorf("%s changed on disk since it was read; refusing to overwrite it", display)
+	}
+	fmt.Fprintf(warn, "warning: %s changed on disk since it was read; skipped it to keep the newer content\n", display)
+	return true, nil
+}
+
+// ReformatOnly reports whether out, the formatted result of transforming src,
+// is just src formatted: the transform itself changed nothing.
+func ReformatOnly(src, out []byte) bool {
+	formatted, err := format.Source(src)
+	return err == nil && bytes.Equal(formatted, out)
+}
+
+// WarnReformat writes to w the warning that the file shown as display only
+// changes by gofmt normalization, not by command.
+func WarnReformat(w io.Writer, display, command string) {
+	fmt.Fprintf(w, "warning: %s only changes by gofmt normalization, not by %s; check the reformat is wanted\n", display, command)
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"go/ast"
+	"go/token"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunHooks(t *testing.T) {
+	var calls []int
+	hook := func(i int, changed bool, err error) Hook {
+		return func(*token.FileSet, *ast.File) (bool, error) {
+			calls = append(calls, i)
+			return changed, err
+		}
+	}
+
+	changed, err := RunHooks([]Hook{hook(0, false, nil), hook(1, true, nil), hook(2, false, nil)}, nil, nil, "a.go")
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, []int{0, 1, 2}, calls)
+
+	calls = nil
+	_, err = RunHooks([]Hook{hook(0, false, nil), hook(1, false, errors.New("boom")), hook(2, false, nil)}, nil, nil, "a.go")
+	require.EqualError(t, err, "hook 1 failed on a.go: boom")
+	require.Equal(t, []int{0, 1}, calls, "hooks after a failure should not run")
+}
+
+func TestWithExec(t *testing.T) {
+	hooks := make([]Hook, 1, 2)
+	got, err := WithExec(hooks, "")
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+
+	got, err = WithExec(hooks, "cat")
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	require.Len(t, hooks[:cap(hooks)], 2)
+	require.Nil(t, hooks[:2][1], "the caller's backing array must not be written")
+}
+
+func TestSkipChanged(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "a.go")
+	require.NoError(t, os.WriteFile(file, []byte("package a\n"), 0644))
+	sum := sha256.Sum256([]byte("package a\n"))
+	var warnings bytes.Buffer
+
+	skip, err := SkipChanged(file, "a.go", sum, false, &warnings)
+	require.NoError(t, err)
+	require.False(t, skip)
+	require.Empty(t, warnings.String())
+
+	require.NoError(t, os.WriteFile(file, []byte("package b\n"), 0644))
+	skip, err = SkipChanged(file, "a.go", sum, false, &warnings)
+	require.NoError(t, err)
+	require.True(t, skip)
+	require.Equal(t, "warning: a.go changed on disk since it was read; skipped it to keep the newer content\n", warnings.String())
+
+	_, err = SkipChanged(file, "a.go", sum, true, &warnings)
+	require.EqualError(t, err, "a.go changed on disk since it was read; refusing to overwrite it")
+}
+
+func TestReformatOnly(t *testing.T) {
+	src := []byte("package a\n\nvar  x = 1\n")
+	require.True(t, ReformatOnly(src, []byte("package a\n\nvar x = 1\n")))
+	require.False(t, ReformatOnly(src, []byte("package a\n\nvar x = 2\n")))
+	require.False(t, ReformatOnly([]byte("not go"), []byte("not go")), "unparsable input is never just a reformat")
+}
@@ -0,0 +1,172 @@
+package pruneimports
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"golang.org/x/tools/go/ast/astutil"
+)
+
+var (
+	parseFile  = parser.ParseFile
+	createFile = os.Create
+	formatNode = format.Node
+	walkDir    = filepath.WalkDir
+
+	stderr io.Writer = os.Stderr
+)
+
+// Run removes imports that are no longer referenced from every .go file
+// under rootDir, e.g. an old models path left behind by a migration.
+//
+// Usage is determined by a lightweight scan rather than full type checking:
+// an import is unused when no selector expression in the file refers to its
+// package name. For unaliased imports the package name is assumed from the
+// import path the same way goimports does (`github.com/jackc/pgx/v5` → pgx,
+// `github.com/mattn/go-sqlite3` → sqlite3). When a file references a
+// package name that none of its imports provides, the assumption is wrong
+// somewhere, so none of that file's imports are removed and a warning is
+// printed instead.
+//
+// Blank (`_`) and dot imports are never removed, remaining import groups are
+// preserved, and files without unused imports are left byte-for-byte
+// untouched, so the command is idempotent.
+//
+// Returns an error if the walk fails or any file can't be parsed or written.
+func Run(rootDir string) error {
+	var files []string
+	if err := walkDir(rootDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to walkDir %s: %w", rootDir, err)
+	}
+
+	for _, file := range files {
+		fset := token.NewFileSet()
+		f, err := parseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		if !prune(fset, f, file) {
+			continue
+		}
+		if err := func() error {
+			outFile, err := createFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to open file %s for writing: %w", file, err)
+			}
+			defer outFile.Close()
+			return formatNode(outFile, fset, f)
+		}(); err != nil {
+			return fmt.Errorf("failed to write updated file %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// prune deletes the unused imports of f and reports whether any were removed.
+func prune(fset *token.FileSet, f *ast.File, file string) bool {
+	used := referencedPackages(f)
+
+	provided := make(map[string]bool)
+	type candidate struct{ name, path string }
+	var unused []candidate
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		alias := ""
+		name := AssumedName(importPath)
+		if spec.Name != nil {
+			alias = spec.Name.Name
+			name = alias
+		}
+		switch name {
+		case ".":
+			// a dot import can satisfy any bare reference; play it safe
+			return false
+		case "_":
+			continue
+		}
+		provided[name] = true
+		if !used[name] {
+			unused = append(unused, candidate{name: alias, path: importPath})
+		}
+	}
+	if len(unused) == 0 {
+		return false
+	}
+	for name := range used {
+		if !provided[name] {
+			fmt.Fprintf(stderr, "warning: %s: %q is not provided by any import; leaving imports untouched\n", file, name)
+			return false
+		}
+	}
+
+	for _, imp := range unused {
+		astutil.DeleteNamedImport(fset, f, imp.name, imp.path)
+	}
+	return true
+}
+
+// referencedPackages returns the names used as the base of a selector
+// expression that don't resolve to a declaration in the file, i.e. the
+// candidate package names the file refers to.
+func referencedPackages(f *ast.File) map[string]bool {
+	used := make(map[string]bool)
+	ast.Inspect(f, func(n ast.Node) bool {
+		sel, ok := n.(*ast.SelectorExpr)
+		if !ok {
+			return true
+		}
+		if ident, ok := sel.X.(*ast.Ident); ok && ident.Obj == nil {
+			used[ident.Name] = true
+		}
+		return true
+	})
+	return used
+}
+
+// AssumedName returns the package name assumed for an unaliased import of
+// importPath: the last path element without a major version suffix or a
+// go- prefix, cut at the first character that can't appear in an identifier.
+func AssumedName(importPath string) string {
+	elems := strings.Split(importPath, "/")
+	name := elems[len(elems)-1]
+	if len(elems) > 1 && isMajorVersion(name) {
+		name = elems[len(elems)-2]
+	}
+	name = strings.TrimPrefix(name, "go-")
+	if i := strings.IndexFunc(name, func(r rune) bool {
+		return !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || r == '_')
+	}); i >= 0 {
+		name = name[:i]
+	}
+	return name
+}
+
+func isMajorVersion(elem string) bool {
+	rest, ok := strings.CutPrefix(elem, "v")
+	if !ok || rest == "" {
+		return false
+	}
+	_, err := strconv.Atoi(rest)
+	return err == nil
+}
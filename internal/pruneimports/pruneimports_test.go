@@ -0,0 +1,178 @@
+package pruneimports
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		InitContent     string
+		ExpectedWarning string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "unused imports removed, groups preserved",
+				ExpectedContent: `package database
+
+import (
+	"context"
+
+	_ "github.com/lib/pq"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+func Get(ctx context.Context) pgtype.Text {
+	return pgtype.Text{}
+}
+`,
+			},
+			InitContent: `package database
+
+import (
+	"context"
+	"database/sql"
+
+	_ "github.com/lib/pq"
+
+	"github.com/jackc/pgx/v5/pgtype"
+	"github.com/me/app/internal/models"
+	oldmodels "github.com/me/app/internal/db/models"
+)
+
+func Get(ctx context.Context) pgtype.Text {
+	return pgtype.Text{}
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "assumed names for versioned and go- prefixed paths",
+				ExpectedContent: `package database
+
+import (
+	"github.com/jackc/pgx/v5"
+	"github.com/mattn/go-sqlite3"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = pgx.ErrNoRows
+var _ = sqlite3.ErrError
+var _ = yaml.Marshal
+`,
+			},
+			InitContent: `package database
+
+import (
+	"github.com/jackc/pgx/v5"
+	"github.com/mattn/go-sqlite3"
+	"gopkg.in/yaml.v3"
+)
+
+var _ = pgx.ErrNoRows
+var _ = sqlite3.ErrError
+var _ = yaml.Marshal
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "local selectors are not package references",
+				ExpectedContent: `package database
+
+type Queries struct{ db DBTX }
+
+func (q *Queries) Exec() { q.db.Exec() }
+`,
+			},
+			InitContent: `package database
+
+import "github.com/me/app/internal/models"
+
+type Queries struct{ db DBTX }
+
+func (q *Queries) Exec() { q.db.Exec() }
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "unknown package name leaves the file alone",
+				ExpectedContent: `package database
+
+import (
+	"github.com/me/app/internal/models"
+	"github.com/me/lib/v2/internal/impl"
+)
+
+var _ = thing.Value
+`,
+			},
+			InitContent: `package database
+
+import (
+	"github.com/me/app/internal/models"
+	"github.com/me/lib/v2/internal/impl"
+)
+
+var _ = thing.Value
+`,
+			ExpectedWarning: `"thing" is not provided by any import`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "files without unused imports are not reformatted",
+				ExpectedContent: `package database
+import "context"
+var   _ context.Context
+`,
+			},
+			InitContent: `package database
+import "context"
+var   _ context.Context
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			var warnings bytes.Buffer
+			stderr = &warnings
+			defer func() { stderr = os.Stderr }()
+
+			tmpDir := t.TempDir()
+			file := filepath.Join(tmpDir, "nested", "query.sql.go")
+			if err := os.MkdirAll(filepath.Dir(file), 0755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			if err := os.WriteFile(file, []byte(tc.InitContent), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+
+			require.NoError(t, Run(tmpDir))
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if diff := cmp.Diff(tc.ExpectedContent, string(got)); diff != "" {
+				t.Errorf("file mismatch (-want +got)\n%s", diff)
+			}
+			if tc.ExpectedWarning != "" {
+				require.Contains(t, warnings.String(), tc.ExpectedWarning)
+			}
+
+			// running again must be a no-op
+			require.NoError(t, Run(tmpDir))
+			again, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			require.Equal(t, string(got), string(again))
+		})
+	}
+}
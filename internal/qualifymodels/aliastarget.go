@@ -0,0 +1,78 @@
+package qualifymodels
+
+import (
+	"go/ast"
+	"strconv"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pruneimports"
+)
+
+// aliasTarget is the type a model alias declaration (`type Account =
+// accounting.Account`) refers to in another package.
+type aliasTarget struct {
+	// Path is the import path of the aliased type's package.
+	Path string
+	// Name is the name the models file imports that package by.
+	Name string
+	// Type is the aliased type's name within its package.
+	Type string
+}
+
+// aliasTargetOf returns the target of typeSpec when it declares an alias to
+// a type of a package imported by modelFile. Aliases to local or
+// predeclared types, and to instantiated generic types, have none.
+func aliasTargetOf(modelFile *ast.File, typeSpec *ast.TypeSpec) (aliasTarget, bool) {
+	if !typeSpec.Assign.IsValid() {
+		return aliasTarget{}, false
+	}
+	sel, ok := typeSpec.Type.(*ast.SelectorExpr)
+	if !ok {
+		return aliasTarget{}, false
+	}
+	x, ok := sel.X.(*ast.Ident)
+	if !ok {
+		return aliasTarget{}, false
+	}
+	importPath, ok := importPathOf(modelFile, x.Name)
+	if !ok {
+		return aliasTarget{}, false
+	}
+	return aliasTarget{Path: importPath, Name: x.Name, Type: sel.Sel.Name}, true
+}
+
+// importPathOf returns the path of the import f refers to as name.
+func importPathOf(f *ast.File, name string) (string, bool) {
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		if localImportName(spec, importPath) == name {
+			return importPath, true
+		}
+	}
+	return "", false
+}
+
+// importedAs returns the name f refers to importPath by, if f imports it.
+// Blank and dot imports don't count.
+func importedAs(f *ast.File, importPath string) (string, bool) {
+	for _, spec := range f.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != importPath {
+			continue
+		}
+		if name := localImportName(spec, importPath); name != "_" && name != "." {
+			return name, true
+		}
+	}
+	return "", false
+}
+
+// localImportName returns the name spec, an import of importPath, is
+// referred to by: its alias, or the package name assumed from the path.
+func localImportName(spec *ast.ImportSpec, importPath string) string {
+	if spec.Name != nil {
+		return spec.Name.Name
+	}
+	return pruneimports.AssumedName(importPath)
+}
@@ -0,0 +1,124 @@
+package qualifymodels
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/directives"
+)
+
+// DefaultSkipDirs are the directory names CheckGlobal skips by default, on
+// top of hidden ones.
+var DefaultSkipDirs = []string{"vendor", "testdata"}
+
+// Bare is a bare reference to a model, used as a type outside the models
+// package: most likely hand-written code that wasn't qualified after the
+// models moved.
+type Bare struct {
+	File string
+	Line int
+	Name string
+}
+
+func (b Bare) String() string {
+	return fmt.Sprintf("%s:%d: bare reference to model %s", b.File, b.Line, b.Name)
+}
+
+// CheckGlobal collects the models from modelPath like Run and reports every
+// bare reference to one in the .go files under rootDir, usually the module
+// root, not just the query dirs. A reference is a model name in a type
+// position (as for CheckMissing) that the file's package doesn't declare
+// itself. Files of the models package's directory, files with a dot import
+// and files carrying the `// sqlc-qol:skip` directive are skipped, as are
+// hidden directories and those named in skipDirs. opts.BuildTags selects
+// the models and files as for Run; nothing is written.
+//
+// Returns an error if the models or any file can't be parsed or the walk
+// fails.
+func CheckGlobal(modelPath, rootDir string, skipDirs []string, opts Options) ([]Bare, error) {
+	buildCtx, modelNames, _, _, err := collectModels(modelPath, opts.BuildTags, opts.StructsOnly)
+	if err != nil {
+		return nil, err
+	}
+	paths, err := modelPathList(modelPath)
+	if err != nil {
+		return nil, err
+	}
+	modelsDir, err := filepath.Abs(filepath.Dir(paths[0]))
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve %s: %w", modelPath, err)
+	}
+
+	var files []string
+	if err := walkDir(rootDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			name := d.Name()
+			if p != rootDir && (strings.HasPrefix(name, ".") || slices.Contains(skipDirs, name)) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+		if dir, err := filepath.Abs(filepath.Dir(p)); err == nil && dir == modelsDir {
+			return nil
+		}
+		if buildCtx != nil {
+			match, err := buildCtx.MatchFile(filepath.Dir(p), filepath.Base(p))
+			if err != nil {
+				return err
+			}
+			if !match {
+				return nil
+			}
+		}
+		files = append(files, p)
+		return nil
+	}); err != nil {
+		return nil, fmt.Errorf("failed to walkDir %s: %w", rootDir, err)
+	}
+
+	fset := token.NewFileSet()
+	parsed := make(map[string]*ast.File, len(files))
+	declared := make(map[string]map[string]bool)
+	for _, file := range files {
+		f, err := parseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		parsed[file] = f
+		key := filepath.Dir(file) + ":" + f.Name.Name
+		if declared[key] == nil {
+			declared[key] = make(map[string]bool)
+		}
+		for _, name := range declaredNames(f) {
+			declared[key][name] = true
+		}
+	}
+
+	var bare []Bare
+	for _, file := range files {
+		f := parsed[file]
+		if directives.SkipFile(f) || hasDotImport(f) {
+			continue
+		}
+		local := declared[filepath.Dir(file)+":"+f.Name.Name]
+		for _, ident := range typeRefs(f) {
+			if ident.Obj != nil || local[ident.Name] || !modelNames[ident.Name] {
+				continue
+			}
+			bare = append(bare, Bare{File: file, Line: fset.Position(ident.Pos()).Line, Name: ident.Name})
+		}
+	}
+	return bare, nil
+}
@@ -0,0 +1,70 @@
+package qualifymodels
+
+import (
+	"go/parser"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckGlobal(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "internal", "models", "models.go")
+	handler := filepath.Join(tmpDir, "internal", "api", "handler.go")
+	files := map[string]string{
+		modelFile: "package models\ntype Transaction struct {}\ntype User struct {}\n",
+		filepath.Join(tmpDir, "internal", "models", "helpers.go"): "package models\n\nfunc Owner(t Transaction) User { return User{} }\n",
+		handler: `package api
+
+import "example.com/app/internal/models"
+
+type Response struct {
+	User  User
+	Other models.User
+}
+
+func Handle(t *Transaction) {
+	_ = models.Transaction{}
+	_ = []User{}
+}
+`,
+		filepath.Join(tmpDir, "internal", "api", "types.go"):       "package api\n",
+		filepath.Join(tmpDir, "internal", "local", "local.go"):     "package local\n\ntype User struct{}\n\nvar U User\n",
+		filepath.Join(tmpDir, "internal", "dot", "dot.go"):         "package dot\n\nimport . \"example.com/app/internal/models\"\n\nvar U User\n",
+		filepath.Join(tmpDir, "internal", "skip", "skip.go"):       "// sqlc-qol:skip\n\npackage skip\n\nvar U User\n",
+		filepath.Join(tmpDir, "vendor", "lib", "lib.go"):           "package lib\n\nvar U User\n",
+		filepath.Join(tmpDir, "testdata", "fixture.go"):            "package fixture\n\nvar U User\n",
+		filepath.Join(tmpDir, ".hidden", "hidden.go"):              "package hidden\n\nvar U User\n",
+		filepath.Join(tmpDir, "internal", "generated", "users.go"): "package generated\n\nvar U User\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	bare, err := CheckGlobal(modelFile, tmpDir, append(DefaultSkipDirs, "generated"), Options{})
+	require.NoError(t, err)
+	require.Equal(t, []Bare{
+		{File: handler, Line: 6, Name: "User"},
+		{File: handler, Line: 10, Name: "Transaction"},
+		{File: handler, Line: 12, Name: "User"},
+	}, bare)
+	require.Equal(t, handler+":6: bare reference to model User", bare[0].String())
+
+	// skipping nothing reaches vendor and testdata, never hidden dirs
+	bare, err = CheckGlobal(modelFile, tmpDir, nil, Options{})
+	require.NoError(t, err)
+	require.Len(t, bare, 6)
+
+	_, err = CheckGlobal(filepath.Join(tmpDir, "missing.go"), tmpDir, nil, Options{})
+	require.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package qualifymodels
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/directives"
+)
+
+// Missing is a bare, exported identifier used as a type in a query file that
+// is neither a known model nor declared in the query package: most likely a
+// reference to a model that was removed or renamed.
+type Missing struct {
+	File string
+	Line int
+	Name string
+}
+
+func (m Missing) String() string {
+	return fmt.Sprintf("%s:%d: %s is not a known model", m.File, m.Line, m.Name)
+}
+
+// CheckMissing collects the models from modelPath like Run and reports every
+// suspicious bare type reference in the query files under rootDbDirs. An
+// identifier is suspicious when it is exported, appears in a type position
+// (a field, parameter or result type, a var or const type, a composite
+// literal or a type assertion, including type arguments), and neither
+// resolves to a model (honoring opts.NameTemplate and opts.CaseInsensitive)
+// nor is declared in the query file's package. Files with a dot import are
+// skipped, since any name could come from it. opts.BuildTags and
+// opts.Pattern select the files as for Run; nothing is written.
+//
+// Returns an error if the models or any query file can't be parsed or the
+// walk fails.
+func CheckMissing(modelPath string, rootDbDirs []string, opts Options) ([]Missing, error) {
+	mapName, err := parseNameTemplate(opts.NameTemplate)
+	if err != nil {
+		return nil, err
+	}
+	buildCtx, modelNames, isModelFile, _, err := collectModels(modelPath, opts.BuildTags, opts.StructsOnly)
+	if err != nil {
+		return nil, err
+	}
+	foldedNames := make(map[string]bool, len(modelNames))
+	for name := range modelNames {
+		foldedNames[strings.ToLower(name)] = true
+	}
+	known := func(name string) bool {
+		return modelNames[name] || (opts.CaseInsensitive && foldedNames[strings.ToLower(name)])
+	}
+	isModel := func(name string) bool {
+		return known(name) || (mapName != nil && known(mapName(name)))
+	}
+	files, err := queryFiles(rootDbDirs, opts.Pattern, opts.Exclude, buildCtx, isModelFile)
+	if err != nil {
+		return nil, err
+	}
+
+	fset := token.NewFileSet()
+	parsed := make(map[string]*ast.File, len(files))
+	// declared holds the package-level names declared per directory and
+	// package, since a type may be declared in a sibling file.
+	declared := make(map[string]map[string]bool)
+	for _, file := range files {
+		f, err := parseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		parsed[file] = f
+		key := filepath.Dir(file) + ":" + f.Name.Name
+		if declared[key] == nil {
+			declared[key] = make(map[string]bool)
+		}
+		for _, name := range declaredNames(f) {
+			declared[key][name] = true
+		}
+	}
+
+	var missing []Missing
+	for _, file := range files {
+		f := parsed[file]
+		if directives.SkipFile(f) || hasDotImport(f) {
+			continue
+		}
+		local := declared[filepath.Dir(file)+":"+f.Name.Name]
+		for _, ident := range typeRefs(f) {
+			if !ident.IsExported() || ident.Obj != nil || local[ident.Name] || isModel(ident.Name) {
+				continue
+			}
+			missing = append(missing, Missing{File: file, Line: fset.Position(ident.Pos()).Line, Name: ident.Name})
+		}
+	}
+	return missing, nil
+}
+
+// declaredNames returns the package-level names f declares, methods
+// excluded.
+func declaredNames(f *ast.File) []string {
+	var names []string
+	for _, decl := range f.Decls {
+		switch d := decl.(type) {
+		case *ast.FuncDecl:
+			if d.Recv == nil {
+				names = append(names, d.Name.Name)
+			}
+		case *ast.GenDecl:
+			for _, spec := range d.Specs {
+				switch s := spec.(type) {
+				case *ast.TypeSpec:
+					names = append(names, s.Name.Name)
+				case *ast.ValueSpec:
+					for _, name := range s.Names {
+						names = append(names, name.Name)
+					}
+				}
+			}
+		}
+	}
+	return names
+}
+
+func hasDotImport(f *ast.File) bool {
+	for _, spec := range f.Imports {
+		if spec.Name != nil && spec.Name.Name == "." {
+			return true
+		}
+	}
+	return false
+}
+
+// typeRefs returns the bare identifiers f uses in type positions, in source
+// order.
+func typeRefs(f *ast.File) []*ast.Ident {
+	seen := make(map[*ast.Ident]bool)
+	var refs []*ast.Ident
+	var collect func(expr ast.Expr)
+	collect = func(expr ast.Expr) {
+		switch e := expr.(type) {
+		case *ast.Ident:
+			if !seen[e] {
+				seen[e] = true
+				refs = append(refs, e)
+			}
+		case *ast.StarExpr:
+			collect(e.X)
+		case *ast.ParenExpr:
+			collect(e.X)
+		case *ast.ArrayType:
+			collect(e.Elt)
+		case *ast.MapType:
+			collect(e.Key)
+			collect(e.Value)
+		case *ast.ChanType:
+			collect(e.Value)
+		case *ast.Ellipsis:
+			collect(e.Elt)
+		case *ast.IndexExpr:
+			collect(e.X)
+			collect(e.Index)
+		case *ast.IndexListExpr:
+			collect(e.X)
+			for _, index := range e.Indices {
+				collect(index)
+			}
+		}
+	}
+	ast.Inspect(f, func(n ast.Node) bool {
+		switch n := n.(type) {
+		case *ast.Field:
+			collect(n.Type)
+		case *ast.ValueSpec:
+			collect(n.Type)
+		case *ast.TypeSpec:
+			collect(n.Type)
+		case *ast.CompositeLit:
+			collect(n.Type)
+		case *ast.TypeAssertExpr:
+			collect(n.Type)
+		}
+		return true
+	})
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].Pos() < refs[j].Pos() })
+	return refs
+}
@@ -0,0 +1,70 @@
+package qualifymodels
+
+import (
+	"go/parser"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckMissing(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models", "models.go")
+	dbDir := filepath.Join(tmpDir, "database")
+	query := filepath.Join(dbDir, "query.sql.go")
+	files := map[string]string{
+		modelFile: "package models\ntype Transaction struct {}\ntype User struct {}\n",
+		query: `package database
+
+import "context"
+
+type GetUserRow struct {
+	ID   int64
+	User User
+	Tags []AccountTag
+}
+
+func (q *Queries) Get(ctx context.Context, arg GetParams) (*Transaction, error) {
+	var rows map[string]Session
+	_ = rows
+	_ = Invoice{}
+	return nil, nil
+}
+
+func First[Model any](items []Model) Model {
+	return items[0]
+}
+
+func Wrap(t NullTransaction) context.Context { return nil }
+`,
+		filepath.Join(dbDir, "db.go"):      "package database\n\ntype Queries struct{}\n\ntype GetParams struct{}\n",
+		filepath.Join(dbDir, "dot.sql.go"): "package database\n\nimport . \"time\"\n\nvar d Duration\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	missing, err := CheckMissing(modelFile, []string{dbDir}, Options{NameTemplate: "strip-prefix:Null"})
+	require.NoError(t, err)
+	require.Equal(t, []Missing{
+		{File: query, Line: 8, Name: "AccountTag"},
+		{File: query, Line: 12, Name: "Session"},
+		{File: query, Line: 14, Name: "Invoice"},
+	}, missing)
+	require.Equal(t, query+":8: AccountTag is not a known model", missing[0].String())
+
+	// nothing was rewritten
+	got, err := os.ReadFile(query)
+	require.NoError(t, err)
+	require.Equal(t, files[query], string(got))
+}
@@ -0,0 +1,55 @@
+package qualifymodels
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// nameTemplate maps a bare identifier found in a query file onto the name of a
+// model type. It is used when the generated code names a type differently from
+// the external models package (e.g. NullTransaction -> Transaction).
+type nameTemplate func(name string) string
+
+// parseNameTemplate parses a --name-template rule. Supported forms are:
+//   - strip-prefix:<prefix>        e.g. strip-prefix:Null
+//   - strip-suffix:<suffix>        e.g. strip-suffix:Row
+//   - regex:<pattern>=><replace>   e.g. regex:^Null(\w+)$=>$1
+//
+// An empty template returns a nil nameTemplate.
+func parseNameTemplate(tmpl string) (nameTemplate, error) {
+	if tmpl == "" {
+		return nil, nil
+	}
+	kind, arg, ok := strings.Cut(tmpl, ":")
+	if !ok || arg == "" {
+		return nil, fmt.Errorf("invalid name template %q: expected <kind>:<argument>", tmpl)
+	}
+	switch kind {
+	case "strip-prefix":
+		return func(name string) string {
+			return strings.TrimPrefix(name, arg)
+		}, nil
+	case "strip-suffix":
+		return func(name string) string {
+			return strings.TrimSuffix(name, arg)
+		}, nil
+	case "regex":
+		pattern, replace, ok := strings.Cut(arg, "=>")
+		if !ok {
+			return nil, fmt.Errorf("invalid name template %q: regex rules must be of the form regex:<pattern>=><replace>", tmpl)
+		}
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid name template %q: %w", tmpl, err)
+		}
+		return func(name string) string {
+			if !re.MatchString(name) {
+				return name
+			}
+			return re.ReplaceAllString(name, replace)
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid name template %q: unknown kind %q", tmpl, kind)
+	}
+}
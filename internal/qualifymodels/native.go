@@ -0,0 +1,55 @@
+package qualifymodels
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"path/filepath"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/directives"
+)
+
+// nativelyQualified reports whether the query files are already qualified
+// natively, as when sqlc generates the queries in a package of their own
+// that imports the models: at least one of files imports modelImport, and
+// none uses a model as a bare type. Files carrying the skip directive or a
+// dot import don't count either way.
+//
+// Returns an error if a file can't be parsed.
+func nativelyQualified(files []string, modelNames map[string]bool, modelImport string) (bool, error) {
+	fset := token.NewFileSet()
+	parsed := make(map[string]*ast.File, len(files))
+	declared := make(map[string]map[string]bool)
+	for _, file := range files {
+		f, err := parseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return false, fmt.Errorf("failed to parse query file %s: %w", file, err)
+		}
+		if directives.SkipFile(f) || hasDotImport(f) {
+			continue
+		}
+		parsed[file] = f
+		key := filepath.Dir(file) + ":" + f.Name.Name
+		if declared[key] == nil {
+			declared[key] = make(map[string]bool)
+		}
+		for _, name := range declaredNames(f) {
+			declared[key][name] = true
+		}
+	}
+
+	imported := false
+	for file, f := range parsed {
+		if _, ok := importedAs(f, modelImport); ok {
+			imported = true
+		}
+		local := declared[filepath.Dir(file)+":"+f.Name.Name]
+		for _, ident := range typeRefs(f) {
+			if ident.Obj == nil && !local[ident.Name] && modelNames[ident.Name] {
+				return false, nil
+			}
+		}
+	}
+	return imported, nil
+}
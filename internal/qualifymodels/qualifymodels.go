@@ -1,17 +1,37 @@
 package qualifymodels
 
 import (
+	"bytes"
+	"crypto/sha256"
 	"fmt"
 	"go/ast"
+	"go/build"
 	"go/format"
 	"go/parser"
 	"go/token"
+	"go/types"
+	"io"
 	"io/fs"
+	"maps"
 	"os"
 	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
 
+	"github.com/seanhuebl/sqlc-qol/v2/internal/archive"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/audit"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/directives"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/incremental"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/modcheck"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pending"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pipeline"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pruneimports"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/sizereport"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/writable"
 	"golang.org/x/tools/go/ast/astutil"
 )
 
@@ -20,115 +40,606 @@ var (
 	createFile = os.Create
 	formatNode = format.Node
 	walkDir    = filepath.WalkDir
+	readDir    = os.ReadDir
+	readFile   = os.ReadFile
+
+	stdout io.Writer = os.Stdout
+	stderr io.Writer = os.Stderr
 )
 
+// Hook is a custom AST transform run on every processed file after the
+// built-in transform and before the file is formatted and written; see
+// pipeline.Hook. Run calls it on up to Options.Jobs files at once.
+type Hook = pipeline.Hook
+
+// Options holds the optional settings for Run. The zero value keeps the
+// default behavior.
+type Options struct {
+	// NameTemplate maps bare identifiers that are not model names onto a
+	// model name before qualifying them (see parseNameTemplate for the
+	// supported rules). The mapped name must exist in the models file.
+	NameTemplate string
+	// CaseInsensitive matches identifiers against model names regardless of
+	// case, qualifying them with the model's declared name. This can
+	// over-match, e.g. a local variable `transaction` is qualified as
+	// models.Transaction.
+	CaseInsensitive bool
+	// StructsOnly restricts the models to the structs and type aliases of
+	// the models file, leaving named non-struct types such as sqlc's enums
+	// (`type Status string`) bare, as earlier versions did.
+	StructsOnly bool
+	// BuildTags, when set, are applied as build constraints: models are
+	// collected from every file of the models package that the tags select
+	// (not only the files modelPath names), and query files the tags
+	// exclude are skipped. The files modelPath names must be selected by
+	// the tags.
+	BuildTags []string
+	// ReplaceAlias, of the form old=new, migrates references already
+	// qualified with a previous alias (db.Transaction) to the models alias
+	// (models.Transaction). new must be the models alias, and both must be
+	// Go identifiers, as checked by config.Config.Validate. The old import
+	// is dropped once nothing refers to it.
+	ReplaceAlias string
+	// Alias, when set, is the name models are qualified with
+	// (alias.Transaction) instead of the last element of modelImport. The
+	// models import is added under that name unless it matches the last
+	// element anyway. It must be a Go identifier, as checked by
+	// config.Config.Validate.
+	Alias string
+	// ResolveAliasTarget qualifies references to a model declared as an
+	// alias of another package's type (`type Account = accounting.Account`)
+	// with that package (accounting.Account) instead of the models package,
+	// adding its import.
+	ResolveAliasTarget bool
+	// Funcs, when set, restricts qualification to references inside the
+	// functions and methods of those names (FuncDecl.Name, so a method is
+	// matched by its name alone). References elsewhere, including top-level
+	// declarations, stay bare.
+	Funcs []string
+	// Pattern, when set, restricts the walk to files whose base name
+	// matches it (filepath.Match syntax, e.g. *.sql.go), leaving other .go
+	// files under the roots untouched.
+	Pattern string
+	// Exclude lists directory names the walk never descends into, on top
+	// of vendor and hidden directories, e.g. testdata.
+	Exclude []string
+	// CheckModule runs a preflight before anything is processed, failing
+	// unless the query dirs, the models file and modelImport all belong to
+	// the module declared by the nearest go.mod (see modcheck.Check).
+	CheckModule bool `json:"-"`
+	// AllowExternal downgrades a failed CheckModule preflight to a warning,
+	// for intentional cross-module setups.
+	AllowExternal bool `json:"-"`
+	// Force processes the query files even when they look natively
+	// qualified (see Run), e.g. to run Hooks or Exec on them.
+	Force bool `json:"-"`
+
+	// Check runs without writing anything: files that would change are
+	// returned in a *pending.Error instead, noting the models import each
+	// would gain. Incremental state and the audit log are neither read nor
+	// written.
+	Check bool `json:"-"`
+	// SizeReport prints the lines and bytes added and removed across the
+	// modified files (the files that would be modified with Check) at the
+	// end of the run.
+	SizeReport bool `json:"-"`
+	// WarnOnReformat prints a warning for every file that would change only
+	// because go/format normalizes it (e.g. spaces hand-edited in place of
+	// tabs), not because of the transform. The file is still rewritten.
+	WarnOnReformat bool `json:"-"`
+	// Strict makes Run fail on a file that changed on disk between being
+	// read and written, instead of skipping it with a warning.
+	Strict bool `json:"-"`
+	// Chmod makes read-only query files writable for their owner instead of
+	// failing the up-front writability check.
+	Chmod bool `json:"-"`
+	// EmitPositions prints a `file:line:col: qualify-models Name` line to
+	// stdout for every identifier qualified, for tooling that jumps to each
+	// change. Usually combined with Check.
+	EmitPositions bool `json:"-"`
+	// ZipOut, when set, is the path of a zip archive receiving the processed
+	// contents of every query file, at its path relative to the working
+	// directory. It is written in check mode too, so CI can collect the
+	// result without touching the working tree.
+	ZipOut string `json:"-"`
+	// ChangedOnly limits ZipOut to the files the run changes.
+	ChangedOnly bool `json:"-"`
+
+	// Incremental skips files whose content hasn't changed since the last
+	// run recorded in StateFile. Any change to the run's inputs invalidates
+	// the state and forces a full pass.
+	Incremental bool
+	// StateFile is the path of the incremental state file.
+	StateFile string
+	// ResetIncremental discards the recorded state, forcing a full pass.
+	ResetIncremental bool
+	// AuditLog, when set, is a JSON-lines file receiving one record per
+	// change (operation, file, identifier, position, run timestamp).
+	AuditLog string `json:"-"`
+	// AuditTruncate truncates AuditLog at the start of the run instead of
+	// appending to it.
+	AuditTruncate bool `json:"-"`
+
+	// Hooks run in order on every processed file, concurrently on
+	// different files when Jobs allows. The first error aborts the run; the
+	// files before the failing one in walk order stay written.
+	Hooks []Hook `json:"-"`
+	// Exec is an external command each processed file is piped through
+	// after Hooks, see exectransform.Hook.
+	Exec string
+	// Jobs is the number of files read, qualified and formatted at once;
+	// zero uses GOMAXPROCS. The results are written one file at a time, in
+	// walk order, each as soon as the files before it are written.
+	Jobs int `json:"-"`
+}
+
+// prepared is a query file read, qualified and formatted by a Run worker,
+// waiting to be recorded and written.
+type prepared struct {
+	file    string
+	src     []byte
+	sum     [sha256.Size]byte
+	skipped bool
+	out     []byte
+	refs    []ref
+	// addedImport reports whether the models import was added, as alias.
+	addedImport  bool
+	alias        string
+	reformatOnly bool
+}
+
+// ref is a qualified reference, for the audit log and EmitPositions.
+type ref struct {
+	identifier string
+	position   token.Position
+}
+
 // Run processes Go source files under a given directory and qualifies bare
 // model type references by prefixing them with a package alias and injecting
 // the corresponding import.
 
 // Workflow:
-//   1. Check for native SQLC qualification support; if present, skip processing.
-//   2. Parse the models file at modelPath and collect all struct type names.
-//   3. Derive the package alias from modelImport (last path element).
-//   4. Recursively walk all `.go` files under rootDir, skipping the model file
-//      itself and any vendor or hidden directories.
+//   1. Check for native SQLC qualification; if present, skip processing.
+//      The query files found in step 4 count as natively qualified when at
+//      least one imports modelImport and none uses a model as a bare type,
+//      as when sqlc generates them in their own package. Run then prints a
+//      note and returns nil without touching them, unless opts.Force is set.
+//      The check is skipped with opts.NameTemplate or opts.ReplaceAlias,
+//      which rewrite references it doesn't look at.
+//   2. Parse the models files modelPath names and collect the names of all
+//      the types they declare: structs, named types such as enums and
+//      aliases (only structs and aliases with opts.StructsOnly).
+//   3. Derive the package alias from modelImport (last path element), or
+//      use opts.Alias when set. With opts.CheckModule set, confirm
+//      modelImport lies within the module of the query dirs and matches
+//      the models files' location.
+//   4. Recursively walk all `.go` files under each of rootDbDirs, skipping the model file
+//      itself and any vendor, hidden or opts.Exclude directories. With
+//      opts.Pattern set, only files whose base name matches it are kept.
+//      Unless opts.Check is set, every file found must be writable (see
+//      writable.Ensure).
 //   5. For each discovered file:
-//      a) Parse its AST and traverse all identifiers.
+//      a) Parse its AST and traverse all identifiers. Files carrying a
+//         `// sqlc-qol:skip` directive above the package clause are skipped.
 //      b) When an identifier matches a model name and is not already
-//         part of a selector, replace it with `alias.Identifier`.
-//      c) Ensure the import for modelImport is present.
-//      d) Overwrite the file in place using `go/format`.
+//         part of a selector, a declared name (e.g. an `Error()` method or
+//         field) or a predeclared identifier, replace it with
+//         `alias.Identifier`. This includes type arguments such as
+//         `Page[Transaction]` and composite literal types such as
+//         `return Transaction{ID: id}, nil`, but not the type parameters of
+//         a generic declaration or composite literal keys. Specs and fields
+//         carrying a `// sqlc-qol:ignore` directive are skipped.
+//         With opts.ReplaceAlias set, references qualified with the old
+//         alias are switched to the models alias as well.
+//      c) Ensure the import for modelImport is present, leaving the import
+//         block alone when it already imports it under the package alias
+//         (dropping the old alias's import once unused), then run opts.Hooks
+//         in order and pipe the file through opts.Exec when set.
+//      d) Overwrite the file in place using `go/format`, unless its content
+//         changed on disk since step 5 read it (see Options.Strict).
+//   6. With opts.BuildTags set, models are collected from every file of the
+//      models package selected by the tags, and query files the tags exclude
+//      are skipped in step 4.
+//   7. With opts.Incremental set, files unchanged since the last run recorded
+//      in opts.StateFile are skipped in step 5.
+//   8. With opts.Check set, step 5d is replaced by comparing the formatted
+//      result with the file; the files that would change are returned in a
+//      *pending.Error, along with the import step 5c would add to each.
+//   9. With opts.ZipOut set, the result of step 5 is also packaged into a zip
+//      archive, whether or not step 5d writes it.
 //
 // Parameters:
-//   - modelPath:   Path to the Go source file defining your models.
-//   - rootDbDir:     Directory root in which to search for `.go` files to update.
+//   - modelPath:   Path to the Go source file defining your models, or a
+//     comma-separated list of such files and globs matching them, all in
+//     the models package directory (see modelPathList).
+//   - rootDbDirs:  Directory roots in which to search for `.go` files to update.
+//     Files reachable from more than one root are processed once.
 //   - modelImport: Import path for your external models package.
+//   - opts:        Optional settings, see Options.
 //
 // Returns:
 //   - error: Any error encountered while parsing, walking the directory, or
 //     writing files. Returns nil if native SQLC qualification is enabled or
 //     if all files are successfully processed.
 
-func Run(modelPath, rootDbDir, modelImport string) error {
-	// Create new file set and parse the models file.
-	fset := token.NewFileSet()
-	modelFile, err := parseFile(fset, modelPath, nil, parser.ParseComments)
+func Run(modelPath string, rootDbDirs []string, modelImport string, opts Options) error {
+	mapName, err := parseNameTemplate(opts.NameTemplate)
 	if err != nil {
-		return fmt.Errorf("failed to parse model file: %w", err)
+		return err
+	}
+	hooks, err := pipeline.WithExec(opts.Hooks, opts.Exec)
+	if err != nil {
+		return err
 	}
 
-	// Extract all struct names defined in the models file.
-	modelNames := make(map[string]bool)
-	for _, decl := range modelFile.Decls {
-		genericDecl, ok := decl.(*ast.GenDecl)
-		if !ok || genericDecl.Tok != token.TYPE {
-			continue
+	buildCtx, modelNames, isModelFile, aliases, err := collectModels(modelPath, opts.BuildTags, opts.StructsOnly)
+	if err != nil {
+		return err
+	}
+	if !opts.ResolveAliasTarget {
+		aliases = nil
+	}
+	// resolve returns the declared model name matching name, if any.
+	foldedNames := make(map[string]string, len(modelNames))
+	for name := range modelNames {
+		foldedNames[strings.ToLower(name)] = name
+	}
+	resolve := func(name string) (string, bool) {
+		if modelNames[name] {
+			return name, true
 		}
-		for _, spec := range genericDecl.Specs {
-			typeSpec, ok := spec.(*ast.TypeSpec)
-			if !ok {
-				continue
-			}
-			if _, ok := typeSpec.Type.(*ast.StructType); ok {
-				modelNames[typeSpec.Name.Name] = true
-			}
+		if opts.CaseInsensitive {
+			canonical, ok := foldedNames[strings.ToLower(name)]
+			return canonical, ok
 		}
+		return "", false
 	}
+
 	// Create package alias from the modelImport path
 	pkgAlias := path.Base(modelImport)
+	if opts.Alias != "" {
+		pkgAlias = opts.Alias
+	}
+	oldAlias := ""
+	if opts.ReplaceAlias != "" {
+		// the form of both is checked by config.Config.Validate
+		from, to, _ := strings.Cut(opts.ReplaceAlias, "=")
+		if to != pkgAlias {
+			return fmt.Errorf("invalid replace-alias %q: new alias must be the models alias %q", opts.ReplaceAlias, pkgAlias)
+		}
+		oldAlias = from
+	}
+	var funcs map[string]bool
+	if len(opts.Funcs) > 0 {
+		funcs = make(map[string]bool, len(opts.Funcs))
+		for _, name := range opts.Funcs {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				return fmt.Errorf("invalid funcs %q: empty function name", strings.Join(opts.Funcs, ","))
+			}
+			funcs[name] = true
+		}
+	}
+	jobs := opts.Jobs
+	if jobs < 0 {
+		return fmt.Errorf("invalid jobs %d: must be at least 1", jobs)
+	} else if jobs == 0 {
+		jobs = runtime.GOMAXPROCS(0)
+	}
+	if opts.Pattern != "" {
+		if _, err := filepath.Match(opts.Pattern, ""); err != nil {
+			return fmt.Errorf("invalid pattern %q: %w", opts.Pattern, err)
+		}
+	}
 
-	var files []string
-	if err := walkDir(rootDbDir, func(p string, d fs.DirEntry, err error) error {
+	if opts.CheckModule {
+		// the models files share a directory, so checking one will do
+		paths, err := modelPathList(modelPath)
 		if err != nil {
 			return err
 		}
-		if d.IsDir() || !strings.HasSuffix(p, ".go") {
-			return nil
+		if _, err := modcheck.Check(rootDbDirs, paths[0], modelImport); err != nil {
+			if !opts.AllowExternal {
+				return fmt.Errorf("module preflight failed: %w", err)
+			}
+			fmt.Fprintf(stderr, "warning: %v\n", err)
+		}
+	}
+
+	files, err := queryFiles(rootDbDirs, opts.Pattern, opts.Exclude, buildCtx, isModelFile)
+	if err != nil {
+		return err
+	}
+	if !opts.Force && mapName == nil && oldAlias == "" {
+		native, err := nativelyQualified(files, modelNames, modelImport)
+		if err != nil {
+			return err
 		}
-		if filepath.Clean(p) == filepath.Clean(modelPath) {
+		if native {
+			fmt.Fprintf(stdout, "query files already import %s and use no bare model types (native sqlc qualification); skipped them, pass --force to process them anyway\n", modelImport)
 			return nil
 		}
-		files = append(files, p)
-		return nil
-	}); err != nil {
-		return fmt.Errorf("failed to walkDir %s: %w", rootDbDir, err)
+	}
+	if !opts.Check {
+		fixed, err := writable.Ensure(files, opts.Chmod)
+		for _, file := range fixed {
+			fmt.Fprintf(stdout, "%s: made writable\n", file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	var state *incremental.State
+	if opts.Incremental && !opts.Check {
+		fpOpts := opts
+		fpOpts.ResetIncremental = false
+		fingerprint, err := incremental.Fingerprint(modelNames, rootDbDirs, modelImport, fpOpts)
+		if err != nil {
+			return err
+		}
+		if state, err = incremental.Load(opts.StateFile, fingerprint, opts.ResetIncremental); err != nil {
+			return err
+		}
+	}
+
+	var auditLog *audit.Log
+	if opts.AuditLog != "" && !opts.Check {
+		if auditLog, err = audit.Open(opts.AuditLog, "qualify-models", opts.AuditTruncate); err != nil {
+			return err
+		}
+		defer auditLog.Close()
+	}
+
+	var zipOut *archive.Zip
+	if opts.ZipOut != "" {
+		if zipOut, err = archive.Create(opts.ZipOut, opts.ChangedOnly); err != nil {
+			return err
+		}
+		defer zipOut.Close()
+	}
+	var report *sizereport.Report
+	if opts.SizeReport {
+		report = &sizereport.Report{}
 	}
 
-	// Process the files
-	for _, file := range files {
+	// Process the files: workers read, qualify and format them in
+	// parallel, and each result is recorded and written in walk order as
+	// soon as the files before it are.
+	if state != nil {
+		var changedFiles []string
+		for _, file := range files {
+			changed, err := state.Changed(file)
+			if err != nil {
+				return err
+			}
+			if changed {
+				changedFiles = append(changedFiles, file)
+			}
+		}
+		files = changedFiles
+	}
+	prepare := func(file string) (*prepared, error) {
+		src, err := readFile(file) // #nosec G304 -- file was found under the user's query directories
+		if err != nil {
+			return nil, fmt.Errorf("failed to read query file %s: %w", file, err)
+		}
+		// the hash read here guards the write below against lost updates
+		res := &prepared{file: file, src: src, sum: sha256.Sum256(src)}
 		fsetQuery := token.NewFileSet()
-		queryFile, err := parseFile(fsetQuery, file, nil, parser.ParseComments)
+		queryFile, err := parseFile(fsetQuery, file, src, parser.ParseComments)
 		if err != nil {
-			return fmt.Errorf("failed to parse query file %s: %w", file, err)
+			return nil, fmt.Errorf("failed to parse query file %s: %w", file, err)
+		}
+		if directives.SkipFile(queryFile) {
+			res.skipped = true
+			return res, nil
 		}
 
-		replaced := false
+		replaced, migrated := false, false
+		// another import or declaration may already use the alias
+		fileAlias := uniqueAlias(queryFile, modelImport, pkgAlias, nil)
+		// qualified returns the reference to model name: models.Name, or
+		// the aliased type itself with opts.ResolveAliasTarget, recording
+		// the import it needs. The package of an aliased type is named once
+		// the walk is done, when it is known whether fileAlias is used.
+		aliasTargets := make(map[string]string)
+		aliasRefs := make(map[string][]*ast.Ident)
+		qualified := func(name string, xPos, selPos token.Pos) *ast.SelectorExpr {
+			if target, ok := aliases[name]; ok {
+				x := &ast.Ident{Name: target.Name, NamePos: xPos}
+				aliasTargets[target.Path] = target.Name
+				aliasRefs[target.Path] = append(aliasRefs[target.Path], x)
+				return &ast.SelectorExpr{
+					X:   x,
+					Sel: &ast.Ident{Name: target.Type, NamePos: selPos},
+				}
+			}
+			replaced = true
+			return &ast.SelectorExpr{
+				X:   &ast.Ident{Name: fileAlias, NamePos: xPos},
+				Sel: &ast.Ident{Name: name, NamePos: selPos},
+			}
+		}
+		// the audit log and positions are written in walk order later
+		record := func(identifier string, pos token.Pos) {
+			res.refs = append(res.refs, ref{identifier, fsetQuery.Position(pos)})
+		}
 		// Traverse AST to find bare identifiers that match the model names.
+		// Type parameters of generic declarations shadow model names
+		// within that declaration (e.g. `type Page[Transaction any]`).
+		shadowed := make(map[string]int)
+		// With opts.Funcs, only references inside those functions qualify.
+		currentFunc := ""
 		astutil.Apply(queryFile, func(c *astutil.Cursor) bool {
+			// Skip specs and fields carrying the `// sqlc-qol:ignore` directive
+			if directives.Ignored(c.Node(), c.Parent()) {
+				return false
+			}
+			for _, name := range typeParamNames(c.Node()) {
+				shadowed[name]++
+			}
+			if fd, ok := c.Node().(*ast.FuncDecl); ok {
+				currentFunc = fd.Name.Name
+			}
+			if funcs != nil && !funcs[currentFunc] {
+				return true
+			}
+			// Migrate `old.Model` references to the models alias
+			if sel, ok := c.Node().(*ast.SelectorExpr); ok && oldAlias != "" {
+				if x, ok := sel.X.(*ast.Ident); ok && x.Name == oldAlias && x.Obj == nil {
+					if name, ok := resolve(sel.Sel.Name); ok {
+						record(oldAlias+"."+sel.Sel.Name, sel.Pos())
+						c.Replace(qualified(name, x.Pos(), sel.Sel.Pos()))
+						migrated = true
+						return false
+					}
+				}
+			}
 			ident, ok := c.Node().(*ast.Ident)
-			if !ok {
+			if !ok || shadowed[ident.Name] > 0 {
+				return true
+			}
+			// Declared names (funcs, methods, fields, params, vars, types,
+			// labels) and predeclared identifiers such as `error` are never
+			// type references, even when they spell a model name like Error.
+			if name := c.Name(); name == "Name" || name == "Names" || name == "Label" {
+				return true
+			}
+			// Identifiers resolving to a declaration in this file (a
+			// parameter or variable named Error) are values, not models.
+			if types.Universe.Lookup(ident.Name) != nil || ident.Obj != nil {
 				return true
 			}
 
-			// Check if ident matches one of the model names
-			if modelNames[ident.Name] {
+			// Check if ident matches one of the model names, falling back
+			// to the name template for generated names that differ
+			name, ok := resolve(ident.Name)
+			if !ok && mapName != nil {
+				name, ok = resolve(mapName(ident.Name))
+			}
+			if ok {
 				// If ident is already part of selector expression skip
 				if _, ok := c.Parent().(*ast.SelectorExpr); ok {
 					return true
 				}
-				// Replace bare ident with qualified selector expression (e.g, models.Transaction)
-				newNode := &ast.SelectorExpr{
-					X:   ast.NewIdent(pkgAlias),
-					Sel: ast.NewIdent(ident.Name),
+				// Field keys of composite literals (`Transaction{User: u}`)
+				// name struct fields, not types, and stay bare
+				if kv, ok := c.Parent().(*ast.KeyValueExpr); ok && kv.Key == ident {
+					return true
+				}
+				// A model can't be an ordinary value, so a name in a value
+				// position refers to something else, e.g. a constant
+				// declared in another file of the package
+				if valuePosition(c) {
+					return true
 				}
-				c.Replace(newNode)
-				replaced = true
+				// Replace bare ident with qualified selector expression (e.g, models.Transaction)
+				// Keep the original position so the printer lays out the
+				// surrounding node (e.g. a parameter list) as before.
+				record(ident.Name, ident.Pos())
+				c.Replace(qualified(name, ident.Pos(), ident.Pos()))
+			}
+			return true
+		}, func(c *astutil.Cursor) bool {
+			for _, name := range typeParamNames(c.Node()) {
+				shadowed[name]--
+			}
+			if _, ok := c.Node().(*ast.FuncDecl); ok {
+				currentFunc = ""
 			}
 			return true
-		}, nil)
+		})
 
+		addedImport := false
+		if replaced && !importsAs(queryFile, modelImport, fileAlias) {
+			if fileAlias == path.Base(modelImport) {
+				addedImport = astutil.AddImport(fsetQuery, queryFile, modelImport)
+			} else {
+				addedImport = astutil.AddNamedImport(fsetQuery, queryFile, fileAlias, modelImport)
+			}
+		}
+		// names picked for the imports added to the file
+		picked := make(map[string]bool)
 		if replaced {
-			astutil.AddImport(fsetQuery, queryFile, modelImport)
+			picked[fileAlias] = true
+		}
+		for _, importPath := range slices.Sorted(maps.Keys(aliasTargets)) {
+			local, ok := importedAs(queryFile, importPath)
+			if !ok {
+				local = uniqueAlias(queryFile, importPath, aliasTargets[importPath], picked)
+				picked[local] = true
+			}
+			for _, x := range aliasRefs[importPath] {
+				x.Name = local
+			}
+			if importsAs(queryFile, importPath, local) {
+				continue
+			}
+			if local == pruneimports.AssumedName(importPath) {
+				astutil.AddImport(fsetQuery, queryFile, importPath)
+			} else {
+				astutil.AddNamedImport(fsetQuery, queryFile, local, importPath)
+			}
+		}
+		if migrated {
+			dropUnusedAlias(fsetQuery, queryFile, oldAlias)
+		}
+		if _, err := pipeline.RunHooks(hooks, fsetQuery, queryFile, file); err != nil {
+			return nil, err
+		}
+		var buf bytes.Buffer
+		if err := formatNode(&buf, fsetQuery, queryFile); err != nil {
+			return nil, fmt.Errorf("failed to format file %s: %w", file, err)
+		}
+		res.out = buf.Bytes()
+		res.addedImport, res.alias = addedImport, fileAlias
+		res.reformatOnly = opts.WarnOnReformat && !bytes.Equal(src, res.out) && pipeline.ReformatOnly(src, res.out)
+		return res, nil
+	}
+	var pendingFiles []string
+	importNotes := make(map[string]string)
+	write := func(res *prepared) error {
+		file := res.file
+		if res.skipped {
+			fmt.Fprintf(stdout, "%s: skipped by %s directive\n", file, directives.Skip)
+			return nil
+		}
+		var auditErr error
+		for _, r := range res.refs {
+			if err := auditLog.Record(r.identifier, r.position); err != nil && auditErr == nil {
+				auditErr = err
+			}
+			if opts.EmitPositions {
+				fmt.Fprintf(stdout, "%s:%d:%d: qualify-models %s\n", file, r.position.Line, r.position.Column, r.identifier)
+			}
+		}
+		if auditErr != nil {
+			return auditErr
+		}
+		changed := !bytes.Equal(res.src, res.out)
+		if res.reformatOnly {
+			pipeline.WarnReformat(stderr, file, "qualify-models")
+		}
+		if !opts.Check {
+			if skip, err := pipeline.SkipChanged(file, file, res.sum, opts.Strict, stderr); err != nil {
+				return err
+			} else if skip {
+				return nil
+			}
+		}
+		report.Add(res.src, res.out)
+		if err := zipOut.Add(file, res.out, changed); err != nil {
+			return err
+		}
+		if opts.Check {
+			if changed {
+				pendingFiles = append(pendingFiles, file)
+				if res.addedImport {
+					importNotes[file] = fmt.Sprintf("adds import %q as %s", modelImport, res.alias)
+				}
+			}
+			return nil
 		}
 
 		// This is so the defer happens after each file is processed
@@ -142,10 +653,408 @@ func Run(modelPath, rootDbDir, modelImport string) error {
 			}
 			defer outFile.Close()
 
-			return formatNode(outFile, fsetQuery, queryFile)
+			_, err = outFile.Write(res.out)
+			return err
 		}(); err != nil {
 			return fmt.Errorf("failed to write updated file %s: %w", file, err)
 		}
+		if state != nil {
+			if err := state.Record(file); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	if err := inWalkOrder(files, jobs, prepare, write); err != nil {
+		return err
+	}
+	if err := zipOut.Close(); err != nil {
+		return err
+	}
+	report.Fprint(stdout)
+	if state != nil {
+		return state.Save(opts.StateFile)
+	}
+	if len(pendingFiles) > 0 {
+		return &pending.Error{Operation: "qualify-models", Files: pendingFiles, Notes: importNotes}
+	}
+	return nil
+}
+
+// inWalkOrder runs prepare on files, up to jobs at once, and write on each
+// result in the order of files as soon as those before it are written. At
+// most jobs results are held at a time, so memory doesn't grow with the
+// number of files. The first error from either stops the files not yet
+// started and is returned once the running ones are done; the files before
+// it stay written.
+func inWalkOrder(files []string, jobs int, prepare func(string) (*prepared, error), write func(*prepared) error) error {
+	type outcome struct {
+		res *prepared
+		err error
+	}
+	// the writer holds one outcome and the window the others, jobs in all
+	window := make(chan chan outcome, jobs-1)
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	go func() {
+		defer close(window)
+		for _, file := range files {
+			done := make(chan outcome, 1)
+			select {
+			case window <- done:
+			case <-stop:
+				return
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				res, err := prepare(file)
+				done <- outcome{res, err}
+			}()
+		}
+	}()
+	defer func() {
+		close(stop)
+		for range window {
+		}
+		wg.Wait()
+	}()
+	for done := range window {
+		o := <-done
+		if o.err != nil {
+			return o.err
+		}
+		if err := write(o.res); err != nil {
+			return err
+		}
 	}
 	return nil
 }
+
+// modelPathList returns the models files modelPath names: a single path, or
+// a comma-separated list of paths and globs (filepath.Glob syntax) such as
+// `internal/models/*.go`. The files must all be in one directory, the
+// models package. An existing file is taken as is, even if its name holds
+// a comma.
+func modelPathList(modelPath string) ([]string, error) {
+	if _, err := os.Stat(modelPath); err == nil {
+		return []string{modelPath}, nil
+	}
+	var paths []string
+	for _, entry := range strings.Split(modelPath, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.ContainsAny(entry, "*?[") {
+			paths = append(paths, entry)
+			continue
+		}
+		matches, err := filepath.Glob(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid models glob %q: %w", entry, err)
+		}
+		matches = slices.DeleteFunc(matches, func(p string) bool {
+			return !strings.HasSuffix(p, ".go") || strings.HasSuffix(p, "_test.go")
+		})
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no models file matches %q", entry)
+		}
+		paths = append(paths, matches...)
+	}
+	if len(paths) == 0 {
+		return nil, fmt.Errorf("no models file given in %q", modelPath)
+	}
+	var unique []string
+	for _, p := range paths {
+		if filepath.Dir(p) != filepath.Dir(paths[0]) {
+			return nil, fmt.Errorf("models files %s and %s are in different directories; they must all belong to the models package", paths[0], p)
+		}
+		if !slices.Contains(unique, p) {
+			unique = append(unique, p)
+		}
+	}
+	return unique, nil
+}
+
+// modelFiles returns the files to collect models from. Without build tags
+// those are the files modelPath names (see modelPathList). With tags, it
+// returns the build context applying them along with those files and every
+// other non-test file of their package directory that the tags select.
+func modelFiles(modelPath string, tags []string) (*build.Context, []string, error) {
+	paths, err := modelPathList(modelPath)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(tags) == 0 {
+		return nil, paths, nil
+	}
+	ctx := build.Default
+	ctx.BuildTags = tags
+	dir := filepath.Dir(paths[0])
+	for _, p := range paths {
+		match, err := ctx.MatchFile(dir, filepath.Base(p))
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read build constraints of %s: %w", p, err)
+		}
+		if !match {
+			return nil, nil, fmt.Errorf("model file %s is excluded by build tags %v", p, tags)
+		}
+	}
+	entries, err := readDir(dir)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read model directory %s: %w", dir, err)
+	}
+	files := paths
+	listed := make(map[string]bool, len(paths))
+	for _, p := range paths {
+		listed[filepath.Base(p)] = true
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") || listed[name] {
+			continue
+		}
+		match, err := ctx.MatchFile(dir, name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read build constraints of %s: %w", filepath.Join(dir, name), err)
+		}
+		if match {
+			files = append(files, filepath.Join(dir, name))
+		}
+	}
+	return &ctx, files, nil
+}
+
+// collectModels parses the models files modelPath names, along with the
+// files of their package selected by tags, and returns the build context for tags (nil
+// without tags), the names of the types they declare (only structs and type
+// aliases with structsOnly), the set of files read and the targets of the
+// aliases referring to imported packages.
+func collectModels(modelPath string, tags []string, structsOnly bool) (*build.Context, map[string]bool, map[string]bool, map[string]aliasTarget, error) {
+	buildCtx, modelPaths, err := modelFiles(modelPath, tags)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+
+	// Create new file set and parse the models files.
+	fset := token.NewFileSet()
+	// Extract all type names defined in the models files.
+	modelNames := make(map[string]bool)
+	aliases := make(map[string]aliasTarget)
+	isModelFile := make(map[string]bool, len(modelPaths))
+	for _, p := range modelPaths {
+		isModelFile[filepath.Clean(p)] = true
+		modelFile, err := parseFile(fset, p, nil, parser.ParseComments)
+		if err != nil {
+			return nil, nil, nil, nil, fmt.Errorf("failed to parse model file: %w", err)
+		}
+		for _, decl := range modelFile.Decls {
+			genericDecl, ok := decl.(*ast.GenDecl)
+			if !ok || genericDecl.Tok != token.TYPE {
+				continue
+			}
+			for _, spec := range genericDecl.Specs {
+				typeSpec, ok := spec.(*ast.TypeSpec)
+				if !ok {
+					continue
+				}
+				if typeSpec.Assign.IsValid() {
+					modelNames[typeSpec.Name.Name] = true
+					if target, ok := aliasTargetOf(modelFile, typeSpec); ok {
+						aliases[typeSpec.Name.Name] = target
+					}
+				} else if _, ok := typeSpec.Type.(*ast.StructType); ok || !structsOnly {
+					modelNames[typeSpec.Name.Name] = true
+				}
+			}
+		}
+	}
+	return buildCtx, modelNames, isModelFile, aliases, nil
+}
+
+// queryFiles walks rootDbDirs and returns the .go files to process: those
+// whose base name matches pattern (when set) and buildCtx selects (when
+// non-nil), excluding the models files. Directories below a root named
+// vendor, starting with a dot or listed in exclude aren't descended into.
+// With several roots, the number of files found under each is printed.
+func queryFiles(rootDbDirs []string, pattern string, exclude []string, buildCtx *build.Context, isModelFile map[string]bool) ([]string, error) {
+	var files []string
+	seen := make(map[string]bool)
+	for _, rootDbDir := range rootDbDirs {
+		found := 0
+		if err := walkDir(rootDbDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.IsDir() {
+				name := d.Name()
+				if p != rootDbDir && (name == "vendor" || strings.HasPrefix(name, ".") || slices.Contains(exclude, name)) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if !strings.HasSuffix(p, ".go") {
+				return nil
+			}
+			if pattern != "" {
+				if match, _ := filepath.Match(pattern, filepath.Base(p)); !match {
+					return nil
+				}
+			}
+			if isModelFile[filepath.Clean(p)] {
+				return nil
+			}
+			if buildCtx != nil {
+				match, err := buildCtx.MatchFile(filepath.Dir(p), filepath.Base(p))
+				if err != nil {
+					return err
+				}
+				if !match {
+					return nil
+				}
+			}
+			found++
+			// overlapping roots can reach the same file twice
+			if seen[filepath.Clean(p)] {
+				return nil
+			}
+			seen[filepath.Clean(p)] = true
+			files = append(files, p)
+			return nil
+		}); err != nil {
+			return nil, fmt.Errorf("failed to walkDir %s: %w", rootDbDir, err)
+		}
+		if len(rootDbDirs) > 1 {
+			fmt.Fprintf(stdout, "%s: %d files\n", rootDbDir, found)
+		}
+	}
+	return files, nil
+}
+
+// importsAs reports whether f already imports importPath under name, either
+// unaliased, when name is the package name assumed from the path or its last
+// element, or with name as an explicit alias.
+func importsAs(f *ast.File, importPath, name string) bool {
+	for _, spec := range f.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err != nil || p != importPath {
+			continue
+		}
+		if localImportName(spec, importPath) == name || (spec.Name == nil && name == path.Base(importPath)) {
+			return true
+		}
+	}
+	return false
+}
+
+// uniqueAlias returns the name f should refer to importPath by: alias,
+// unless f already gives that name to another import or declares it at the
+// top level, or it is in reserved, in which case the first of alias2,
+// alias3, ... that is free.
+func uniqueAlias(f *ast.File, importPath, alias string, reserved map[string]bool) string {
+	if importsAs(f, importPath, alias) {
+		return alias
+	}
+	taken := maps.Clone(reserved)
+	if taken == nil {
+		taken = make(map[string]bool)
+	}
+	for _, spec := range f.Imports {
+		if p, err := strconv.Unquote(spec.Path.Value); err == nil && p != importPath {
+			taken[localImportName(spec, p)] = true
+		}
+	}
+	free := func(name string) bool {
+		return !taken[name] && (f.Scope == nil || f.Scope.Lookup(name) == nil)
+	}
+	if free(alias) {
+		return alias
+	}
+	for i := 2; ; i++ {
+		if name := alias + strconv.Itoa(i); free(name) {
+			return name
+		}
+	}
+}
+
+// dropUnusedAlias deletes the import f refers to as alias once no selector
+// in f uses it anymore.
+func dropUnusedAlias(fset *token.FileSet, f *ast.File, alias string) {
+	used := false
+	ast.Inspect(f, func(n ast.Node) bool {
+		if sel, ok := n.(*ast.SelectorExpr); ok {
+			if x, ok := sel.X.(*ast.Ident); ok && x.Name == alias && x.Obj == nil {
+				used = true
+			}
+		}
+		return !used
+	})
+	if used {
+		return
+	}
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := ""
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == alias || (name == "" && pruneimports.AssumedName(importPath) == alias) {
+			astutil.DeleteNamedImport(fset, f, name, importPath)
+			return
+		}
+	}
+}
+
+// valuePosition reports whether the node at c is used as a value rather
+// than a type: an operand, an assigned or returned value, a call argument
+// (other than the type passed to new or make), a map key or value, or a
+// composite literal element. Conversions (`Transaction(x)`) and positions
+// that may hold either, like `*X` or a case clause, don't count.
+func valuePosition(c *astutil.Cursor) bool {
+	switch p := c.Parent().(type) {
+	case *ast.AssignStmt, *ast.ReturnStmt, *ast.BinaryExpr, *ast.UnaryExpr,
+		*ast.IncDecStmt, *ast.SendStmt, *ast.RangeStmt, *ast.ExprStmt,
+		*ast.IfStmt, *ast.SwitchStmt, *ast.ForStmt:
+		return true
+	case *ast.ValueSpec:
+		return c.Name() == "Values"
+	case *ast.KeyValueExpr:
+		return c.Name() == "Value"
+	case *ast.CompositeLit:
+		return c.Name() == "Elts"
+	case *ast.CallExpr:
+		if c.Name() != "Args" {
+			return false
+		}
+		if fn, ok := p.Fun.(*ast.Ident); ok && fn.Obj == nil && (fn.Name == "new" || fn.Name == "make") && c.Index() == 0 {
+			return false
+		}
+		return true
+	}
+	return false
+}
+
+// typeParamNames returns the type parameter names declared by a generic
+// type or function declaration.
+func typeParamNames(node ast.Node) []string {
+	var params *ast.FieldList
+	switch n := node.(type) {
+	case *ast.TypeSpec:
+		params = n.TypeParams
+	case *ast.FuncDecl:
+		params = n.Type.TypeParams
+	}
+	if params == nil {
+		return nil
+	}
+	var names []string
+	for _, field := range params.List {
+		for _, name := range field.Names {
+			names = append(names, name.Name)
+		}
+	}
+	return names
+}
@@ -1,14 +1,24 @@
 package qualifymodels
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go/ast"
 	"go/format"
 	"go/parser"
+	"go/token"
+	"go/types"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/audit"
 	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pending"
 	"github.com/stretchr/testify/require"
 )
 
@@ -66,6 +76,309 @@ func Bar() {
 func FooBar() {
 	var U User
 }
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "enum and named non-struct types",
+				ExpectedContent: `package queries
+import "internal/models"
+type GetUsersParams struct {
+	Status models.UserStatus
+	Tags   models.Tags
+}
+func Get(s models.NullUserStatus) models.UserStatus {
+	return models.UserStatus(s.UserStatus)
+}
+`,
+			},
+			ModelContent: `package models
+type UserStatus string
+const UserStatusActive UserStatus = "active"
+type NullUserStatus struct {
+	UserStatus UserStatus
+	Valid      bool
+}
+type Tags []string
+`,
+			QueryContent: `package queries
+type GetUsersParams struct {
+	Status UserStatus
+	Tags   Tags
+}
+func Get(s NullUserStatus) UserStatus {
+	return UserStatus(s.UserStatus)
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "no model references leaves imports untouched",
+				ExpectedContent: `package queries
+import "fmt"
+type Row struct{ ID int64 }
+func Print(r Row) { fmt.Println(r.ID) }
+`,
+			},
+			ModelContent: `package models
+type Transaction struct {}
+`,
+			QueryContent: `package queries
+import "fmt"
+type Row struct{ ID int64 }
+func Print(r Row) { fmt.Println(r.ID) }
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "interface assertion var",
+				ExpectedContent: `package queries
+import "internal/models"
+type Getter interface{ Get() }
+var _ Getter = (*models.Transaction)(nil)
+var _ Getter = models.Transaction{}
+`,
+			},
+			ModelContent: `package models
+type Transaction struct {}
+`,
+			QueryContent: `package queries
+type Getter interface{ Get() }
+var _ Getter = (*Transaction)(nil)
+var _ Getter = Transaction{}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "typed var and const declarations",
+				ExpectedContent: `package queries
+import "internal/models"
+var zero models.Transaction
+var ptr *models.Transaction = nil
+func Foo() {
+	var list []models.Transaction
+	var t = (models.Transaction)(zero)
+	_, _ = list, t
+}
+`,
+			},
+			ModelContent: `package models
+type Transaction struct {}
+`,
+			QueryContent: `package queries
+var zero Transaction
+var ptr *Transaction = nil
+func Foo() {
+	var list []Transaction
+	var t = (Transaction)(zero)
+	_, _ = list, t
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "generic type arguments",
+				ExpectedContent: `package queries
+import "internal/models"
+type Paginated[T any] struct {
+	Items []T
+}
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+func List() Paginated[models.Transaction] {
+	return Paginated[models.Transaction]{}
+}
+func Join() Pair[models.Transaction, *models.User] {
+	return Pair[models.Transaction, *models.User]{}
+}
+func Map[K comparable, V any](m map[K]V) {}
+func Use() {
+	Map[string, models.User](nil)
+}
+`,
+			},
+			ModelContent: `package models
+type Transaction struct {}
+type User struct {}
+`,
+			QueryContent: `package queries
+type Paginated[T any] struct {
+	Items []T
+}
+type Pair[A, B any] struct {
+	First  A
+	Second B
+}
+func List() Paginated[Transaction] {
+	return Paginated[Transaction]{}
+}
+func Join() Pair[Transaction, *User] {
+	return Pair[Transaction, *User]{}
+}
+func Map[K comparable, V any](m map[K]V) {}
+func Use() {
+	Map[string, User](nil)
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "composite literals keep field keys bare",
+				ExpectedContent: `package queries
+import "internal/models"
+func GetTransaction(id int64) (models.Transaction, error) {
+	return models.Transaction{ID: id}, nil
+}
+func GetRow() models.Row {
+	row := models.Row{User: models.User{ID: 1}, Transaction: &models.Transaction{}}
+	rows := []models.Row{{User: models.User{}}}
+	_ = rows
+	return row
+}
+`,
+			},
+			ModelContent: `package models
+type Transaction struct {
+	ID int64
+}
+type User struct {
+	ID int64
+}
+type Row struct {
+	User        User
+	Transaction *Transaction
+}
+`,
+			QueryContent: `package queries
+func GetTransaction(id int64) (Transaction, error) {
+	return Transaction{ID: id}, nil
+}
+func GetRow() Row {
+	row := Row{User: User{ID: 1}, Transaction: &Transaction{}}
+	rows := []Row{{User: User{}}}
+	_ = rows
+	return row
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "model names colliding with builtins and declarations",
+				ExpectedContent: `package queries
+import "internal/models"
+type Result struct {
+	Error models.Error
+	Err   error
+}
+type Failer interface {
+	Error() string
+}
+func (r Result) Error() string {
+	return r.Error.String()
+}
+func Check(Error models.Error) error {
+	var err error = nil
+	_ = Error
+	return err
+}
+`,
+			},
+			ModelContent: `package models
+type Error struct {}
+type String struct {}
+`,
+			QueryContent: `package queries
+type Result struct {
+	Error Error
+	Err   error
+}
+type Failer interface {
+	Error() string
+}
+func (r Result) Error() string {
+	return r.Error.String()
+}
+func Check(Error Error) error {
+	var err error = nil
+	_ = Error
+	return err
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "same-named fields, locals and values stay bare",
+				ExpectedContent: `package queries
+import "internal/models"
+type Row struct {
+	Transaction models.Transaction
+	Kind        Kind
+}
+func Get(r Row) *models.Transaction {
+	t := new(models.Transaction)
+	Transaction := r.Transaction
+	kinds := map[Kind]string{User: "user"}
+	byName := map[string]Kind{"user": User}
+	list := []Kind{User, Kind(User)}
+	if r.Kind == User {
+		_, _, _ = kinds, byName, list
+	}
+	*t = Transaction
+	return t
+}
+`,
+			},
+			ModelContent: `package models
+type Transaction struct {}
+type User struct {}
+`,
+			QueryContent: `package queries
+type Row struct {
+	Transaction Transaction
+	Kind        Kind
+}
+func Get(r Row) *Transaction {
+	t := new(Transaction)
+	Transaction := r.Transaction
+	kinds := map[Kind]string{User: "user"}
+	byName := map[string]Kind{"user": User}
+	list := []Kind{User, Kind(User)}
+	if r.Kind == User {
+		_, _, _ = kinds, byName, list
+	}
+	*t = Transaction
+	return t
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "type parameters shadow model names",
+				ExpectedContent: `package queries
+import "internal/models"
+type Page[Transaction any] struct {
+	Items []Transaction
+}
+func First[User any](items []User) User {
+	return items[0]
+}
+var p Page[models.Transaction]
+`,
+			},
+			ModelContent: `package models
+type Transaction struct {}
+type User struct {}
+`,
+			QueryContent: `package queries
+type Page[Transaction any] struct {
+	Items []Transaction
+}
+func First[User any](items []User) User {
+	return items[0]
+}
+var p Page[Transaction]
 `,
 		},
 		{
@@ -121,7 +434,7 @@ func Foo() {
 				Name:              "simulate format error",
 				ExpectedContent:   "",
 				FormatErr:         true,
-				ExpectedErrSubStr: "failed to write updated file",
+				ExpectedErrSubStr: "failed to format file",
 			},
 			ModelContent: `package models
 type Transaction struct {}
@@ -155,7 +468,7 @@ func Foo() {
 			}
 			parseFile, walkDir, createFile, formatNode = helpers.ExecuteBaseTCErrorsQM(tc.BaseTestCase, parseFile, walkDir, createFile, formatNode)
 
-			err := Run(modelFile, queryFile, "internal/models")
+			err := Run(modelFile, []string{queryFile}, "internal/models", Options{})
 			if tc.ExpectedErrSubStr != "" {
 				require.Contains(t, err.Error(), tc.ExpectedErrSubStr)
 				return
@@ -177,3 +490,1455 @@ func Foo() {
 		})
 	}
 }
+
+// runWithOptions writes the model and query content to a temp dir, runs Run
+// with opts and returns the rewritten query file.
+func runWithOptions(t *testing.T, modelContent, queryContent string, opts Options) (string, error) {
+	t.Helper()
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	createFile = os.Create
+	formatNode = format.Node
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models.go")
+	queryFile := filepath.Join(tmpDir, "query.sql.go")
+	if err := os.WriteFile(modelFile, []byte(modelContent), 0644); err != nil {
+		t.Fatalf("failed to write model file: %v", err)
+	}
+	if err := os.WriteFile(queryFile, []byte(queryContent), 0644); err != nil {
+		t.Fatalf("failed to write query file: %v", err)
+	}
+	if err := Run(modelFile, []string{queryFile}, "internal/models", opts); err != nil {
+		return "", err
+	}
+	got, err := os.ReadFile(queryFile)
+	if err != nil {
+		t.Fatalf("failed to read query file: %v", err)
+	}
+	return string(got), nil
+}
+
+// requireFormatted compares got against the gofmt'd form of expected.
+func requireFormatted(t *testing.T, expected, got string) {
+	t.Helper()
+	formattedExpected, err := format.Source([]byte(expected))
+	if err != nil {
+		t.Fatalf("failed to format expected content with gofmt standards: %v", err)
+	}
+	if diff := cmp.Diff(string(formattedExpected), got); diff != "" {
+		t.Errorf("query file mismatch (-want +got)\n%s", diff)
+	}
+}
+
+func TestRunAliasTarget(t *testing.T) {
+	modelContent := `package models
+
+import (
+	"example.com/app/internal/accounting"
+	acct "example.com/ledger/v2"
+)
+
+type Account = accounting.Account
+type Entry = acct.LedgerEntry
+type ID = int64
+type Transaction struct{}
+`
+	tests := []struct {
+		Name         string
+		QueryContent string
+		Options      Options
+		Expected     string
+	}{
+		{
+			Name: "aliases qualified with the models package by default",
+			QueryContent: `package queries
+func Get(id ID) (Account, []Entry, Transaction) { return Account{}, nil, Transaction{} }
+`,
+			Expected: `package queries
+
+import "internal/models"
+
+func Get(id models.ID) (models.Account, []models.Entry, models.Transaction) {
+	return models.Account{}, nil, models.Transaction{}
+}
+`,
+		},
+		{
+			Name: "alias targets resolved",
+			QueryContent: `package queries
+func Get(id ID) (Account, []Entry, Transaction) { return Account{}, nil, Transaction{} }
+`,
+			Options: Options{ResolveAliasTarget: true},
+			Expected: `package queries
+
+import (
+	"example.com/app/internal/accounting"
+	acct "example.com/ledger/v2"
+	"internal/models"
+)
+
+func Get(id models.ID) (accounting.Account, []acct.LedgerEntry, models.Transaction) {
+	return accounting.Account{}, nil, models.Transaction{}
+}
+`,
+		},
+		{
+			Name: "only alias targets resolved needs no models import",
+			QueryContent: `package queries
+func Get() Account { return Account{} }
+`,
+			Options: Options{ResolveAliasTarget: true},
+			Expected: `package queries
+
+import "example.com/app/internal/accounting"
+
+func Get() accounting.Account { return accounting.Account{} }
+`,
+		},
+		{
+			Name: "existing import of the alias target reused",
+			QueryContent: `package queries
+import ac "example.com/app/internal/accounting"
+var _ = ac.Open
+func Get(db.Entry) Account { return Account{} }
+`,
+			Options: Options{ResolveAliasTarget: true, ReplaceAlias: "db=models"},
+			Expected: `package queries
+
+import (
+	ac "example.com/app/internal/accounting"
+	acct "example.com/ledger/v2"
+)
+
+var _ = ac.Open
+
+func Get(acct.LedgerEntry) ac.Account { return ac.Account{} }
+`,
+		},
+		{
+			Name: "alias target names taken in the file",
+			QueryContent: `package queries
+import "example.com/other/accounting"
+var _ = accounting.Open
+const acct = 1
+func Get(Entry) Account { return Account{} }
+`,
+			Options: Options{ResolveAliasTarget: true},
+			Expected: `package queries
+
+import (
+	accounting2 "example.com/app/internal/accounting"
+	acct2 "example.com/ledger/v2"
+	"example.com/other/accounting"
+)
+
+var _ = accounting.Open
+
+const acct = 1
+
+func Get(acct2.LedgerEntry) accounting2.Account { return accounting2.Account{} }
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			got, err := runWithOptions(t, modelContent, tc.QueryContent, tc.Options)
+			require.NoError(t, err)
+			requireFormatted(t, tc.Expected, got)
+		})
+	}
+}
+
+func TestRunFuncs(t *testing.T) {
+	modelContent := `package models
+type Transaction struct {}
+type User struct {}
+`
+	queryContent := `package queries
+
+type row struct{ T Transaction }
+
+func GetUser(id int) (*User, error) {
+	f := func() Transaction { return Transaction{} }
+	_ = f
+	return &User{}, nil
+}
+
+func (q *Queries) ListUsers() ([]User, error) { return nil, nil }
+
+func Other() Transaction { return Transaction{} }
+`
+	expected := `package queries
+
+import "internal/models"
+
+type row struct{ T Transaction }
+
+func GetUser(id int) (*models.User, error) {
+	f := func() models.Transaction { return models.Transaction{} }
+	_ = f
+	return &models.User{}, nil
+}
+
+func (q *Queries) ListUsers() ([]models.User, error) { return nil, nil }
+
+func Other() Transaction { return Transaction{} }
+`
+	got, err := runWithOptions(t, modelContent, queryContent, Options{Funcs: []string{"GetUser", " ListUsers"}})
+	require.NoError(t, err)
+	requireFormatted(t, expected, got)
+
+	// no reference inside the functions leaves the file and its imports alone
+	got, err = runWithOptions(t, modelContent, queryContent, Options{Funcs: []string{"Missing"}})
+	require.NoError(t, err)
+	require.Equal(t, queryContent, got)
+
+	_, err = runWithOptions(t, modelContent, queryContent, Options{Funcs: []string{"GetUser", ""}})
+	require.EqualError(t, err, `invalid funcs "GetUser,": empty function name`)
+}
+
+func TestRunNameTemplate(t *testing.T) {
+	modelContent := `package models
+type Transaction struct {}
+type User struct {}
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		Template     string
+		QueryContent string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "strip prefix",
+				ExpectedContent: `package queries
+import "internal/models"
+func Foo() {
+	var T models.Transaction
+	var U models.User
+}
+`,
+			},
+			Template: "strip-prefix:Null",
+			QueryContent: `package queries
+func Foo() {
+	var T NullTransaction
+	var U User
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "strip suffix",
+				ExpectedContent: `package queries
+import "internal/models"
+func Foo() {
+	var T models.Transaction
+}
+`,
+			},
+			Template: "strip-suffix:Row",
+			QueryContent: `package queries
+func Foo() {
+	var T TransactionRow
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "regex replace",
+				ExpectedContent: `package queries
+import "internal/models"
+func Foo() {
+	var T models.Transaction
+}
+`,
+			},
+			Template: `regex:^Db(\w+)Record$=>$1`,
+			QueryContent: `package queries
+func Foo() {
+	var T DbTransactionRecord
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "mapped name not in models is left alone",
+				ExpectedContent: `package queries
+func Foo() {
+	var A NullAccount
+}
+`,
+			},
+			Template: "strip-prefix:Null",
+			QueryContent: `package queries
+func Foo() {
+	var A NullAccount
+}
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "unknown kind",
+				ExpectedErrSubStr: "unknown kind",
+			},
+			Template:     "upper:x",
+			QueryContent: "package queries\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "bad regex",
+				ExpectedErrSubStr: "invalid name template",
+			},
+			Template:     "regex:([=>$1",
+			QueryContent: "package queries\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			got, err := runWithOptions(t, modelContent, tc.QueryContent, Options{NameTemplate: tc.Template})
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			} else if err != nil {
+				t.Fatalf("run failed: %v", err)
+			}
+			requireFormatted(t, tc.ExpectedContent, got)
+		})
+	}
+}
+
+func TestRunIgnoreDirective(t *testing.T) {
+	modelContent := `package models
+type Transaction struct {}
+`
+	queryContent := `package queries
+type Row struct {
+	Keep Transaction // sqlc-qol:ignore
+	Move Transaction
+}
+func Foo() {
+	var T Transaction // sqlc-qol:ignore
+	var U Transaction
+}
+`
+	expected := `package queries
+import "internal/models"
+type Row struct {
+	Keep Transaction // sqlc-qol:ignore
+	Move models.Transaction
+}
+func Foo() {
+	var T Transaction // sqlc-qol:ignore
+	var U models.Transaction
+}
+`
+	got, err := runWithOptions(t, modelContent, queryContent, Options{})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	requireFormatted(t, expected, got)
+}
+
+func TestRunSkipDirective(t *testing.T) {
+	var created []string
+	createFile = func(name string) (*os.File, error) {
+		created = append(created, name)
+		return os.Create(name)
+	}
+	defer func() { createFile = os.Create }()
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	formatNode = format.Node
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models.go")
+	queryFile := filepath.Join(tmpDir, "query.sql.go")
+	queryContent := `// Code generated by sqlc. DO NOT EDIT.
+// sqlc-qol:skip
+
+package queries
+
+var T Transaction
+`
+	if err := os.WriteFile(modelFile, []byte("package models\ntype Transaction struct {}\n"), 0644); err != nil {
+		t.Fatalf("failed to write model file: %v", err)
+	}
+	if err := os.WriteFile(queryFile, []byte(queryContent), 0644); err != nil {
+		t.Fatalf("failed to write query file: %v", err)
+	}
+	require.NoError(t, Run(modelFile, []string{queryFile}, "internal/models", Options{}))
+	require.Empty(t, created)
+	require.Equal(t, queryFile+": skipped by sqlc-qol:skip directive\n", out.String())
+}
+
+func TestRunCheck(t *testing.T) {
+	var created []string
+	createFile = func(name string) (*os.File, error) {
+		created = append(created, name)
+		return os.Create(name)
+	}
+	defer func() { createFile = os.Create }()
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	formatNode = format.Node
+	readFile = os.ReadFile
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models.go")
+	queryFile := filepath.Join(tmpDir, "query.sql.go")
+	queryContent := "package queries\n\nvar T Transaction\n"
+	if err := os.WriteFile(modelFile, []byte("package models\ntype Transaction struct {}\n"), 0644); err != nil {
+		t.Fatalf("failed to write model file: %v", err)
+	}
+	if err := os.WriteFile(queryFile, []byte(queryContent), 0644); err != nil {
+		t.Fatalf("failed to write query file: %v", err)
+	}
+
+	err := Run(modelFile, []string{queryFile}, "internal/models", Options{Check: true})
+	var pendingErr *pending.Error
+	require.ErrorAs(t, err, &pendingErr)
+	require.Equal(t, []string{queryFile}, pendingErr.Files)
+	require.Equal(t, map[string]string{queryFile: `adds import "internal/models" as models`}, pendingErr.Notes)
+	require.Contains(t, pendingErr.Error(), queryFile+` (adds import "internal/models" as models)`)
+	require.Empty(t, created)
+	got, err := os.ReadFile(queryFile)
+	require.NoError(t, err)
+	require.Equal(t, queryContent, string(got))
+
+	require.NoError(t, Run(modelFile, []string{queryFile}, "internal/models", Options{}))
+	require.NoError(t, Run(modelFile, []string{queryFile}, "internal/models", Options{Check: true}))
+}
+
+func TestRunZipOut(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	formatNode = format.Node
+	readFile = os.ReadFile
+	createFile = os.Create
+
+	t.Chdir(t.TempDir())
+	if err := os.Mkdir("db", 0755); err != nil {
+		t.Fatalf("failed to create query dir: %v", err)
+	}
+	queryContent := "package queries\n\nvar T Transaction\n"
+	otherContent := "package queries\n\nvar N int\n"
+	for name, content := range map[string]string{
+		"models.go":       "package models\ntype Transaction struct {}\n",
+		"db/query.sql.go": queryContent,
+		"db/other.sql.go": otherContent,
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+	qualified := "package queries\n\nimport \"internal/models\"\n\nvar T models.Transaction\n"
+
+	err := Run("models.go", []string{"db"}, "internal/models", Options{Check: true, ZipOut: "changed.zip", ChangedOnly: true})
+	var pendingErr *pending.Error
+	require.ErrorAs(t, err, &pendingErr)
+	got, err := os.ReadFile("db/query.sql.go")
+	require.NoError(t, err)
+	require.Equal(t, queryContent, string(got))
+	archived, err := helpers.ReadZip("changed.zip")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"db/query.sql.go": qualified}, archived)
+
+	require.NoError(t, Run("models.go", []string{"db"}, "internal/models", Options{ZipOut: "all.zip"}))
+	got, err = os.ReadFile("db/query.sql.go")
+	require.NoError(t, err)
+	require.Equal(t, qualified, string(got))
+	archived, err = helpers.ReadZip("all.zip")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{"db/query.sql.go": qualified, "db/other.sql.go": otherContent}, archived)
+}
+
+func TestRunCheckModule(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	formatNode = format.Node
+	createFile = os.Create
+	var warnings bytes.Buffer
+	stderr = &warnings
+	defer func() { stderr = os.Stderr }()
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "internal", "models", "models.go")
+	queryDir := filepath.Join(tmpDir, "internal", "database")
+	queryFile := filepath.Join(queryDir, "query.sql.go")
+	for name, content := range map[string]string{
+		filepath.Join(tmpDir, "go.mod"): "module example.com/app\n",
+		modelFile:                       "package models\ntype Transaction struct {}\n",
+		queryFile:                       "package queries\n\nvar T Transaction\n",
+	} {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	err := Run(modelFile, []string{queryDir}, "example.com/other/models", Options{CheckModule: true})
+	require.ErrorContains(t, err, `module preflight failed: import "example.com/other/models" is outside module example.com/app`)
+	got, err := os.ReadFile(queryFile)
+	require.NoError(t, err)
+	require.Equal(t, "package queries\n\nvar T Transaction\n", string(got))
+
+	require.NoError(t, Run(modelFile, []string{queryDir}, "example.com/other/models", Options{CheckModule: true, AllowExternal: true}))
+	require.Contains(t, warnings.String(), `warning: import "example.com/other/models" is outside module example.com/app`)
+
+	warnings.Reset()
+	require.NoError(t, Run(modelFile, []string{queryDir}, "example.com/app/internal/models", Options{CheckModule: true}))
+	require.Empty(t, warnings.String())
+}
+
+func TestRunEmitPositions(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	formatNode = format.Node
+	readFile = os.ReadFile
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models.go")
+	queryFile := filepath.Join(tmpDir, "query.sql.go")
+	queryContent := "package queries\n\nimport db \"example.com/app/internal/db\"\n\nvar T Transaction\n\nfunc Get() (*db.User, error) { return nil, nil }\n"
+	if err := os.WriteFile(modelFile, []byte("package models\ntype Transaction struct {}\ntype User struct {}\n"), 0644); err != nil {
+		t.Fatalf("failed to write model file: %v", err)
+	}
+	if err := os.WriteFile(queryFile, []byte(queryContent), 0644); err != nil {
+		t.Fatalf("failed to write query file: %v", err)
+	}
+
+	err := Run(modelFile, []string{queryFile}, "internal/models", Options{Check: true, EmitPositions: true, ReplaceAlias: "db=models"})
+	var pendingErr *pending.Error
+	require.ErrorAs(t, err, &pendingErr)
+	require.Equal(t, queryFile+":5:7: qualify-models Transaction\n"+queryFile+":7:14: qualify-models db.User\n", out.String())
+	got, err := os.ReadFile(queryFile)
+	require.NoError(t, err)
+	require.Equal(t, queryContent, string(got))
+}
+
+func TestRunReadOnly(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	formatNode = format.Node
+	readFile = os.ReadFile
+	var created []string
+	createFile = func(name string) (*os.File, error) {
+		created = append(created, name)
+		return os.Create(name)
+	}
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { createFile, stdout = os.Create, os.Stdout }()
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models.go")
+	queryFile := filepath.Join(tmpDir, "query.sql.go")
+	otherFile := filepath.Join(tmpDir, "other.sql.go")
+	queryContent := "package queries\n\nvar T Transaction\n"
+	if err := os.WriteFile(modelFile, []byte("package models\ntype Transaction struct {}\n"), 0644); err != nil {
+		t.Fatalf("failed to write model file: %v", err)
+	}
+	for _, file := range []string{queryFile, otherFile} {
+		if err := os.WriteFile(file, []byte(queryContent), 0644); err != nil {
+			t.Fatalf("failed to write query file: %v", err)
+		}
+	}
+	if err := os.Chmod(queryFile, 0444); err != nil {
+		t.Fatalf("failed to chmod query file: %v", err)
+	}
+
+	err := Run(modelFile, []string{tmpDir}, "internal/models", Options{})
+	require.EqualError(t, err, "1 file is not writable (make them writable or pass --chmod):\n  "+queryFile+": read-only (mode -r--r--r--)")
+	require.Empty(t, created)
+
+	err = Run(modelFile, []string{tmpDir}, "internal/models", Options{Chmod: true})
+	require.NoError(t, err)
+	require.Contains(t, out.String(), queryFile+": made writable\n")
+	got, err := os.ReadFile(queryFile)
+	require.NoError(t, err)
+	requireFormatted(t, "package queries\n\nimport \"internal/models\"\n\nvar T models.Transaction\n", string(got))
+}
+
+func TestRunConcurrentModification(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	createFile = os.Create
+	formatNode = format.Node
+	var warnings bytes.Buffer
+	stderr = &warnings
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models.go")
+	queryFile := filepath.Join(tmpDir, "query.sql.go")
+	if err := os.WriteFile(modelFile, []byte("package models\ntype Transaction struct {}\n"), 0644); err != nil {
+		t.Fatalf("failed to write model file: %v", err)
+	}
+	newer := "package queries\n\nvar T, U Transaction\n"
+	reset := func() {
+		if err := os.WriteFile(queryFile, []byte("package queries\n\nvar T Transaction\n"), 0644); err != nil {
+			t.Fatalf("failed to write query file: %v", err)
+		}
+	}
+	// another process rewrites the query file right after it is read
+	readFile = func(name string) ([]byte, error) {
+		data, err := os.ReadFile(name)
+		if err == nil && name == queryFile && !strings.Contains(string(data), "U") {
+			return data, os.WriteFile(name, []byte(newer), 0644)
+		}
+		return data, err
+	}
+	defer func() { readFile, stderr = os.ReadFile, os.Stderr }()
+
+	reset()
+	require.NoError(t, Run(modelFile, []string{tmpDir}, "internal/models", Options{}))
+	require.Contains(t, warnings.String(), "warning: "+queryFile+" changed on disk since it was read; skipped it")
+	got, err := os.ReadFile(queryFile)
+	require.NoError(t, err)
+	require.Equal(t, newer, string(got))
+
+	reset()
+	err = Run(modelFile, []string{tmpDir}, "internal/models", Options{Strict: true})
+	require.EqualError(t, err, queryFile+" changed on disk since it was read; refusing to overwrite it")
+	got, err = os.ReadFile(queryFile)
+	require.NoError(t, err)
+	require.Equal(t, newer, string(got))
+}
+
+func TestRunSizeReport(t *testing.T) {
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	modelContent := "package models\ntype Transaction struct {}\n"
+	queryContent := "package queries\n\nvar T Transaction\n"
+	_, err := runWithOptions(t, modelContent, queryContent, Options{SizeReport: true})
+	require.NoError(t, err)
+	// adds the import and a blank line, qualifies the var
+	require.Equal(t, "size report: 1 files changed, +3/-1 lines, +51/-18 bytes (net +33 bytes)\n", out.String())
+}
+
+func TestRunWarnOnReformat(t *testing.T) {
+	var warnings bytes.Buffer
+	stderr = &warnings
+	defer func() { stderr = os.Stderr }()
+
+	modelContent := "package models\ntype Transaction struct {}\n"
+	tests := []struct {
+		Name         string
+		QueryContent string
+		Warned       bool
+	}{
+		{Name: "only reformatted", QueryContent: "package queries\n\nvar  T int\n", Warned: true},
+		{Name: "reformatted and qualified", QueryContent: "package queries\n\nvar  T Transaction\n"},
+		{Name: "already formatted", QueryContent: "package queries\n\nvar T int\n"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			warnings.Reset()
+			_, err := runWithOptions(t, modelContent, tc.QueryContent, Options{WarnOnReformat: true})
+			require.NoError(t, err)
+			if tc.Warned {
+				require.Contains(t, warnings.String(), "only changes by gofmt normalization, not by qualify-models")
+			} else {
+				require.Empty(t, warnings.String())
+			}
+		})
+	}
+}
+
+func TestRunNativeQualification(t *testing.T) {
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	modelContent := "package models\ntype Transaction struct {}\n"
+	qualified := `package queries
+
+import "internal/models"
+
+func Get() (models.Transaction, error) {
+	return models.Transaction{}, nil
+}
+`
+	tests := []struct {
+		Name         string
+		QueryContent string
+		Force        bool
+		Skipped      bool
+	}{
+		{Name: "natively qualified", QueryContent: qualified, Skipped: true},
+		{Name: "forced", QueryContent: qualified, Force: true},
+		{
+			Name:         "bare reference left",
+			QueryContent: strings.Replace(qualified, "(models.Transaction, error)", "(Transaction, error)", 1),
+		},
+		{
+			Name:         "models not imported",
+			QueryContent: "package queries\n\nfunc Get() error { return nil }\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			out.Reset()
+			hooked := false
+			hook := func(fset *token.FileSet, f *ast.File) (bool, error) {
+				hooked = true
+				return false, nil
+			}
+			got, err := runWithOptions(t, modelContent, tc.QueryContent, Options{Force: tc.Force, Hooks: []Hook{hook}})
+			require.NoError(t, err)
+			require.Equal(t, !tc.Skipped, hooked)
+			if tc.Skipped {
+				require.Equal(t, "query files already import internal/models and use no bare model types (native sqlc qualification); skipped them, pass --force to process them anyway\n", out.String())
+				require.Equal(t, tc.QueryContent, got)
+			} else {
+				require.NotContains(t, out.String(), "native sqlc qualification")
+				require.NotContains(t, got, " Transaction")
+			}
+		})
+	}
+}
+
+func TestRunStructsOnly(t *testing.T) {
+	modelContent := `package models
+type UserStatus string
+type Transaction struct {}
+type ID = int64
+`
+	got, err := runWithOptions(t, modelContent, `package queries
+func Get(id ID, s UserStatus) Transaction { return Transaction{} }
+`, Options{StructsOnly: true})
+	require.NoError(t, err)
+	requireFormatted(t, `package queries
+
+import "internal/models"
+
+func Get(id models.ID, s UserStatus) models.Transaction { return models.Transaction{} }
+`, got)
+}
+
+func TestRunAlias(t *testing.T) {
+	modelContent := `package models
+type Transaction struct {}
+`
+	tests := []struct {
+		Name              string
+		Alias             string
+		QueryContent      string
+		Expected          string
+		ExpectedErrSubStr string
+	}{
+		{
+			Name:  "named import added",
+			Alias: "dbmodels",
+			QueryContent: `package queries
+import "example.com/other/models"
+var _ models.User
+func Get() Transaction { return Transaction{} }
+`,
+			Expected: `package queries
+
+import (
+	"example.com/other/models"
+	dbmodels "internal/models"
+)
+
+var _ models.User
+
+func Get() dbmodels.Transaction { return dbmodels.Transaction{} }
+`,
+		},
+		{
+			Name:  "alias matching the last element",
+			Alias: "models",
+			QueryContent: `package queries
+func Get() Transaction { return Transaction{} }
+`,
+			Expected: `package queries
+
+import "internal/models"
+
+func Get() models.Transaction { return models.Transaction{} }
+`,
+		},
+		{
+			Name:  "existing aliased import reused",
+			Alias: "dbm",
+			QueryContent: `package queries
+import dbm "internal/models"
+var _ dbm.Transaction
+func Get() Transaction { return Transaction{} }
+`,
+			Expected: `package queries
+
+import dbm "internal/models"
+
+var _ dbm.Transaction
+
+func Get() dbm.Transaction { return dbm.Transaction{} }
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			got, err := runWithOptions(t, modelContent, tc.QueryContent, Options{Alias: tc.Alias})
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			requireFormatted(t, tc.Expected, got)
+		})
+	}
+}
+
+func TestRunAliasCollision(t *testing.T) {
+	modelContent := `package models
+type Transaction struct {}
+`
+	otherContent := `package models
+type User struct {}
+`
+	tests := []struct {
+		Name         string
+		QueryContent string
+		Expected     string
+	}{
+		{
+			Name: "same-named import",
+			QueryContent: `package queries
+import "example.com/other/models"
+var _ models.User
+func Get() Transaction { return Transaction{} }
+`,
+			Expected: `package queries
+
+import (
+	"example.com/other/models"
+	models2 "internal/models"
+)
+
+var _ models.User
+
+func Get() models2.Transaction { return models2.Transaction{} }
+`,
+		},
+		{
+			Name: "first suffix declared",
+			QueryContent: `package queries
+import "example.com/other/models"
+var _ models.User
+var models2 = 2
+func Get() Transaction { return Transaction{} }
+`,
+			Expected: `package queries
+
+import (
+	"example.com/other/models"
+	models3 "internal/models"
+)
+
+var _ models.User
+var models2 = 2
+
+func Get() models3.Transaction { return models3.Transaction{} }
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			got, err := runWithOptions(t, modelContent, tc.QueryContent, Options{})
+			require.NoError(t, err)
+			requireFormatted(t, tc.Expected, got)
+
+			// the result must type-check against both packages
+			fset := token.NewFileSet()
+			deps := map[string]*types.Package{}
+			for importPath, src := range map[string]string{"internal/models": modelContent, "example.com/other/models": otherContent} {
+				f, err := parser.ParseFile(fset, importPath+".go", src, 0)
+				require.NoError(t, err)
+				deps[importPath], err = (&types.Config{}).Check(importPath, fset, []*ast.File{f}, nil)
+				require.NoError(t, err)
+			}
+			f, err := parser.ParseFile(fset, "query.sql.go", got, 0)
+			require.NoError(t, err)
+			conf := types.Config{Importer: importerFunc(func(path string) (*types.Package, error) {
+				if pkg, ok := deps[path]; ok {
+					return pkg, nil
+				}
+				return nil, fmt.Errorf("unknown package %s", path)
+			})}
+			_, err = conf.Check("queries", fset, []*ast.File{f}, nil)
+			require.NoError(t, err)
+		})
+	}
+}
+
+// importerFunc adapts a function to types.Importer.
+type importerFunc func(path string) (*types.Package, error)
+
+func (f importerFunc) Import(path string) (*types.Package, error) { return f(path) }
+
+func TestRunJobs(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	createFile = os.Create
+	formatNode = format.Node
+	readFile = os.ReadFile
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models", "models.go")
+	queryDir := filepath.Join(tmpDir, "db")
+	require.NoError(t, os.MkdirAll(filepath.Dir(modelFile), 0755))
+	require.NoError(t, os.MkdirAll(queryDir, 0755))
+	require.NoError(t, os.WriteFile(modelFile, []byte("package models\ntype Transaction struct {}\n"), 0644))
+	var files, positions []string
+	for i := range 20 {
+		file := filepath.Join(queryDir, fmt.Sprintf("q%02d.sql.go", i))
+		require.NoError(t, os.WriteFile(file, []byte("package db\n\nvar T Transaction\n"), 0644))
+		files = append(files, file)
+		positions = append(positions, file+":3:7: qualify-models Transaction\n")
+	}
+
+	err := Run(modelFile, []string{queryDir}, "internal/models", Options{Jobs: 4, Check: true, EmitPositions: true})
+	var pendingErr *pending.Error
+	require.ErrorAs(t, err, &pendingErr)
+	require.Equal(t, files, pendingErr.Files, "files are reported in walk order")
+	require.Equal(t, strings.Join(positions, ""), out.String())
+
+	// a file failing to parse aborts the run before anything is written
+	broken := filepath.Join(queryDir, "q10.sql.go")
+	require.NoError(t, os.WriteFile(broken, []byte("package db\n\nvar T Transaction{\n"), 0644))
+	err = Run(modelFile, []string{queryDir}, "internal/models", Options{Jobs: 4})
+	require.ErrorContains(t, err, "failed to parse query file "+broken)
+	got, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	require.Equal(t, "package db\n\nvar T Transaction\n", string(got))
+
+	// a hook failing on a file aborts the run, the files before it being
+	// written
+	require.NoError(t, os.WriteFile(broken, []byte("package db\n\nvar T Transaction\n"), 0644))
+	failing := func(fset *token.FileSet, f *ast.File) (bool, error) {
+		if fset.Position(f.Package).Filename == broken {
+			return false, errors.New("boom")
+		}
+		return false, nil
+	}
+	err = Run(modelFile, []string{queryDir}, "internal/models", Options{Jobs: 4, Hooks: []Hook{failing}})
+	require.EqualError(t, err, "hook 0 failed on "+broken+": boom")
+	for i, file := range files {
+		got, err := os.ReadFile(file)
+		require.NoError(t, err)
+		switch {
+		case i < 10:
+			require.Contains(t, string(got), "var T models.Transaction", file)
+		case i > 10:
+			require.Equal(t, "package db\n\nvar T Transaction\n", string(got), file)
+		}
+	}
+
+	require.EqualError(t, Run(modelFile, []string{queryDir}, "internal/models", Options{Jobs: -1}), "invalid jobs -1: must be at least 1")
+}
+
+func BenchmarkRunJobs(b *testing.B) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	createFile = os.Create
+	formatNode = format.Node
+	readFile = os.ReadFile
+
+	tmpDir := b.TempDir()
+	modelFile := filepath.Join(tmpDir, "models", "models.go")
+	queryDir := filepath.Join(tmpDir, "db")
+	if err := os.MkdirAll(filepath.Dir(modelFile), 0755); err != nil {
+		b.Fatal(err)
+	}
+	if err := os.MkdirAll(queryDir, 0755); err != nil {
+		b.Fatal(err)
+	}
+	var models, query strings.Builder
+	models.WriteString("package models\n")
+	query.WriteString("package db\n")
+	for i := range 50 {
+		fmt.Fprintf(&models, "type Model%d struct{ ID int64 }\n", i)
+		fmt.Fprintf(&query, "func Get%d(id int64) (Model%d, error) {\n\treturn Model%d{ID: id}, nil\n}\n", i, i, i)
+	}
+	if err := os.WriteFile(modelFile, []byte(models.String()), 0644); err != nil {
+		b.Fatal(err)
+	}
+	// a synthetic tree of 200 query files
+	reset := func() {
+		for i := range 200 {
+			dir := filepath.Join(queryDir, fmt.Sprintf("pkg%d", i%10))
+			if err := os.MkdirAll(dir, 0755); err != nil {
+				b.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("q%03d.sql.go", i)), []byte(query.String()), 0644); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+
+	for _, jobs := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("jobs=%d", jobs), func(b *testing.B) {
+			for range b.N {
+				b.StopTimer()
+				reset()
+				b.StartTimer()
+				if err := Run(modelFile, []string{queryDir}, "internal/models", Options{Jobs: jobs}); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+func TestRunMultipleModelFiles(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	createFile = os.Create
+	formatNode = format.Node
+	readFile = os.ReadFile
+
+	tmpDir := t.TempDir()
+	modelsDir := filepath.Join(tmpDir, "models")
+	queryFile := filepath.Join(tmpDir, "db", "query.sql.go")
+	for name, content := range map[string]string{
+		filepath.Join(modelsDir, "models.go"):  "package models\ntype Transaction struct{ Status Status }\n",
+		filepath.Join(modelsDir, "enums.go"):   "package models\ntype Status string\n",
+		filepath.Join(modelsDir, "users.go"):   "package models\ntype User struct{}\n",
+		filepath.Join(tmpDir, "other", "x.go"): "package other\ntype Other struct{}\n",
+	} {
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+	}
+	queryContent := "package db\n\nfunc Get(s Status) (Transaction, User) { return Transaction{}, User{} }\n"
+
+	tests := []struct {
+		Name              string
+		Models            string
+		Expected          string
+		ExpectedErrSubStr string
+	}{
+		{
+			Name:     "list",
+			Models:   filepath.Join(modelsDir, "models.go") + "," + filepath.Join(modelsDir, "enums.go"),
+			Expected: "func Get(s models.Status) (models.Transaction, User) { return models.Transaction{}, User{} }",
+		},
+		{
+			Name:     "glob",
+			Models:   filepath.Join(modelsDir, "*.go"),
+			Expected: "func Get(s models.Status) (models.Transaction, models.User) {\n\treturn models.Transaction{}, models.User{}\n}",
+		},
+		{
+			Name:              "glob matching nothing",
+			Models:            filepath.Join(modelsDir, "*.sql.go"),
+			ExpectedErrSubStr: "no models file matches",
+		},
+		{
+			Name:              "different directories",
+			Models:            filepath.Join(modelsDir, "models.go") + "," + filepath.Join(tmpDir, "other", "x.go"),
+			ExpectedErrSubStr: "are in different directories",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			require.NoError(t, os.MkdirAll(filepath.Dir(queryFile), 0755))
+			require.NoError(t, os.WriteFile(queryFile, []byte(queryContent), 0644))
+			err := Run(tc.Models, []string{filepath.Dir(queryFile)}, "internal/models", Options{})
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			got, err := os.ReadFile(queryFile)
+			require.NoError(t, err)
+			requireFormatted(t, "package db\n\nimport \"internal/models\"\n\n"+tc.Expected+"\n", string(got))
+		})
+	}
+}
+
+func TestRunSkippedDirs(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	createFile = os.Create
+	formatNode = format.Node
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models", "models.go")
+	queryDir := filepath.Join(tmpDir, "db")
+	bare := "package lib\n\nvar T Transaction\n"
+	files := map[string]string{
+		modelFile:                                     "package models\ntype Transaction struct {}\n",
+		filepath.Join(queryDir, "query.sql.go"):       "package db\n\nvar T Transaction\n",
+		filepath.Join(queryDir, "vendor", "lib.go"):   bare,
+		filepath.Join(queryDir, ".cache", "lib.go"):   bare,
+		filepath.Join(queryDir, "testdata", "lib.go"): bare,
+	}
+	for name, content := range files {
+		require.NoError(t, os.MkdirAll(filepath.Dir(name), 0755))
+		require.NoError(t, os.WriteFile(name, []byte(content), 0644))
+	}
+
+	require.NoError(t, Run(modelFile, []string{queryDir}, "internal/models", Options{Exclude: []string{"testdata"}}))
+	got, err := os.ReadFile(filepath.Join(queryDir, "query.sql.go"))
+	require.NoError(t, err)
+	require.Contains(t, string(got), "var T models.Transaction")
+	for _, dir := range []string{"vendor", ".cache", "testdata"} {
+		got, err := os.ReadFile(filepath.Join(queryDir, dir, "lib.go"))
+		require.NoError(t, err)
+		require.Equal(t, bare, string(got), "%s should be left untouched", dir)
+	}
+}
+
+func TestRunReplaceAlias(t *testing.T) {
+	modelContent := `package models
+type Transaction struct {}
+type User struct {}
+`
+	queryContent := `package queries
+import (
+	"context"
+
+	db "example.com/app/internal/db"
+)
+func Get(ctx context.Context) (db.Transaction, *User, error) {
+	var u User
+	return db.Transaction{}, &u, nil
+}
+`
+	expected := `package queries
+import (
+	"context"
+	"internal/models"
+)
+func Get(ctx context.Context) (models.Transaction, *models.User, error) {
+	var u models.User
+	return models.Transaction{}, &u, nil
+}
+`
+	got, err := runWithOptions(t, modelContent, queryContent, Options{ReplaceAlias: "db=models"})
+	require.NoError(t, err)
+	requireFormatted(t, expected, got)
+
+	// the old import stays while non-model references still use it
+	queryContent = `package queries
+import db "example.com/app/internal/db"
+var T db.Transaction
+var Q *db.Queries
+`
+	expected = `package queries
+import (
+	db "example.com/app/internal/db"
+	"internal/models"
+)
+var T models.Transaction
+var Q *db.Queries
+`
+	got, err = runWithOptions(t, modelContent, queryContent, Options{ReplaceAlias: "db=models"})
+	require.NoError(t, err)
+	requireFormatted(t, expected, got)
+
+	_, err = runWithOptions(t, modelContent, queryContent, Options{ReplaceAlias: "db=entities"})
+	require.EqualError(t, err, `invalid replace-alias "db=entities": new alias must be the models alias "models"`)
+}
+
+func TestRunCaseInsensitive(t *testing.T) {
+	modelContent := `package models
+type Transaction struct {}
+type APIKey struct {}
+type Error struct {}
+`
+	queryContent := `package queries
+func Foo() error {
+	var T TRANSACTION
+	var K ApiKey
+	return nil
+}
+`
+	expected := `package queries
+import "internal/models"
+func Foo() error {
+	var T models.Transaction
+	var K models.APIKey
+	return nil
+}
+`
+	got, err := runWithOptions(t, modelContent, queryContent, Options{})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	requireFormatted(t, queryContent, got)
+
+	got, err = runWithOptions(t, modelContent, queryContent, Options{CaseInsensitive: true})
+	if err != nil {
+		t.Fatalf("run failed: %v", err)
+	}
+	requireFormatted(t, expected, got)
+}
+
+func TestRunMultipleDirs(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	formatNode = format.Node
+	var created []string
+	createFile = func(name string) (*os.File, error) {
+		created = append(created, name)
+		return os.Create(name)
+	}
+	defer func() { createFile = os.Create }()
+	var out bytes.Buffer
+	stdout = &out
+	defer func() { stdout = os.Stdout }()
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models", "models.go")
+	dbDir := filepath.Join(tmpDir, "database")
+	readDir := filepath.Join(tmpDir, "readmodels")
+	files := map[string]string{
+		modelFile:                                  "package models\ntype Transaction struct {}\n",
+		filepath.Join(dbDir, "a.sql.go"):           "package database\nvar T Transaction\n",
+		filepath.Join(dbDir, "nested", "b.sql.go"): "package nested\nvar T Transaction\n",
+		filepath.Join(readDir, "c.sql.go"):         "package readmodels\nvar T Transaction\n",
+	}
+	for name, content := range files {
+		if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+			t.Fatalf("failed to create dir: %v", err)
+		}
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	// the nested root overlaps with dbDir and must not be processed twice
+	roots := []string{dbDir, readDir, filepath.Join(dbDir, "nested")}
+	require.NoError(t, Run(modelFile, roots, "internal/models", Options{}))
+	require.ElementsMatch(t, []string{
+		filepath.Join(dbDir, "a.sql.go"),
+		filepath.Join(dbDir, "nested", "b.sql.go"),
+		filepath.Join(readDir, "c.sql.go"),
+	}, created)
+	require.Equal(t, dbDir+": 2 files\n"+readDir+": 1 files\n"+filepath.Join(dbDir, "nested")+": 1 files\n", out.String())
+
+	for _, name := range created {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		require.Contains(t, string(got), "var T models.Transaction")
+	}
+}
+
+func TestRunImportPresent(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	createFile = os.Create
+	formatNode = format.Node
+
+	importBlock := `import (
+	"context"
+	db "internal/database/shared"
+
+	models "internal/models"
+)
+`
+	tests := []struct {
+		Name        string
+		ImportBlock string
+	}{
+		{Name: "explicit default alias", ImportBlock: importBlock},
+		{Name: "unaliased", ImportBlock: strings.Replace(importBlock, `models "internal/models"`, `"internal/models"`, 1)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			tmpDir := t.TempDir()
+			modelFile := filepath.Join(tmpDir, "models.go")
+			queryFile := filepath.Join(tmpDir, "query.sql.go")
+			if err := os.WriteFile(modelFile, []byte("package models\ntype Transaction struct {}\n"), 0644); err != nil {
+				t.Fatalf("failed to write model file: %v", err)
+			}
+			body := "\nvar _ = context.Background\nvar _ db.Queries\nvar U models.Transaction\n"
+			content := "package queries\n\n" + tc.ImportBlock + body + "var T Transaction\n"
+			if err := os.WriteFile(queryFile, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write query file: %v", err)
+			}
+
+			require.NoError(t, Run(modelFile, []string{queryFile}, "internal/models", Options{}))
+			got, err := os.ReadFile(queryFile)
+			require.NoError(t, err)
+			require.Equal(t, "package queries\n\n"+tc.ImportBlock+body+"var T models.Transaction\n", string(got))
+		})
+	}
+}
+
+func TestRunPattern(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	createFile = os.Create
+	formatNode = format.Node
+
+	tmpDir := t.TempDir()
+	modelFile := filepath.Join(tmpDir, "models.go")
+	dbDir := filepath.Join(tmpDir, "database")
+	generated := filepath.Join(dbDir, "query.sql.go")
+	handWritten := filepath.Join(dbDir, "helpers.go")
+	handWrittenContent := "package database\n\nvar T Transaction\n"
+	if err := os.MkdirAll(dbDir, 0755); err != nil {
+		t.Fatalf("failed to create dir: %v", err)
+	}
+	for name, content := range map[string]string{
+		modelFile:   "package models\ntype Transaction struct {}\n",
+		generated:   "package database\n\nvar T Transaction\n",
+		handWritten: handWrittenContent,
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	require.ErrorContains(t, Run(modelFile, []string{dbDir}, "internal/models", Options{Pattern: "["}), `invalid pattern "["`)
+
+	require.NoError(t, Run(modelFile, []string{dbDir}, "internal/models", Options{Pattern: "*.sql.go"}))
+	got, err := os.ReadFile(generated)
+	require.NoError(t, err)
+	require.Contains(t, string(got), "var T models.Transaction")
+	got, err = os.ReadFile(handWritten)
+	require.NoError(t, err)
+	require.Equal(t, handWrittenContent, string(got))
+}
+
+func TestRunBuildTags(t *testing.T) {
+	parseFile = parser.ParseFile
+	walkDir = filepath.WalkDir
+	readDir = os.ReadDir
+	createFile = os.Create
+	formatNode = format.Node
+
+	setup := func(t *testing.T) (string, string, string) {
+		tmpDir := t.TempDir()
+		modelFile := filepath.Join(tmpDir, "models", "models.go")
+		dbDir := filepath.Join(tmpDir, "database")
+		files := map[string]string{
+			modelFile: "package models\ntype Transaction struct {}\n",
+			filepath.Join(tmpDir, "models", "models_pg.go"): "//go:build pg\n\npackage models\ntype PgRow struct {}\n",
+			filepath.Join(dbDir, "query.sql.go"):            "package database\nvar T Transaction\nvar R PgRow\n",
+			filepath.Join(dbDir, "query_sqlite.sql.go"):     "//go:build !pg\n\npackage database\nvar T Transaction\n",
+		}
+		for name, content := range files {
+			if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+				t.Fatalf("failed to create dir: %v", err)
+			}
+			if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+				t.Fatalf("failed to write %s: %v", name, err)
+			}
+		}
+		return modelFile, dbDir, files[filepath.Join(dbDir, "query_sqlite.sql.go")]
+	}
+	read := func(t *testing.T, name string) string {
+		got, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("failed to read %s: %v", name, err)
+		}
+		return string(got)
+	}
+
+	t.Run("tag-guarded model is qualified", func(t *testing.T) {
+		modelFile, dbDir, sqlite := setup(t)
+		require.NoError(t, Run(modelFile, []string{dbDir}, "internal/models", Options{BuildTags: []string{"pg"}}))
+		got := read(t, filepath.Join(dbDir, "query.sql.go"))
+		require.Contains(t, got, "var T models.Transaction")
+		require.Contains(t, got, "var R models.PgRow")
+		// excluded by the tags, left untouched
+		require.Equal(t, sqlite, read(t, filepath.Join(dbDir, "query_sqlite.sql.go")))
+	})
+
+	t.Run("without tags only the models file is read", func(t *testing.T) {
+		modelFile, dbDir, _ := setup(t)
+		require.NoError(t, Run(modelFile, []string{dbDir}, "internal/models", Options{}))
+		got := read(t, filepath.Join(dbDir, "query.sql.go"))
+		require.Contains(t, got, "var R PgRow")
+		require.Contains(t, read(t, filepath.Join(dbDir, "query_sqlite.sql.go")), "var T models.Transaction")
+	})
+
+	t.Run("models file excluded by tags", func(t *testing.T) {
+		modelFile, dbDir, _ := setup(t)
+		pgModels := filepath.Join(filepath.Dir(modelFile), "models_pg.go")
+		err := Run(pgModels, []string{dbDir}, "internal/models", Options{BuildTags: []string{"sqlite"}})
+		require.EqualError(t, err, fmt.Sprintf("model file %s is excluded by build tags [sqlite]", pgModels))
+	})
+}
+
+func TestRunHooks(t *testing.T) {
+	modelContent := `package models
+type Transaction struct {}
+`
+	queryContent := `package queries
+func Foo() {
+	var T Transaction
+}
+`
+	// the hook sees the already qualified AST
+	var sawQualified bool
+	hook := func(fset *token.FileSet, f *ast.File) (bool, error) {
+		ast.Inspect(f, func(n ast.Node) bool {
+			if sel, ok := n.(*ast.SelectorExpr); ok && sel.Sel.Name == "Transaction" {
+				sawQualified = true
+			}
+			return true
+		})
+		f.Name.Name = "database"
+		return true, nil
+	}
+	got, err := runWithOptions(t, modelContent, queryContent, Options{Hooks: []Hook{hook}})
+	require.NoError(t, err)
+	require.True(t, sawQualified)
+	requireFormatted(t, `package database
+import "internal/models"
+func Foo() {
+	var T models.Transaction
+}
+`, got)
+
+	failing := func(fset *token.FileSet, f *ast.File) (bool, error) {
+		return false, fmt.Errorf("boom")
+	}
+	_, err = runWithOptions(t, modelContent, queryContent, Options{Hooks: []Hook{failing}})
+	require.ErrorContains(t, err, "hook 0 failed")
+}
+
+func TestRunAuditLog(t *testing.T) {
+	modelContent := `package models
+type Transaction struct {}
+`
+	queryContent := `package queries
+func Foo() {
+	var T Transaction
+}
+`
+	auditPath := filepath.Join(t.TempDir(), "audit.jsonl")
+	_, err := runWithOptions(t, modelContent, queryContent, Options{AuditLog: auditPath})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(auditPath)
+	require.NoError(t, err)
+	var record audit.Record
+	require.NoError(t, json.Unmarshal(data, &record))
+	require.Equal(t, "qualify-models", record.Operation)
+	require.Equal(t, "Transaction", record.Identifier)
+	require.Equal(t, "query.sql.go", filepath.Base(record.File))
+	require.Equal(t, 3, record.Line)
+	require.Equal(t, 8, record.Column)
+	require.False(t, record.Time.IsZero())
+}
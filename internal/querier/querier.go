@@ -0,0 +1,97 @@
+package querier
+
+import (
+	"fmt"
+	"go/ast"
+	"go/parser"
+	"go/token"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var (
+	parseFile = parser.ParseFile
+	readDir   = os.ReadDir
+)
+
+// Method is an exported method declared on the generated query receiver.
+type Method struct {
+	Name string
+	// Package is the package name of the declaring file.
+	Package string
+	// Type is the method's signature as declared in the generated code.
+	Type *ast.FuncType
+	// Imports maps the package names visible in the declaring file to their
+	// import paths, so types referenced by Type can be resolved.
+	Imports map[string]string
+}
+
+// Collect parses the non-test .go files directly inside dir and returns the
+// exported methods declared on *receiver (or receiver), sorted by name.
+// WithTx is skipped since sqlc leaves it out of the Querier interface.
+func Collect(dir, receiver string) ([]Method, error) {
+	entries, err := readDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	var methods []Method
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, ".go") || strings.HasSuffix(name, "_test.go") {
+			continue
+		}
+		file := filepath.Join(dir, name)
+		f, err := parseFile(token.NewFileSet(), file, nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		imports := Imports(f)
+		for _, decl := range f.Decls {
+			fn, ok := decl.(*ast.FuncDecl)
+			if !ok || fn.Recv == nil || len(fn.Recv.List) != 1 {
+				continue
+			}
+			if !fn.Name.IsExported() || fn.Name.Name == "WithTx" || receiverName(fn.Recv.List[0].Type) != receiver {
+				continue
+			}
+			methods = append(methods, Method{Name: fn.Name.Name, Package: f.Name.Name, Type: fn.Type, Imports: imports})
+		}
+	}
+	sort.Slice(methods, func(i, j int) bool { return methods[i].Name < methods[j].Name })
+	return methods, nil
+}
+
+// Imports maps the package name of every import in f to its path. Blank and
+// dot imports are skipped.
+func Imports(f *ast.File) map[string]string {
+	imports := make(map[string]string)
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		name := path.Base(importPath)
+		if spec.Name != nil {
+			name = spec.Name.Name
+		}
+		if name == "_" || name == "." {
+			continue
+		}
+		imports[name] = importPath
+	}
+	return imports
+}
+
+func receiverName(expr ast.Expr) string {
+	if star, ok := expr.(*ast.StarExpr); ok {
+		expr = star.X
+	}
+	if ident, ok := expr.(*ast.Ident); ok {
+		return ident.Name
+	}
+	return ""
+}
@@ -0,0 +1,104 @@
+package renameimport
+
+import (
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/pruneimports"
+)
+
+var (
+	parseFile  = parser.ParseFile
+	createFile = os.Create
+	formatNode = format.Node
+	walkDir    = filepath.WalkDir
+)
+
+// Run rewrites every import of from (and of packages below it, e.g.
+// from/enums) to the matching path under to, in every .go file under
+// rootDir. Selector expressions are left untouched: an aliased import keeps
+// its alias, and an unaliased import whose package name would change with
+// the new path (internal/models → internal/entities) is given the old name
+// as an explicit alias.
+//
+// Files that don't import from are left byte-for-byte untouched.
+//
+// Returns an error if from or to is empty, the walk fails, or any file can't
+// be parsed or written.
+func Run(rootDir, from, to string) error {
+	if from == "" || to == "" {
+		return fmt.Errorf("both the old and the new import path are required")
+	}
+	var files []string
+	if err := walkDir(rootDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to walkDir %s: %w", rootDir, err)
+	}
+
+	for _, file := range files {
+		fset := token.NewFileSet()
+		f, err := parseFile(fset, file, nil, parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		if !rename(f, from, to) {
+			continue
+		}
+		if err := func() error {
+			outFile, err := createFile(file)
+			if err != nil {
+				return fmt.Errorf("failed to open file %s for writing: %w", file, err)
+			}
+			defer outFile.Close()
+			return formatNode(outFile, fset, f)
+		}(); err != nil {
+			return fmt.Errorf("failed to write updated file %s: %w", file, err)
+		}
+	}
+	return nil
+}
+
+// rename rewrites the imports of from in f and reports whether any changed.
+func rename(f *ast.File, from, to string) bool {
+	changed := false
+	for _, spec := range f.Imports {
+		importPath, err := strconv.Unquote(spec.Path.Value)
+		if err != nil {
+			continue
+		}
+		var newPath string
+		switch {
+		case importPath == from:
+			newPath = to
+		case strings.HasPrefix(importPath, from+"/"):
+			newPath = to + strings.TrimPrefix(importPath, from)
+		default:
+			continue
+		}
+		// keep selectors resolving: pin the old default name if it changes
+		if spec.Name == nil {
+			if oldName := pruneimports.AssumedName(importPath); oldName != pruneimports.AssumedName(newPath) {
+				spec.Name = &ast.Ident{Name: oldName, NamePos: spec.Path.Pos()}
+			}
+		}
+		spec.Path.Value = strconv.Quote(newPath)
+		changed = true
+	}
+	return changed
+}
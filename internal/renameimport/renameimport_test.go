@@ -0,0 +1,147 @@
+package renameimport
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	tests := []struct {
+		helpers.BaseTestCase
+		InitContent string
+		From, To    string
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "default name unchanged",
+				ExpectedContent: `package database
+
+import (
+	"context"
+
+	"github.com/me/app/pkg/models"
+)
+
+func Get(ctx context.Context) models.User {
+	return models.User{}
+}
+`,
+			},
+			InitContent: `package database
+
+import (
+	"context"
+
+	"github.com/me/app/internal/models"
+)
+
+func Get(ctx context.Context) models.User {
+	return models.User{}
+}
+`,
+			From: "github.com/me/app/internal/models",
+			To:   "github.com/me/app/pkg/models",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "alias preserved and subpackages moved",
+				ExpectedContent: `package database
+
+import (
+	m "github.com/me/app/pkg/models"
+	"github.com/me/app/pkg/models/enums"
+)
+
+var _ m.User
+var _ enums.Status
+`,
+			},
+			InitContent: `package database
+
+import (
+	m "github.com/me/app/internal/models"
+	"github.com/me/app/internal/models/enums"
+)
+
+var _ m.User
+var _ enums.Status
+`,
+			From: "github.com/me/app/internal/models",
+			To:   "github.com/me/app/pkg/models",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "changed default name pinned with alias",
+				ExpectedContent: `package database
+
+import models "github.com/me/app/pkg/entities"
+
+var _ models.User
+`,
+			},
+			InitContent: `package database
+
+import "github.com/me/app/internal/models"
+
+var _ models.User
+`,
+			From: "github.com/me/app/internal/models",
+			To:   "github.com/me/app/pkg/entities",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "similar prefix untouched",
+				ExpectedContent: `package database
+
+import "github.com/me/app/internal/modelsx"
+
+var _ modelsx.User
+`,
+			},
+			InitContent: `package database
+
+import "github.com/me/app/internal/modelsx"
+
+var _ modelsx.User
+`,
+			From: "github.com/me/app/internal/models",
+			To:   "github.com/me/app/pkg/models",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "missing paths",
+				ExpectedErrSubStr: "both the old and the new import path are required",
+			},
+			InitContent: "package database\n",
+			From:        "github.com/me/app/internal/models",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "query.sql.go")
+			if err := os.WriteFile(file, []byte(tc.InitContent), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			err := Run(dir, tc.From, tc.To)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if diff := cmp.Diff(tc.ExpectedContent, string(got)); diff != "" {
+				t.Errorf("content mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
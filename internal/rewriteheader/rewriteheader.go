@@ -0,0 +1,158 @@
+package rewriteheader
+
+import (
+	"bytes"
+	"fmt"
+	"go/parser"
+	"go/token"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/seanhuebl/sqlc-qol/v2/internal/directives"
+)
+
+var (
+	parseFile  = parser.ParseFile
+	readFile   = os.ReadFile
+	createFile = os.Create
+	walkDir    = filepath.WalkDir
+
+	stdout io.Writer = os.Stdout
+)
+
+// DefaultPattern matches the standard generated-code header line.
+const DefaultPattern = `^// Code generated .* DO NOT EDIT\.$`
+
+// Options configures Run.
+type Options struct {
+	// Pattern is the regular expression a header comment line must match,
+	// `//` included. Defaults to DefaultPattern.
+	Pattern string
+	// Replacement is the header written in place of each matching line, one
+	// or more `//` comment lines. Empty removes the line.
+	Replacement string
+}
+
+// Run rewrites the header of every .go file under rootDir: each line comment
+// above the package clause matching opts.Pattern is replaced with
+// opts.Replacement, or removed along with the blank line it would leave
+// behind when opts.Replacement is empty. Everything else, including build
+// constraints above the header, is left byte-for-byte untouched; files
+// without a matching line or carrying the sqlc-qol:skip directive aren't
+// rewritten, so the command is idempotent as long as the replacement doesn't
+// contain a line matching the pattern.
+//
+// Returns an error if the pattern doesn't compile, the replacement isn't made
+// of line comments, the walk fails, or any file can't be parsed or written.
+func Run(rootDir string, opts Options) error {
+	if opts.Pattern == "" {
+		opts.Pattern = DefaultPattern
+	}
+	pattern, err := regexp.Compile(opts.Pattern)
+	if err != nil {
+		return fmt.Errorf("invalid header pattern %q: %w", opts.Pattern, err)
+	}
+	if opts.Replacement != "" {
+		for _, line := range strings.Split(opts.Replacement, "\n") {
+			if !strings.HasPrefix(line, "//") {
+				return fmt.Errorf("invalid replacement header line %q: expected a // comment", line)
+			}
+		}
+	}
+
+	var files []string
+	if err := walkDir(rootDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".go") {
+			return nil
+		}
+		files = append(files, p)
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to walkDir %s: %w", rootDir, err)
+	}
+
+	for _, file := range files {
+		fset := token.NewFileSet()
+		f, err := parseFile(fset, file, nil, parser.PackageClauseOnly|parser.ParseComments)
+		if err != nil {
+			return fmt.Errorf("failed to parse file %s: %w", file, err)
+		}
+		if directives.SkipFile(f) {
+			fmt.Fprintf(stdout, "%s: skipped by %s directive\n", file, directives.Skip)
+			continue
+		}
+		var spans [][2]int
+		for _, cg := range f.Comments {
+			if cg.Pos() > f.Package {
+				break
+			}
+			for _, cm := range cg.List {
+				if pattern.MatchString(cm.Text) {
+					spans = append(spans, [2]int{fset.Position(cm.Pos()).Offset, fset.Position(cm.End()).Offset})
+				}
+			}
+		}
+		if len(spans) == 0 {
+			continue
+		}
+		if err := rewrite(file, spans, opts.Replacement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// rewrite replaces the comments at spans (in order) in file with
+// replacement, or removes their lines when replacement is empty, and writes
+// the file if its content changed.
+func rewrite(file string, spans [][2]int, replacement string) error {
+	src, err := readFile(file) // #nosec G304 -- the file was just parsed from this path
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %w", file, err)
+	}
+	var out []byte
+	prev := 0
+	for _, span := range spans {
+		start, end := span[0], span[1]
+		out = append(out, src[prev:start]...)
+		if replacement != "" {
+			out = append(out, replacement...)
+			prev = end
+			continue
+		}
+		// drop the line, and the blank line below it when it would
+		// follow the start of the file or another blank line
+		if end < len(src) && src[end] == '\n' {
+			end++
+		}
+		atParagraph := len(out) == 0 || (len(out) >= 2 && out[len(out)-1] == '\n' && out[len(out)-2] == '\n')
+		if atParagraph && end < len(src) && src[end] == '\n' {
+			end++
+		}
+		prev = end
+	}
+	out = append(out, src[prev:]...)
+	if bytes.Equal(out, src) {
+		return nil
+	}
+	if _, err := parser.ParseFile(token.NewFileSet(), file, out, parser.ParseComments); err != nil {
+		return fmt.Errorf("rewritten header of %s doesn't parse: %w", file, err)
+	}
+
+	outFile, err := createFile(file)
+	if err != nil {
+		return fmt.Errorf("failed to open file %s for writing: %w", file, err)
+	}
+	defer outFile.Close()
+	if _, err := outFile.Write(out); err != nil {
+		return fmt.Errorf("failed to write updated file %s: %w", file, err)
+	}
+	return nil
+}
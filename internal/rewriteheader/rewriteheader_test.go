@@ -0,0 +1,129 @@
+package rewriteheader
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+	"github.com/seanhuebl/sqlc-qol/v2/internal/helpers"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRun(t *testing.T) {
+	sqlcContent := `// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+// source: users.sql
+
+package database
+`
+	tests := []struct {
+		helpers.BaseTestCase
+		InitContent string
+		Opts        Options
+	}{
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "removed from sqlc header",
+				ExpectedContent: `// versions:
+//   sqlc v1.27.0
+// source: users.sql
+
+package database
+`,
+			},
+			InitContent: sqlcContent,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "removed below build constraint",
+				ExpectedContent: `//go:build pg
+
+package database
+`,
+			},
+			InitContent: `//go:build pg
+
+// Code generated by sqlc. DO NOT EDIT.
+
+package database
+`,
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "replaced",
+				ExpectedContent: `// Code generated by sqlc, post-processed by sqlc-qol.
+// versions:
+//   sqlc v1.27.0
+// source: users.sql
+
+package database
+`,
+			},
+			InitContent: sqlcContent,
+			Opts:        Options{Replacement: "// Code generated by sqlc, post-processed by sqlc-qol."},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name: "custom pattern",
+				ExpectedContent: `// Code generated by sqlc. DO NOT EDIT.
+// versions:
+//   sqlc v1.27.0
+
+package database
+`,
+			},
+			InitContent: sqlcContent,
+			Opts:        Options{Pattern: `^// source: `},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:            "hand-written file untouched",
+				ExpectedContent: "// Package database wraps the queries.\npackage database\n",
+			},
+			InitContent: "// Package database wraps the queries.\npackage database\n",
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "invalid pattern",
+				ExpectedErrSubStr: "invalid header pattern",
+			},
+			InitContent: sqlcContent,
+			Opts:        Options{Pattern: "("},
+		},
+		{
+			BaseTestCase: helpers.BaseTestCase{
+				Name:              "replacement not a comment",
+				ExpectedErrSubStr: `invalid replacement header line "Generated"`,
+			},
+			InitContent: sqlcContent,
+			Opts:        Options{Replacement: "// Code generated.\nGenerated"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			file := filepath.Join(dir, "query.sql.go")
+			if err := os.WriteFile(file, []byte(tc.InitContent), 0644); err != nil {
+				t.Fatalf("failed to write file: %v", err)
+			}
+			err := Run(dir, tc.Opts)
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			// a second run changes nothing
+			require.NoError(t, Run(dir, tc.Opts))
+			got, err := os.ReadFile(file)
+			if err != nil {
+				t.Fatalf("failed to read file: %v", err)
+			}
+			if diff := cmp.Diff(tc.ExpectedContent, string(got)); diff != "" {
+				t.Errorf("content mismatch (-want +got):\n%s", diff)
+			}
+		})
+	}
+}
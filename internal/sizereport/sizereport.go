@@ -0,0 +1,70 @@
+package sizereport
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+)
+
+// Report totals how much the files modified by a run grew or shrank. A nil
+// *Report discards changes, so callers don't need to check whether the
+// report is enabled.
+type Report struct {
+	Files        int
+	LinesAdded   int
+	LinesRemoved int
+	BytesAdded   int
+	BytesRemoved int
+}
+
+// Add records the change of one file from before to after. Lines are
+// compared as in a diff that ignores their order: a line of after with no
+// identical line left in before counts as added, and the other way round as
+// removed. Bytes are those of the added and removed lines, newlines
+// included. Unchanged content records nothing.
+func (r *Report) Add(before, after []byte) {
+	if r == nil || bytes.Equal(before, after) {
+		return
+	}
+	r.Files++
+	remaining := make(map[string]int)
+	for _, line := range lines(before) {
+		remaining[string(line)]++
+	}
+	for _, line := range lines(after) {
+		if remaining[string(line)] > 0 {
+			remaining[string(line)]--
+			continue
+		}
+		r.LinesAdded++
+		r.BytesAdded += len(line)
+	}
+	for line, n := range remaining {
+		r.LinesRemoved += n
+		r.BytesRemoved += n * len(line)
+	}
+}
+
+// Fprint writes the report as a single line to w.
+func (r *Report) Fprint(w io.Writer) {
+	if r == nil {
+		return
+	}
+	fmt.Fprintf(w, "size report: %d files changed, +%d/-%d lines, +%d/-%d bytes (net %+d bytes)\n",
+		r.Files, r.LinesAdded, r.LinesRemoved, r.BytesAdded, r.BytesRemoved, r.BytesAdded-r.BytesRemoved)
+}
+
+// lines splits data into lines, each keeping its trailing newline.
+func lines(data []byte) [][]byte {
+	var out [][]byte
+	for len(data) > 0 {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			out = append(out, data)
+			break
+		}
+		out = append(out, data[:i+1])
+		data = data[i+1:]
+	}
+	return out
+}
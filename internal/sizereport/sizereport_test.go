@@ -0,0 +1,30 @@
+package sizereport
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReport(t *testing.T) {
+	report := &Report{}
+	// a trailing comment replaces one line; reordered lines don't count
+	report.Add([]byte("package foo\n\nconst bar = \"x\"\n"), []byte("package foo\n\nconst bar = \"x\" // #nosec\n"))
+	report.Add([]byte("a\nb\n"), []byte("b\na\n"))
+	report.Add([]byte("a\nb\nb\n"), []byte("b\nc"))
+	report.Add([]byte("same\n"), []byte("same\n"))
+
+	require.Equal(t, Report{Files: 3, LinesAdded: 2, LinesRemoved: 3, BytesAdded: 27, BytesRemoved: 20}, *report)
+	var out bytes.Buffer
+	report.Fprint(&out)
+	require.Equal(t, "size report: 3 files changed, +2/-3 lines, +27/-20 bytes (net +7 bytes)\n", out.String())
+}
+
+func TestNilReport(t *testing.T) {
+	var report *Report
+	report.Add([]byte("a\n"), []byte("b\n"))
+	var out bytes.Buffer
+	report.Fprint(&out)
+	require.Empty(t, out.String())
+}
@@ -0,0 +1,157 @@
+package sqlcconfig
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	readFile = os.ReadFile
+	stat     = os.Stat
+)
+
+// FileNames are the names sqlc looks for its configuration under, in order.
+var FileNames = []string{"sqlc.yaml", "sqlc.yml", "sqlc.json"}
+
+// Target is a Go code generation target of a sqlc configuration.
+type Target struct {
+	// Package is the Go package name of the generated code (gen.go.package,
+	// or name for version 1).
+	Package string
+	// Out is the directory the code is generated into (gen.go.out, or path
+	// for version 1), resolved against the directory of the config file.
+	Out string
+	// ModelsImport is the import path of the models package
+	// (gen.go.models_package_import_path), if set.
+	ModelsImport string
+}
+
+// Config is the part of a sqlc configuration the tool reads.
+type Config struct {
+	// Path is the config file read.
+	Path string
+	// Targets lists the Go code generation targets, in file order.
+	Targets []Target
+}
+
+// file mirrors the fields of both sqlc configuration versions. JSON being a
+// subset of YAML, it decodes sqlc.json too.
+type file struct {
+	Version string `yaml:"version"`
+	SQL     []struct {
+		Gen struct {
+			Go *struct {
+				Package      string `yaml:"package"`
+				Out          string `yaml:"out"`
+				ModelsImport string `yaml:"models_package_import_path"`
+			} `yaml:"go"`
+		} `yaml:"gen"`
+	} `yaml:"sql"`
+	Packages []struct {
+		Name string `yaml:"name"`
+		Path string `yaml:"path"`
+	} `yaml:"packages"`
+}
+
+// Load reads the sqlc configuration at path. When path is a directory, the
+// first of FileNames found in it is read, as sqlc itself does.
+//
+// Returns an error if no config file is found, it can't be read or parsed,
+// its version is neither 1 nor 2, or it declares no Go target.
+func Load(path string) (Config, error) {
+	info, err := stat(path)
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to locate sqlc config: %w", err)
+	}
+	if info.IsDir() {
+		if path, err = find(path); err != nil {
+			return Config{}, err
+		}
+	}
+
+	data, err := readFile(path) // #nosec G304 -- config file is chosen by the user running the tool
+	if err != nil {
+		return Config{}, fmt.Errorf("failed to read sqlc config: %w", err)
+	}
+	var f file
+	if err := yaml.Unmarshal(data, &f); err != nil {
+		return Config{}, fmt.Errorf("failed to parse sqlc config %s: %w", path, err)
+	}
+
+	conf := Config{Path: path}
+	base := filepath.Dir(path)
+	switch f.Version {
+	case "2":
+		for _, sql := range f.SQL {
+			if gen := sql.Gen.Go; gen != nil && gen.Out != "" {
+				conf.Targets = append(conf.Targets, Target{Package: gen.Package, Out: filepath.Join(base, gen.Out), ModelsImport: gen.ModelsImport})
+			}
+		}
+	case "1":
+		for _, pkg := range f.Packages {
+			if pkg.Path != "" {
+				conf.Targets = append(conf.Targets, Target{Package: pkg.Name, Out: filepath.Join(base, pkg.Path)})
+			}
+		}
+	default:
+		return Config{}, fmt.Errorf("sqlc config %s: unsupported version %q (expected 1 or 2)", path, f.Version)
+	}
+	if len(conf.Targets) == 0 {
+		return Config{}, fmt.Errorf("sqlc config %s declares no Go code generation target (gen.go.out)", path)
+	}
+	return conf, nil
+}
+
+// OutDirs returns the output directories of the targets.
+func (c Config) OutDirs() []string {
+	dirs := make([]string, len(c.Targets))
+	for i, target := range c.Targets {
+		dirs[i] = target.Out
+	}
+	return dirs
+}
+
+// ModelsImport returns the models import path shared by the targets setting
+// one, or "" if none does.
+//
+// Returns an error if targets set different ones.
+func (c Config) ModelsImport() (string, error) {
+	modelsImport := ""
+	conflict := false
+	var packages []string
+	for _, target := range c.Targets {
+		if target.ModelsImport == "" {
+			continue
+		}
+		if modelsImport == "" {
+			modelsImport = target.ModelsImport
+		}
+		conflict = conflict || target.ModelsImport != modelsImport
+		packages = append(packages, fmt.Sprintf("%s (%s)", target.Package, target.ModelsImport))
+	}
+	if !conflict {
+		return modelsImport, nil
+	}
+	return "", fmt.Errorf("sqlc config %s imports models from different packages: %s; pass --import", c.Path, strings.Join(packages, ", "))
+}
+
+// find returns the first of FileNames in dir.
+func find(dir string) (string, error) {
+	for _, name := range FileNames {
+		path := filepath.Join(dir, name)
+		_, err := stat(path)
+		if err == nil {
+			return path, nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to locate sqlc config: %w", err)
+		}
+	}
+	return "", fmt.Errorf("no sqlc config found in %s (looked for %s)", dir, strings.Join(FileNames, ", "))
+}
@@ -0,0 +1,131 @@
+package sqlcconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	tests := []struct {
+		Name              string
+		FileName          string
+		Content           string
+		ExpectedTargets   []Target
+		ExpectedImport    string
+		ExpectedErrSubStr string
+	}{
+		{
+			Name:     "version 2 yaml",
+			FileName: "sqlc.yaml",
+			Content: `version: "2"
+sql:
+  - engine: postgresql
+    queries: queries
+    schema: schema
+    gen:
+      go:
+        package: database
+        out: internal/database
+        models_package_import_path: example.com/app/internal/models
+  - engine: postgresql
+    queries: reports
+    schema: schema
+    gen:
+      go:
+        package: reports
+        out: internal/reports
+`,
+			ExpectedTargets: []Target{
+				{Package: "database", Out: "internal/database", ModelsImport: "example.com/app/internal/models"},
+				{Package: "reports", Out: "internal/reports"},
+			},
+			ExpectedImport: "example.com/app/internal/models",
+		},
+		{
+			Name:     "version 2 json",
+			FileName: "sqlc.json",
+			Content: `{"version": "2", "sql": [{"gen": {"go": {"package": "database", "out": "db"}}}]}
+`,
+			ExpectedTargets: []Target{{Package: "database", Out: "db"}},
+		},
+		{
+			Name:     "version 1",
+			FileName: "sqlc.yml",
+			Content: `version: "1"
+packages:
+  - name: database
+    path: internal/database
+`,
+			ExpectedTargets: []Target{{Package: "database", Out: "internal/database"}},
+		},
+		{
+			Name:     "different models imports",
+			FileName: "sqlc.yaml",
+			Content: `version: "2"
+sql:
+  - gen: {go: {package: database, out: db, models_package_import_path: example.com/app/models}}
+  - gen: {go: {package: reports, out: reports, models_package_import_path: example.com/app/reports/models}}
+`,
+			ExpectedErrSubStr: "imports models from different packages: database (example.com/app/models), reports (example.com/app/reports/models)",
+		},
+		{
+			Name:              "no Go target",
+			FileName:          "sqlc.yaml",
+			Content:           "version: \"2\"\nsql:\n  - gen: {kotlin: {out: kt}}\n",
+			ExpectedErrSubStr: "declares no Go code generation target",
+		},
+		{
+			Name:              "unsupported version",
+			FileName:          "sqlc.yaml",
+			Content:           "version: \"3\"\n",
+			ExpectedErrSubStr: `unsupported version "3"`,
+		},
+		{
+			Name:              "malformed",
+			FileName:          "sqlc.yaml",
+			Content:           "version: [\n",
+			ExpectedErrSubStr: "failed to parse sqlc config",
+		},
+		{
+			Name:              "no config",
+			ExpectedErrSubStr: "no sqlc config found",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			if tc.FileName != "" {
+				if err := os.WriteFile(filepath.Join(dir, tc.FileName), []byte(tc.Content), 0644); err != nil {
+					t.Fatalf("failed to write config: %v", err)
+				}
+			}
+
+			conf, err := Load(dir)
+			if err == nil {
+				var modelsImport string
+				modelsImport, err = conf.ModelsImport()
+				if err == nil {
+					require.Equal(t, tc.ExpectedImport, modelsImport)
+				}
+			}
+			if tc.ExpectedErrSubStr != "" {
+				require.ErrorContains(t, err, tc.ExpectedErrSubStr)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, filepath.Join(dir, tc.FileName), conf.Path)
+			for i := range tc.ExpectedTargets {
+				tc.ExpectedTargets[i].Out = filepath.Join(dir, tc.ExpectedTargets[i].Out)
+			}
+			require.Equal(t, tc.ExpectedTargets, conf.Targets)
+
+			// the file itself can be given too
+			byFile, err := Load(conf.Path)
+			require.NoError(t, err)
+			require.Equal(t, conf, byFile)
+		})
+	}
+}
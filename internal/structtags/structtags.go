@@ -0,0 +1,52 @@
+package structtags
+
+import (
+	"go/ast"
+	"go/token"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// Value returns the raw (unquoted) struct tag of field, or "" if it has none.
+func Value(field *ast.Field) string {
+	if field.Tag == nil {
+		return ""
+	}
+	tag, err := strconv.Unquote(field.Tag.Value)
+	if err != nil {
+		return ""
+	}
+	return tag
+}
+
+// Has reports whether field already carries a tag for key.
+func Has(field *ast.Field, key string) bool {
+	_, ok := reflect.StructTag(Value(field)).Lookup(key)
+	return ok
+}
+
+// Add appends key:"value" to field's struct tag, keeping any existing
+// entries. It does nothing if the tag already has an entry for key, so
+// repeated runs are idempotent. It reports whether the tag changed.
+func Add(field *ast.Field, key, value string) bool {
+	if Has(field, key) {
+		return false
+	}
+	entry := key + ":" + strconv.Quote(value)
+	tag := strings.TrimSpace(Value(field))
+	if tag != "" {
+		tag += " "
+	}
+	tag += entry
+
+	lit := "`" + tag + "`"
+	if strings.Contains(tag, "`") {
+		lit = strconv.Quote(tag)
+	}
+	if field.Tag == nil {
+		field.Tag = &ast.BasicLit{ValuePos: field.Type.End(), Kind: token.STRING}
+	}
+	field.Tag.Value = lit
+	return true
+}
@@ -0,0 +1,96 @@
+package unifieddiff
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+func TestDiff(t *testing.T) {
+	lines := func(n int) string {
+		var b strings.Builder
+		for i := 1; i <= n; i++ {
+			b.WriteString(strings.Repeat("x", i) + "\n")
+		}
+		return b.String()
+	}
+	tests := []struct {
+		Name     string
+		Old      string
+		New      string
+		Expected string
+	}{
+		{
+			Name: "equal",
+			Old:  "a\nb\n",
+			New:  "a\nb\n",
+		},
+		{
+			Name: "changed line",
+			Old:  "package foo\n\nconst bar = \"x\"\n",
+			New:  "package foo\n\nconst bar = \"x\" // #nosec\n",
+			Expected: `--- old.go
++++ new.go
+@@ -1,3 +1,3 @@
+ package foo
+ 
+-const bar = "x"
++const bar = "x" // #nosec
+`,
+		},
+		{
+			Name: "distant changes in separate hunks",
+			Old:  lines(20),
+			New:  strings.Replace(strings.Replace(lines(20), "xx\n", "two\n", 1), strings.Repeat("x", 18)+"\n", "", 1),
+			Expected: `--- old.go
++++ new.go
+@@ -1,5 +1,5 @@
+ x
+-xx
++two
+ xxx
+ xxxx
+ xxxxx
+@@ -15,6 +15,5 @@
+ xxxxxxxxxxxxxxx
+ xxxxxxxxxxxxxxxx
+ xxxxxxxxxxxxxxxxx
+-xxxxxxxxxxxxxxxxxx
+ xxxxxxxxxxxxxxxxxxx
+ xxxxxxxxxxxxxxxxxxxx
+`,
+		},
+		{
+			Name: "insertion into empty",
+			Old:  "",
+			New:  "a\n",
+			Expected: `--- old.go
++++ new.go
+@@ -0,0 +1 @@
++a
+`,
+		},
+		{
+			Name: "missing final newline",
+			Old:  "a\nb",
+			New:  "a\nb\n",
+			Expected: `--- old.go
++++ new.go
+@@ -1,2 +1,2 @@
+ a
+-b
+\ No newline at end of file
++b
+`,
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			got := Diff("old.go", "new.go", []byte(tc.Old), []byte(tc.New))
+			if diff := cmp.Diff(tc.Expected, got); diff != "" {
+				t.Errorf("diff mismatch (-want +got)\n%s", diff)
+			}
+		})
+	}
+}
@@ -0,0 +1,77 @@
+package writable
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+var (
+	stat     = os.Stat
+	openFile = os.OpenFile
+	chmod    = os.Chmod
+)
+
+// Ensure checks, before anything is written, that every one of files can be
+// rewritten in place. A file is not writable when its owner write bit is
+// clear (as in CI checkouts marking generated files read-only) or opening it
+// for writing fails. Opening doesn't truncate, so the check leaves files
+// untouched.
+//
+// With fix set, read-only files are made writable for their owner instead,
+// and the files fixed this way are returned.
+//
+// Returns an error listing every file that isn't writable, so a run doesn't
+// fail halfway through on the first one.
+func Ensure(files []string, fix bool) ([]string, error) {
+	var fixed, problems []string
+	for _, file := range files {
+		err := check(file)
+		if err != nil && fix {
+			if err = makeWritable(file); err == nil {
+				fixed = append(fixed, file)
+			}
+		}
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", file, err))
+		}
+	}
+	if len(problems) > 0 {
+		hint := "make them writable or pass --chmod"
+		if fix {
+			hint = "check their ownership and directory permissions"
+		}
+		count := fmt.Sprintf("%d files are", len(problems))
+		if len(problems) == 1 {
+			count = "1 file is"
+		}
+		return fixed, fmt.Errorf("%s not writable (%s):\n  %s", count, hint, strings.Join(problems, "\n  "))
+	}
+	return fixed, nil
+}
+
+func check(file string) error {
+	info, err := stat(file)
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0200 == 0 {
+		return fmt.Errorf("read-only (mode %s)", info.Mode().Perm())
+	}
+	f, err := openFile(file, os.O_WRONLY, 0) // #nosec G304 -- the file was just found by the discovery phase
+	if err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func makeWritable(file string) error {
+	info, err := stat(file)
+	if err != nil {
+		return err
+	}
+	if err := chmod(file, info.Mode().Perm()|0200); err != nil {
+		return fmt.Errorf("failed to make writable: %w", err)
+	}
+	return check(file)
+}
@@ -0,0 +1,95 @@
+package writable
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnsure(t *testing.T) {
+	tests := []struct {
+		Name              string
+		Modes             map[string]os.FileMode
+		Fix               bool
+		Denied            string
+		ExpectedFixed     []string
+		ExpectedErrSubStr []string
+	}{
+		{
+			Name:  "all writable",
+			Modes: map[string]os.FileMode{"a.sql.go": 0644, "b.sql.go": 0600},
+		},
+		{
+			Name:  "read-only files reported together",
+			Modes: map[string]os.FileMode{"a.sql.go": 0444, "b.sql.go": 0644, "c.sql.go": 0400},
+			ExpectedErrSubStr: []string{
+				"2 files are not writable (make them writable or pass --chmod):\n",
+				"a.sql.go: read-only (mode -r--r--r--)\n",
+				"c.sql.go: read-only (mode -r--------)",
+			},
+		},
+		{
+			Name:  "open denied",
+			Modes: map[string]os.FileMode{"a.sql.go": 0644},
+			// simulates a file owned by another user, which chmod can't fix
+			Denied:            "a.sql.go",
+			Fix:               true,
+			ExpectedErrSubStr: []string{"1 file is not writable (check their ownership and directory permissions):\n", "a.sql.go: permission denied"},
+		},
+		{
+			Name:          "fix read-only files",
+			Modes:         map[string]os.FileMode{"a.sql.go": 0444, "b.sql.go": 0644},
+			Fix:           true,
+			ExpectedFixed: []string{"a.sql.go"},
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.Name, func(t *testing.T) {
+			dir := t.TempDir()
+			var files []string
+			for _, name := range []string{"a.sql.go", "b.sql.go", "c.sql.go"} {
+				mode, ok := tc.Modes[name]
+				if !ok {
+					continue
+				}
+				file := filepath.Join(dir, name)
+				if err := os.WriteFile(file, []byte("package foo\n"), 0644); err != nil {
+					t.Fatalf("failed to write %s: %v", name, err)
+				}
+				if err := os.Chmod(file, mode); err != nil {
+					t.Fatalf("failed to chmod %s: %v", name, err)
+				}
+				files = append(files, file)
+			}
+			openFile = func(name string, flag int, perm os.FileMode) (*os.File, error) {
+				if tc.Denied != "" && filepath.Base(name) == tc.Denied {
+					return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrPermission}
+				}
+				return os.OpenFile(name, flag, perm)
+			}
+			defer func() { openFile = os.OpenFile }()
+
+			fixed, err := Ensure(files, tc.Fix)
+			if len(tc.ExpectedErrSubStr) > 0 {
+				for _, sub := range tc.ExpectedErrSubStr {
+					require.ErrorContains(t, err, sub)
+				}
+			} else {
+				require.NoError(t, err)
+			}
+			var fixedNames []string
+			for _, file := range fixed {
+				fixedNames = append(fixedNames, filepath.Base(file))
+			}
+			require.Equal(t, tc.ExpectedFixed, fixedNames)
+			for _, file := range fixed {
+				info, err := os.Stat(file)
+				require.NoError(t, err)
+				require.Equal(t, os.FileMode(0644), info.Mode().Perm())
+			}
+		})
+	}
+}